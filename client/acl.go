@@ -0,0 +1,464 @@
+package client
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tidwall/match"
+)
+
+// maxACLLogEntries is the maximum number of entries kept by ACL LOG
+const maxACLLogEntries = 128
+
+// aclUser is a user of the access control list
+type aclUser struct {
+	name      string
+	enabled   bool
+	noPass    bool
+	passwords []string // hex-encoded SHA-256 of passwords
+	commands  []string // command rules in order, such as +@all, -@dangerous and +get
+	keys      []string // key patterns
+	channels  []string // channel patterns
+}
+
+// newACLUser initializes a user without any permission
+func newACLUser(name string) *aclUser {
+	return &aclUser{
+		name:     name,
+		commands: []string{"-@all"},
+	}
+}
+
+// clone copies a user, so rules could be applied without affecting connected clients
+func (user *aclUser) clone() *aclUser {
+	u := *user
+	u.passwords = append([]string(nil), user.passwords...)
+	u.commands = append([]string(nil), user.commands...)
+	u.keys = append([]string(nil), user.keys...)
+	u.channels = append([]string(nil), user.channels...)
+	return &u
+}
+
+// applyRule applies an ACL rule to the user
+func (user *aclUser) applyRule(rule string, commands *CommandTable) error {
+	lowerRule := strings.ToLower(rule)
+	switch {
+	case lowerRule == "on":
+		user.enabled = true
+	case lowerRule == "off":
+		user.enabled = false
+	case lowerRule == "nopass":
+		user.noPass = true
+		user.passwords = nil
+	case lowerRule == "resetpass":
+		user.noPass = false
+		user.passwords = nil
+	case lowerRule == "allkeys":
+		user.keys = []string{"*"}
+	case lowerRule == "resetkeys":
+		user.keys = nil
+	case lowerRule == "allchannels":
+		user.channels = []string{"*"}
+	case lowerRule == "resetchannels":
+		user.channels = nil
+	case lowerRule == "allcommands":
+		user.commands = []string{"+@all"}
+	case lowerRule == "nocommands":
+		user.commands = []string{"-@all"}
+	case lowerRule == "reset":
+		*user = *newACLUser(user.name)
+	case strings.HasPrefix(rule, ">"):
+		user.addPassword(hashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "<"):
+		user.removePassword(hashPassword(rule[1:]))
+	case strings.HasPrefix(rule, "#"):
+		hash := strings.ToLower(rule[1:])
+		if _, err := hex.DecodeString(hash); err != nil || len(hash) != sha256.Size*2 {
+			return errInvalidPasswordHash
+		}
+		user.addPassword(hash)
+	case strings.HasPrefix(rule, "!"):
+		user.removePassword(strings.ToLower(rule[1:]))
+	case strings.HasPrefix(rule, "~"):
+		user.keys = appendPattern(user.keys, rule[1:])
+	case strings.HasPrefix(rule, "&"):
+		user.channels = appendPattern(user.channels, rule[1:])
+	case strings.HasPrefix(rule, "+") || strings.HasPrefix(rule, "-"):
+		return user.addCommandRule(lowerRule, commands)
+	default:
+		return newError("ERR Error in ACL SETUSER modifier '%s': Syntax error", rule)
+	}
+	return nil
+}
+
+func (user *aclUser) addPassword(hash string) {
+	user.noPass = false
+	for _, p := range user.passwords {
+		if p == hash {
+			return
+		}
+	}
+	user.passwords = append(user.passwords, hash)
+}
+
+func (user *aclUser) removePassword(hash string) {
+	for i, p := range user.passwords {
+		if p == hash {
+			user.passwords = append(user.passwords[:i], user.passwords[i+1:]...)
+			return
+		}
+	}
+}
+
+// addCommandRule adds a rule such as +get, -set, +@read or -@all, whose command must be in the table
+func (user *aclUser) addCommandRule(rule string, commands *CommandTable) error {
+	name := rule[1:]
+	if category, ok := strings.CutPrefix(name, "@"); ok {
+		if category != "all" && !isACLCategory(category) {
+			return newError("ERR Error in ACL SETUSER modifier '%s': Unknown command or category name in ACL", rule)
+		}
+		if category == "all" {
+			// +@all and -@all override all previous command rules
+			user.commands = nil
+		}
+	} else if commands.lookupName(name) == nil {
+		return newError("ERR Error in ACL SETUSER modifier '%s': Unknown command or category name in ACL", rule)
+	}
+	user.commands = append(user.commands, rule)
+	return nil
+}
+
+// canExecute tells whether the user is allowed to execute a command or subcommand
+func (user *aclUser) canExecute(c *command) bool {
+	commandName, _, _ := strings.Cut(c.name, "|")
+
+	allowed := false
+	for _, rule := range user.commands {
+		name := rule[1:]
+		var matched bool
+		if category, ok := strings.CutPrefix(name, "@"); ok {
+			matched = category == "all" || c.hasCategory(category)
+		} else {
+			matched = name == commandName || name == c.name
+		}
+		if matched {
+			allowed = rule[0] == '+'
+		}
+	}
+	return allowed
+}
+
+// canAccessKey tells whether the user is allowed to access a key
+func (user *aclUser) canAccessKey(key []byte) bool {
+	return matchAny(user.keys, string(key))
+}
+
+// canAccessKeyPrefix tells whether the user is allowed to access all keys starting with a prefix,
+// which is true only if a key pattern is a literal prefix of it followed by *
+func (user *aclUser) canAccessKeyPrefix(prefix string) bool {
+	for _, pattern := range user.keys {
+		base, ok := strings.CutSuffix(pattern, "*")
+		if ok && !strings.ContainsAny(base, "*?[\\") && strings.HasPrefix(prefix, base) {
+			return true
+		}
+	}
+	return false
+}
+
+// canAccessChannel tells whether the user is allowed to access a channel
+func (user *aclUser) canAccessChannel(channel []byte) bool {
+	return matchAny(user.channels, string(channel))
+}
+
+// describe describes the user with rules, which could be loaded by ACL SETUSER or an ACL file
+func (user *aclUser) describe() string {
+	rules := []string{"user", user.name}
+	if user.enabled {
+		rules = append(rules, "on")
+	} else {
+		rules = append(rules, "off")
+	}
+	// permissions are reset before being described, so the rules restore the user even if it exists
+	if user.noPass {
+		rules = append(rules, "nopass")
+	} else {
+		rules = append(rules, "resetpass")
+	}
+	for _, p := range user.passwords {
+		rules = append(rules, "#"+p)
+	}
+	rules = append(rules, "resetkeys")
+	for _, k := range user.keys {
+		rules = append(rules, "~"+k)
+	}
+	rules = append(rules, "resetchannels")
+	for _, c := range user.channels {
+		rules = append(rules, "&"+c)
+	}
+	rules = append(rules, user.commands...)
+	return strings.Join(rules, " ")
+}
+
+// aclLogEntry is an entry of ACL LOG
+type aclLogEntry struct {
+	id         int64
+	count      int
+	reason     string // command, key, channel or auth
+	context    string
+	object     string
+	username   string
+	clientInfo string
+	created    time.Time
+	updated    time.Time
+}
+
+// ACL is the access control list of a server.
+//
+// It is shared by all clients of a server,
+// so failed authentication attempts are rate limited per host rather than per connection,
+// except for clients of a Unix socket.
+type ACL struct {
+	mu       sync.RWMutex
+	users    map[string]*aclUser
+	log      []*aclLogEntry // the latest entry is the first one
+	logID    int64
+	file     string                  // path of the ACL file
+	commands *CommandTable           // commands checked by command rules
+	failures map[string]*authFailure // failed authentication attempts of sources
+
+	failuresEvicted time.Time // when forgotten failed attempts were deleted last time
+}
+
+// NewACL initializes an access control list with the default user.
+//
+// If requirePass is empty, then the default user does not need a password.
+// Command rules are checked against commands in the table.
+func NewACL(requirePass string, commands *CommandTable) *ACL {
+	defaultUser := newACLUser(defaultUsername)
+	for _, rule := range []string{"on", "~*", "&*", "+@all"} {
+		_ = defaultUser.applyRule(rule, commands)
+	}
+	if requirePass == "" {
+		_ = defaultUser.applyRule("nopass", commands)
+	} else {
+		_ = defaultUser.applyRule(">"+requirePass, commands)
+	}
+
+	return &ACL{
+		users:    map[string]*aclUser{defaultUsername: defaultUser},
+		commands: commands,
+		failures: make(map[string]*authFailure),
+	}
+}
+
+// LoadFile loads users from an ACL file, every line of which is like "user <username> ... acl rules ...".
+//
+// The file is remembered by ACL LOAD and ACL SAVE.
+func (acl *ACL) LoadFile(path string) error {
+	acl.mu.Lock()
+	acl.file = path
+	acl.mu.Unlock()
+	return acl.load()
+}
+
+// load reloads users from the ACL file
+func (acl *ACL) load() error {
+	acl.mu.RLock()
+	path := acl.file
+	acl.mu.RUnlock()
+	if path == "" {
+		return errNoACLFile
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return newError("ERR Error loading ACLs, opening file '%s': %v", path, err)
+	}
+	defer file.Close()
+
+	users := make(map[string]*aclUser)
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		if fields[0] != "user" || len(fields) < 2 {
+			return newError("ERR %s:%d: should start with user keyword", path, lineNumber)
+		}
+		if _, ok := users[fields[1]]; ok {
+			return newError("ERR %s:%d: duplicate user '%s' found", path, lineNumber, fields[1])
+		}
+		user := newACLUser(fields[1])
+		for _, rule := range fields[2:] {
+			if err := user.applyRule(rule, acl.commands); err != nil {
+				return newError("ERR %s:%d: %v", path, lineNumber, err)
+			}
+		}
+		users[user.name] = user
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+	if _, ok := users[defaultUsername]; !ok {
+		// the default user is kept if the file does not define it
+		users[defaultUsername] = acl.users[defaultUsername]
+	}
+	acl.users = users
+	return nil
+}
+
+// save saves all users to the ACL file
+func (acl *ACL) save() error {
+	acl.mu.RLock()
+	defer acl.mu.RUnlock()
+	if acl.file == "" {
+		return errNoACLFile
+	}
+
+	var builder strings.Builder
+	for _, name := range acl.usernames() {
+		builder.WriteString(acl.users[name].describe())
+		builder.WriteByte('\n')
+	}
+	tmp := acl.file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(builder.String()), 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, acl.file)
+}
+
+// user gets a user by name
+func (acl *ACL) user(name string) *aclUser {
+	acl.mu.RLock()
+	defer acl.mu.RUnlock()
+	return acl.users[name]
+}
+
+// setUser creates or modifies a user with rules
+func (acl *ACL) setUser(name string, rules []string) error {
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	var user *aclUser
+	if u, ok := acl.users[name]; ok {
+		user = u.clone()
+	} else {
+		user = newACLUser(name)
+	}
+	for _, rule := range rules {
+		if err := user.applyRule(rule, acl.commands); err != nil {
+			return err
+		}
+	}
+	acl.users[name] = user
+	return nil
+}
+
+// delUser deletes users and returns the number of deleted ones
+func (acl *ACL) delUser(names []string) (int, error) {
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	var count int
+	for _, name := range names {
+		if name == defaultUsername {
+			return 0, errDeleteDefaultUser
+		}
+	}
+	for _, name := range names {
+		if _, ok := acl.users[name]; ok {
+			delete(acl.users, name)
+			count++
+		}
+	}
+	return count, nil
+}
+
+// usernames lists names of all users in order, the caller should hold the lock
+func (acl *ACL) usernames() []string {
+	names := make([]string, 0, len(acl.users))
+	for name := range acl.users {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addLog records a denied command or a failed authentication.
+//
+// Entries with the same reason, object, user and context are merged in a minute.
+func (acl *ACL) addLog(reason, object, username string, client *RedisClient, remoteAddr string) {
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	now := time.Now()
+	for _, entry := range acl.log {
+		if entry.reason == reason && entry.object == object && entry.username == username &&
+			now.Sub(entry.updated) < time.Minute {
+			entry.count++
+			entry.updated = now
+			return
+		}
+	}
+
+	entry := &aclLogEntry{
+		id:         acl.logID,
+		count:      1,
+		reason:     reason,
+		context:    "toplevel",
+		object:     object,
+		username:   username,
+		clientInfo: fmt.Sprintf("id=%d addr=%s name=%s user=%s", client.ID, remoteAddr, client.Name, client.username),
+		created:    now,
+		updated:    now,
+	}
+	acl.logID++
+	acl.log = append([]*aclLogEntry{entry}, acl.log...)
+	if len(acl.log) > maxACLLogEntries {
+		acl.log = acl.log[:maxACLLogEntries]
+	}
+}
+
+// hashPassword hashes a password like Redis does
+func hashPassword(password string) string {
+	sum := sha256.Sum256([]byte(password))
+	return hex.EncodeToString(sum[:])
+}
+
+func isACLCategory(category string) bool {
+	for _, c := range aclCategories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+func appendPattern(patterns []string, pattern string) []string {
+	for _, p := range patterns {
+		if p == pattern {
+			return patterns
+		}
+	}
+	return append(patterns, pattern)
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, pattern := range patterns {
+		if match.Match(s, pattern) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package client
+
+// ACL categories of commands
+const (
+	categoryKeyspace    = "keyspace"
+	categoryRead        = "read"
+	categoryWrite       = "write"
+	categorySet         = "set"
+	categorySortedSet   = "sortedset"
+	categoryList        = "list"
+	categoryHash        = "hash"
+	categoryString      = "string"
+	categoryBitmap      = "bitmap"
+	categoryHyperLogLog = "hyperloglog"
+	categoryGeo         = "geo"
+	categoryStream      = "stream"
+	categoryPubSub      = "pubsub"
+	categoryAdmin       = "admin"
+	categoryFast        = "fast"
+	categorySlow        = "slow"
+	categoryBlocking    = "blocking"
+	categoryDangerous   = "dangerous"
+	categoryConnection  = "connection"
+	categoryJSON        = "json"
+	categoryBloom       = "bloom"
+	categoryCuckoo      = "cuckoo"
+	categoryCMS         = "cms"
+	categoryTopK        = "topk"
+	categoryTimeSeries  = "timeseries"
+	categorySearch      = "search"
+)
+
+// aclCategories lists all ACL categories in the order of ACL CAT
+var aclCategories = []string{
+	categoryKeyspace,
+	categoryRead,
+	categoryWrite,
+	categorySet,
+	categorySortedSet,
+	categoryList,
+	categoryHash,
+	categoryString,
+	categoryBitmap,
+	categoryHyperLogLog,
+	categoryGeo,
+	categoryStream,
+	categoryPubSub,
+	categoryAdmin,
+	categoryFast,
+	categorySlow,
+	categoryBlocking,
+	categoryDangerous,
+	categoryConnection,
+	categoryJSON,
+	categoryBloom,
+	categoryCuckoo,
+	categoryCMS,
+	categoryTopK,
+	categoryTimeSeries,
+	categorySearch,
+}
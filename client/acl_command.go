@@ -0,0 +1,215 @@
+package client
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tidwall/redcon"
+)
+
+// acl executes ACL subcommands
+func (client *RedisClient) acl(conn redcon.Conn, args [][]byte) {
+	subcommand := strings.ToLower(string(args[0]))
+	args = args[1:]
+	switch subcommand {
+	case "setuser":
+		rules := make([]string, 0, len(args)-1)
+		for _, rule := range args[1:] {
+			rules = append(rules, string(rule))
+		}
+		if err := client.ACL.setUser(string(args[0]), rules); err != nil {
+			conn.WriteError(errorMessage(err))
+			return
+		}
+		conn.WriteString("OK")
+	case "getuser":
+		user := client.ACL.user(string(args[0]))
+		if user == nil {
+			conn.WriteNull()
+			return
+		}
+		writeACLUser(conn, user)
+	case "deluser":
+		names := make([]string, 0, len(args))
+		for _, name := range args {
+			names = append(names, string(name))
+		}
+		count, err := client.ACL.delUser(names)
+		if err != nil {
+			conn.WriteError(errorMessage(err))
+			return
+		}
+		conn.WriteInt(count)
+	case "list", "users":
+		client.ACL.mu.RLock()
+		defer client.ACL.mu.RUnlock()
+		names := client.ACL.usernames()
+		conn.WriteArray(len(names))
+		for _, name := range names {
+			if subcommand == "list" {
+				conn.WriteBulkString(client.ACL.users[name].describe())
+			} else {
+				conn.WriteBulkString(name)
+			}
+		}
+	case "whoami":
+		conn.WriteBulkString(client.Username())
+	case "cat":
+		client.writeACLCategory(conn, args)
+	case "log":
+		client.aclLog(conn, args)
+	case "load":
+		if err := client.ACL.load(); err != nil {
+			conn.WriteError(errorMessage(err))
+			return
+		}
+		conn.WriteString("OK")
+	case "save":
+		if err := client.ACL.save(); err != nil {
+			conn.WriteError(errorMessage(err))
+			return
+		}
+		conn.WriteString("OK")
+	}
+}
+
+// writeACLUser writes the reply of ACL GETUSER
+func writeACLUser(conn redcon.Conn, user *aclUser) {
+	conn.WriteArray(12)
+
+	conn.WriteBulkString("flags")
+	flags := make([]string, 0, 2)
+	if user.enabled {
+		flags = append(flags, "on")
+	} else {
+		flags = append(flags, "off")
+	}
+	if user.noPass {
+		flags = append(flags, "nopass")
+	}
+	conn.WriteArray(len(flags))
+	for _, flag := range flags {
+		conn.WriteBulkString(flag)
+	}
+
+	conn.WriteBulkString("passwords")
+	conn.WriteArray(len(user.passwords))
+	for _, p := range user.passwords {
+		conn.WriteBulkString(p)
+	}
+
+	conn.WriteBulkString("commands")
+	conn.WriteBulkString(strings.Join(user.commands, " "))
+
+	keys := make([]string, 0, len(user.keys))
+	for _, k := range user.keys {
+		keys = append(keys, "~"+k)
+	}
+	conn.WriteBulkString("keys")
+	conn.WriteBulkString(strings.Join(keys, " "))
+
+	channels := make([]string, 0, len(user.channels))
+	for _, c := range user.channels {
+		channels = append(channels, "&"+c)
+	}
+	conn.WriteBulkString("channels")
+	conn.WriteBulkString(strings.Join(channels, " "))
+
+	conn.WriteBulkString("selectors")
+	conn.WriteArray(0)
+}
+
+// writeACLCategory writes the reply of ACL CAT [category]
+func (client *RedisClient) writeACLCategory(conn redcon.Conn, args [][]byte) {
+	switch len(args) {
+	case 0:
+		conn.WriteArray(len(aclCategories))
+		for _, category := range aclCategories {
+			conn.WriteBulkString(category)
+		}
+	case 1:
+		category := strings.ToLower(string(args[0]))
+		if !isACLCategory(category) {
+			conn.WriteError("ERR Unknown category '" + string(args[0]) + "'")
+			return
+		}
+		names := make([]string, 0)
+		for _, c := range client.Commands.sorted() {
+			if c.hasCategory(category) {
+				names = append(names, c.name)
+			}
+			for _, subcommand := range c.subcommands {
+				if subcommand.hasCategory(category) {
+					names = append(names, subcommand.name)
+				}
+			}
+		}
+		sort.Strings(names)
+		conn.WriteArray(len(names))
+		for _, name := range names {
+			conn.WriteBulkString(name)
+		}
+	default:
+		conn.WriteError(newErrWrongNumberOfArguments("acl|cat").Error())
+	}
+}
+
+// aclLog executes ACL LOG [count | RESET]
+func (client *RedisClient) aclLog(conn redcon.Conn, args [][]byte) {
+	count := 10
+	switch len(args) {
+	case 0:
+	case 1:
+		if strings.ToLower(string(args[0])) == "reset" {
+			client.ACL.mu.Lock()
+			client.ACL.log = nil
+			client.ACL.mu.Unlock()
+			conn.WriteString("OK")
+			return
+		}
+		n, err := strconv.Atoi(string(args[0]))
+		if err != nil || n < 0 {
+			conn.WriteError("ERR value is out of range, must be positive")
+			return
+		}
+		count = n
+	default:
+		conn.WriteError(newErrWrongNumberOfArguments("acl|log").Error())
+		return
+	}
+
+	client.ACL.mu.RLock()
+	defer client.ACL.mu.RUnlock()
+	entries := client.ACL.log
+	if count < len(entries) {
+		entries = entries[:count]
+	}
+
+	now := time.Now()
+	conn.WriteArray(len(entries))
+	for _, entry := range entries {
+		conn.WriteArray(20)
+		conn.WriteBulkString("count")
+		conn.WriteInt(entry.count)
+		conn.WriteBulkString("reason")
+		conn.WriteBulkString(entry.reason)
+		conn.WriteBulkString("context")
+		conn.WriteBulkString(entry.context)
+		conn.WriteBulkString("object")
+		conn.WriteBulkString(entry.object)
+		conn.WriteBulkString("username")
+		conn.WriteBulkString(entry.username)
+		conn.WriteBulkString("age-seconds")
+		conn.WriteBulkString(strconv.FormatFloat(now.Sub(entry.created).Seconds(), 'f', 3, 64))
+		conn.WriteBulkString("client-info")
+		conn.WriteBulkString(entry.clientInfo)
+		conn.WriteBulkString("entry-id")
+		conn.WriteInt64(entry.id)
+		conn.WriteBulkString("timestamp-created")
+		conn.WriteInt64(entry.created.UnixMilli())
+		conn.WriteBulkString("timestamp-last-updated")
+		conn.WriteInt64(entry.updated.UnixMilli())
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACLUser_ApplyRule(t *testing.T) {
+	commands := NewCommandTable()
+	user := newACLUser("alice")
+	for _, rule := range []string{"on", ">secret", ">secret", "~app:*", "~*", "&news.*", "+@read", "-get", "+acl|whoami"} {
+		assert.Nil(t, user.applyRule(rule, commands), rule)
+	}
+	assert.True(t, user.enabled)
+	assert.Equal(t, []string{hashPassword("secret")}, user.passwords)
+	assert.Equal(t, []string{"app:*", "*"}, user.keys)
+	assert.Equal(t, []string{"news.*"}, user.channels)
+	assert.Equal(t, []string{"-@all", "+@read", "-get", "+acl|whoami"}, user.commands)
+
+	// rules are case insensitive, except passwords and patterns
+	assert.Nil(t, user.applyRule("<secret", commands))
+	assert.Nil(t, user.applyRule("#"+strings.ToUpper(hashPassword("other")), commands))
+	assert.Equal(t, []string{hashPassword("other")}, user.passwords)
+	assert.Nil(t, user.applyRule("RESETKEYS", commands))
+	assert.Nil(t, user.applyRule("ResetChannels", commands))
+	assert.Nil(t, user.applyRule("+@ALL", commands))
+	assert.Empty(t, user.keys)
+	assert.Empty(t, user.channels)
+	assert.Equal(t, []string{"+@all"}, user.commands)
+
+	for _, rule := range []string{"maybe", "#123", "+unknown", "-@unknown", "+acl|unknown"} {
+		assert.NotNil(t, user.applyRule(rule, commands), rule)
+	}
+
+	assert.Nil(t, user.applyRule("reset", commands))
+	assert.Equal(t, newACLUser("alice"), user)
+}
+
+func TestACLUser_CanExecute(t *testing.T) {
+	commands := NewCommandTable()
+	tests := []struct {
+		rules   []string
+		allowed []string
+		denied  []string
+	}{
+		{[]string{"+@all"}, []string{"get", "set", "acl|setuser"}, nil},
+		{[]string{"+@read"}, []string{"get", "hget", "xrange"}, []string{"set", "del", "acl|whoami"}},
+		{[]string{"+@all", "-@dangerous"}, []string{"get", "acl|whoami"}, []string{"acl|setuser", "acl|deluser"}},
+		{[]string{"+@string", "-@write", "+set"}, []string{"get", "set"}, []string{"append", "hget"}},
+		{[]string{"+acl", "-acl|setuser"}, []string{"acl|whoami", "acl|list"}, []string{"acl|setuser"}},
+		{[]string{"+acl|whoami"}, []string{"acl|whoami"}, []string{"acl|list"}},
+		{[]string{"allcommands", "nocommands"}, nil, []string{"get"}},
+	}
+	for _, tt := range tests {
+		user := newACLUser("alice")
+		for _, rule := range tt.rules {
+			assert.Nil(t, user.applyRule(rule, commands), rule)
+		}
+		for _, name := range tt.allowed {
+			assert.True(t, user.canExecute(commands.lookupName(name)), "%v %s", tt.rules, name)
+		}
+		for _, name := range tt.denied {
+			assert.False(t, user.canExecute(commands.lookupName(name)), "%v %s", tt.rules, name)
+		}
+	}
+}
+
+func TestACLUser_Patterns(t *testing.T) {
+	commands := NewCommandTable()
+	user := newACLUser("alice")
+	assert.False(t, user.canAccessKey([]byte("app:1")))
+	assert.False(t, user.canAccessChannel([]byte("news.sports")))
+
+	for _, rule := range []string{"~app:*", "~user:?", "&news.*"} {
+		assert.Nil(t, user.applyRule(rule, commands))
+	}
+	assert.True(t, user.canAccessKey([]byte("app:1")))
+	assert.True(t, user.canAccessKey([]byte("user:7")))
+	assert.False(t, user.canAccessKey([]byte("user:10")))
+	assert.False(t, user.canAccessKey([]byte("news.sports")))
+	assert.True(t, user.canAccessChannel([]byte("news.sports")))
+	assert.False(t, user.canAccessChannel([]byte("app:1")))
+
+	assert.True(t, user.canAccessKeyPrefix("app:"))
+	assert.True(t, user.canAccessKeyPrefix("app:users:"))
+	assert.False(t, user.canAccessKeyPrefix("app"))
+	assert.False(t, user.canAccessKeyPrefix("user:"))
+
+	assert.Nil(t, user.applyRule("allkeys", commands))
+	assert.True(t, user.canAccessKeyPrefix(""))
+	assert.Nil(t, user.applyRule("allchannels", commands))
+	assert.True(t, user.canAccessKey([]byte("anything")))
+	assert.True(t, user.canAccessChannel([]byte("anything")))
+}
+
+func TestACLUser_Describe(t *testing.T) {
+	commands := NewCommandTable()
+	users := []*aclUser{newACLUser("alice"), newACLUser("bob")}
+	for _, rule := range []string{"on", ">secret", "~app:*", "&news.*", "+@read", "-get"} {
+		assert.Nil(t, users[1].applyRule(rule, commands))
+	}
+	assert.Equal(t, "user alice off resetpass resetkeys resetchannels -@all", users[0].describe())
+
+	// described rules restore the user, even if applied to a user with other permissions
+	for _, user := range users {
+		restored := newACLUser(user.name)
+		for _, rule := range []string{"~other:*", "&other.*"} {
+			assert.Nil(t, restored.applyRule(rule, commands))
+		}
+		for _, rule := range strings.Fields(user.describe())[2:] {
+			assert.Nil(t, restored.applyRule(rule, commands), rule)
+		}
+		assert.Equal(t, user, restored)
+	}
+}
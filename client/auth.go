@@ -0,0 +1,102 @@
+package client
+
+import (
+	"crypto/subtle"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/tidwall/redcon"
+)
+
+const (
+	// maxFailedAuthAttempts is the number of failed attempts before a host is locked out
+	maxFailedAuthAttempts = 5
+
+	// authLockout is how long a host is locked out after too many failed attempts,
+	// and failed attempts of a host are forgotten after the period since the last one as well
+	authLockout = time.Minute
+)
+
+// authFailure records failed authentication attempts of a host
+type authFailure struct {
+	attempts int
+	last     time.Time
+}
+
+// expired tells whether the failed attempts are forgotten
+func (failure *authFailure) expired(now time.Time) bool {
+	return now.Sub(failure.last) >= authLockout
+}
+
+// verify checks the password of a user from the given source,
+// which is the remote host or the connection failed attempts are limited by
+func (acl *ACL) verify(source, username, password string) error {
+	acl.mu.Lock()
+	defer acl.mu.Unlock()
+
+	now := time.Now()
+	acl.evictAuthFailures(now)
+	failure, ok := acl.failures[source]
+	if ok && failure.expired(now) {
+		delete(acl.failures, source)
+		failure, ok = nil, false
+	}
+	if ok && failure.attempts >= maxFailedAuthAttempts {
+		return errTooManyAuthFailures
+	}
+
+	if user, exists := acl.users[username]; exists && user.enabled {
+		hash := hashPassword(password)
+		valid := user.noPass
+		for _, p := range user.passwords {
+			if subtle.ConstantTimeCompare([]byte(hash), []byte(p)) == 1 {
+				valid = true
+			}
+		}
+		if valid {
+			delete(acl.failures, source)
+			return nil
+		}
+	}
+
+	if !ok {
+		failure = &authFailure{}
+		acl.failures[source] = failure
+	}
+	failure.attempts++
+	failure.last = now
+	return errWrongPass
+}
+
+// evictAuthFailures deletes forgotten failed attempts at most once per lockout period,
+// so failed attempts of hosts which never come back don't pile up
+func (acl *ACL) evictAuthFailures(now time.Time) {
+	if now.Sub(acl.failuresEvicted) < authLockout {
+		return
+	}
+	for source, failure := range acl.failures {
+		if failure.expired(now) {
+			delete(acl.failures, source)
+		}
+	}
+	acl.failuresEvicted = now
+}
+
+// defaultNoPass tells whether connections are authenticated as the default user automatically
+func (acl *ACL) defaultNoPass() bool {
+	user := acl.user(defaultUsername)
+	return user != nil && user.enabled && user.noPass
+}
+
+// authSource gets the source failed authentication attempts of a client are limited by.
+//
+// It is the remote host, or the connection itself for a Unix socket, whose remote address is not a host and a port.
+// Otherwise all local clients would be locked out together by any of them,
+// while accesses to the socket are restricted by its permissions anyway.
+func (client *RedisClient) authSource(conn redcon.Conn) string {
+	if host, _, err := net.SplitHostPort(conn.RemoteAddr()); err == nil {
+		return host
+	}
+	return "conn:" + strconv.FormatInt(client.ID, 10)
+}
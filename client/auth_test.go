@@ -0,0 +1,71 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestACL_Verify(t *testing.T) {
+	acl := NewACL("secret", NewCommandTable())
+	for i := 0; i < maxFailedAuthAttempts; i++ {
+		assert.Equal(t, errWrongPass, acl.verify("10.0.0.1", defaultUsername, "guess"))
+	}
+	// the host is locked out even with the right password, while other hosts are not
+	assert.Equal(t, errTooManyAuthFailures, acl.verify("10.0.0.1", defaultUsername, "secret"))
+	assert.Nil(t, acl.verify("10.0.0.2", defaultUsername, "secret"))
+
+	// the lockout ends after the period
+	acl.failures["10.0.0.1"].last = time.Now().Add(-authLockout)
+	assert.Nil(t, acl.verify("10.0.0.1", defaultUsername, "secret"))
+	assert.NotContains(t, acl.failures, "10.0.0.1")
+
+	// a success resets failed attempts
+	for i := 0; i < maxFailedAuthAttempts-1; i++ {
+		assert.Equal(t, errWrongPass, acl.verify("10.0.0.1", defaultUsername, "guess"))
+	}
+	assert.Nil(t, acl.verify("10.0.0.1", defaultUsername, "secret"))
+	assert.Equal(t, errWrongPass, acl.verify("10.0.0.1", defaultUsername, "guess"))
+	assert.Equal(t, 1, acl.failures["10.0.0.1"].attempts)
+}
+
+func TestACL_EvictAuthFailures(t *testing.T) {
+	acl := NewACL("secret", NewCommandTable())
+	for i := 0; i < 100; i++ {
+		assert.Equal(t, errWrongPass, acl.verify(string(rune('a'+i)), defaultUsername, "guess"))
+	}
+	assert.Len(t, acl.failures, 100)
+
+	// forgotten failed attempts are deleted at most once per lockout period
+	for _, failure := range acl.failures {
+		failure.last = failure.last.Add(-authLockout)
+	}
+	assert.Equal(t, errWrongPass, acl.verify("10.0.0.1", defaultUsername, "guess"))
+	assert.Len(t, acl.failures, 101)
+	acl.failuresEvicted = acl.failuresEvicted.Add(-authLockout)
+	assert.Equal(t, errWrongPass, acl.verify("10.0.0.2", defaultUsername, "guess"))
+	assert.Len(t, acl.failures, 2)
+}
+
+func TestRedisClient_AuthSource(t *testing.T) {
+	acl := NewACL("secret", NewCommandTable())
+	tcp1, tcp2 := newTestingConn(acl, "10.0.0.1:50001"), newTestingConn(acl, "10.0.0.1:50002")
+	unix1, unix2 := newTestingConn(acl, "@"), newTestingConn(acl, "@")
+	for i, conn := range []*testingConn{tcp1, tcp2, unix1, unix2} {
+		conn.Context().(*RedisClient).ID = int64(i + 1)
+	}
+
+	// connections of a host share failed attempts
+	for i := 0; i < maxFailedAuthAttempts; i++ {
+		assert.Equal(t, "-WRONGPASS invalid username-password pair or user is disabled.", tcp1.execute("AUTH", "guess"))
+	}
+	assert.Equal(t, "-ERR too many failed authentication attempts, try again later", tcp2.execute("AUTH", "secret"))
+
+	// connections of a Unix socket have their own failed attempts
+	for i := 0; i < maxFailedAuthAttempts; i++ {
+		assert.Equal(t, "-WRONGPASS invalid username-password pair or user is disabled.", unix1.execute("AUTH", "guess"))
+	}
+	assert.Equal(t, "-ERR too many failed authentication attempts, try again later", unix1.execute("AUTH", "secret"))
+	assert.Equal(t, "+OK", unix2.execute("AUTH", "secret"))
+}
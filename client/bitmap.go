@@ -0,0 +1,280 @@
+package client
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// setbit executes SETBIT key offset value
+func setbit(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	offset, err := parseInteger(args[1])
+	if err != nil {
+		return nil, ds.ErrBitOffsetOutOfRange
+	}
+	var bit byte
+	switch string(args[2]) {
+	case "0":
+	case "1":
+		bit = 1
+	default:
+		return nil, ds.ErrBitOutOfRange
+	}
+
+	original, err := rds.SetBit(key, offset, bit)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(original), nil
+}
+
+// getbit executes GETBIT key offset
+func getbit(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	offset, err := parseInteger(args[1])
+	if err != nil {
+		return nil, ds.ErrBitOffsetOutOfRange
+	}
+
+	bit, err := rds.GetBit(key, offset)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(bit), nil
+}
+
+// bitcount executes BITCOUNT key [start end [BYTE | BIT]]
+func bitcount(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	var r *ds.BitRange
+	switch len(args) {
+	case 1:
+	case 3, 4:
+		var err error
+		r, err = parseBitRange(args[1:])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errSyntax
+	}
+
+	count, err := rds.BitCount(key, r)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(count), nil
+}
+
+// bitpos executes BITPOS key bit [start [end [BYTE | BIT]]]
+func bitpos(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	n, err := parseInteger(args[1])
+	if err != nil {
+		return nil, err
+	}
+	if n != 0 && n != 1 {
+		return nil, newError("ERR The bit argument must be 1 or 0.")
+	}
+
+	var r *ds.BitRange
+	if len(args) > 5 {
+		return nil, errSyntax
+	}
+	if len(args) > 2 {
+		r, err = parseBitRange(args[2:])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	pos, err := rds.BitPos(key, byte(n), r)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(pos), nil
+}
+
+// parseBitRange parses start [end [BYTE | BIT]]
+func parseBitRange(args [][]byte) (*ds.BitRange, error) {
+	var r ds.BitRange
+	var err error
+	r.Start, err = parseInteger(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if len(args) > 1 {
+		r.End, err = parseInteger(args[1])
+		if err != nil {
+			return nil, err
+		}
+		r.HasEnd = true
+	}
+	if len(args) > 2 {
+		switch strings.ToLower(string(args[2])) {
+		case "byte":
+		case "bit":
+			r.Bit = true
+		default:
+			return nil, errSyntax
+		}
+	}
+	return &r, nil
+}
+
+// bitop executes BITOP AND | OR | XOR | NOT destkey key [key ...]
+func bitop(rds *ds.DS, args ...[]byte) (Reply, error) {
+	var op ds.BitOperation
+	switch strings.ToLower(string(args[0])) {
+	case "and":
+		op = ds.BitAnd
+	case "or":
+		op = ds.BitOr
+	case "xor":
+		op = ds.BitXor
+	case "not":
+		op = ds.BitNot
+	default:
+		return nil, errSyntax
+	}
+
+	length, err := rds.BitOp(op, args[1], args[2:]...)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(length), nil
+}
+
+// bitfield executes BITFIELD key [GET encoding offset | [OVERFLOW WRAP | SAT | FAIL] SET encoding offset value | INCRBY encoding offset increment ...]
+func bitfield(rds *ds.DS, args ...[]byte) (Reply, error) {
+	ops, err := parseBitFieldOperations(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	return bitFieldReply(rds.BitField(args[0], ops))
+}
+
+// bitfieldRO executes BITFIELD_RO key [GET encoding offset ...]
+func bitfieldRO(rds *ds.DS, args ...[]byte) (Reply, error) {
+	ops, err := parseBitFieldOperations(args[1:])
+	if err != nil {
+		return nil, err
+	}
+	for _, op := range ops {
+		if op.Subcommand != ds.BitFieldGet {
+			return nil, newError("ERR BITFIELD_RO only supports the GET subcommand")
+		}
+	}
+	return bitFieldReply(rds.BitField(args[0], ops))
+}
+
+func bitFieldReply(results []*int64, err error) (Reply, error) {
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(results))
+	for i, result := range results {
+		if result == nil {
+			replies[i] = nullBulkReply
+		} else {
+			replies[i] = integerReply(*result)
+		}
+	}
+	return replies, nil
+}
+
+func parseBitFieldOperations(args [][]byte) ([]ds.BitFieldOperation, error) {
+	var ops []ds.BitFieldOperation
+	overflow := ds.OverflowWrap
+	for i := 0; i < len(args); {
+		var op ds.BitFieldOperation
+		subcommand := strings.ToLower(string(args[i]))
+		switch {
+		case subcommand == "overflow" && i+1 < len(args):
+			switch strings.ToLower(string(args[i+1])) {
+			case "wrap":
+				overflow = ds.OverflowWrap
+			case "sat":
+				overflow = ds.OverflowSat
+			case "fail":
+				overflow = ds.OverflowFail
+			default:
+				return nil, newError("ERR Invalid OVERFLOW type specified")
+			}
+			i += 2
+			continue
+		case subcommand == "get" && i+2 < len(args):
+			op.Subcommand = ds.BitFieldGet
+		case subcommand == "set" && i+3 < len(args):
+			op.Subcommand = ds.BitFieldSet
+		case subcommand == "incrby" && i+3 < len(args):
+			op.Subcommand = ds.BitFieldIncrBy
+		default:
+			return nil, errSyntax
+		}
+
+		var err error
+		op.Type, err = parseBitFieldType(args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		op.Offset, err = parseBitFieldOffset(args[i+2], op.Type)
+		if err != nil {
+			return nil, err
+		}
+		i += 3
+		if op.Subcommand != ds.BitFieldGet {
+			op.Value, err = parseInteger(args[i])
+			if err != nil {
+				return nil, err
+			}
+			op.Overflow = overflow
+			i++
+		}
+		ops = append(ops, op)
+	}
+	return ops, nil
+}
+
+// parseBitFieldType parses an encoding such as i8 or u16
+func parseBitFieldType(arg []byte) (ds.BitFieldType, error) {
+	var t ds.BitFieldType
+	if len(arg) < 2 {
+		return t, ds.ErrBitFieldType
+	}
+	switch arg[0] {
+	case 'i', 'I':
+		t.Signed = true
+	case 'u', 'U':
+	default:
+		return t, ds.ErrBitFieldType
+	}
+	bits, err := strconv.Atoi(string(arg[1:]))
+	if err != nil {
+		return t, ds.ErrBitFieldType
+	}
+	t.Bits = bits
+	return t, nil
+}
+
+// parseBitFieldOffset parses an offset in bits, or an offset multiplied by the width of the type if it is prefixed by #
+func parseBitFieldOffset(arg []byte, t ds.BitFieldType) (int64, error) {
+	typed := len(arg) > 0 && arg[0] == '#'
+	if typed {
+		arg = arg[1:]
+	}
+	offset, err := parseInteger(arg)
+	if err != nil {
+		return 0, ds.ErrBitOffsetOutOfRange
+	}
+	if typed {
+		if offset > math.MaxInt64/int64(t.Bits) {
+			return 0, ds.ErrBitOffsetOutOfRange
+		}
+		offset *= int64(t.Bits)
+	}
+	return offset, nil
+}
@@ -0,0 +1,243 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+var (
+	errBadErrorRate   = newError("ERR bad error rate")
+	errErrorRateRange = newError("ERR (0 < error rate range < 1)")
+	errBadCapacity    = newError("ERR bad capacity")
+	errCapacityRange  = newError("ERR (capacity should be larger than 0)")
+	errBadExpansion   = newError("ERR bad expansion")
+	errExpansionRange = newError("ERR expansion should be greater or equal to 1")
+	errNonScaling     = newError("ERR Nonscaling filters cannot expand")
+)
+
+// parseBloomOption parses the value of an option of BF.RESERVE and BF.INSERT
+func parseBloomOption(opts *ds.BloomOptions, option string, arg []byte) error {
+	switch option {
+	case "ERROR":
+		errorRate, err := strconv.ParseFloat(string(arg), 64)
+		if err != nil {
+			return errBadErrorRate
+		}
+		if errorRate <= 0 || errorRate >= 1 {
+			return errErrorRateRange
+		}
+		opts.ErrorRate = errorRate
+	case "CAPACITY":
+		capacity, err := strconv.ParseInt(string(arg), 10, 64)
+		if err != nil {
+			return errBadCapacity
+		}
+		if capacity <= 0 {
+			return errCapacityRange
+		}
+		opts.Capacity = uint64(capacity)
+	case "EXPANSION":
+		expansion, err := strconv.ParseInt(string(arg), 10, 32)
+		if err != nil {
+			return errBadExpansion
+		}
+		if expansion < 1 {
+			return errExpansionRange
+		}
+		opts.Expansion = uint32(expansion)
+	}
+	return nil
+}
+
+// bloomAddedReply replies whether an item is added, or an error if the filter is full
+func bloomAddedReply(added int64) Reply {
+	if added < 0 {
+		return errorReply(ds.ErrBloomFull.Error())
+	}
+	return boolReply(added == 1)
+}
+
+// bfReserve executes BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING]
+func bfReserve(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	opts := ds.DefaultBloomOptions
+	if err := parseBloomOption(&opts, "ERROR", args[1]); err != nil {
+		return nil, err
+	}
+	if err := parseBloomOption(&opts, "CAPACITY", args[2]); err != nil {
+		return nil, err
+	}
+
+	var hasExpansion bool
+	for i := 3; i < len(args); i++ {
+		switch option := strings.ToUpper(string(args[i])); option {
+		case "NONSCALING":
+			opts.NonScaling = true
+		case "EXPANSION":
+			if i+1 >= len(args) {
+				return nil, errSyntax
+			}
+			i++
+			if err := parseBloomOption(&opts, option, args[i]); err != nil {
+				return nil, err
+			}
+			hasExpansion = true
+		default:
+			return nil, errSyntax
+		}
+	}
+	if opts.NonScaling && hasExpansion {
+		return nil, errNonScaling
+	}
+
+	if err := rds.BFReserve(key, opts); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// bfAdd executes BF.ADD key item
+func bfAdd(rds *ds.DS, args ...[]byte) (Reply, error) {
+	added, err := rds.BFAdd(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	if added[0] < 0 {
+		return nil, ds.ErrBloomFull
+	}
+	return boolReply(added[0] == 1), nil
+}
+
+// bfMAdd executes BF.MADD key item [item ...]
+func bfMAdd(rds *ds.DS, args ...[]byte) (Reply, error) {
+	added, err := rds.BFAdd(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(added))
+	for i, a := range added {
+		replies[i] = bloomAddedReply(a)
+	}
+	return replies, nil
+}
+
+// bfInsert executes BF.INSERT key [CAPACITY capacity] [ERROR error] [EXPANSION expansion] [NOCREATE] [NONSCALING] ITEMS item [item ...]
+func bfInsert(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	opts := ds.DefaultBloomOptions
+	var noCreate, hasExpansion bool
+	i := 1
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "ITEMS" {
+			break
+		}
+		switch option {
+		case "NOCREATE":
+			noCreate = true
+		case "NONSCALING":
+			opts.NonScaling = true
+		case "ERROR", "CAPACITY", "EXPANSION":
+			if i+1 >= len(args) {
+				return nil, errSyntax
+			}
+			i++
+			if err := parseBloomOption(&opts, option, args[i]); err != nil {
+				return nil, err
+			}
+			hasExpansion = hasExpansion || option == "EXPANSION"
+		default:
+			return nil, errSyntax
+		}
+	}
+	if i+1 >= len(args) {
+		return nil, newErrWrongNumberOfArguments("bf.insert")
+	}
+	if opts.NonScaling && hasExpansion {
+		return nil, errNonScaling
+	}
+
+	added, err := rds.BFInsert(key, opts, noCreate, args[i+1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(added))
+	for i, a := range added {
+		replies[i] = bloomAddedReply(a)
+	}
+	return replies, nil
+}
+
+// bfExists executes BF.EXISTS key item
+func bfExists(rds *ds.DS, args ...[]byte) (Reply, error) {
+	results, err := rds.BFExists(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return boolReply(results[0]), nil
+}
+
+// bfMExists executes BF.MEXISTS key item [item ...]
+func bfMExists(rds *ds.DS, args ...[]byte) (Reply, error) {
+	results, err := rds.BFExists(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(results))
+	for i, ok := range results {
+		replies[i] = boolReply(ok)
+	}
+	return replies, nil
+}
+
+// bfCard executes BF.CARD key
+func bfCard(rds *ds.DS, args ...[]byte) (Reply, error) {
+	n, err := rds.BFCard(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// bfInfo executes BF.INFO key [CAPACITY | SIZE | FILTERS | ITEMS | EXPANSION]
+func bfInfo(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 {
+		return nil, errSyntax
+	}
+	info, err := rds.BFInfo(args[0])
+	if err != nil {
+		return nil, err
+	}
+
+	var expansion Reply = integerReply(info.Expansion)
+	if info.NonScaling {
+		expansion = nullBulkReply
+	}
+	if len(args) == 2 {
+		// a single field is replied in an array
+		switch strings.ToUpper(string(args[1])) {
+		case "CAPACITY":
+			return arrayReply{integerReply(info.Capacity)}, nil
+		case "SIZE":
+			return arrayReply{integerReply(info.Size)}, nil
+		case "FILTERS":
+			return arrayReply{integerReply(info.Filters)}, nil
+		case "ITEMS":
+			return arrayReply{integerReply(info.Items)}, nil
+		case "EXPANSION":
+			return arrayReply{expansion}, nil
+		default:
+			return nil, newError("ERR Invalid information value")
+		}
+	}
+
+	return mapReply{
+		bulkReply("Capacity"), integerReply(info.Capacity),
+		bulkReply("Size"), integerReply(info.Size),
+		bulkReply("Number of filters"), integerReply(info.Filters),
+		bulkReply("Number of items inserted"), integerReply(info.Items),
+		bulkReply("Expansion rate"), expansion,
+	}, nil
+}
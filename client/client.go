@@ -1,8 +1,8 @@
 package client
 
 import (
-	"fmt"
 	"strings"
+	"time"
 
 	"github.com/saint-yellow/baradb"
 	"github.com/tidwall/redcon"
@@ -11,33 +11,182 @@ import (
 )
 
 type RedisClient struct {
-	DB *ds.DS
+	DB       *ds.DS
+	ID       int64         // unique ID of the connection
+	Name     string        // name set by HELLO SETNAME
+	ACL      *ACL          // shared access control list of the server
+	Commands *CommandTable // shared table of commands of the server
+
+	username      string // user authenticated as, empty for the default user
+	authenticated bool
+	protocol      int // protocol version negotiated by HELLO, 0 for the default RESP2
+}
+
+// Protocol gets the protocol version of the client, either 2 or 3
+func (client *RedisClient) Protocol() int {
+	if client.protocol == 0 {
+		return resp2
+	}
+	return client.protocol
+}
+
+// Authenticated tells whether the client is allowed to execute commands
+func (client *RedisClient) Authenticated() bool {
+	return client.authenticated || client.ACL.defaultNoPass()
+}
+
+// Username gets the name of the user the client is authenticated as
+func (client *RedisClient) Username() string {
+	if client.username == "" {
+		return defaultUsername
+	}
+	return client.username
+}
+
+// Permit checks whether the client is allowed to execute a command.
+//
+// The command must be a known command with a valid number of arguments.
+// If not allowed, it writes an error to the connection, and records NOPERM errors in ACL LOG.
+func (client *RedisClient) Permit(conn redcon.Conn, cmd redcon.Command) bool {
+	return client.permit(conn, cmd) != nil
+}
+
+// permit checks whether the client is allowed to execute a command, and returns the command if allowed
+func (client *RedisClient) permit(conn redcon.Conn, cmd redcon.Command) *command {
+	c, err := client.Commands.lookup(cmd.Args)
+	if err != nil {
+		if c != nil {
+			c.stats.rejectedCalls.Add(1)
+		}
+		conn.WriteError(err.Error())
+		return nil
+	}
+	if err := client.authorize(conn, c, cmd.Args); err != nil {
+		c.stats.rejectedCalls.Add(1)
+		if err != errUserRevoked {
+			conn.WriteError(err.Error())
+		}
+		return nil
+	}
+	return c
+}
+
+// authorize checks whether the client is allowed to execute a command with its arguments
+func (client *RedisClient) authorize(conn redcon.Conn, c *command, args [][]byte) error {
+	if c.hasFlag(flagNoAuth) {
+		return nil
+	}
+	if !client.Authenticated() {
+		return errNoAuth
+	}
+
+	// clients of a deleted or disabled user are disconnected, like by ACL DELUSER of Redis
+	user := client.ACL.user(client.Username())
+	if user == nil || !user.enabled {
+		conn.Close()
+		return errUserRevoked
+	}
+
+	if !user.canExecute(c) {
+		client.ACL.addLog("command", c.name, user.name, client, conn.RemoteAddr())
+		return newError("NOPERM User %s has no permissions to run the '%s' command", user.name, c.name)
+	}
+
+	for _, key := range c.commandKeys(args) {
+		if ds.IsReservedKey(key) {
+			return errReservedKey
+		}
+		if !user.canAccessKey(key) {
+			client.ACL.addLog("key", string(key), user.name, client, conn.RemoteAddr())
+			return errNoPermKey
+		}
+	}
+	for _, channel := range c.commandChannels(args) {
+		if !user.canAccessChannel(channel) {
+			client.ACL.addLog("channel", string(channel), user.name, client, conn.RemoteAddr())
+			return errNoPermChannel
+		}
+	}
+	return nil
+}
+
+// canAccessKey tells whether the client is allowed to access a key,
+// which is checked by commands finding keys by themselves rather than from their arguments
+func (client *RedisClient) canAccessKey(key []byte) bool {
+	if ds.IsReservedKey(key) {
+		return false
+	}
+	user := client.ACL.user(client.Username())
+	return user != nil && user.canAccessKey(key)
 }
 
 func ExecuteClientCommand(conn redcon.Conn, cmd redcon.Command) {
 	commandName := strings.ToLower(string(cmd.Args[0]))
 	client, _ := conn.Context().(*RedisClient)
 
+	c := client.permit(conn, cmd)
+	if c == nil {
+		return
+	}
+
+	start := time.Now()
 	switch commandName {
 	case "quit":
 		conn.Close()
-	case "ping":
-		conn.WriteString("PONG!")
+	case "auth":
+		client.auth(conn, cmd.Args[1:])
+	case "hello":
+		client.hello(conn, cmd.Args[1:])
+	case "acl":
+		client.acl(conn, cmd.Args[1:])
+	case "command":
+		client.Commands.introspect(conn, cmd.Args[1:])
 	default:
-		commandHandler, ok := supportedCommands[commandName]
-		if !ok {
-			conn.WriteError(fmt.Sprintf("%s is unsupported Redis command", commandName))
-			return
-		}
-		result, err := commandHandler(client.DB, cmd.Args[1:]...)
+		w := NewReplyWriter(conn)
+		result, err := client.call(c, cmd.Args[1:])
+		c.stats.record(time.Since(start), err != nil && err != baradb.ErrKeyNotFound)
 		if err != nil {
 			if err == baradb.ErrKeyNotFound {
-				conn.WriteNull()
+				nullBulkReply.writeTo(w)
 			} else {
-				conn.WriteError(err.Error())
+				w.WriteError(errorMessage(err))
 			}
 			return
 		}
-		conn.WriteAny(result)
+		result.writeTo(w)
+		return
+	}
+	c.stats.record(time.Since(start), false)
+}
+
+// call executes a command with its handler
+func (client *RedisClient) call(c *command, args [][]byte) (Reply, error) {
+	switch {
+	case c.custom != nil:
+		ctx := &CommandContext{
+			Client: client,
+			DB:     client.DB,
+			Args:   args,
+		}
+		result, err := c.custom(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.batch != nil {
+			if err := ctx.batch.Commit(); err != nil {
+				return nil, err
+			}
+		}
+		if result == nil {
+			return okReply, nil
+		}
+		return result, nil
+	case c.handler != nil:
+		return c.handler(client.DB, args...)
+	case c.clientHandler != nil:
+		return c.clientHandler(client, args...)
+	default:
+		// commands executed by the server are not expected here
+		return nil, newError("ERR Can't execute '%s' in this context", c.name)
 	}
 }
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/stretchr/testify/assert"
+	"github.com/tidwall/redcon"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// testingConn is a connection which records replies in RESP
+type testingConn struct {
+	redcon.Conn // methods not used by clients are not implemented
+
+	remoteAddr string
+	context    interface{}
+	replies    []byte
+	closed     bool
+}
+
+func (conn *testingConn) RemoteAddr() string {
+	return conn.remoteAddr
+}
+
+func (conn *testingConn) Close() error {
+	conn.closed = true
+	return nil
+}
+
+func (conn *testingConn) Context() interface{} {
+	return conn.context
+}
+
+func (conn *testingConn) SetContext(v interface{}) {
+	conn.context = v
+}
+
+func (conn *testingConn) WriteError(msg string) {
+	conn.replies = redcon.AppendError(conn.replies, msg)
+}
+
+func (conn *testingConn) WriteString(str string) {
+	conn.replies = redcon.AppendString(conn.replies, str)
+}
+
+func (conn *testingConn) WriteBulk(bulk []byte) {
+	conn.replies = redcon.AppendBulk(conn.replies, bulk)
+}
+
+func (conn *testingConn) WriteBulkString(bulk string) {
+	conn.replies = redcon.AppendBulkString(conn.replies, bulk)
+}
+
+func (conn *testingConn) WriteInt(num int) {
+	conn.replies = redcon.AppendInt(conn.replies, int64(num))
+}
+
+func (conn *testingConn) WriteInt64(num int64) {
+	conn.replies = redcon.AppendInt(conn.replies, num)
+}
+
+func (conn *testingConn) WriteUint64(num uint64) {
+	conn.replies = redcon.AppendUint(conn.replies, num)
+}
+
+func (conn *testingConn) WriteArray(count int) {
+	conn.replies = redcon.AppendArray(conn.replies, count)
+}
+
+func (conn *testingConn) WriteNull() {
+	conn.replies = redcon.AppendNull(conn.replies)
+}
+
+func (conn *testingConn) WriteRaw(data []byte) {
+	conn.replies = append(conn.replies, data...)
+}
+
+func (conn *testingConn) WriteAny(v interface{}) {
+	conn.replies = redcon.AppendAny(conn.replies, v)
+}
+
+// newTestingConn initializes a connection of a client sharing the ACL, without any database
+func newTestingConn(acl *ACL, remoteAddr string) *testingConn {
+	conn := &testingConn{remoteAddr: remoteAddr}
+	conn.SetContext(&RedisClient{ACL: acl, Commands: acl.commands})
+	return conn
+}
+
+// newTestingDB opens a database in a temporary directory, which is closed when the test finishes
+func newTestingDB(t *testing.T) *ds.DS {
+	opts := baradb.DefaultDBOptions
+	opts.Directory = t.TempDir()
+	db, err := ds.New(opts)
+	assert.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// execute executes a command, and returns the first line of its reply
+func (conn *testingConn) execute(args ...string) string {
+	cmd := redcon.Command{}
+	for _, arg := range args {
+		cmd.Args = append(cmd.Args, []byte(arg))
+	}
+	conn.replies = conn.replies[:0]
+	ExecuteClientCommand(conn, cmd)
+	line, _, _ := strings.Cut(string(conn.replies), "\r\n")
+	return line
+}
+
+func TestRedisClient_Authorize(t *testing.T) {
+	acl := NewACL("", NewCommandTable())
+	assert.Nil(t, acl.setUser("alice", []string{"on", ">secret", "~app:*", "&news.*", "+@connection", "+command", "+get", "+spublish"}))
+	conn := newTestingConn(acl, "127.0.0.1:6379")
+
+	assert.Equal(t, "+OK", conn.execute("AUTH", "alice", "secret"))
+	assert.Equal(t, "*1", conn.execute("COMMAND", "GETKEYS", "GET", "app:1"))
+	assert.Equal(t, "-NOPERM User alice has no permissions to run the 'set' command", conn.execute("SET", "app:1", "v"))
+	assert.Equal(t, "-NOPERM No permissions to access a key", conn.execute("GET", "other:1"))
+	assert.Equal(t, "-NOPERM No permissions to access a channel", conn.execute("SPUBLISH", "sports", "goal"))
+	assert.Equal(t, "-ERR the key is reserved for internal use", conn.execute("GET", "\x00baradb-redis\x00timeseries\x00"))
+	assert.Equal(t, 3, len(acl.log))
+
+	// a disabled user could not execute commands with established connections
+	assert.Nil(t, acl.setUser("alice", []string{"off"}))
+	assert.Equal(t, "", conn.execute("PING"))
+	assert.True(t, conn.closed)
+	assert.Equal(t, "-WRONGPASS invalid username-password pair or user is disabled.", newTestingConn(acl, "127.0.0.1:6379").execute("AUTH", "alice", "secret"))
+
+	// a deleted user could not either
+	assert.Nil(t, acl.setUser("alice", []string{"on"}))
+	conn = newTestingConn(acl, "127.0.0.1:6379")
+	assert.Equal(t, "+OK", conn.execute("AUTH", "alice", "secret"))
+	_, err := acl.delUser([]string{"alice"})
+	assert.Nil(t, err)
+	assert.Equal(t, "", conn.execute("PING"))
+	assert.True(t, conn.closed)
+}
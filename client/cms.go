@@ -0,0 +1,152 @@
+package client
+
+import (
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+var (
+	errCMSInvalidWidth   = newError("ERR CMS: invalid width")
+	errCMSInvalidDepth   = newError("ERR CMS: invalid depth")
+	errCMSInvalidError   = newError("ERR CMS: invalid overestimation value")
+	errCMSInvalidProb    = newError("ERR CMS: invalid prob value")
+	errCMSInvalidNumber  = newError("ERR CMS: Cannot parse number")
+	errCMSInvalidNumKeys = newError("ERR CMS: invalid numkeys")
+	errCMSInvalidWeight  = newError("ERR CMS: invalid weight value")
+)
+
+// cmsCountsReply replies estimated counts of items
+func cmsCountsReply(counts []uint32) Reply {
+	replies := make(arrayReply, len(counts))
+	for i, n := range counts {
+		replies[i] = integerReply(n)
+	}
+	return replies
+}
+
+// cmsMergeKeys extracts the destination and sources of CMS.MERGE
+func cmsMergeKeys(args [][]byte) [][]byte {
+	if len(args) < 3 {
+		return nil
+	}
+	numKeys, err := strconv.Atoi(string(args[2]))
+	if err != nil || numKeys <= 0 || numKeys > len(args)-3 {
+		return nil
+	}
+	return append([][]byte{args[1]}, args[3:3+numKeys]...)
+}
+
+// cmsInitByDim executes CMS.INITBYDIM key width depth
+func cmsInitByDim(rds *ds.DS, args ...[]byte) (Reply, error) {
+	width, err := strconv.ParseUint(string(args[1]), 10, 32)
+	if err != nil || width == 0 {
+		return nil, errCMSInvalidWidth
+	}
+	depth, err := strconv.ParseUint(string(args[2]), 10, 32)
+	if err != nil || depth == 0 {
+		return nil, errCMSInvalidDepth
+	}
+	if err := rds.CMSInitByDim(args[0], width, depth); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// cmsInitByProb executes CMS.INITBYPROB key error probability
+func cmsInitByProb(rds *ds.DS, args ...[]byte) (Reply, error) {
+	errorRate, err := strconv.ParseFloat(string(args[1]), 64)
+	if err != nil || errorRate <= 0 || errorRate >= 1 {
+		return nil, errCMSInvalidError
+	}
+	probability, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil || probability <= 0 || probability >= 1 {
+		return nil, errCMSInvalidProb
+	}
+	if err := rds.CMSInitByProb(args[0], errorRate, probability); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// cmsIncrBy executes CMS.INCRBY key item increment [item increment ...]
+func cmsIncrBy(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args)%2 == 0 {
+		return nil, newErrWrongNumberOfArguments("cms.incrby")
+	}
+	items := make([][]byte, 0, len(args)/2)
+	increments := make([]uint32, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		increment, err := strconv.ParseUint(string(args[i+1]), 10, 32)
+		if err != nil {
+			return nil, errCMSInvalidNumber
+		}
+		items = append(items, args[i])
+		increments = append(increments, uint32(increment))
+	}
+
+	counts, err := rds.CMSIncrBy(args[0], items, increments)
+	if err != nil {
+		return nil, err
+	}
+	return cmsCountsReply(counts), nil
+}
+
+// cmsQuery executes CMS.QUERY key item [item ...]
+func cmsQuery(rds *ds.DS, args ...[]byte) (Reply, error) {
+	counts, err := rds.CMSQuery(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return cmsCountsReply(counts), nil
+}
+
+// cmsMerge executes CMS.MERGE destination numKeys source [source ...] [WEIGHTS weight [weight ...]]
+func cmsMerge(rds *ds.DS, args ...[]byte) (Reply, error) {
+	destination := args[0]
+	numKeys, err := strconv.Atoi(string(args[1]))
+	if err != nil || numKeys <= 0 {
+		return nil, errCMSInvalidNumKeys
+	}
+	if numKeys > len(args)-2 {
+		return nil, newErrWrongNumberOfArguments("cms.merge")
+	}
+	sources := args[2 : 2+numKeys]
+
+	weights := make([]int64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	if rest := args[2+numKeys:]; len(rest) > 0 {
+		if strings.ToUpper(string(rest[0])) != "WEIGHTS" || len(rest)-1 != numKeys {
+			return nil, errSyntax
+		}
+		for i, arg := range rest[1:] {
+			weight, err := strconv.ParseInt(string(arg), 10, 64)
+			if err != nil || weight > math.MaxUint32 || weight < -math.MaxUint32 {
+				return nil, errCMSInvalidWeight
+			}
+			weights[i] = weight
+		}
+	}
+
+	if err := rds.CMSMerge(destination, sources, weights); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// cmsInfo executes CMS.INFO key
+func cmsInfo(rds *ds.DS, args ...[]byte) (Reply, error) {
+	info, err := rds.CMSInfo(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mapReply{
+		bulkReply("width"), integerReply(info.Width),
+		bulkReply("depth"), integerReply(info.Depth),
+		bulkReply("count"), integerReply(info.Count),
+	}, nil
+}
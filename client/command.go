@@ -0,0 +1,1378 @@
+package client
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// commandHandler is a wrapper of Redis commands
+type commandHandler func(service *ds.DS, arguments ...[]byte) (Reply, error)
+
+// clientCommandHandler executes a command depending on the client,
+// such as a command checking key permissions of keys found by itself
+type clientCommandHandler func(client *RedisClient, arguments ...[]byte) (Reply, error)
+
+// keysFunc extracts keys from the arguments of a command, including the command name
+type keysFunc func(args [][]byte) [][]byte
+
+// Command flags replied by COMMAND INFO
+const (
+	flagWrite    = "write"
+	flagReadonly = "readonly"
+	flagDenyOOM  = "denyoom"
+	flagAdmin    = "admin"
+	flagPubSub   = "pubsub"
+	flagNoScript = "noscript"
+	flagLoading  = "loading"
+	flagStale    = "stale"
+	flagFast     = "fast"
+	flagNoAuth   = "no_auth"
+	flagBlocking = "blocking"
+	flagMovable  = "movablekeys"
+)
+
+// command describes a Redis command or subcommand
+type command struct {
+	name string // a subcommand is named as "command|subcommand"
+
+	// handler executes the command,
+	// which is nil if the command is executed by the client or the server itself
+	handler       commandHandler
+	clientHandler clientCommandHandler // handler of a command depending on the client
+	custom        CommandFunc          // handler of a custom command registered by CommandTable.Register
+
+	arity      int // number of arguments including the name, negative values for the minimum number
+	flags      []string
+	categories []string // ACL categories
+	firstKey   int      // position of the first key, 0 if the command has no key
+	lastKey    int      // position of the last key, negative values count from the end
+	step       int      // step between keys
+	keys       keysFunc // extracts keys at positions depending on other arguments, such as XREAD
+	channels   bool     // whether the arguments after the name are channels
+
+	// documentation replied by COMMAND DOCS
+	group   string
+	since   string
+	summary string
+
+	subcommands map[string]*command
+
+	stats *commandStats // nil for built-in commands until they are copied into a CommandTable
+}
+
+// commandTable builds a table of commands indexed by their names
+func commandTable(commands ...*command) map[string]*command {
+	table := make(map[string]*command, len(commands))
+	for _, c := range commands {
+		name := c.name
+		if _, subcommand, ok := strings.Cut(c.name, "|"); ok {
+			name = subcommand
+		}
+		table[name] = c
+	}
+	return table
+}
+
+// builtinCommands registers all built-in commands, which are copied into every CommandTable.
+//
+// AUTH, HELLO, QUIT, ACL and COMMAND are executed by the client,
+// and commands of shard channels are executed by the server.
+var builtinCommands = commandTable(
+	// commands available for connections
+	&command{
+		name: "auth", arity: -2,
+		flags:      []string{flagNoScript, flagLoading, flagStale, flagFast, flagNoAuth},
+		categories: []string{categoryFast, categoryConnection},
+		group:      "connection", since: "1.0.0", summary: "Authenticates the connection.",
+	},
+	&command{
+		name: "hello", arity: -1,
+		flags:      []string{flagNoScript, flagLoading, flagStale, flagFast, flagNoAuth},
+		categories: []string{categoryFast, categoryConnection},
+		group:      "connection", since: "6.0.0", summary: "Handshakes with the Redis server.",
+	},
+	&command{
+		name: "ping", handler: ping, arity: -1,
+		flags:      []string{flagFast},
+		categories: []string{categoryFast, categoryConnection},
+		group:      "connection", since: "1.0.0", summary: "Returns the server's liveliness response.",
+	},
+	&command{
+		name: "quit", arity: -1,
+		flags:      []string{flagNoScript, flagLoading, flagStale, flagFast, flagNoAuth},
+		categories: []string{categoryFast, categoryConnection},
+		group:      "connection", since: "1.0.0", summary: "Closes the connection.",
+	},
+
+	// commands for introspection
+	&command{
+		name: "command", arity: -1,
+		flags:      []string{flagLoading, flagStale},
+		categories: []string{categorySlow, categoryConnection},
+		group:      "server", since: "2.8.13", summary: "Returns detailed information about all commands.",
+		subcommands: commandTable(
+			&command{
+				name: "command|count", arity: 2,
+				flags:      []string{flagLoading, flagStale},
+				categories: []string{categorySlow, categoryConnection},
+				group:      "server", since: "2.8.13", summary: "Returns a count of commands.",
+			},
+			&command{
+				name: "command|docs", arity: -2,
+				flags:      []string{flagLoading, flagStale},
+				categories: []string{categorySlow, categoryConnection},
+				group:      "server", since: "7.0.0", summary: "Returns documentary information about one, multiple or all commands.",
+			},
+			&command{
+				name: "command|getkeys", arity: -3,
+				flags:      []string{flagLoading, flagStale},
+				categories: []string{categorySlow, categoryConnection},
+				group:      "server", since: "2.8.13", summary: "Extracts the key names from an arbitrary command.",
+			},
+			&command{
+				name: "command|info", arity: -2,
+				flags:      []string{flagLoading, flagStale},
+				categories: []string{categorySlow, categoryConnection},
+				group:      "server", since: "2.8.13", summary: "Returns information about one, multiple or all commands.",
+			},
+			&command{
+				name: "command|list", arity: -2,
+				flags:      []string{flagLoading, flagStale},
+				categories: []string{categorySlow, categoryConnection},
+				group:      "server", since: "7.0.0", summary: "Returns a list of command names.",
+			},
+		),
+	},
+
+	// commands for access control
+	&command{
+		name: "acl", arity: -2,
+		categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+		group:      "server", since: "6.0.0", summary: "A container for Access List Control commands.",
+		subcommands: commandTable(
+			&command{
+				name: "acl|cat", arity: -2,
+				flags:      []string{flagNoScript, flagLoading, flagStale},
+				categories: []string{categorySlow},
+				group:      "server", since: "6.0.0", summary: "Lists the ACL categories, or the commands inside a category.",
+			},
+			&command{
+				name: "acl|deluser", arity: -3,
+				flags:      []string{flagAdmin, flagNoScript, flagLoading, flagStale},
+				categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+				group:      "server", since: "6.0.0", summary: "Deletes ACL users, and terminates their connections.",
+			},
+			&command{
+				name: "acl|getuser", arity: 3,
+				flags:      []string{flagAdmin, flagNoScript, flagLoading, flagStale},
+				categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+				group:      "server", since: "6.0.0", summary: "Lists the ACL rules of a user.",
+			},
+			&command{
+				name: "acl|list", arity: 2,
+				flags:      []string{flagAdmin, flagNoScript, flagLoading, flagStale},
+				categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+				group:      "server", since: "6.0.0", summary: "Dumps the effective rules in ACL file format.",
+			},
+			&command{
+				name: "acl|load", arity: 2,
+				flags:      []string{flagAdmin, flagNoScript, flagLoading, flagStale},
+				categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+				group:      "server", since: "6.0.0", summary: "Reloads the rules from the configured ACL file.",
+			},
+			&command{
+				name: "acl|log", arity: -2,
+				flags:      []string{flagAdmin, flagNoScript, flagLoading, flagStale},
+				categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+				group:      "server", since: "6.0.0", summary: "Lists recent security events generated due to ACL rejections.",
+			},
+			&command{
+				name: "acl|save", arity: 2,
+				flags:      []string{flagAdmin, flagNoScript, flagLoading, flagStale},
+				categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+				group:      "server", since: "6.0.0", summary: "Saves the effective ACL rules in the configured ACL file.",
+			},
+			&command{
+				name: "acl|setuser", arity: -3,
+				flags:      []string{flagAdmin, flagNoScript, flagLoading, flagStale},
+				categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+				group:      "server", since: "6.0.0", summary: "Creates and modifies an ACL user and its rules.",
+			},
+			&command{
+				name: "acl|users", arity: 2,
+				flags:      []string{flagAdmin, flagNoScript, flagLoading, flagStale},
+				categories: []string{categoryAdmin, categorySlow, categoryDangerous},
+				group:      "server", since: "6.0.0", summary: "Lists all ACL users.",
+			},
+			&command{
+				name: "acl|whoami", arity: 2,
+				flags:      []string{flagNoScript, flagLoading, flagStale},
+				categories: []string{categorySlow},
+				group:      "server", since: "6.0.0", summary: "Returns the authenticated username of the current connection.",
+			},
+		),
+	},
+
+	// commands for shard channels
+	&command{
+		name: "ssubscribe", arity: -2,
+		flags:      []string{flagPubSub, flagNoScript, flagLoading, flagStale},
+		categories: []string{categoryPubSub, categorySlow},
+		channels:   true,
+		group:      "pubsub", since: "7.0.0", summary: "Listens for messages published to shard channels.",
+	},
+	&command{
+		name: "sunsubscribe", arity: -1,
+		flags:      []string{flagPubSub, flagNoScript, flagLoading, flagStale},
+		categories: []string{categoryPubSub, categorySlow},
+		group:      "pubsub", since: "7.0.0", summary: "Stops listening to messages posted to shard channels.",
+	},
+	&command{
+		name: "spublish", arity: 3,
+		flags:      []string{flagPubSub, flagLoading, flagStale, flagFast},
+		categories: []string{categoryPubSub, categoryFast},
+		channels:   true,
+		group:      "pubsub", since: "7.0.0", summary: "Post a message to a shard channel",
+	},
+	&command{
+		name: "pubsub", arity: -2,
+		categories: []string{categoryPubSub, categorySlow},
+		group:      "pubsub", since: "2.8.0", summary: "A container for Pub/Sub commands.",
+		subcommands: commandTable(
+			&command{
+				name: "pubsub|shardchannels", arity: -2,
+				flags:      []string{flagPubSub, flagLoading, flagStale},
+				categories: []string{categoryPubSub, categorySlow},
+				group:      "pubsub", since: "7.0.0", summary: "Returns the active shard channels.",
+			},
+			&command{
+				name: "pubsub|shardnumsub", arity: -2,
+				flags:      []string{flagPubSub, flagLoading, flagStale},
+				categories: []string{categoryPubSub, categorySlow},
+				group:      "pubsub", since: "7.0.0", summary: "Returns the count of subscribers of shard channels.",
+			},
+		),
+	},
+
+	// commands available for all data types
+	&command{
+		name: "del", handler: del, arity: -2,
+		flags:      []string{flagWrite},
+		categories: []string{categoryKeyspace, categoryWrite, categorySlow},
+		firstKey:   1, lastKey: -1, step: 1,
+		group: "generic", since: "1.0.0", summary: "Deletes one or more keys.",
+	},
+	&command{
+		name: "exists", handler: exists, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryKeyspace, categoryRead, categoryFast},
+		firstKey:   1, lastKey: -1, step: 1,
+		group: "generic", since: "1.0.0", summary: "Determines whether one or more keys exist.",
+	},
+	&command{
+		name: "touch", handler: touch, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryKeyspace, categoryRead, categoryFast},
+		firstKey:   1, lastKey: -1, step: 1,
+		group: "generic", since: "3.2.1", summary: "Returns the number of existing keys out of those specified after updating the time they were last accessed.",
+	},
+	&command{
+		name: "type", handler: datatype, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryKeyspace, categoryRead, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "generic", since: "1.0.0", summary: "Determines the type of value stored at a key.",
+	},
+	&command{
+		name: "unlink", handler: unlink, arity: -2,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryKeyspace, categoryWrite, categoryFast},
+		firstKey:   1, lastKey: -1, step: 1,
+		group: "generic", since: "4.0.0", summary: "Asynchronously deletes one or more keys.",
+	},
+
+	// commands available for string only
+	&command{
+		name: "append", handler: strappend, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "2.0.0", summary: "Appends a string to the value of a key. Creates the key if it doesn't exist.",
+	},
+	&command{
+		name: "decr", handler: decr, arity: 2,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "1.0.0", summary: "Decrements the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+	},
+	&command{
+		name: "decrby", handler: decrby, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "1.0.0", summary: "Decrements a number from the integer value of a key. Uses 0 as initial value if the key doesn't exist.",
+	},
+	&command{
+		name: "get", handler: get, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "1.0.0", summary: "Returns the string value of a key.",
+	},
+	&command{
+		name: "getdel", handler: getdel, arity: 2,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "6.2.0", summary: "Returns the string value of a key after deleting the key.",
+	},
+	&command{
+		name: "getex", handler: getex, arity: -2,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "6.2.0", summary: "Returns the string value of a key after setting its expiration time.",
+	},
+	&command{
+		name: "getrange", handler: getrange, arity: 4,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryString, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "2.4.0", summary: "Returns a substring of the string stored at a key.",
+	},
+	&command{
+		name: "getset", handler: getset, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryString, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "1.0.0", summary: "Returns the previous string value of a key after setting it to a new value.",
+	},
+	&command{
+		name: "incr", handler: incr, arity: 2,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "1.0.0", summary: "Increments the integer value of a key by one. Uses 0 as initial value if the key doesn't exist.",
+	},
+	&command{
+		name: "incrby", handler: incrby, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "1.0.0", summary: "Increments the integer value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+	},
+	&command{
+		name: "incrbyfloat", handler: incrbyfloat, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "2.6.0", summary: "Increment the floating point value of a key by a number. Uses 0 as initial value if the key doesn't exist.",
+	},
+	&command{
+		name: "lcs", handler: lcs, arity: -3,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryString, categorySlow},
+		firstKey:   1, lastKey: 2, step: 1,
+		group: "string", since: "7.0.0", summary: "Finds the longest common substring.",
+	},
+	&command{
+		name: "mget", handler: mget, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryString, categoryFast},
+		firstKey:   1, lastKey: -1, step: 1,
+		group: "string", since: "1.0.0", summary: "Atomically returns the string values of one or more keys.",
+	},
+	&command{
+		name: "mset", handler: mset, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryString, categorySlow},
+		firstKey:   1, lastKey: -1, step: 2,
+		group: "string", since: "1.0.1", summary: "Atomically creates or modifies the string values of one or more keys.",
+	},
+	&command{
+		name: "msetnx", handler: msetnx, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryString, categorySlow},
+		firstKey:   1, lastKey: -1, step: 2,
+		group: "string", since: "1.0.1", summary: "Atomically modifies the string values of one or more keys only when all keys don't exist.",
+	},
+	&command{
+		name: "psetex", handler: psetex, arity: 4,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryString, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "2.6.0", summary: "Sets both string value and expiration time in milliseconds of a key. The key is created if it doesn't exist.",
+	},
+	&command{
+		name: "set", handler: set, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryString, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "1.0.0", summary: "Sets the string value of a key, ignoring its type. The key is created if it doesn't exist.",
+	},
+	&command{
+		name: "setex", handler: setex, arity: 4,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryString, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "2.0.0", summary: "Sets the string value and expiration time of a key. Creates the key if it doesn't exist.",
+	},
+	&command{
+		name: "setnx", handler: setnx, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "1.0.0", summary: "Set the string value of a key only when the key doesn't exist.",
+	},
+	&command{
+		name: "setrange", handler: setrange, arity: 4,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryString, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "2.2.0", summary: "Overwrites a part of a string value with another by an offset. Creates the key if it doesn't exist.",
+	},
+	&command{
+		name: "strlen", handler: strlen, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryString, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "string", since: "2.2.0", summary: "Returns the length of a string value.",
+	},
+
+	// commands available for bitmap only
+	&command{
+		name: "bitcount", handler: bitcount, arity: -2,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryBitmap, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bitmap", since: "2.6.0", summary: "Counts the number of set bits (population counting) in a string.",
+	},
+	&command{
+		name: "bitfield", handler: bitfield, arity: -2,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryBitmap, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bitmap", since: "3.2.0", summary: "Performs arbitrary bitfield integer operations on strings.",
+	},
+	&command{
+		name: "bitfield_ro", handler: bitfieldRO, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryBitmap, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bitmap", since: "6.0.0", summary: "Performs arbitrary read-only bitfield integer operations on strings.",
+	},
+	&command{
+		name: "bitop", handler: bitop, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryBitmap, categorySlow},
+		firstKey:   2, lastKey: -1, step: 1,
+		group: "bitmap", since: "2.6.0", summary: "Performs bitwise operations on multiple strings, and stores the result.",
+	},
+	&command{
+		name: "bitpos", handler: bitpos, arity: -3,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryBitmap, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bitmap", since: "2.8.7", summary: "Finds the first set (1) or clear (0) bit in a string.",
+	},
+	&command{
+		name: "getbit", handler: getbit, arity: 3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryBitmap, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bitmap", since: "2.2.0", summary: "Returns a bit value by offset.",
+	},
+	&command{
+		name: "setbit", handler: setbit, arity: 4,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryBitmap, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bitmap", since: "2.2.0", summary: "Sets or clears the bit at offset of the string value. Creates the key if it doesn't exist.",
+	},
+
+	// commands available for hyperloglog only
+	&command{
+		name: "pfadd", handler: pfadd, arity: -2,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryHyperLogLog, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "hyperloglog", since: "2.8.9", summary: "Adds elements to a HyperLogLog key. Creates the key if it doesn't exist.",
+	},
+	&command{
+		name: "pfcount", handler: pfcount, arity: -2,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryHyperLogLog, categorySlow},
+		firstKey:   1, lastKey: -1, step: 1,
+		group: "hyperloglog", since: "2.8.9", summary: "Returns the approximated cardinality of the set(s) observed by the HyperLogLog key(s).",
+	},
+	&command{
+		name: "pfmerge", handler: pfmerge, arity: -2,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryHyperLogLog, categorySlow},
+		firstKey:   1, lastKey: -1, step: 1,
+		group: "hyperloglog", since: "2.8.9", summary: "Merges one or more HyperLogLog values into a single key.",
+	},
+
+	// commands available for geospatial indexes only
+	&command{
+		name: "geoadd", handler: geoadd, arity: -5,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryGeo, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "geo", since: "3.2.0", summary: "Adds one or more members to a geospatial index. The key is created if it doesn't exist.",
+	},
+	&command{
+		name: "geodist", handler: geodist, arity: -4,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryGeo, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "geo", since: "3.2.0", summary: "Returns the distance between two members of a geospatial index.",
+	},
+	&command{
+		name: "geohash", handler: geohash, arity: -2,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryGeo, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "geo", since: "3.2.0", summary: "Returns members from a geospatial index as geohash strings.",
+	},
+	&command{
+		name: "geopos", handler: geopos, arity: -2,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryGeo, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "geo", since: "3.2.0", summary: "Returns the longitude and latitude of members from a geospatial index.",
+	},
+	&command{
+		name: "geosearch", handler: geosearch, arity: -7,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryGeo, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "geo", since: "6.2.0", summary: "Queries a geospatial index for members inside an area of a box or a circle.",
+	},
+	&command{
+		name: "geosearchstore", handler: geosearchstore, arity: -8,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryGeo, categorySlow},
+		firstKey:   1, lastKey: 2, step: 1,
+		group: "geo", since: "6.2.0", summary: "Queries a geospatial index for members inside an area of a box or a circle, optionally stores the result.",
+	},
+
+	// commands available for hash only
+	&command{
+		name: "hdel", handler: hdel, arity: -3,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryHash, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "hash", since: "2.0.0", summary: "Deletes one or more fields and their values from a hash. Deletes the hash if no fields remain.",
+	},
+	&command{
+		name: "hget", handler: hget, arity: 3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryHash, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "hash", since: "2.0.0", summary: "Returns the value of a field in a hash.",
+	},
+	&command{
+		name: "hgetall", handler: hgetall, arity: 2,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryHash, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "hash", since: "2.0.0", summary: "Returns all fields and values in a hash.",
+	},
+	&command{
+		name: "hset", handler: hset, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryHash, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "hash", since: "2.0.0", summary: "Creates or modifies the value of a field in a hash.",
+	},
+
+	// commands available for list only
+	&command{
+		name: "llen", handler: llen, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryList, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "list", since: "1.0.0", summary: "Returns the length of a list.",
+	},
+	&command{
+		name: "lpush", handler: lpush, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryList, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "list", since: "1.0.0", summary: "Prepends one or more elements to a list. Creates the key if it doesn't exist.",
+	},
+	&command{
+		name: "rpush", handler: rpush, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryList, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "list", since: "1.0.0", summary: "Appends one or more elements to a list. Creates the key if it doesn't exist.",
+	},
+
+	// commands available for set only
+	&command{
+		name: "sadd", handler: sadd, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categorySet, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "set", since: "1.0.0", summary: "Adds one or more members to a set. Creates the key if it doesn't exist.",
+	},
+	&command{
+		name: "scard", handler: scard, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categorySet, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "set", since: "1.0.0", summary: "Returns the number of members in a set.",
+	},
+	&command{
+		name: "sismember", handler: sismember, arity: 3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categorySet, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "set", since: "1.0.0", summary: "Determines whether a member belongs to a set.",
+	},
+	&command{
+		name: "smembers", handler: smembers, arity: 2,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categorySet, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "set", since: "1.0.0", summary: "Returns all members of a set.",
+	},
+	&command{
+		name: "srem", handler: srem, arity: -3,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categorySet, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "set", since: "1.0.0", summary: "Removes one or more members from a set. Deletes the set if the last member was removed.",
+	},
+
+	// commands available for sorted set only
+	&command{
+		name: "zadd", handler: zadd, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categorySortedSet, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "sorted-set", since: "1.2.0", summary: "Adds one or more members to a sorted set, or updates their scores. Creates the key if it doesn't exist.",
+	},
+	&command{
+		name: "zscore", handler: zscore, arity: 3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categorySortedSet, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "sorted-set", since: "1.2.0", summary: "Returns the score of a member in a sorted set.",
+	},
+
+	// commands available for streams only
+	&command{
+		name: "xack", handler: xack, arity: -4,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryStream, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Returns the number of messages that were successfully acknowledged by the consumer group member of a stream.",
+	},
+	&command{
+		name: "xadd", handler: xadd, arity: -5,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryStream, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Appends a new message to a stream. Creates the key if it doesn't exist.",
+	},
+	&command{
+		name: "xautoclaim", handler: xautoclaim, arity: -6,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryStream, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "6.2.0", summary: "Changes, or acquires, ownership of messages in a consumer group, as if the messages were delivered to as consumer group member.",
+	},
+	&command{
+		name: "xclaim", handler: xclaim, arity: -6,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryStream, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Changes, or acquires, ownership of a message in a consumer group, as if the message was delivered a consumer group member.",
+	},
+	&command{
+		name: "xdel", handler: xdel, arity: -3,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryStream, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Returns the number of messages after removing them from a stream.",
+	},
+	&command{
+		name: "xgroup", arity: -2,
+		categories: []string{categorySlow},
+		group:      "stream", since: "5.0.0", summary: "A container for consumer groups commands.",
+		subcommands: commandTable(
+			&command{
+				name: "xgroup|create", handler: xgroupCreate, arity: -5,
+				flags:      []string{flagWrite, flagDenyOOM},
+				categories: []string{categoryWrite, categoryStream, categorySlow},
+				firstKey:   2, lastKey: 2, step: 1,
+				group: "stream", since: "5.0.0", summary: "Creates a consumer group.",
+			},
+			&command{
+				name: "xgroup|createconsumer", handler: xgroupCreateConsumer, arity: 5,
+				flags:      []string{flagWrite, flagDenyOOM},
+				categories: []string{categoryWrite, categoryStream, categorySlow},
+				firstKey:   2, lastKey: 2, step: 1,
+				group: "stream", since: "6.2.0", summary: "Creates a consumer in a consumer group.",
+			},
+			&command{
+				name: "xgroup|delconsumer", handler: xgroupDelConsumer, arity: 5,
+				flags:      []string{flagWrite},
+				categories: []string{categoryWrite, categoryStream, categorySlow},
+				firstKey:   2, lastKey: 2, step: 1,
+				group: "stream", since: "5.0.0", summary: "Deletes a consumer from a consumer group.",
+			},
+			&command{
+				name: "xgroup|destroy", handler: xgroupDestroy, arity: 4,
+				flags:      []string{flagWrite},
+				categories: []string{categoryWrite, categoryStream, categorySlow},
+				firstKey:   2, lastKey: 2, step: 1,
+				group: "stream", since: "5.0.0", summary: "Destroys a consumer group.",
+			},
+			&command{
+				name: "xgroup|setid", handler: xgroupSetID, arity: -5,
+				flags:      []string{flagWrite},
+				categories: []string{categoryWrite, categoryStream, categorySlow},
+				firstKey:   2, lastKey: 2, step: 1,
+				group: "stream", since: "5.0.0", summary: "Sets the last-delivered ID of a consumer group.",
+			},
+		),
+	},
+	&command{
+		name: "xinfo", arity: -2,
+		categories: []string{categorySlow},
+		group:      "stream", since: "5.0.0", summary: "A container for stream introspection commands.",
+		subcommands: commandTable(
+			&command{
+				name: "xinfo|consumers", handler: xinfoConsumers, arity: 4,
+				flags:      []string{flagReadonly},
+				categories: []string{categoryRead, categoryStream, categorySlow},
+				firstKey:   2, lastKey: 2, step: 1,
+				group: "stream", since: "5.0.0", summary: "Returns a list of the consumers in a consumer group.",
+			},
+			&command{
+				name: "xinfo|groups", handler: xinfoGroups, arity: 3,
+				flags:      []string{flagReadonly},
+				categories: []string{categoryRead, categoryStream, categorySlow},
+				firstKey:   2, lastKey: 2, step: 1,
+				group: "stream", since: "5.0.0", summary: "Returns a list of the consumer groups of a stream.",
+			},
+			&command{
+				name: "xinfo|stream", handler: xinfoStream, arity: -3,
+				flags:      []string{flagReadonly},
+				categories: []string{categoryRead, categoryStream, categorySlow},
+				firstKey:   2, lastKey: 2, step: 1,
+				group: "stream", since: "5.0.0", summary: "Returns information about a stream.",
+			},
+		),
+	},
+	&command{
+		name: "xlen", handler: xlen, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryStream, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Return the number of messages in a stream.",
+	},
+	&command{
+		name: "xpending", handler: xpending, arity: -3,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryStream, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Returns the information and entries from a stream consumer group's pending entries list.",
+	},
+	&command{
+		name: "xrange", handler: xrange, arity: -4,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryStream, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Returns the messages from a stream within a range of IDs.",
+	},
+	&command{
+		name: "xread", handler: xread, arity: -4,
+		flags:      []string{flagReadonly, flagBlocking, flagMovable},
+		categories: []string{categoryRead, categoryStream, categorySlow, categoryBlocking},
+		keys:       streamReadKeys,
+		group:      "stream", since: "5.0.0", summary: "Returns messages from multiple streams with IDs greater than the ones requested. Blocks until a message is available otherwise.",
+	},
+	&command{
+		name: "xreadgroup", handler: xreadgroup, arity: -7,
+		flags:      []string{flagWrite, flagBlocking, flagMovable},
+		categories: []string{categoryWrite, categoryStream, categorySlow, categoryBlocking},
+		keys:       streamReadKeys,
+		group:      "stream", since: "5.0.0", summary: "Returns new or historical messages from a stream for a consumer in a group. Blocks until a message is available otherwise.",
+	},
+	&command{
+		name: "xrevrange", handler: xrevrange, arity: -4,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryStream, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Returns the messages from a stream within a range of IDs in reverse order.",
+	},
+	&command{
+		name: "xtrim", handler: xtrim, arity: -4,
+		flags:      []string{flagWrite},
+		categories: []string{categoryWrite, categoryStream, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "stream", since: "5.0.0", summary: "Deletes messages from the beginning of a stream.",
+	},
+
+	// commands available for JSON documents only
+	&command{
+		name: "json.arrappend", handler: jsonArrAppend, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Append one or more json values into the array at path after the last element in it.",
+	},
+	&command{
+		name: "json.arrinsert", handler: jsonArrInsert, arity: -5,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Inserts the JSON scalar(s) value at the specified index in the array at path.",
+	},
+	&command{
+		name: "json.arrlen", handler: jsonArrLen, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Returns the length of the array at path.",
+	},
+	&command{
+		name: "json.arrpop", handler: jsonArrPop, arity: -2,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Removes and returns the element at the specified index in the array at path.",
+	},
+	&command{
+		name: "json.arrtrim", handler: jsonArrTrim, arity: 5,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Trims the array at path to contain only the specified inclusive range of indices from start to stop.",
+	},
+	&command{
+		name: "json.del", handler: jsonDel, arity: -2,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Deletes a value.",
+	},
+	&command{
+		name: "json.forget", handler: jsonDel, arity: -2,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Deletes a value.",
+	},
+	&command{
+		name: "json.get", handler: jsonGet, arity: -2,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryJSON, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Gets the value at one or more paths in JSON serialized form.",
+	},
+	&command{
+		name: "json.numincrby", handler: jsonNumIncrBy, arity: 4,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Increments the numeric value at path by a value.",
+	},
+	&command{
+		name: "json.objkeys", handler: jsonObjKeys, arity: -2,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryJSON, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Returns the JSON keys of the object at path.",
+	},
+	&command{
+		name: "json.objlen", handler: jsonObjLen, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Returns the number of keys of the object at path.",
+	},
+	&command{
+		name: "json.set", handler: jsonSet, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categoryJSON, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Sets or updates the JSON value at a path.",
+	},
+	&command{
+		name: "json.strlen", handler: jsonStrLen, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Returns the length of the JSON String at path in key.",
+	},
+	&command{
+		name: "json.type", handler: jsonType, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryJSON, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "json", since: "1.0.0", summary: "Returns the type of the JSON value at path.",
+	},
+
+	// commands available for Bloom filters only
+	&command{
+		name: "bf.add", handler: bfAdd, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryBloom, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bf", since: "1.0.0", summary: "Adds an item to a Bloom Filter.",
+	},
+	&command{
+		name: "bf.card", handler: bfCard, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryBloom, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bf", since: "1.0.0", summary: "Returns the cardinality of a Bloom filter.",
+	},
+	&command{
+		name: "bf.exists", handler: bfExists, arity: 3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryBloom, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bf", since: "1.0.0", summary: "Checks whether an item exists in a Bloom Filter.",
+	},
+	&command{
+		name: "bf.info", handler: bfInfo, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryBloom, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bf", since: "1.0.0", summary: "Returns information about a Bloom Filter.",
+	},
+	&command{
+		name: "bf.insert", handler: bfInsert, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryBloom, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bf", since: "1.0.0", summary: "Adds one or more items to a Bloom Filter. A filter will be created if it does not exist.",
+	},
+	&command{
+		name: "bf.madd", handler: bfMAdd, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryBloom, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bf", since: "1.0.0", summary: "Adds one or more items to a Bloom Filter. A filter will be created if it does not exist.",
+	},
+	&command{
+		name: "bf.mexists", handler: bfMExists, arity: -3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryBloom, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bf", since: "1.0.0", summary: "Checks whether one or more items exist in a Bloom Filter.",
+	},
+	&command{
+		name: "bf.reserve", handler: bfReserve, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryBloom, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "bf", since: "1.0.0", summary: "Creates a new Bloom Filter.",
+	},
+
+	// commands available for Cuckoo filters only
+	&command{
+		name: "cf.add", handler: cfAdd, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Adds an item to a Cuckoo Filter.",
+	},
+	&command{
+		name: "cf.addnx", handler: cfAddNx, arity: 3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Adds an item to a Cuckoo Filter if the item did not exist previously.",
+	},
+	&command{
+		name: "cf.count", handler: cfCount, arity: 3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Return the number of times an item might be in a Cuckoo Filter.",
+	},
+	&command{
+		name: "cf.del", handler: cfDel, arity: 3,
+		flags:      []string{flagWrite, flagFast},
+		categories: []string{categoryWrite, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Deletes an item from a Cuckoo Filter.",
+	},
+	&command{
+		name: "cf.exists", handler: cfExists, arity: 3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Checks whether one or more items exist in a Cuckoo Filter.",
+	},
+	&command{
+		name: "cf.info", handler: cfInfo, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Returns information about a Cuckoo Filter.",
+	},
+	&command{
+		name: "cf.insert", handler: cfInsert, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Adds one or more items to a Cuckoo Filter. A filter will be created if it does not exist.",
+	},
+	&command{
+		name: "cf.insertnx", handler: cfInsertNx, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Adds one or more items to a Cuckoo Filter if the items did not exist previously. A filter will be created if it does not exist.",
+	},
+	&command{
+		name: "cf.mexists", handler: cfMExists, arity: -3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Checks whether one or more items exist in a Cuckoo Filter.",
+	},
+	&command{
+		name: "cf.reserve", handler: cfReserve, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryCuckoo, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cf", since: "1.0.0", summary: "Creates a new Cuckoo Filter.",
+	},
+	// commands available for Count-Min Sketches only
+	&command{
+		name: "cms.incrby", handler: cmsIncrBy, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryCMS, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cms", since: "1.0.0", summary: "Increases the count of one or more items by increment.",
+	},
+	&command{
+		name: "cms.info", handler: cmsInfo, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryCMS, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cms", since: "1.0.0", summary: "Returns information about a sketch.",
+	},
+	&command{
+		name: "cms.initbydim", handler: cmsInitByDim, arity: 4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryCMS, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cms", since: "1.0.0", summary: "Initializes a Count-Min Sketch to dimensions specified by user.",
+	},
+	&command{
+		name: "cms.initbyprob", handler: cmsInitByProb, arity: 4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryCMS, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cms", since: "1.0.0", summary: "Initializes a Count-Min Sketch to accommodate requested tolerances.",
+	},
+	&command{
+		name: "cms.merge", handler: cmsMerge, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast, flagMovable},
+		categories: []string{categoryWrite, categoryCMS, categoryFast},
+		keys:       cmsMergeKeys,
+		group:      "cms", since: "1.0.0", summary: "Merges several sketches into one sketch.",
+	},
+	&command{
+		name: "cms.query", handler: cmsQuery, arity: -3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryCMS, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "cms", since: "1.0.0", summary: "Returns the count for one or more items in a sketch.",
+	},
+	// commands available for Top-K only
+	&command{
+		name: "topk.add", handler: topkAdd, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryTopK, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "topk", since: "1.0.0", summary: "Adds an item to a Top-k sketch. Multiple items can be added at the same time.",
+	},
+	&command{
+		name: "topk.count", handler: topkCount, arity: -3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryTopK, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "topk", since: "1.0.0", summary: "Return the count for one or more items are in a sketch.",
+	},
+	&command{
+		name: "topk.incrby", handler: topkIncrBy, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryTopK, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "topk", since: "1.0.0", summary: "Increases the count of one or more items by increment.",
+	},
+	&command{
+		name: "topk.info", handler: topkInfo, arity: 2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryTopK, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "topk", since: "1.0.0", summary: "Returns information about a sketch.",
+	},
+	&command{
+		name: "topk.list", handler: topkList, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryTopK, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "topk", since: "1.0.0", summary: "Return full list of items in Top-K list.",
+	},
+	&command{
+		name: "topk.query", handler: topkQuery, arity: -3,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryTopK, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "topk", since: "1.0.0", summary: "Checks whether one or more items are in a sketch.",
+	},
+	&command{
+		name: "topk.reserve", handler: topkReserve, arity: -3,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryTopK, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "topk", since: "1.0.0", summary: "Initializes a Top-K sketch with specified parameters.",
+	},
+	// commands available for time series only
+	&command{
+		name: "ts.add", handler: tsAdd, arity: -4,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryTimeSeries, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "timeseries", since: "1.0.0", summary: "Append a sample to a time series.",
+	},
+	&command{
+		name: "ts.create", handler: tsCreate, arity: -2,
+		flags:      []string{flagWrite, flagDenyOOM, flagFast},
+		categories: []string{categoryWrite, categoryTimeSeries, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "timeseries", since: "1.0.0", summary: "Create a new time series.",
+	},
+	&command{
+		name: "ts.get", handler: tsGet, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryTimeSeries, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "timeseries", since: "1.0.0", summary: "Get the sample with the highest timestamp from a given time series.",
+	},
+	&command{
+		name: "ts.info", handler: tsInfo, arity: -2,
+		flags:      []string{flagReadonly, flagFast},
+		categories: []string{categoryRead, categoryTimeSeries, categoryFast},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "timeseries", since: "1.0.0", summary: "Returns information and statistics for a time series.",
+	},
+	&command{
+		name: "ts.mrange", clientHandler: tsMRange, arity: -5,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryTimeSeries, categorySlow},
+		group:      "timeseries", since: "1.0.0", summary: "Query a range across multiple time series by filters in forward direction.",
+	},
+	&command{
+		name: "ts.mrevrange", clientHandler: tsMRevRange, arity: -5,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryTimeSeries, categorySlow},
+		group:      "timeseries", since: "1.0.0", summary: "Query a range across multiple time series by filters in reverse direction.",
+	},
+	&command{
+		name: "ts.range", handler: tsRange, arity: -4,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryTimeSeries, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "timeseries", since: "1.0.0", summary: "Query a range in forward direction.",
+	},
+	&command{
+		name: "ts.revrange", handler: tsRevRange, arity: -4,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categoryTimeSeries, categorySlow},
+		firstKey:   1, lastKey: 1, step: 1,
+		group: "timeseries", since: "1.0.0", summary: "Query a range in reverse direction.",
+	},
+	// commands available for search indexes only
+	&command{
+		name: "ft._list", handler: ftList, arity: 1,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categorySearch, categorySlow},
+		group:      "search", since: "1.0.0", summary: "Returns a list of all existing indexes.",
+	},
+	&command{
+		name: "ft.create", clientHandler: ftCreate, arity: -5,
+		flags:      []string{flagWrite, flagDenyOOM},
+		categories: []string{categoryWrite, categorySearch, categorySlow},
+		group:      "search", since: "1.0.0", summary: "Creates an index with the given spec.",
+	},
+	&command{
+		name: "ft.dropindex", clientHandler: ftDropIndex, arity: -2,
+		flags:      []string{flagWrite},
+		categories: []string{categoryWrite, categorySearch, categorySlow},
+		group:      "search", since: "1.0.0", summary: "Deletes the index.",
+	},
+	&command{
+		name: "ft.search", clientHandler: ftSearch, arity: -3,
+		flags:      []string{flagReadonly},
+		categories: []string{categoryRead, categorySearch, categorySlow},
+		group:      "search", since: "1.0.0", summary: "Searches the index with a textual query, returning either documents or just ids.",
+	},
+)
+
+// CommandTable is a table of built-in and custom commands along with their statistics.
+//
+// Every server has its own table, so custom commands and statistics are not shared among servers.
+type CommandTable struct {
+	mu       sync.RWMutex
+	commands map[string]*command
+}
+
+// NewCommandTable initializes a table of built-in commands
+func NewCommandTable() *CommandTable {
+	t := &CommandTable{commands: make(map[string]*command, len(builtinCommands))}
+	for name, c := range builtinCommands {
+		t.commands[name] = c.clone()
+	}
+	return t
+}
+
+// clone copies the command and its subcommands with empty statistics
+func (c *command) clone() *command {
+	cc := *c
+	cc.stats = new(commandStats)
+	if c.subcommands != nil {
+		cc.subcommands = make(map[string]*command, len(c.subcommands))
+		for name, subcommand := range c.subcommands {
+			cc.subcommands[name] = subcommand.clone()
+		}
+	}
+	return &cc
+}
+
+// get gets a command, but not a subcommand, by its case insensitive name
+func (t *CommandTable) get(name string) *command {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.commands[strings.ToLower(name)]
+}
+
+// lookup finds the command, or the subcommand if the command has subcommands,
+// and validates the number of arguments.
+//
+// The command is returned along with the error of a wrong number of arguments.
+func (t *CommandTable) lookup(args [][]byte) (*command, error) {
+	c := t.get(string(args[0]))
+	if c == nil {
+		return nil, newErrUnknownCommand(args[0], args[1:])
+	}
+	if c.subcommands != nil && len(args) > 1 {
+		subcommand, ok := c.subcommands[strings.ToLower(string(args[1]))]
+		if !ok {
+			return nil, newError("ERR unknown subcommand '%.128s'. Try %s HELP.", args[1], strings.ToUpper(c.name))
+		}
+		c = subcommand
+	}
+	if !c.acceptArguments(len(args)) {
+		return c, newErrWrongNumberOfArguments(c.name)
+	}
+	return c, nil
+}
+
+// lookupName finds a command by its name, such as "get" or "acl|setuser"
+func (t *CommandTable) lookupName(name string) *command {
+	commandName, subcommand, ok := strings.Cut(strings.ToLower(name), "|")
+	c := t.get(commandName)
+	if !ok || c == nil {
+		return c
+	}
+	return c.subcommands[subcommand]
+}
+
+// sorted lists commands in the table ordered by names
+func (t *CommandTable) sorted() []*command {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return sortedCommands(t.commands)
+}
+
+// sortedCommands lists commands in a table ordered by names
+func sortedCommands(table map[string]*command) []*command {
+	list := make([]*command, 0, len(table))
+	for _, c := range table {
+		list = append(list, c)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].name < list[j].name
+	})
+	return list
+}
+
+// acceptArguments tells whether the command accepts n arguments including the name
+func (c *command) acceptArguments(n int) bool {
+	if c.arity >= 0 {
+		return n == c.arity
+	}
+	return n >= -c.arity
+}
+
+// hasFlag tells whether the command has a flag
+func (c *command) hasFlag(flag string) bool {
+	for _, f := range c.flags {
+		if f == flag {
+			return true
+		}
+	}
+	return false
+}
+
+// hasCategory tells whether the command belongs to an ACL category
+func (c *command) hasCategory(category string) bool {
+	for _, cat := range c.categories {
+		if cat == category {
+			return true
+		}
+	}
+	return false
+}
+
+// commandKeys extracts keys from the arguments of the command, including the command name
+func (c *command) commandKeys(args [][]byte) [][]byte {
+	if c.keys != nil {
+		return c.keys(args)
+	}
+	if c.firstKey == 0 || c.firstKey >= len(args) {
+		return nil
+	}
+	last := c.lastKey
+	if last < 0 {
+		last += len(args)
+	}
+	if last >= len(args) {
+		last = len(args) - 1
+	}
+
+	keys := make([][]byte, 0)
+	for i := c.firstKey; i <= last; i += c.step {
+		keys = append(keys, args[i])
+	}
+	return keys
+}
+
+// commandChannels extracts channels from the arguments of the command, including the command name
+func (c *command) commandChannels(args [][]byte) [][]byte {
+	if !c.channels || len(args) < 2 {
+		return nil
+	}
+	if c.name == "spublish" {
+		return args[1:2]
+	}
+	return args[1:]
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// commandStats counts executions of a command
+type commandStats struct {
+	calls         atomic.Int64
+	usec          atomic.Int64
+	rejectedCalls atomic.Int64 // calls rejected before execution, such as by arity or ACL checks
+	failedCalls   atomic.Int64 // calls failed with errors during execution
+}
+
+// record records an execution of the command
+func (stats *commandStats) record(duration time.Duration, failed bool) {
+	stats.calls.Add(1)
+	stats.usec.Add(duration.Microseconds())
+	if failed {
+		stats.failedCalls.Add(1)
+	}
+}
+
+// CommandStats is statistics of a command, like INFO commandstats of Redis
+type CommandStats struct {
+	Name          string
+	Calls         int64
+	Usec          int64
+	RejectedCalls int64
+	FailedCalls   int64
+}
+
+// Stats lists statistics of commands and subcommands in the table that have been called or rejected
+func (t *CommandTable) Stats() []CommandStats {
+	list := make([]CommandStats, 0)
+	appendStats := func(c *command) {
+		stats := CommandStats{
+			Name:          c.name,
+			Calls:         c.stats.calls.Load(),
+			Usec:          c.stats.usec.Load(),
+			RejectedCalls: c.stats.rejectedCalls.Load(),
+			FailedCalls:   c.stats.failedCalls.Load(),
+		}
+		if stats.Calls > 0 || stats.RejectedCalls > 0 {
+			list = append(list, stats)
+		}
+	}
+	for _, c := range t.sorted() {
+		appendStats(c)
+		for _, subcommand := range c.subcommands {
+			appendStats(subcommand)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
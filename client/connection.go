@@ -0,0 +1,132 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/tidwall/redcon"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+const (
+	// redisVersion is the version of Redis the server is compatible with
+	redisVersion = "7.0.0"
+
+	// defaultUsername is the user every connection is authenticated as
+	defaultUsername = "default"
+)
+
+// auth executes AUTH [username] password
+func (client *RedisClient) auth(conn redcon.Conn, args [][]byte) {
+	var username, password string
+	switch len(args) {
+	case 1:
+		username, password = defaultUsername, string(args[0])
+	case 2:
+		username, password = string(args[0]), string(args[1])
+	default:
+		conn.WriteError(newErrWrongNumberOfArguments("auth").Error())
+		return
+	}
+
+	if len(args) == 1 && client.ACL.defaultNoPass() {
+		conn.WriteError(errAuthWithoutPassword.Error())
+		return
+	}
+	if err := client.authenticate(conn, username, password); err != nil {
+		conn.WriteError(errorMessage(err))
+		return
+	}
+	conn.WriteString("OK")
+}
+
+// authenticate authenticates the client as a user
+func (client *RedisClient) authenticate(conn redcon.Conn, username, password string) error {
+	if err := client.ACL.verify(client.authSource(conn), username, password); err != nil {
+		if err == errWrongPass {
+			client.ACL.addLog("auth", "AUTH", username, client, conn.RemoteAddr())
+		}
+		return err
+	}
+	client.username = username
+	client.authenticated = true
+	return nil
+}
+
+// hello executes HELLO [protover [AUTH username password] [SETNAME clientname]]
+func (client *RedisClient) hello(conn redcon.Conn, args [][]byte) {
+	var authArgs [][]byte
+	var name []byte
+	protocol := client.Protocol()
+	if len(args) > 0 {
+		var err error
+		protocol, err = strconv.Atoi(string(args[0]))
+		if err != nil {
+			conn.WriteError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+		if protocol != resp2 && protocol != resp3 {
+			conn.WriteError(errNoProto.Error())
+			return
+		}
+
+		for i := 1; i < len(args); i++ {
+			option := strings.ToLower(string(args[i]))
+			switch {
+			case option == "auth" && i+2 < len(args):
+				authArgs = args[i+1 : i+3]
+				i += 2
+			case option == "setname" && i+1 < len(args):
+				name = args[i+1]
+				i++
+			default:
+				conn.WriteError(errSyntax.Error())
+				return
+			}
+		}
+	}
+
+	if authArgs != nil {
+		if err := client.authenticate(conn, string(authArgs[0]), string(authArgs[1])); err != nil {
+			conn.WriteError(errorMessage(err))
+			return
+		}
+	}
+	if !client.Authenticated() {
+		conn.WriteError(errHelloNoAuth.Error())
+		return
+	}
+	if name != nil {
+		client.Name = string(name)
+	}
+	client.protocol = protocol
+
+	w := NewReplyWriter(conn)
+	w.WriteMap(7)
+	w.WriteBulkString("server")
+	w.WriteBulkString("redis")
+	w.WriteBulkString("version")
+	w.WriteBulkString(redisVersion)
+	w.WriteBulkString("proto")
+	w.WriteInt(protocol)
+	w.WriteBulkString("id")
+	w.WriteInt64(client.ID)
+	w.WriteBulkString("mode")
+	w.WriteBulkString("standalone")
+	w.WriteBulkString("role")
+	w.WriteBulkString("master")
+	w.WriteBulkString("modules")
+	w.WriteArray(0)
+}
+
+func ping(ds *ds.DS, args ...[]byte) (Reply, error) {
+	switch len(args) {
+	case 0:
+		return simpleStringReply("PONG"), nil
+	case 1:
+		return bulkReply(args[0]), nil
+	default:
+		return nil, newErrWrongNumberOfArguments("ping")
+	}
+}
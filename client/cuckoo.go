@@ -0,0 +1,198 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// parseCuckooOption parses the value of an option of CF.RESERVE and CF.INSERT
+func parseCuckooOption(opts *ds.CuckooOptions, option string, arg []byte) error {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	switch option {
+	case "CAPACITY":
+		if err != nil || n <= 0 {
+			return newError("ERR Bad capacity")
+		}
+		opts.Capacity = uint64(n)
+	case "BUCKETSIZE":
+		if err != nil || n <= 0 || n > 255 {
+			return newError("ERR Bad bucket size")
+		}
+		opts.BucketSize = uint32(n)
+	case "MAXITERATIONS":
+		if err != nil || n <= 0 || n > 65535 {
+			return newError("ERR Bad max iterations")
+		}
+		opts.MaxIterations = uint32(n)
+	case "EXPANSION":
+		if err != nil || n < 0 || n > 32768 {
+			return newError("ERR Bad expansion")
+		}
+		opts.Expansion = uint32(n)
+	}
+	return nil
+}
+
+// cfReserve executes CF.RESERVE key capacity [BUCKETSIZE bucketsize] [MAXITERATIONS maxiterations] [EXPANSION expansion]
+func cfReserve(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	opts := ds.DefaultCuckooOptions
+	if err := parseCuckooOption(&opts, "CAPACITY", args[1]); err != nil {
+		return nil, err
+	}
+	for i := 2; i < len(args); i += 2 {
+		option := strings.ToUpper(string(args[i]))
+		switch option {
+		case "BUCKETSIZE", "MAXITERATIONS", "EXPANSION":
+		default:
+			return nil, errSyntax
+		}
+		if i+1 >= len(args) {
+			return nil, errSyntax
+		}
+		if err := parseCuckooOption(&opts, option, args[i+1]); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Capacity < uint64(opts.BucketSize)*2 {
+		return nil, newError("ERR Capacity must be at least (BucketSize * 2)")
+	}
+
+	if err := rds.CFReserve(key, opts); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// cfAddItem adds an item, and fails if the filter is full
+func cfAddItem(rds *ds.DS, key, item []byte, nx bool) (Reply, error) {
+	added, err := rds.CFInsert(key, ds.DefaultCuckooOptions, false, nx, item)
+	if err != nil {
+		return nil, err
+	}
+	if added[0] < 0 {
+		return nil, ds.ErrCuckooFull
+	}
+	return boolReply(added[0] == 1), nil
+}
+
+// cfAdd executes CF.ADD key item
+func cfAdd(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return cfAddItem(rds, args[0], args[1], false)
+}
+
+// cfAddNx executes CF.ADDNX key item
+func cfAddNx(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return cfAddItem(rds, args[0], args[1], true)
+}
+
+// cfInsertItems parses options of CF.INSERT and CF.INSERTNX, and adds items
+func cfInsertItems(rds *ds.DS, commandName string, nx bool, args [][]byte) (Reply, error) {
+	key := args[0]
+	opts := ds.DefaultCuckooOptions
+	var noCreate bool
+	i := 1
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "ITEMS" {
+			break
+		}
+		switch option {
+		case "NOCREATE":
+			noCreate = true
+		case "CAPACITY":
+			if i+1 >= len(args) {
+				return nil, errSyntax
+			}
+			i++
+			if err := parseCuckooOption(&opts, option, args[i]); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errSyntax
+		}
+	}
+	if i+1 >= len(args) {
+		return nil, newErrWrongNumberOfArguments(commandName)
+	}
+
+	added, err := rds.CFInsert(key, opts, noCreate, nx, args[i+1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(added))
+	for i, a := range added {
+		replies[i] = integerReply(a)
+	}
+	return replies, nil
+}
+
+// cfInsert executes CF.INSERT key [CAPACITY capacity] [NOCREATE] ITEMS item [item ...]
+func cfInsert(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return cfInsertItems(rds, "cf.insert", false, args)
+}
+
+// cfInsertNx executes CF.INSERTNX key [CAPACITY capacity] [NOCREATE] ITEMS item [item ...]
+func cfInsertNx(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return cfInsertItems(rds, "cf.insertnx", true, args)
+}
+
+// cfExists executes CF.EXISTS key item
+func cfExists(rds *ds.DS, args ...[]byte) (Reply, error) {
+	results, err := rds.CFExists(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return boolReply(results[0]), nil
+}
+
+// cfMExists executes CF.MEXISTS key item [item ...]
+func cfMExists(rds *ds.DS, args ...[]byte) (Reply, error) {
+	results, err := rds.CFExists(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(results))
+	for i, ok := range results {
+		replies[i] = boolReply(ok)
+	}
+	return replies, nil
+}
+
+// cfCount executes CF.COUNT key item
+func cfCount(rds *ds.DS, args ...[]byte) (Reply, error) {
+	n, err := rds.CFCount(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// cfDel executes CF.DEL key item
+func cfDel(rds *ds.DS, args ...[]byte) (Reply, error) {
+	ok, err := rds.CFDel(args[0], args[1])
+	if err != nil {
+		return nil, err
+	}
+	return boolReply(ok), nil
+}
+
+// cfInfo executes CF.INFO key
+func cfInfo(rds *ds.DS, args ...[]byte) (Reply, error) {
+	info, err := rds.CFInfo(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mapReply{
+		bulkReply("Size"), integerReply(info.Size),
+		bulkReply("Number of buckets"), integerReply(info.Buckets),
+		bulkReply("Number of filters"), integerReply(info.Filters),
+		bulkReply("Number of items inserted"), integerReply(info.Items),
+		bulkReply("Number of items deleted"), integerReply(info.Deleted),
+		bulkReply("Bucket size"), integerReply(info.BucketSize),
+		bulkReply("Expansion rate"), integerReply(info.Expansion),
+		bulkReply("Max iterations"), integerReply(info.MaxIterations),
+	}, nil
+}
@@ -0,0 +1,101 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// CommandFunc executes a custom command.
+//
+// The returned reply is written to the client, and a nil reply is written as OK.
+// The returned error is written as a Redis error, prefixed with ERR unless it has an error code.
+type CommandFunc func(ctx *CommandContext) (Reply, error)
+
+// CommandContext is the context of an execution of a custom command
+type CommandContext struct {
+	Client *RedisClient
+	DB     *ds.DS
+	Args   [][]byte // arguments excluding the command name
+
+	batch *ds.Batch
+}
+
+// Batch gets the write batch of the execution.
+//
+// The batch is committed after the command succeeds, and discarded if the command fails.
+// It makes writes of the command atomic but not isolated:
+// other clients may write the same keys between reads of the command and the commit,
+// so a value checked by the command may have changed when the batch is committed.
+func (ctx *CommandContext) Batch() *ds.Batch {
+	if ctx.batch == nil {
+		ctx.batch = ctx.DB.NewBatch()
+	}
+	return ctx.batch
+}
+
+// Command describes a custom command
+type Command struct {
+	Name    string // case insensitive, such as RESERVE.SEAT
+	Handler CommandFunc
+
+	Arity      int      // number of arguments including the name, negative values for the minimum number
+	Flags      []string // flags replied by COMMAND INFO, such as write, readonly, denyoom, admin, noscript and fast
+	Categories []string // ACL categories without @, such as write, string and fast
+	FirstKey   int      // position of the first key, 0 if the command has no key
+	LastKey    int      // position of the last key, negative values count from the end
+	Step       int      // step between keys
+
+	// documentation replied by COMMAND DOCS
+	Group   string
+	Since   string
+	Summary string
+}
+
+// Register registers a custom command, which is checked by ACLs and listed by COMMAND like built-in ones.
+//
+// A custom command could be registered again to replace the previous one,
+// while built-in commands could not be replaced.
+func (t *CommandTable) Register(cmd Command) error {
+	name := strings.ToLower(cmd.Name)
+	if name == "" || strings.ContainsAny(name, "| \t\r\n") {
+		return fmt.Errorf("invalid command name: %q", cmd.Name)
+	}
+	if _, ok := builtinCommands[name]; ok {
+		return fmt.Errorf("built-in command %s could not be replaced", name)
+	}
+	if cmd.Handler == nil {
+		return fmt.Errorf("no handler of command %s", name)
+	}
+	if cmd.Arity == 0 {
+		return fmt.Errorf("invalid arity of command %s: 0", name)
+	}
+	for _, category := range cmd.Categories {
+		if !isACLCategory(category) {
+			return fmt.Errorf("unknown ACL category of command %s: %s", name, category)
+		}
+	}
+	if cmd.FirstKey < 0 || (cmd.FirstKey > 0 && cmd.Step <= 0) {
+		return fmt.Errorf("invalid key positions of command %s", name)
+	}
+
+	c := &command{
+		name:       name,
+		custom:     cmd.Handler,
+		arity:      cmd.Arity,
+		flags:      cmd.Flags,
+		categories: cmd.Categories,
+		firstKey:   cmd.FirstKey,
+		lastKey:    cmd.LastKey,
+		step:       cmd.Step,
+		group:      cmd.Group,
+		since:      cmd.Since,
+		summary:    cmd.Summary,
+		stats:      new(commandStats),
+	}
+	t.mu.Lock()
+	t.commands[name] = c
+	t.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testingCustomCommand = Command{
+	Name:       "Reserve.Seat",
+	Handler:    func(ctx *CommandContext) (Reply, error) { return nil, nil },
+	Arity:      3,
+	Flags:      []string{flagWrite, flagDenyOOM},
+	Categories: []string{"write", "string"},
+	FirstKey:   1,
+	LastKey:    1,
+	Step:       1,
+}
+
+func TestCommandTable_Register(t *testing.T) {
+	table := NewCommandTable()
+	assert.Nil(t, table.Register(testingCustomCommand))
+	assert.NotNil(t, table.lookupName("reserve.seat"))
+
+	// a custom command could be replaced, while built-in commands could not
+	replacement := testingCustomCommand
+	replacement.Arity = -2
+	assert.Nil(t, table.Register(replacement))
+	assert.Equal(t, -2, table.lookupName("reserve.seat").arity)
+	for _, name := range []string{"get", "SET", "acl"} {
+		cmd := testingCustomCommand
+		cmd.Name = name
+		assert.NotNil(t, table.Register(cmd), name)
+	}
+
+	// invalid commands are rejected
+	invalid := []func(cmd *Command){
+		func(cmd *Command) { cmd.Name = "" },
+		func(cmd *Command) { cmd.Name = "acl|seat" },
+		func(cmd *Command) { cmd.Name = "reserve seat" },
+		func(cmd *Command) { cmd.Handler = nil },
+		func(cmd *Command) { cmd.Arity = 0 },
+		func(cmd *Command) { cmd.Categories = []string{"seats"} },
+		func(cmd *Command) { cmd.FirstKey = -1 },
+		func(cmd *Command) { cmd.Step = 0 },
+	}
+	for i, modify := range invalid {
+		cmd := testingCustomCommand
+		cmd.Name = "invalid.seat"
+		modify(&cmd)
+		assert.NotNil(t, table.Register(cmd), i)
+	}
+	assert.Nil(t, table.lookupName("invalid.seat"))
+
+	// commands are only registered in their own table, along with their own statistics
+	other := NewCommandTable()
+	assert.Nil(t, other.lookupName("reserve.seat"))
+	_, err := other.lookup([][]byte{[]byte("reserve.seat"), []byte("a"), []byte("b")})
+	assert.NotNil(t, err)
+	c, err := table.lookup([][]byte{[]byte("RESERVE.SEAT"), []byte("a"), []byte("b")})
+	assert.Nil(t, err)
+	c.stats.calls.Add(1)
+	table.get("get").stats.calls.Add(1)
+	assert.Equal(t, []CommandStats{{Name: "get", Calls: 1}, {Name: "reserve.seat", Calls: 1}}, table.Stats())
+	assert.Equal(t, []CommandStats{}, other.Stats())
+}
+
+func TestCommandTable_ACL(t *testing.T) {
+	table := NewCommandTable()
+	assert.Nil(t, table.Register(testingCustomCommand))
+	acl := NewACL("", table)
+
+	// rules could refer to custom commands of the table only
+	assert.NotNil(t, NewACL("", NewCommandTable()).setUser("alice", []string{"+reserve.seat"}))
+	assert.Nil(t, acl.setUser("alice", []string{"on", "nopass", "+reserve.seat"}))
+	assert.Nil(t, acl.setUser("bob", []string{"on", "nopass", "+@string", "-@write"}))
+	assert.Nil(t, acl.setUser("carol", []string{"on", "nopass", "+@write"}))
+
+	c := table.lookupName("reserve.seat")
+	assert.True(t, acl.user("alice").canExecute(c))
+	assert.False(t, acl.user("bob").canExecute(c))
+	assert.True(t, acl.user("carol").canExecute(c))
+	assert.True(t, acl.user(defaultUsername).canExecute(c))
+}
+
+func TestCommandTable_Info(t *testing.T) {
+	table := NewCommandTable()
+	cmd := testingCustomCommand
+	cmd.Group, cmd.Since, cmd.Summary = "seats", "1.0.0", "Reserves a seat."
+	assert.Nil(t, table.Register(cmd))
+
+	c := table.lookupName("reserve.seat")
+	assert.Equal(t, arrayReply{
+		bulkReply("reserve.seat"),
+		integerReply(3),
+		setReply{simpleStringReply("write"), simpleStringReply("denyoom")},
+		integerReply(1),
+		integerReply(1),
+		integerReply(1),
+		setReply{simpleStringReply("@write"), simpleStringReply("@string")},
+		arrayReply{},
+		c.keySpecs(),
+		arrayReply{},
+	}, c.info())
+	assert.Equal(t, setReply{simpleStringReply("RW"), simpleStringReply("UPDATE")}, c.keySpecs()[0].(mapReply)[1])
+
+	// custom commands are listed and counted along with built-in ones
+	assert.Contains(t, table.commandList([][]byte{[]byte("filterby"), []byte("pattern"), []byte("reserve.*")}), bulkReply("reserve.seat"))
+	assert.Equal(t, len(NewCommandTable().sorted())+1, len(table.sorted()))
+	assert.Equal(t, arrayReply{bulkReply("k")}, table.commandGetKeys([][]byte{[]byte("reserve.seat"), []byte("k"), []byte("v")}))
+}
@@ -3,6 +3,11 @@ package client
 import (
 	"errors"
 	"fmt"
+	"strings"
+
+	"github.com/saint-yellow/baradb"
+
+	"github.com/saint-yellow/baradb-redis/ds"
 )
 
 func newError(message string, argumrnts ...any) error {
@@ -15,3 +20,63 @@ func newError(message string, argumrnts ...any) error {
 func newErrWrongNumberOfArguments(commandName string) error {
 	return newError("ERR wrong number of arguments for '%s' command", commandName)
 }
+
+func newErrInvalidExpireTime(commandName string) error {
+	return newError("ERR invalid expire time in '%s' command", commandName)
+}
+
+func newErrUnknownCommand(commandName []byte, arguments [][]byte) error {
+	var builder strings.Builder
+	for _, argument := range arguments {
+		builder.WriteString(fmt.Sprintf("'%.128s' ", argument))
+	}
+	return newError("ERR unknown command '%.128s', with args beginning with: %s", commandName, builder.String())
+}
+
+// errorMessage converts an error to a Redis error message, which always starts with an error code
+func errorMessage(err error) string {
+	var dsErr *ds.Error
+	if errors.As(err, &dsErr) {
+		return dsErr.Error()
+	}
+	if errors.Is(err, baradb.ErrNoMoreDiskSpace) {
+		return ds.CodeOOM + " command not allowed when there is no more disk space"
+	}
+
+	message := err.Error()
+	if hasErrorCode(message) {
+		return message
+	}
+	return ds.CodeErr + " " + message
+}
+
+// hasErrorCode tells whether a message starts with an error code, which is an upper case word
+func hasErrorCode(message string) bool {
+	code, _, found := strings.Cut(message, " ")
+	if !found || code == "" {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	errUserRevoked         = newError("ERR the user of the client has been deleted or disabled")
+	errNoAuth              = newError("NOAUTH Authentication required.")
+	errWrongPass           = newError("WRONGPASS invalid username-password pair or user is disabled.")
+	errAuthWithoutPassword = newError("ERR AUTH <password> called without any password configured for the default user. Are you sure your configuration is correct?")
+	errTooManyAuthFailures = newError("ERR too many failed authentication attempts, try again later")
+	errHelloNoAuth         = newError("NOAUTH HELLO must be called with the client already authenticated, otherwise the HELLO <proto> AUTH <user> <pass> option can be used to authenticate the client and select the RESP protocol version at the same time")
+	errNoProto             = newError("NOPROTO unsupported protocol version")
+	errSyntax              = newError("ERR syntax error")
+	errNoPermKey           = newError("NOPERM No permissions to access a key")
+	errNoPermChannel       = newError("NOPERM No permissions to access a channel")
+	errReservedKey         = newError("ERR the key is reserved for internal use")
+	errInvalidPasswordHash = newError("ERR The password hash must be exactly 64 characters and contain only lowercase hexadecimal characters")
+	errNoACLFile           = newError("ERR This Redis instance is not configured to use an ACL file. You may want to specify users via the ACL SETUSER command and then issue a CONFIG REWRITE (assuming you have a Redis configuration file set) in order to store users in the Redis configuration.")
+	errDeleteDefaultUser   = newError("ERR The 'default' user cannot be removed")
+)
@@ -1,38 +1,71 @@
 package client
 
-import "github.com/saint-yellow/baradb-redis/ds"
+import (
+	"github.com/saint-yellow/baradb"
 
-func del(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("del")
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+func del(rds *ds.DS, args ...[]byte) (Reply, error) {
+	var count int
+	for _, key := range args {
+		exists := rds.Exists(key)
+		if err := rds.Del(key); err != nil {
+			return nil, err
+		}
+		if exists {
+			count++
+		}
 	}
+	return integerReply(count), nil
+}
 
-	key := args[0]
-	return nil, ds.Del(key)
+func unlink(rds *ds.DS, args ...[]byte) (Reply, error) {
+	var count int
+	for _, key := range args {
+		exists, err := rds.Unlink(key)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			count++
+		}
+	}
+	return integerReply(count), nil
 }
 
-func datatype(rds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("type")
+func touch(rds *ds.DS, args ...[]byte) (Reply, error) {
+	var count int
+	for _, key := range args {
+		if rds.Touch(key) {
+			count++
+		}
 	}
+	return integerReply(count), nil
+}
 
+func datatype(rds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
 	dt, err := rds.Type(key)
+	if err == baradb.ErrKeyNotFound {
+		return simpleStringReply("none"), nil
+	}
 	if err != nil {
 		return nil, err
 	}
 	dtn := dataTypeName(dt)
-	return dtn, nil
+	return simpleStringReply(dtn), nil
 }
 
-func exists(rds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("exists")
+func exists(rds *ds.DS, args ...[]byte) (Reply, error) {
+	// a key given multiple times is counted multiple times
+	var count int
+	for _, key := range args {
+		if rds.Exists(key) {
+			count++
+		}
 	}
-
-	key := args[0]
-	exists := rds.Exists(key)
-	return exists, nil
+	return integerReply(count), nil
 }
 
 func dataTypeName(dt byte) string {
@@ -47,6 +80,20 @@ func dataTypeName(dt byte) string {
 		return "list"
 	case ds.ZSet:
 		return "zset"
+	case ds.Stream:
+		return "stream"
+	case ds.JSON:
+		return "ReJSON-RL"
+	case ds.Bloom:
+		return "MBbloom--"
+	case ds.Cuckoo:
+		return "MBbloomCF"
+	case ds.CountMinSketch:
+		return "CMSk-TYPE"
+	case ds.TopK:
+		return "TopK-TYPE"
+	case ds.TimeSeries:
+		return "TSDB-TYPE"
 	default:
 		return "unknown data type"
 	}
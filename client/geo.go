@@ -0,0 +1,301 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// geoadd executes GEOADD key [NX | XX] [CH] longitude latitude member [longitude latitude member ...]
+func geoadd(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	var opts ds.GeoAddOptions
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		switch strings.ToLower(string(args[i])) {
+		case "nx":
+			opts.NX = true
+		case "xx":
+			opts.XX = true
+		case "ch":
+			opts.CH = true
+		default:
+			break options
+		}
+	}
+	if opts.NX && opts.XX {
+		return nil, newError("ERR XX and NX options at the same time are not compatible")
+	}
+	if i == len(args) || (len(args)-i)%3 != 0 {
+		return nil, errSyntax
+	}
+
+	locations := make([]ds.GeoLocation, 0, (len(args)-i)/3)
+	for ; i < len(args); i += 3 {
+		p, err := parseGeoPoint(args[i], args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		locations = append(locations, ds.GeoLocation{Member: args[i+2], GeoPoint: p})
+	}
+
+	n, err := rds.GeoAdd(key, opts, locations...)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// geopos executes GEOPOS key [member [member ...]]
+func geopos(rds *ds.DS, args ...[]byte) (Reply, error) {
+	points, err := rds.GeoPos(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(points))
+	for i, p := range points {
+		if p == nil {
+			replies[i] = nullArrayReply
+		} else {
+			replies[i] = geoPointReply(*p)
+		}
+	}
+	return replies, nil
+}
+
+// geodist executes GEODIST key member1 member2 [M | KM | FT | MI]
+func geodist(rds *ds.DS, args ...[]byte) (Reply, error) {
+	unit := 1.0
+	switch len(args) {
+	case 3:
+	case 4:
+		var err error
+		unit, err = parseGeoUnit(args[3])
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errSyntax
+	}
+
+	dist, ok, err := rds.GeoDist(args[0], args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nullBulkReply, nil
+	}
+	return geoDistanceReply(dist / unit), nil
+}
+
+// geohash executes GEOHASH key [member [member ...]]
+func geohash(rds *ds.DS, args ...[]byte) (Reply, error) {
+	hashes, err := rds.GeoHash(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(hashes))
+	for i, hash := range hashes {
+		if hash == nil {
+			replies[i] = nullBulkReply
+		} else {
+			replies[i] = bulkReply(hash)
+		}
+	}
+	return replies, nil
+}
+
+// geosearch executes GEOSEARCH key <FROMMEMBER member | FROMLONLAT longitude latitude>
+// <BYRADIUS radius <M | KM | FT | MI> | BYBOX width height <M | KM | FT | MI>>
+// [ASC | DESC] [COUNT count [ANY]] [WITHCOORD] [WITHDIST] [WITHHASH]
+func geosearch(rds *ds.DS, args ...[]byte) (Reply, error) {
+	search, err := parseGeoSearch(args[1:], false)
+	if err != nil {
+		return nil, err
+	}
+	locations, err := rds.GeoSearch(args[0], search.GeoSearchOptions)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(arrayReply, len(locations))
+	for i, location := range locations {
+		if !search.withCoord && !search.withDist && !search.withHash {
+			replies[i] = bulkReply(location.Member)
+			continue
+		}
+		reply := arrayReply{bulkReply(location.Member)}
+		if search.withDist {
+			reply = append(reply, geoDistanceReply(location.Dist))
+		}
+		if search.withHash {
+			reply = append(reply, integerReply(location.Hash))
+		}
+		if search.withCoord {
+			reply = append(reply, geoPointReply(location.GeoPoint))
+		}
+		replies[i] = reply
+	}
+	return replies, nil
+}
+
+// geosearchstore executes GEOSEARCHSTORE destination source <FROMMEMBER member | FROMLONLAT longitude latitude>
+// <BYRADIUS radius <M | KM | FT | MI> | BYBOX width height <M | KM | FT | MI>>
+// [ASC | DESC] [COUNT count [ANY]] [STOREDIST]
+func geosearchstore(rds *ds.DS, args ...[]byte) (Reply, error) {
+	search, err := parseGeoSearch(args[2:], true)
+	if err != nil {
+		return nil, err
+	}
+	n, err := rds.GeoSearchStore(args[0], args[1], search.GeoSearchOptions, search.storeDist)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// geoSearch is a parsed GEOSEARCH or GEOSEARCHSTORE
+type geoSearch struct {
+	ds.GeoSearchOptions
+	withCoord bool
+	withDist  bool
+	withHash  bool
+	storeDist bool
+}
+
+func parseGeoSearch(args [][]byte, store bool) (*geoSearch, error) {
+	search := &geoSearch{}
+	var fromMember, fromLonLat, byRadius, byBox, hasCount bool
+	for i := 0; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		left := len(args) - i - 1
+		var err error
+		switch {
+		case option == "frommember" && left >= 1:
+			search.FromMember = args[i+1]
+			fromMember = true
+			i++
+		case option == "fromlonlat" && left >= 2:
+			search.FromPoint, err = parseGeoPoint(args[i+1], args[i+2])
+			fromLonLat = true
+			i += 2
+		case option == "byradius" && left >= 2:
+			search.Radius, err = parseGeoNumber(args[i+1], "radius")
+			if err == nil && search.Radius < 0 {
+				err = newError("ERR radius cannot be negative")
+			}
+			if err == nil {
+				search.Unit, err = parseGeoUnit(args[i+2])
+			}
+			byRadius = true
+			i += 2
+		case option == "bybox" && left >= 3:
+			search.Width, err = parseGeoNumber(args[i+1], "width")
+			if err == nil {
+				search.Height, err = parseGeoNumber(args[i+2], "height")
+			}
+			if err == nil && (search.Width < 0 || search.Height < 0) {
+				err = newError("ERR height or width cannot be negative")
+			}
+			if err == nil {
+				search.Unit, err = parseGeoUnit(args[i+3])
+			}
+			search.ByBox = true
+			byBox = true
+			i += 3
+		case option == "asc":
+			search.Sort = ds.GeoAsc
+		case option == "desc":
+			search.Sort = ds.GeoDesc
+		case option == "count" && left >= 1:
+			var n int64
+			n, err = parseInteger(args[i+1])
+			if err == nil && n <= 0 {
+				err = newError("ERR COUNT must be > 0")
+			}
+			search.Count = int(n)
+			hasCount = true
+			i++
+			if left >= 2 && strings.ToLower(string(args[i+1])) == "any" {
+				search.Any = true
+				i++
+			}
+		case option == "any":
+			return nil, newError("ERR the ANY argument requires COUNT argument")
+		case option == "withcoord" && !store:
+			search.withCoord = true
+		case option == "withdist" && !store:
+			search.withDist = true
+		case option == "withhash" && !store:
+			search.withHash = true
+		case option == "storedist" && store:
+			search.storeDist = true
+		default:
+			return nil, errSyntax
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if fromMember == fromLonLat {
+		return nil, newError("ERR exactly one of FROMMEMBER or FROMLONLAT can be specified for geosearch")
+	}
+	if byRadius == byBox {
+		return nil, newError("ERR exactly one of BYRADIUS and BYBOX can be specified for geosearch")
+	}
+	if search.Any && !hasCount {
+		return nil, newError("ERR the ANY argument requires COUNT argument")
+	}
+	return search, nil
+}
+
+func parseGeoPoint(longitude, latitude []byte) (ds.GeoPoint, error) {
+	var p ds.GeoPoint
+	var err error
+	p.Longitude, err = strconv.ParseFloat(string(longitude), 64)
+	if err != nil {
+		return p, ds.ErrInvalidFloat
+	}
+	p.Latitude, err = strconv.ParseFloat(string(latitude), 64)
+	if err != nil {
+		return p, ds.ErrInvalidFloat
+	}
+	return p, nil
+}
+
+func parseGeoNumber(arg []byte, name string) (float64, error) {
+	n, err := strconv.ParseFloat(string(arg), 64)
+	if err != nil {
+		return 0, newError("ERR need numeric %s", name)
+	}
+	return n, nil
+}
+
+// parseGeoUnit parses a unit of distances, and returns meters of the unit
+func parseGeoUnit(arg []byte) (float64, error) {
+	switch strings.ToLower(string(arg)) {
+	case "m":
+		return 1, nil
+	case "km":
+		return 1000, nil
+	case "ft":
+		return 0.3048, nil
+	case "mi":
+		return 1609.34, nil
+	default:
+		return 0, newError("ERR unsupported unit provided. please use M, KM, FT, MI")
+	}
+}
+
+// geoPointReply replies a point as an array of its longitude and latitude
+func geoPointReply(p ds.GeoPoint) Reply {
+	return arrayReply{doubleReply(p.Longitude), doubleReply(p.Latitude)}
+}
+
+// geoDistanceReply replies a distance as a bulk string with 4 decimal places
+func geoDistanceReply(dist float64) Reply {
+	return bulkReply(strconv.FormatFloat(dist, 'f', 4, 64))
+}
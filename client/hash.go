@@ -0,0 +1,59 @@
+package client
+
+import "github.com/saint-yellow/baradb-redis/ds"
+
+func hset(ds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args)%2 != 1 {
+		return nil, newErrWrongNumberOfArguments("hset")
+	}
+
+	key := args[0]
+	var count int
+	for i := 1; i < len(args); i += 2 {
+		field, value := args[i], args[i+1]
+		isNew, err := ds.HSet(key, field, value)
+		if err != nil {
+			return nil, err
+		}
+		if isNew {
+			count++
+		}
+	}
+	return integerReply(count), nil
+}
+
+func hget(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key, field := args[0], args[1]
+	value, err := ds.HGet(key, field)
+	if err != nil {
+		return nil, err
+	}
+	if value == nil {
+		return nullBulkReply, nil
+	}
+	return bulkReply(value), nil
+}
+
+func hdel(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	var count int
+	for _, field := range args[1:] {
+		exist, err := ds.HDel(key, field)
+		if err != nil {
+			return nil, err
+		}
+		if exist {
+			count++
+		}
+	}
+	return integerReply(count), nil
+}
+
+func hgetall(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	pairs, err := ds.HGetAll(key)
+	if err != nil {
+		return nil, err
+	}
+	return mapReply(bulks(pairs)), nil
+}
@@ -0,0 +1,29 @@
+package client
+
+import "github.com/saint-yellow/baradb-redis/ds"
+
+// pfadd executes PFADD key [element [element ...]]
+func pfadd(rds *ds.DS, args ...[]byte) (Reply, error) {
+	changed, err := rds.PFAdd(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return boolToInteger(changed), nil
+}
+
+// pfcount executes PFCOUNT key [key ...]
+func pfcount(rds *ds.DS, args ...[]byte) (Reply, error) {
+	n, err := rds.PFCount(args...)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// pfmerge executes PFMERGE destkey [sourcekey [sourcekey ...]]
+func pfmerge(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if err := rds.PFMerge(args[0], args[1:]...); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
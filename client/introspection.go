@@ -0,0 +1,203 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/tidwall/match"
+	"github.com/tidwall/redcon"
+)
+
+// introspect executes COMMAND and its subcommands
+func (t *CommandTable) introspect(conn redcon.Conn, args [][]byte) {
+	w := NewReplyWriter(conn)
+	if len(args) == 0 {
+		commandInfos(t.sorted()).writeTo(w)
+		return
+	}
+
+	subcommand := strings.ToLower(string(args[0]))
+	args = args[1:]
+	switch subcommand {
+	case "count":
+		integerReply(len(t.sorted())).writeTo(w)
+	case "info":
+		if len(args) == 0 {
+			commandInfos(t.sorted()).writeTo(w)
+			return
+		}
+		infos := make(arrayReply, len(args))
+		for i, name := range args {
+			if c := t.lookupName(string(name)); c != nil {
+				infos[i] = c.info()
+			} else {
+				infos[i] = nullBulkReply
+			}
+		}
+		infos.writeTo(w)
+	case "docs":
+		list := t.sorted()
+		if len(args) > 0 {
+			list = list[:0]
+			for _, name := range args {
+				if c := t.lookupName(string(name)); c != nil {
+					list = append(list, c)
+				}
+			}
+		}
+		docs := make(mapReply, 0, len(list)*2)
+		for _, c := range list {
+			docs = append(docs, bulkReply(c.name), c.docs())
+		}
+		docs.writeTo(w)
+	case "getkeys":
+		t.commandGetKeys(args).writeTo(w)
+	case "list":
+		t.commandList(args).writeTo(w)
+	}
+}
+
+// commandInfos replies information of commands
+func commandInfos(list []*command) arrayReply {
+	infos := make(arrayReply, len(list))
+	for i, c := range list {
+		infos[i] = c.info()
+	}
+	return infos
+}
+
+// commandGetKeys executes COMMAND GETKEYS command [arg ...]
+func (t *CommandTable) commandGetKeys(args [][]byte) Reply {
+	c := t.get(string(args[0]))
+	if c == nil {
+		return errorReply("ERR Invalid command specified")
+	}
+	if c.subcommands != nil && len(args) > 1 {
+		if subcommand, ok := c.subcommands[strings.ToLower(string(args[1]))]; ok {
+			c = subcommand
+		}
+	}
+	if !c.acceptArguments(len(args)) {
+		return errorReply("ERR Invalid number of arguments specified for command")
+	}
+
+	keys := c.commandKeys(args)
+	if len(keys) == 0 {
+		return errorReply("ERR The command has no key arguments")
+	}
+	return arrayReply(bulks(keys))
+}
+
+// commandList executes COMMAND LIST [FILTERBY MODULE module-name | ACLCAT category | PATTERN pattern]
+func (t *CommandTable) commandList(args [][]byte) Reply {
+	filter := func(*command) bool { return true }
+	switch {
+	case len(args) == 0:
+	case len(args) == 3 && strings.ToLower(string(args[0])) == "filterby":
+		value := string(args[2])
+		switch strings.ToLower(string(args[1])) {
+		case "module":
+			// no command is provided by modules
+			filter = func(*command) bool { return false }
+		case "aclcat":
+			filter = func(c *command) bool { return c.hasCategory(strings.ToLower(value)) }
+		case "pattern":
+			filter = func(c *command) bool { return match.Match(c.name, strings.ToLower(value)) }
+		default:
+			return errorReply(errSyntax.Error())
+		}
+	default:
+		return errorReply(errSyntax.Error())
+	}
+
+	names := make(arrayReply, 0)
+	for _, c := range t.sorted() {
+		if filter(c) {
+			names = append(names, bulkReply(c.name))
+		}
+		for _, subcommand := range sortedCommands(c.subcommands) {
+			if filter(subcommand) {
+				names = append(names, bulkReply(subcommand.name))
+			}
+		}
+	}
+	return names
+}
+
+// info replies the command in the format of COMMAND INFO
+func (c *command) info() Reply {
+	flags := make(setReply, len(c.flags))
+	for i, flag := range c.flags {
+		flags[i] = simpleStringReply(flag)
+	}
+	categories := make(setReply, len(c.categories))
+	for i, category := range c.categories {
+		categories[i] = simpleStringReply("@" + category)
+	}
+
+	return arrayReply{
+		bulkReply(c.name),
+		integerReply(c.arity),
+		flags,
+		integerReply(c.firstKey),
+		integerReply(c.lastKey),
+		integerReply(c.step),
+		categories,
+		arrayReply{}, // tips
+		c.keySpecs(),
+		commandInfos(sortedCommands(c.subcommands)),
+	}
+}
+
+// keySpecs replies key specifications of the command, which describe the same keys as the key positions
+func (c *command) keySpecs() arrayReply {
+	if c.firstKey == 0 {
+		return arrayReply{}
+	}
+
+	flags := setReply{simpleStringReply("RO"), simpleStringReply("ACCESS")}
+	if c.hasFlag(flagWrite) {
+		flags = setReply{simpleStringReply("RW"), simpleStringReply("UPDATE")}
+	}
+	// the last key is relative to the first key in key specifications
+	lastKey := c.lastKey
+	if lastKey > 0 {
+		lastKey -= c.firstKey
+	}
+
+	return arrayReply{
+		mapReply{
+			bulkReply("flags"), flags,
+			bulkReply("begin_search"), mapReply{
+				bulkReply("type"), bulkReply("index"),
+				bulkReply("spec"), mapReply{
+					bulkReply("index"), integerReply(c.firstKey),
+				},
+			},
+			bulkReply("find_keys"), mapReply{
+				bulkReply("type"), bulkReply("range"),
+				bulkReply("spec"), mapReply{
+					bulkReply("lastkey"), integerReply(lastKey),
+					bulkReply("keystep"), integerReply(c.step),
+					bulkReply("limit"), integerReply(0),
+				},
+			},
+		},
+	}
+}
+
+// docs replies the command in the format of COMMAND DOCS
+func (c *command) docs() Reply {
+	docs := mapReply{
+		bulkReply("summary"), bulkReply(c.summary),
+		bulkReply("since"), bulkReply(c.since),
+		bulkReply("group"), bulkReply(c.group),
+	}
+	if c.subcommands != nil {
+		subcommands := make(mapReply, 0, len(c.subcommands)*2)
+		for _, subcommand := range sortedCommands(c.subcommands) {
+			subcommands = append(subcommands, bulkReply(subcommand.name), subcommand.docs())
+		}
+		docs = append(docs, bulkReply("subcommands"), subcommands)
+	}
+	return docs
+}
@@ -0,0 +1,89 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandTable_Introspect(t *testing.T) {
+	commands := NewCommandTable()
+	conn := newTestingConn(NewACL("", commands), "127.0.0.1:6379")
+
+	assert.Equal(t, ":"+strconv.Itoa(len(commands.sorted())), conn.execute("COMMAND", "COUNT"))
+	assert.Equal(t, "*"+strconv.Itoa(len(commands.sorted())), conn.execute("COMMAND"))
+
+	// COMMAND LIST includes subcommands
+	conn.execute("COMMAND", "LIST", "FILTERBY", "PATTERN", "xread*")
+	assert.Equal(t, "*2\r\n$5\r\nxread\r\n$10\r\nxreadgroup\r\n", string(conn.replies))
+	conn.execute("COMMAND", "LIST", "FILTERBY", "PATTERN", "command|c*")
+	assert.Equal(t, "*1\r\n$13\r\ncommand|count\r\n", string(conn.replies))
+	conn.execute("COMMAND", "LIST", "FILTERBY", "ACLCAT", "hyperloglog")
+	assert.Equal(t, "*3\r\n$5\r\npfadd\r\n$7\r\npfcount\r\n$7\r\npfmerge\r\n", string(conn.replies))
+	assert.Equal(t, "*0", conn.execute("COMMAND", "LIST", "FILTERBY", "MODULE", "search"))
+	assert.Equal(t, "-ERR syntax error", conn.execute("COMMAND", "LIST", "FILTERBY", "NAME", "get"))
+	assert.Equal(t, "-ERR syntax error", conn.execute("COMMAND", "LIST", "PATTERN"))
+
+	// COMMAND INFO replies nulls for unknown commands
+	assert.Equal(t, "*3", conn.execute("COMMAND", "INFO", "get", "nosuch", "command|count"))
+	info := string(conn.replies)
+	assert.True(t, strings.HasPrefix(info, "*3\r\n*10\r\n$3\r\nget\r\n:2\r\n*2\r\n+readonly\r\n+fast\r\n:1\r\n:1\r\n:1\r\n"), info)
+	assert.Contains(t, info, "\r\n$-1\r\n*10\r\n$13\r\ncommand|count\r\n:2\r\n")
+
+	// COMMAND DOCS replies a map, which is a flat array in RESP2
+	conn.execute("COMMAND", "DOCS", "get", "nosuch")
+	assert.Equal(t, "*2\r\n$3\r\nget\r\n*6\r\n"+
+		"$7\r\nsummary\r\n$34\r\nReturns the string value of a key.\r\n"+
+		"$5\r\nsince\r\n$5\r\n1.0.0\r\n"+
+		"$5\r\ngroup\r\n$6\r\nstring\r\n", string(conn.replies))
+	assert.Equal(t, "%7", conn.execute("HELLO", "3"))
+	assert.Equal(t, "%1", conn.execute("COMMAND", "DOCS", "get"))
+	assert.Equal(t, "%1", conn.execute("COMMAND", "DOCS", "command"))
+	assert.Contains(t, string(conn.replies), "$11\r\nsubcommands\r\n%5\r\n$13\r\ncommand|count\r\n")
+}
+
+func TestCommandTable_GetKeys(t *testing.T) {
+	conn := newTestingConn(NewACL("", NewCommandTable()), "127.0.0.1:6379")
+	tests := []struct {
+		args  []string
+		reply string
+	}{
+		{[]string{"SET", "k", "v"}, "*1\r\n$1\r\nk\r\n"},
+		{[]string{"MSET", "a", "1", "b", "2"}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"DEL", "a", "b", "c"}, "*3\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n"},
+		// keys of movable positions are extracted by the commands
+		{[]string{"XREAD", "COUNT", "1", "STREAMS", "a", "b", "0", "0"}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"XREADGROUP", "GROUP", "g", "c", "NOACK", "STREAMS", "a", ">"}, "*1\r\n$1\r\na\r\n"},
+		{[]string{"CMS.MERGE", "dest", "2", "a", "b", "WEIGHTS", "1", "2"}, "*3\r\n$4\r\ndest\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{[]string{"CMS.MERGE", "dest", "3", "a", "b"}, "-ERR The command has no key arguments\r\n"},
+		{[]string{"PING", "hello"}, "-ERR The command has no key arguments\r\n"},
+		{[]string{"GET"}, "-ERR Invalid number of arguments specified for command\r\n"},
+		{[]string{"NOSUCH", "k"}, "-ERR Invalid command specified\r\n"},
+	}
+	for _, tt := range tests {
+		conn.execute(append([]string{"COMMAND", "GETKEYS"}, tt.args...)...)
+		assert.Equal(t, tt.reply, string(conn.replies), strings.Join(tt.args, " "))
+	}
+}
+
+func TestCommandTable_Arity(t *testing.T) {
+	conn := newTestingConn(NewACL("", NewCommandTable()), "127.0.0.1:6379")
+	tests := []struct {
+		args  []string
+		reply string
+	}{
+		{[]string{"GET"}, "-ERR wrong number of arguments for 'get' command"},
+		{[]string{"GET", "a", "b"}, "-ERR wrong number of arguments for 'get' command"},
+		{[]string{"SET", "k"}, "-ERR wrong number of arguments for 'set' command"},
+		{[]string{"XREAD", "STREAMS", "a"}, "-ERR wrong number of arguments for 'xread' command"},
+		{[]string{"COMMAND", "COUNT", "x"}, "-ERR wrong number of arguments for 'command|count' command"},
+		{[]string{"COMMAND", "GETKEYS"}, "-ERR wrong number of arguments for 'command|getkeys' command"},
+		{[]string{"COMMAND", "nosuch"}, "-ERR unknown subcommand 'nosuch'. Try COMMAND HELP."},
+		{[]string{"NOSUCH", "a"}, "-ERR unknown command 'NOSUCH', with args beginning with: 'a' "},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.reply, conn.execute(tt.args...), strings.Join(tt.args, " "))
+	}
+}
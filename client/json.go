@@ -0,0 +1,292 @@
+package client
+
+import (
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// rootJSONPath is the default path of JSON commands, which is the legacy path of the root
+const rootJSONPath = "."
+
+// optionalJSONPath gets the path at the index of arguments, or the root if it is omitted
+func optionalJSONPath(args [][]byte, index int) string {
+	if index < len(args) {
+		return string(args[index])
+	}
+	return rootJSONPath
+}
+
+// jsonIntegersReply replies the only result of a legacy path as an integer, or results of a JSONPath as an array,
+// where nil stands for values of unexpected types
+func jsonIntegersReply(path string, results []*int64) Reply {
+	if ds.IsLegacyJSONPath(path) {
+		return integerReply(*results[0])
+	}
+	replies := make(arrayReply, len(results))
+	for i, n := range results {
+		if n == nil {
+			replies[i] = nullBulkReply
+			continue
+		}
+		replies[i] = integerReply(*n)
+	}
+	return replies
+}
+
+// jsonBulksReply replies the only result of a legacy path as a bulk string, or results of a JSONPath as an array
+func jsonBulksReply(path string, results [][]byte) Reply {
+	replies := make(arrayReply, len(results))
+	for i, result := range results {
+		if result == nil {
+			replies[i] = nullBulkReply
+			continue
+		}
+		replies[i] = bulkReply(result)
+	}
+	if ds.IsLegacyJSONPath(path) {
+		return replies[0]
+	}
+	return replies
+}
+
+// jsonSet executes JSON.SET key path value [NX | XX]
+func jsonSet(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key, path, value := args[0], string(args[1]), args[2]
+	var opts ds.JSONSetOptions
+	for _, arg := range args[3:] {
+		switch strings.ToUpper(string(arg)) {
+		case "NX":
+			opts.NX = true
+		case "XX":
+			opts.XX = true
+		default:
+			return nil, errSyntax
+		}
+	}
+	if opts.NX && opts.XX {
+		return nil, errSyntax
+	}
+
+	ok, err := rds.JSONSet(key, path, value, opts)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nullBulkReply, nil
+	}
+	return okReply, nil
+}
+
+// jsonGet executes JSON.GET key [INDENT indent] [NEWLINE newline] [SPACE space] [NOESCAPE] [path [path ...]]
+func jsonGet(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	var format ds.JSONFormat
+	i := 1
+	for ; i < len(args); i++ {
+		var option *string
+		switch strings.ToUpper(string(args[i])) {
+		case "INDENT":
+			option = &format.Indent
+		case "NEWLINE":
+			option = &format.Newline
+		case "SPACE":
+			option = &format.Space
+		case "NOESCAPE":
+			// kept for compatibility, strings are never escaped more than JSON requires
+			continue
+		}
+		if option == nil {
+			break
+		}
+		if i+1 >= len(args) {
+			return nil, errSyntax
+		}
+		i++
+		*option = string(args[i])
+	}
+
+	paths := make([]string, 0, len(args)-i)
+	for _, arg := range args[i:] {
+		paths = append(paths, string(arg))
+	}
+	value, err := rds.JSONGet(key, format, paths...)
+	if err != nil {
+		return nil, err
+	}
+	return bulkReply(value), nil
+}
+
+// jsonDel executes JSON.DEL key [path]
+func jsonDel(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 {
+		return nil, errSyntax
+	}
+	n, err := rds.JSONDel(args[0], optionalJSONPath(args, 1))
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// jsonNumIncrBy executes JSON.NUMINCRBY key path value
+func jsonNumIncrBy(rds *ds.DS, args ...[]byte) (Reply, error) {
+	value, err := rds.JSONNumIncrBy(args[0], string(args[1]), args[2])
+	if err != nil {
+		return nil, err
+	}
+	return bulkReply(value), nil
+}
+
+// jsonArrAppend executes JSON.ARRAPPEND key path value [value ...]
+func jsonArrAppend(rds *ds.DS, args ...[]byte) (Reply, error) {
+	path := string(args[1])
+	lengths, err := rds.JSONArrAppend(args[0], path, args[2:]...)
+	if err != nil {
+		return nil, err
+	}
+	return jsonIntegersReply(path, lengths), nil
+}
+
+// jsonArrInsert executes JSON.ARRINSERT key path index value [value ...]
+func jsonArrInsert(rds *ds.DS, args ...[]byte) (Reply, error) {
+	path := string(args[1])
+	index, err := parseInteger(args[2])
+	if err != nil {
+		return nil, err
+	}
+	lengths, err := rds.JSONArrInsert(args[0], path, index, args[3:]...)
+	if err != nil {
+		return nil, err
+	}
+	return jsonIntegersReply(path, lengths), nil
+}
+
+// jsonArrLen executes JSON.ARRLEN key [path]
+func jsonArrLen(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 {
+		return nil, errSyntax
+	}
+	path := optionalJSONPath(args, 1)
+	lengths, err := rds.JSONArrLen(args[0], path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonIntegersReply(path, lengths), nil
+}
+
+// jsonArrPop executes JSON.ARRPOP key [path [index]]
+func jsonArrPop(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 3 {
+		return nil, errSyntax
+	}
+	path := optionalJSONPath(args, 1)
+	index := int64(-1)
+	if len(args) == 3 {
+		var err error
+		if index, err = parseInteger(args[2]); err != nil {
+			return nil, err
+		}
+	}
+	popped, err := rds.JSONArrPop(args[0], path, index)
+	if err != nil {
+		return nil, err
+	}
+	return jsonBulksReply(path, popped), nil
+}
+
+// jsonArrTrim executes JSON.ARRTRIM key path start stop
+func jsonArrTrim(rds *ds.DS, args ...[]byte) (Reply, error) {
+	path := string(args[1])
+	start, err := parseInteger(args[2])
+	if err != nil {
+		return nil, err
+	}
+	stop, err := parseInteger(args[3])
+	if err != nil {
+		return nil, err
+	}
+	lengths, err := rds.JSONArrTrim(args[0], path, start, stop)
+	if err != nil {
+		return nil, err
+	}
+	return jsonIntegersReply(path, lengths), nil
+}
+
+// jsonObjKeys executes JSON.OBJKEYS key [path]
+func jsonObjKeys(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 {
+		return nil, errSyntax
+	}
+	path := optionalJSONPath(args, 1)
+	keys, err := rds.JSONObjKeys(args[0], path)
+	if err != nil {
+		return nil, err
+	}
+
+	replies := make(arrayReply, len(keys))
+	for i, objectKeys := range keys {
+		if objectKeys == nil {
+			replies[i] = nullArrayReply
+			continue
+		}
+		reply := make(arrayReply, len(objectKeys))
+		for j, k := range objectKeys {
+			reply[j] = bulkReply(k)
+		}
+		replies[i] = reply
+	}
+	if ds.IsLegacyJSONPath(path) {
+		return replies[0], nil
+	}
+	return replies, nil
+}
+
+// jsonObjLen executes JSON.OBJLEN key [path]
+func jsonObjLen(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 {
+		return nil, errSyntax
+	}
+	path := optionalJSONPath(args, 1)
+	lengths, err := rds.JSONObjLen(args[0], path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonIntegersReply(path, lengths), nil
+}
+
+// jsonStrLen executes JSON.STRLEN key [path]
+func jsonStrLen(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 {
+		return nil, errSyntax
+	}
+	path := optionalJSONPath(args, 1)
+	lengths, err := rds.JSONStrLen(args[0], path)
+	if err != nil {
+		return nil, err
+	}
+	return jsonIntegersReply(path, lengths), nil
+}
+
+// jsonType executes JSON.TYPE key [path]
+func jsonType(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 {
+		return nil, errSyntax
+	}
+	path := optionalJSONPath(args, 1)
+	types, err := rds.JSONType(args[0], path)
+	if err != nil {
+		return nil, err
+	}
+	if ds.IsLegacyJSONPath(path) {
+		if len(types) == 0 {
+			return nullBulkReply, nil
+		}
+		return simpleStringReply(types[0]), nil
+	}
+	replies := make(arrayReply, len(types))
+	for i, t := range types {
+		replies[i] = bulkReply(t)
+	}
+	return replies, nil
+}
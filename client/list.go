@@ -2,30 +2,31 @@ package client
 
 import "github.com/saint-yellow/baradb-redis/ds"
 
-func lpush(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) < 2 {
-		return nil, newErrWrongNumberOfArguments("lpush")
-	}
-
+func lpush(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
 	elements := args[1:]
-	return ds.LPush(key, elements...)
-}
-
-func rpush(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) < 2 {
-		return nil, newErrWrongNumberOfArguments("rpush")
+	length, err := ds.LPush(key, elements...)
+	if err != nil {
+		return nil, err
 	}
+	return integerReply(length), nil
+}
 
+func rpush(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
 	elements := args[1:]
-	return ds.RPush(key, elements...)
+	length, err := ds.RPush(key, elements...)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(length), nil
 }
 
-func llen(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("llen")
-	}
+func llen(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
-	return ds.LLen(key)
+	length, err := ds.LLen(key)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(length), nil
 }
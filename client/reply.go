@@ -0,0 +1,275 @@
+package client
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/tidwall/redcon"
+)
+
+const (
+	resp2 = 2 // protocol version RESP2, the default one
+	resp3 = 3 // protocol version RESP3, negotiated by HELLO 3
+)
+
+// ReplyWriter writes replies to a connection in the protocol negotiated by HELLO.
+//
+// RESP3 types are written as their RESP2 equivalents to RESP2 clients.
+type ReplyWriter struct {
+	redcon.Conn
+	protocol int
+}
+
+// NewReplyWriter initializes a reply writer with the protocol of the client of the connection
+func NewReplyWriter(conn redcon.Conn) *ReplyWriter {
+	w := &ReplyWriter{
+		Conn:     conn,
+		protocol: resp2,
+	}
+	if client, ok := conn.Context().(*RedisClient); ok {
+		w.protocol = client.Protocol()
+	}
+	return w
+}
+
+// WriteNull writes a null, which is a null bulk string in RESP2
+func (w *ReplyWriter) WriteNull() {
+	if w.protocol == resp3 {
+		w.WriteRaw([]byte("_\r\n"))
+		return
+	}
+	w.Conn.WriteNull()
+}
+
+// WriteMap writes the header of a map with n key/value pairs, which is a flat array in RESP2
+func (w *ReplyWriter) WriteMap(n int) {
+	if w.protocol == resp3 {
+		w.WriteRaw([]byte("%" + strconv.Itoa(n) + "\r\n"))
+		return
+	}
+	w.WriteArray(n * 2)
+}
+
+// WriteSet writes the header of a set with n members, which is an array in RESP2
+func (w *ReplyWriter) WriteSet(n int) {
+	if w.protocol == resp3 {
+		w.WriteRaw([]byte("~" + strconv.Itoa(n) + "\r\n"))
+		return
+	}
+	w.WriteArray(n)
+}
+
+// WritePush writes the header of a push message with n elements, which is an array in RESP2
+func (w *ReplyWriter) WritePush(n int) {
+	if w.protocol == resp3 {
+		w.WriteRaw([]byte(">" + strconv.Itoa(n) + "\r\n"))
+		return
+	}
+	w.WriteArray(n)
+}
+
+// WriteDouble writes a double, which is a bulk string in RESP2
+func (w *ReplyWriter) WriteDouble(f float64) {
+	if w.protocol == resp3 {
+		w.WriteRaw([]byte("," + formatDouble(f) + "\r\n"))
+		return
+	}
+	w.WriteBulkString(formatDouble(f))
+}
+
+// WriteBool writes a boolean, which is an integer 1 or 0 in RESP2
+func (w *ReplyWriter) WriteBool(b bool) {
+	if w.protocol == resp3 {
+		if b {
+			w.WriteRaw([]byte("#t\r\n"))
+		} else {
+			w.WriteRaw([]byte("#f\r\n"))
+		}
+		return
+	}
+	if b {
+		w.WriteInt(1)
+	} else {
+		w.WriteInt(0)
+	}
+}
+
+// formatDouble formats a float like Redis does with %.17g, but with the shortest representation
+func formatDouble(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "inf"
+	case math.IsInf(f, -1):
+		return "-inf"
+	case math.IsNaN(f):
+		return "nan"
+	}
+
+	if f != 0 {
+		exponent := int(math.Floor(math.Log10(math.Abs(f))))
+		if exponent < -4 || exponent >= 17 {
+			return strconv.FormatFloat(f, 'e', -1, 64)
+		}
+	}
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// Reply is a reply of a command, which knows how to write itself in both protocols.
+//
+// Replies of custom commands are built by NewSimpleStringReply, NewIntegerReply and so on.
+type Reply interface {
+	writeTo(w *ReplyWriter)
+}
+
+var (
+	okReply        = simpleStringReply("OK")
+	nullBulkReply  = nullReply{array: false}
+	nullArrayReply = nullReply{array: true}
+)
+
+// simpleStringReply is a simple string, such as OK
+type simpleStringReply string
+
+func (r simpleStringReply) writeTo(w *ReplyWriter) {
+	w.WriteString(string(r))
+}
+
+// errorReply is an error message with an error code, such as ERR or WRONGTYPE
+type errorReply string
+
+func (r errorReply) writeTo(w *ReplyWriter) {
+	w.WriteError(string(r))
+}
+
+// integerReply is an integer, such as a length or a count
+type integerReply int64
+
+func (r integerReply) writeTo(w *ReplyWriter) {
+	w.WriteInt64(int64(r))
+}
+
+// bulkReply is a binary safe string, such as a value of a string
+type bulkReply []byte
+
+func (r bulkReply) writeTo(w *ReplyWriter) {
+	w.WriteBulk(r)
+}
+
+// nullReply is a null, which is either a null bulk string or a null array in RESP2
+type nullReply struct {
+	array bool
+}
+
+func (r nullReply) writeTo(w *ReplyWriter) {
+	if w.protocol == resp2 && r.array {
+		w.WriteRaw([]byte("*-1\r\n"))
+		return
+	}
+	w.WriteNull()
+}
+
+// boolReply is a boolean, which is an integer 1 or 0 in RESP2
+type boolReply bool
+
+func (r boolReply) writeTo(w *ReplyWriter) {
+	w.WriteBool(bool(r))
+}
+
+// doubleReply is a double, such as the score of a sorted set member
+type doubleReply float64
+
+func (r doubleReply) writeTo(w *ReplyWriter) {
+	w.WriteDouble(float64(r))
+}
+
+// arrayReply is an array of replies
+type arrayReply []Reply
+
+func (r arrayReply) writeTo(w *ReplyWriter) {
+	w.WriteArray(len(r))
+	for _, element := range r {
+		element.writeTo(w)
+	}
+}
+
+// mapReply is a map with keys and values stored alternately, such as fields and values of a hash
+type mapReply []Reply
+
+func (r mapReply) writeTo(w *ReplyWriter) {
+	w.WriteMap(len(r) / 2)
+	for _, element := range r {
+		element.writeTo(w)
+	}
+}
+
+// setReply is a set of unique replies, such as members of a set
+type setReply []Reply
+
+func (r setReply) writeTo(w *ReplyWriter) {
+	w.WriteSet(len(r))
+	for _, element := range r {
+		element.writeTo(w)
+	}
+}
+
+// bulks converts binary safe strings to replies
+func bulks(elements [][]byte) []Reply {
+	replies := make([]Reply, len(elements))
+	for i, element := range elements {
+		replies[i] = bulkReply(element)
+	}
+	return replies
+}
+
+// boolToInteger converts a boolean to an integer 1 or 0, which is how Redis replies most predicates
+func boolToInteger(b bool) integerReply {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// NewSimpleStringReply initializes a simple string reply, such as OK
+func NewSimpleStringReply(s string) Reply {
+	return simpleStringReply(s)
+}
+
+// NewIntegerReply initializes an integer reply
+func NewIntegerReply(n int64) Reply {
+	return integerReply(n)
+}
+
+// NewBulkReply initializes a binary safe string reply
+func NewBulkReply(b []byte) Reply {
+	return bulkReply(b)
+}
+
+// NewNullReply initializes a null reply, which is a null bulk string in RESP2
+func NewNullReply() Reply {
+	return nullBulkReply
+}
+
+// NewBoolReply initializes a boolean reply, which is an integer 1 or 0 in RESP2
+func NewBoolReply(b bool) Reply {
+	return boolReply(b)
+}
+
+// NewDoubleReply initializes a double reply, which is a bulk string in RESP2
+func NewDoubleReply(f float64) Reply {
+	return doubleReply(f)
+}
+
+// NewArrayReply initializes an array reply
+func NewArrayReply(elements ...Reply) Reply {
+	return arrayReply(elements)
+}
+
+// NewMapReply initializes a map reply with keys and values given alternately, which is a flat array in RESP2
+func NewMapReply(pairs ...Reply) Reply {
+	return mapReply(pairs)
+}
+
+// NewSetReply initializes a set reply, which is an array in RESP2
+func NewSetReply(members ...Reply) Reply {
+	return setReply(members)
+}
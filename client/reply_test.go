@@ -0,0 +1,78 @@
+package client
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDouble(t *testing.T) {
+	tests := []struct {
+		f        float64
+		expected string
+	}{
+		{0, "0"},
+		{math.Copysign(0, -1), "-0"},
+		{3, "3"},
+		{-2, "-2"},
+		{1.5, "1.5"},
+		{0.1, "0.1"},
+		{1e16, "10000000000000000"},
+		{1e17, "1e+17"},
+		{-1.5e300, "-1.5e+300"},
+		{0.0001, "0.0001"},
+		{0.00001, "1e-05"},
+		{math.Inf(1), "inf"},
+		{math.Inf(-1), "-inf"},
+		{math.NaN(), "nan"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, formatDouble(tt.f), tt.expected)
+	}
+}
+
+func TestReply_WriteTo(t *testing.T) {
+	tests := []struct {
+		name  string
+		reply Reply
+		resp2 string
+		resp3 string
+	}{
+		{"null bulk", nullBulkReply, "$-1\r\n", "_\r\n"},
+		{"null array", nullArrayReply, "*-1\r\n", "_\r\n"},
+		{"true", boolReply(true), ":1\r\n", "#t\r\n"},
+		{"false", boolReply(false), ":0\r\n", "#f\r\n"},
+		{"double", doubleReply(1.5), "$3\r\n1.5\r\n", ",1.5\r\n"},
+		{"integral double", doubleReply(3), "$1\r\n3\r\n", ",3\r\n"},
+		{"inf", doubleReply(math.Inf(-1)), "$4\r\n-inf\r\n", ",-inf\r\n"},
+		{"map", mapReply{bulkReply("a"), integerReply(1)}, "*2\r\n$1\r\na\r\n:1\r\n", "%1\r\n$1\r\na\r\n:1\r\n"},
+		{"set", setReply{bulkReply("a"), bulkReply("b")}, "*2\r\n$1\r\na\r\n$1\r\nb\r\n", "~2\r\n$1\r\na\r\n$1\r\nb\r\n"},
+		{
+			"nested",
+			arrayReply{mapReply{bulkReply("k"), nullBulkReply}, boolReply(false), okReply},
+			"*3\r\n*2\r\n$1\r\nk\r\n$-1\r\n:0\r\n+OK\r\n",
+			"*3\r\n%1\r\n$1\r\nk\r\n_\r\n#f\r\n+OK\r\n",
+		},
+	}
+	acl := NewACL("", NewCommandTable())
+	for _, tt := range tests {
+		for protocol, expected := range map[int]string{resp2: tt.resp2, resp3: tt.resp3} {
+			conn := newTestingConn(acl, "127.0.0.1:6379")
+			tt.reply.writeTo(&ReplyWriter{Conn: conn, protocol: protocol})
+			assert.Equal(t, expected, string(conn.replies), "%s in RESP%d", tt.name, protocol)
+		}
+	}
+}
+
+func TestNewReplyWriter(t *testing.T) {
+	conn := newTestingConn(NewACL("", NewCommandTable()), "127.0.0.1:6379")
+	NewReplyWriter(conn).WritePush(1)
+	assert.Equal(t, "*1\r\n", string(conn.replies))
+
+	// the protocol negotiated by HELLO is used
+	assert.Equal(t, "%7", conn.execute("HELLO", "3"))
+	conn.replies = conn.replies[:0]
+	NewReplyWriter(conn).WritePush(1)
+	assert.Equal(t, ">1\r\n", string(conn.replies))
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+var (
+	errSearchOnlyHash      = newError("ERR Only HASH is supported as index data type")
+	errSearchMissingSchema = newError("ERR Fields arguments are missing")
+	errSearchBadSeparator  = newError("ERR Tag separator must be a single character")
+	errSearchBadLimit      = newError("ERR LIMIT: offset and number must be nonnegative integers")
+)
+
+// parseSearchSchema parses fields after SCHEMA of FT.CREATE
+func parseSearchSchema(args [][]byte) ([]ds.SearchField, error) {
+	var fields []ds.SearchField
+	seen := make(map[string]bool)
+	for i := 0; i < len(args); i++ {
+		f := ds.SearchField{Name: string(args[i]), Separator: ','}
+		if i+2 < len(args) && strings.ToUpper(string(args[i+1])) == "AS" {
+			f.Alias = string(args[i+2])
+			i += 2
+		}
+		attribute := f.Name
+		if f.Alias != "" {
+			attribute = f.Alias
+		}
+		if seen[attribute] {
+			return nil, newError(fmt.Sprintf("ERR Duplicate field in schema - %s", attribute))
+		}
+		seen[attribute] = true
+		if i+1 >= len(args) {
+			return nil, newError(fmt.Sprintf("ERR Field `%s` does not have a type", f.Name))
+		}
+		i++
+		switch strings.ToUpper(string(args[i])) {
+		case "TEXT":
+			f.Type = ds.SearchText
+		case "TAG":
+			f.Type = ds.SearchTag
+		case "NUMERIC":
+			f.Type = ds.SearchNumeric
+		default:
+			return nil, newError(fmt.Sprintf("ERR Invalid field type for field `%s`", f.Name))
+		}
+		for ; i+1 < len(args); i++ {
+			option := strings.ToUpper(string(args[i+1]))
+			if option == "SORTABLE" {
+				f.Sortable = true
+			} else if option == "SEPARATOR" && f.Type == ds.SearchTag {
+				if i+2 >= len(args) || len(args[i+2]) != 1 {
+					return nil, errSearchBadSeparator
+				}
+				f.Separator = args[i+2][0]
+				i++
+			} else if option == "CASESENSITIVE" && f.Type == ds.SearchTag {
+				f.CaseSensitive = true
+			} else {
+				break
+			}
+		}
+		fields = append(fields, f)
+	}
+	if len(fields) == 0 {
+		return nil, errSearchMissingSchema
+	}
+	return fields, nil
+}
+
+// ftCreate executes FT.CREATE index [ON HASH] [PREFIX count prefix...] SCHEMA field [AS alias] type [options]...
+//
+// The client must be allowed to access all keys of the prefixes, which are all keys if there is no prefix.
+func ftCreate(client *RedisClient, args ...[]byte) (Reply, error) {
+	var opts ds.SearchIndexOptions
+	i := 1
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "SCHEMA" {
+			break
+		}
+		if i+1 >= len(args) {
+			return nil, errSyntax
+		}
+		i++
+		switch option {
+		case "ON":
+			if strings.ToUpper(string(args[i])) != "HASH" {
+				return nil, errSearchOnlyHash
+			}
+		case "PREFIX":
+			n, err := strconv.Atoi(string(args[i]))
+			if err != nil || n < 0 || i+n >= len(args) {
+				return nil, errSyntax
+			}
+			for _, prefix := range args[i+1 : i+1+n] {
+				opts.Prefixes = append(opts.Prefixes, string(prefix))
+			}
+			i += n
+		default:
+			return nil, errSyntax
+		}
+	}
+	if i >= len(args) {
+		return nil, errSearchMissingSchema
+	}
+	fields, err := parseSearchSchema(args[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	opts.Fields = fields
+
+	user := client.ACL.user(client.Username())
+	prefixes := opts.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	for _, prefix := range prefixes {
+		if user == nil || !user.canAccessKeyPrefix(prefix) {
+			return nil, errNoPermKey
+		}
+	}
+	if err := client.DB.FTCreate(string(args[0]), opts); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// ftSearch executes FT.SEARCH index query [NOCONTENT] [RETURN count field...] [SORTBY field [ASC|DESC]] [LIMIT offset num]
+//
+// Documents of keys the client is not allowed to access are skipped.
+func ftSearch(client *RedisClient, args ...[]byte) (Reply, error) {
+	opts := ds.SearchOptions{Limit: 10, KeyFilter: client.canAccessKey}
+	for i := 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "NOCONTENT":
+			opts.NoContent = true
+		case "RETURN":
+			if i+1 >= len(args) {
+				return nil, errSyntax
+			}
+			n, err := strconv.Atoi(string(args[i+1]))
+			if err != nil || n < 0 || i+1+n >= len(args) {
+				return nil, errSyntax
+			}
+			opts.Return = nil
+			for _, field := range args[i+2 : i+2+n] {
+				opts.Return = append(opts.Return, string(field))
+			}
+			// RETURN 0 returns keys only
+			opts.NoContent = opts.NoContent || n == 0
+			i += 1 + n
+		case "SORTBY":
+			if i+1 >= len(args) {
+				return nil, errSyntax
+			}
+			opts.SortBy = string(args[i+1])
+			i++
+			if i+1 < len(args) {
+				switch strings.ToUpper(string(args[i+1])) {
+				case "ASC":
+					opts.Descending = false
+					i++
+				case "DESC":
+					opts.Descending = true
+					i++
+				}
+			}
+		case "LIMIT":
+			if i+2 >= len(args) {
+				return nil, errSyntax
+			}
+			offset, err1 := strconv.Atoi(string(args[i+1]))
+			limit, err2 := strconv.Atoi(string(args[i+2]))
+			if err1 != nil || err2 != nil || offset < 0 || limit < 0 {
+				return nil, errSearchBadLimit
+			}
+			opts.Offset, opts.Limit = offset, limit
+			i += 2
+		default:
+			return nil, errSyntax
+		}
+	}
+
+	total, docs, err := client.DB.FTSearch(string(args[0]), string(args[1]), opts)
+	if err != nil {
+		return nil, err
+	}
+	reply := arrayReply{integerReply(total)}
+	for _, doc := range docs {
+		reply = append(reply, bulkReply(doc.Key))
+		if !opts.NoContent {
+			reply = append(reply, mapReply(bulks(doc.Fields)))
+		}
+	}
+	return reply, nil
+}
+
+// ftDropIndex executes FT.DROPINDEX index [DD]
+//
+// Hashes the client is not allowed to access are kept.
+func ftDropIndex(client *RedisClient, args ...[]byte) (Reply, error) {
+	if len(args) > 2 || (len(args) == 2 && strings.ToUpper(string(args[1])) != "DD") {
+		return nil, errSyntax
+	}
+	if err := client.DB.FTDropIndex(string(args[0]), len(args) == 2, client.canAccessKey); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// ftList executes FT._LIST
+func ftList(rds *ds.DS, args ...[]byte) (Reply, error) {
+	names := rds.FTList()
+	reply := make(arrayReply, len(names))
+	for i, name := range names {
+		reply[i] = bulkReply(name)
+	}
+	return reply, nil
+}
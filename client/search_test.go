@@ -0,0 +1,36 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSearch_ACL(t *testing.T) {
+	acl := NewACL("", NewCommandTable())
+	assert.Nil(t, acl.setUser("alice", []string{"on", ">secret", "~svc:*", "+@all"}))
+	db := newTestingDB(t)
+	admin, conn := newTestingConn(acl, "127.0.0.1:6379"), newTestingConn(acl, "127.0.0.1:6379")
+	admin.Context().(*RedisClient).DB = db
+	conn.Context().(*RedisClient).DB = db
+	assert.Equal(t, ":1", admin.execute("HSET", "svc:1", "name", "api"))
+	assert.Equal(t, ":1", admin.execute("HSET", "secret:1", "name", "token"))
+	assert.Equal(t, "+OK", admin.execute("FT.CREATE", "all", "SCHEMA", "name", "TEXT"))
+	assert.Equal(t, "+OK", conn.execute("AUTH", "alice", "secret"))
+	assert.Equal(t, "-NOPERM No permissions to access a key", conn.execute("HGETALL", "secret:1"))
+
+	// indexes could only be created on keys the client is allowed to access
+	assert.Equal(t, "-NOPERM No permissions to access a key", conn.execute("FT.CREATE", "secrets", "ON", "HASH", "PREFIX", "1", "secret:", "SCHEMA", "name", "TEXT"))
+	assert.Equal(t, "-NOPERM No permissions to access a key", conn.execute("FT.CREATE", "everything", "SCHEMA", "name", "TEXT"))
+	assert.Equal(t, "-NOPERM No permissions to access a key", conn.execute("FT.CREATE", "services", "PREFIX", "2", "svc:", "sec", "SCHEMA", "name", "TEXT"))
+	assert.Equal(t, "+OK", conn.execute("FT.CREATE", "services", "PREFIX", "1", "svc:api:", "SCHEMA", "name", "TEXT"))
+
+	// documents of other keys are neither found nor deleted
+	assert.Equal(t, "*3", conn.execute("FT.SEARCH", "all", "*"))
+	assert.Contains(t, string(conn.replies), "svc:1")
+	assert.NotContains(t, string(conn.replies), "secret:1")
+	assert.NotContains(t, string(conn.replies), "token")
+	assert.Equal(t, "+OK", conn.execute("FT.DROPINDEX", "all", "DD"))
+	assert.Equal(t, ":0", admin.execute("EXISTS", "svc:1"))
+	assert.Equal(t, ":1", admin.execute("EXISTS", "secret:1"))
+}
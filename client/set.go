@@ -2,47 +2,55 @@ package client
 
 import "github.com/saint-yellow/baradb-redis/ds"
 
-func sadd(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 2 {
-		return nil, newErrWrongNumberOfArguments("sadd")
+func sadd(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	var count int
+	for _, member := range args[1:] {
+		added, err := ds.SAdd(key, member)
+		if err != nil {
+			return nil, err
+		}
+		if added {
+			count++
+		}
 	}
-
-	key, member := args[0], args[1]
-	return ds.SAdd(key, member)
+	return integerReply(count), nil
 }
 
-func sismember(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 2 {
-		return nil, newErrWrongNumberOfArguments("sismember")
-	}
-
+func sismember(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key, member := args[0], args[1]
-	return ds.SIsMember(key, member)
-}
-
-func srem(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 2 {
-		return nil, newErrWrongNumberOfArguments("srem")
+	isMember, err := ds.SIsMember(key, member)
+	if err != nil {
+		return nil, err
 	}
-
-	key, member := args[0], args[1]
-	return ds.SRem(key, member)
+	return boolToInteger(isMember), nil
 }
 
-func smembers(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("smembers")
-	}
-
+func srem(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
-	return ds.SMembers(key)
+	var count int
+	for _, member := range args[1:] {
+		removed, err := ds.SRem(key, member)
+		if err != nil {
+			return nil, err
+		}
+		if removed {
+			count++
+		}
+	}
+	return integerReply(count), nil
 }
 
-func scard(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("scard")
+func smembers(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	members, err := ds.SMembers(key)
+	if err != nil {
+		return nil, err
 	}
+	return setReply(bulks(members)), nil
+}
 
+func scard(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
-	return ds.SCard(key), nil
+	return integerReply(ds.SCard(key)), nil
 }
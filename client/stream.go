@@ -0,0 +1,409 @@
+package client
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+var errInvalidStreamID = newError("ERR Invalid stream ID specified as stream command argument")
+
+// parseStreamID parses an ID in the form of ms-seq or ms, where seq is missingSeq if it is omitted
+func parseStreamID(arg []byte, missingSeq uint64) (ds.StreamID, error) {
+	ms, seq, ok := strings.Cut(string(arg), "-")
+	var id ds.StreamID
+	var err error
+	if id.Ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return id, errInvalidStreamID
+	}
+	if !ok {
+		id.Seq = missingSeq
+		return id, nil
+	}
+	if id.Seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+		return id, errInvalidStreamID
+	}
+	return id, nil
+}
+
+// parseRangeID parses an ID of XRANGE and XREVRANGE, which is either -, +, or an ID optionally prefixed by ( for exclusive ranges
+func parseRangeID(arg []byte, missingSeq uint64, isStart bool) (ds.StreamID, bool, error) {
+	switch string(arg) {
+	case "-":
+		return ds.MinStreamID, true, nil
+	case "+":
+		return ds.MaxStreamID, true, nil
+	}
+	exclusive := len(arg) > 0 && arg[0] == '('
+	if !exclusive {
+		id, err := parseStreamID(arg, missingSeq)
+		return id, true, err
+	}
+
+	id, err := parseStreamID(arg[1:], missingSeq)
+	if err != nil {
+		return id, false, err
+	}
+	if isStart {
+		id, ok := id.Next()
+		return id, ok, nil
+	}
+	id, ok := id.Prev()
+	return id, ok, nil
+}
+
+// streamIDReply replies an ID as a string in the form of ms-seq
+func streamIDReply(id ds.StreamID) Reply {
+	return bulkReply(id.String())
+}
+
+// streamEntriesReply replies entries as arrays of IDs and field-value pairs, or IDs and nulls for deleted ones
+func streamEntriesReply(entries []ds.StreamEntry) arrayReply {
+	replies := make(arrayReply, len(entries))
+	for i, entry := range entries {
+		if entry.Fields == nil {
+			// a pending entry deleted from the stream
+			replies[i] = arrayReply{streamIDReply(entry.ID), nullArrayReply}
+			continue
+		}
+		replies[i] = arrayReply{streamIDReply(entry.ID), arrayReply(bulks(entry.Fields))}
+	}
+	return replies
+}
+
+// streamsReply is entries read from streams, which is a map of keys and entries in RESP3,
+// and an array of key-entries pairs in RESP2
+type streamsReply []ds.StreamEntries
+
+func (r streamsReply) writeTo(w *ReplyWriter) {
+	if w.protocol == resp3 {
+		w.WriteMap(len(r))
+	} else {
+		w.WriteArray(len(r))
+	}
+	for _, stream := range r {
+		if w.protocol != resp3 {
+			w.WriteArray(2)
+		}
+		bulkReply(stream.Key).writeTo(w)
+		streamEntriesReply(stream.Entries).writeTo(w)
+	}
+}
+
+// parseStreamTrim parses a trimming strategy MAXLEN | MINID [= | ~] threshold [LIMIT count] from args[i],
+// and returns the index of the next argument
+func parseStreamTrim(args [][]byte, i int) (*ds.StreamTrim, int, error) {
+	trim := &ds.StreamTrim{ByMinID: strings.ToLower(string(args[i])) == "minid"}
+	i++
+	if i < len(args) && (string(args[i]) == "=" || string(args[i]) == "~") {
+		trim.Approx = string(args[i]) == "~"
+		i++
+	}
+	if i >= len(args) {
+		return nil, i, errSyntax
+	}
+	if trim.ByMinID {
+		id, err := parseStreamID(args[i], 0)
+		if err != nil {
+			return nil, i, err
+		}
+		trim.MinID = id
+	} else {
+		n, err := parseInteger(args[i])
+		if err != nil {
+			return nil, i, err
+		}
+		if n < 0 {
+			return nil, i, newError("ERR The MAXLEN argument must be >= 0.")
+		}
+		trim.MaxLen = n
+	}
+	i++
+
+	if i+1 < len(args) && strings.ToLower(string(args[i])) == "limit" {
+		if !trim.Approx {
+			return nil, i, newError("ERR syntax error, LIMIT cannot be used without the special ~ option")
+		}
+		n, err := parseInteger(args[i+1])
+		if err != nil {
+			return nil, i, err
+		}
+		if n < 0 {
+			return nil, i, newError("ERR The LIMIT argument must be >= 0.")
+		}
+		trim.Limit = n
+		i += 2
+	} else if trim.Approx {
+		trim.Limit = 100 * 100 // the default limit of Redis, 100 times stream-node-max-entries
+	}
+	return trim, i, nil
+}
+
+func isStreamTrimOption(option string) bool {
+	return option == "maxlen" || option == "minid"
+}
+
+// xadd executes XADD key [NOMKSTREAM] [MAXLEN | MINID [= | ~] threshold [LIMIT count]] * | id field value [field value ...]
+func xadd(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	var opts ds.XAddOptions
+	i := 1
+options:
+	for ; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		switch {
+		case option == "nomkstream":
+			opts.NoMkStream = true
+		case isStreamTrimOption(option):
+			trim, next, err := parseStreamTrim(args, i)
+			if err != nil {
+				return nil, err
+			}
+			opts.Trim = trim
+			i = next - 1
+		default:
+			break options
+		}
+	}
+	if i == len(args) || (len(args)-i-1)%2 != 0 || len(args)-i-1 == 0 {
+		return nil, newErrWrongNumberOfArguments("xadd")
+	}
+
+	switch id := args[i]; {
+	case string(id) == "*":
+		opts.AutoID = true
+	case strings.HasSuffix(string(id), "-*"):
+		ms, err := strconv.ParseUint(string(id[:len(id)-2]), 10, 64)
+		if err != nil {
+			return nil, errInvalidStreamID
+		}
+		opts.ID = ds.StreamID{Ms: ms}
+		opts.AutoSeq = true
+	default:
+		parsed, err := parseStreamID(id, 0)
+		if err != nil {
+			return nil, err
+		}
+		opts.ID = parsed
+	}
+
+	id, ok, err := rds.XAdd(key, opts, args[i+1:]...)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nullBulkReply, nil
+	}
+	return streamIDReply(id), nil
+}
+
+// xrange executes XRANGE key start end [COUNT count]
+func xrange(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return streamRange(rds, args, false)
+}
+
+// xrevrange executes XREVRANGE key end start [COUNT count]
+func xrevrange(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return streamRange(rds, args, true)
+}
+
+func streamRange(rds *ds.DS, args [][]byte, reverse bool) (Reply, error) {
+	key, startArg, endArg := args[0], args[1], args[2]
+	if reverse {
+		startArg, endArg = endArg, startArg
+	}
+	start, startOK, err := parseRangeID(startArg, 0, true)
+	if err != nil {
+		return nil, err
+	}
+	end, endOK, err := parseRangeID(endArg, math.MaxUint64, false)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	switch {
+	case len(args) == 3:
+	case len(args) == 5 && strings.ToLower(string(args[3])) == "count":
+		if count, err = parseInteger(args[4]); err != nil {
+			return nil, err
+		}
+		if count <= 0 {
+			return arrayReply{}, nil
+		}
+	default:
+		return nil, errSyntax
+	}
+	if !startOK || !endOK {
+		return arrayReply{}, nil
+	}
+
+	entries, err := rds.XRange(key, start, end, int(count), reverse)
+	if err != nil {
+		return nil, err
+	}
+	return streamEntriesReply(entries), nil
+}
+
+// xlen executes XLEN key
+func xlen(rds *ds.DS, args ...[]byte) (Reply, error) {
+	n, err := rds.XLen(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// xdel executes XDEL key id [id ...]
+func xdel(rds *ds.DS, args ...[]byte) (Reply, error) {
+	ids := make([]ds.StreamID, len(args)-1)
+	for i, arg := range args[1:] {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	n, err := rds.XDel(args[0], ids...)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// xtrim executes XTRIM key MAXLEN | MINID [= | ~] threshold [LIMIT count]
+func xtrim(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if !isStreamTrimOption(strings.ToLower(string(args[1]))) {
+		return nil, errSyntax
+	}
+	trim, i, err := parseStreamTrim(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if i != len(args) {
+		return nil, errSyntax
+	}
+	n, err := rds.XTrim(args[0], *trim)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// streamReadOptions are options of XREAD and XREADGROUP
+type streamReadOptions struct {
+	count    int64
+	block    int64 // -1 if the command does not block
+	group    []byte
+	consumer []byte
+	noAck    bool
+	keys     [][]byte
+	ids      [][]byte
+}
+
+// parseStreamReadOptions parses [GROUP group consumer] [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func parseStreamReadOptions(commandName string, args [][]byte) (*streamReadOptions, error) {
+	opts := &streamReadOptions{block: -1}
+	withGroup := commandName == "xreadgroup"
+	i := 0
+options:
+	for ; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		switch {
+		case option == "count" && i+1 < len(args):
+			n, err := parseInteger(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			if n > 0 {
+				opts.count = n
+			}
+			i++
+		case option == "block" && i+1 < len(args):
+			n, err := parseInteger(args[i+1])
+			if err != nil {
+				return nil, newError("ERR timeout is not an integer or out of range")
+			}
+			if n < 0 {
+				return nil, newError("ERR timeout is negative")
+			}
+			opts.block = n
+			i++
+		case option == "group" && withGroup && i+2 < len(args):
+			opts.group, opts.consumer = args[i+1], args[i+2]
+			i += 2
+		case option == "noack" && withGroup:
+			opts.noAck = true
+		case option == "streams":
+			i++
+			break options
+		default:
+			return nil, errSyntax
+		}
+	}
+	if withGroup && opts.group == nil {
+		return nil, newError("ERR Missing GROUP option for XREADGROUP")
+	}
+	streams := args[i:]
+	if len(streams) == 0 || len(streams)%2 != 0 {
+		return nil, newError("ERR Unbalanced '%s' list of streams: for each stream key an ID or '$' must be specified.", commandName)
+	}
+	opts.keys, opts.ids = streams[:len(streams)/2], streams[len(streams)/2:]
+	return opts, nil
+}
+
+// xread executes XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]
+func xread(rds *ds.DS, args ...[]byte) (Reply, error) {
+	opts, err := parseStreamReadOptions("xread", args)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]ds.StreamID, len(opts.keys))
+	for i, arg := range opts.ids {
+		if string(arg) == "$" {
+			ids[i], err = rds.StreamLastID(opts.keys[i])
+		} else {
+			ids[i], err = parseStreamID(arg, 0)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var results []ds.StreamEntries
+	if opts.block < 0 {
+		results, err = rds.XRead(opts.keys, ids, int(opts.count))
+	} else {
+		results, err = rds.XReadBlock(opts.keys, ids, int(opts.count), time.Duration(opts.block)*time.Millisecond)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nullArrayReply, nil
+	}
+	return streamsReply(results), nil
+}
+
+// streamReadKeys extracts keys of XREAD and XREADGROUP, which are the first half of the arguments after STREAMS
+func streamReadKeys(args [][]byte) [][]byte {
+	for i := 1; i < len(args); {
+		switch strings.ToLower(string(args[i])) {
+		case "streams":
+			streams := args[i+1:]
+			return streams[:len(streams)/2]
+		case "count", "block":
+			i += 2
+		case "group":
+			i += 3
+		case "noack":
+			i++
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,518 @@
+package client
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// Handlers of XGROUP and XINFO subcommands receive the subcommand name as the first argument.
+
+// parseGroupOptions parses id | $ [MKSTREAM] [ENTRIESREAD entries-read] of XGROUP CREATE and XGROUP SETID
+func parseGroupOptions(args [][]byte, mkStream bool) (ds.XGroupOptions, error) {
+	opts := ds.XGroupOptions{EntriesRead: -1}
+	if string(args[0]) == "$" {
+		opts.Latest = true
+	} else {
+		id, err := parseStreamID(args[0], 0)
+		if err != nil {
+			return opts, err
+		}
+		opts.ID = id
+	}
+
+	for i := 1; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		switch {
+		case option == "mkstream" && mkStream:
+			opts.MkStream = true
+		case option == "entriesread" && i+1 < len(args):
+			n, err := parseInteger(args[i+1])
+			if err != nil {
+				return opts, err
+			}
+			if n < -1 {
+				return opts, newError("ERR value for ENTRIESREAD must be positive or -1")
+			}
+			opts.EntriesRead = n
+			i++
+		default:
+			return opts, errSyntax
+		}
+	}
+	return opts, nil
+}
+
+// xgroupCreate executes XGROUP CREATE key group id | $ [MKSTREAM] [ENTRIESREAD entries-read]
+func xgroupCreate(rds *ds.DS, args ...[]byte) (Reply, error) {
+	opts, err := parseGroupOptions(args[3:], true)
+	if err != nil {
+		return nil, err
+	}
+	if err := rds.XGroupCreate(args[1], args[2], opts); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// xgroupSetID executes XGROUP SETID key group id | $ [ENTRIESREAD entries-read]
+func xgroupSetID(rds *ds.DS, args ...[]byte) (Reply, error) {
+	opts, err := parseGroupOptions(args[3:], false)
+	if err != nil {
+		return nil, err
+	}
+	if err := rds.XGroupSetID(args[1], args[2], opts); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// xgroupDestroy executes XGROUP DESTROY key group
+func xgroupDestroy(rds *ds.DS, args ...[]byte) (Reply, error) {
+	ok, err := rds.XGroupDestroy(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	return boolToInteger(ok), nil
+}
+
+// xgroupCreateConsumer executes XGROUP CREATECONSUMER key group consumer
+func xgroupCreateConsumer(rds *ds.DS, args ...[]byte) (Reply, error) {
+	ok, err := rds.XGroupCreateConsumer(args[1], args[2], args[3])
+	if err != nil {
+		return nil, err
+	}
+	return boolToInteger(ok), nil
+}
+
+// xgroupDelConsumer executes XGROUP DELCONSUMER key group consumer
+func xgroupDelConsumer(rds *ds.DS, args ...[]byte) (Reply, error) {
+	n, err := rds.XGroupDelConsumer(args[1], args[2], args[3])
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+// xreadgroup executes XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]
+func xreadgroup(rds *ds.DS, args ...[]byte) (Reply, error) {
+	opts, err := parseStreamReadOptions("xreadgroup", args)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]*ds.StreamID, len(opts.keys))
+	for i, arg := range opts.ids {
+		switch string(arg) {
+		case ">":
+		case "$":
+			return nil, newError("ERR The $ ID is meaningless in the context of XREADGROUP: you want to read the history of this consumer by specifying a proper ID, or use the > ID to get new messages. The $ ID would just return an empty result set.")
+		default:
+			id, err := parseStreamID(arg, 0)
+			if err != nil {
+				return nil, err
+			}
+			ids[i] = &id
+		}
+	}
+
+	var results []ds.StreamEntries
+	if opts.block < 0 {
+		results, err = rds.XReadGroup(opts.group, opts.consumer, opts.keys, ids, int(opts.count), opts.noAck)
+	} else {
+		timeout := time.Duration(opts.block) * time.Millisecond
+		results, err = rds.XReadGroupBlock(opts.group, opts.consumer, opts.keys, ids, int(opts.count), opts.noAck, timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(results) == 0 {
+		return nullArrayReply, nil
+	}
+	return streamsReply(results), nil
+}
+
+// xack executes XACK key group id [id ...]
+func xack(rds *ds.DS, args ...[]byte) (Reply, error) {
+	ids, err := parseStreamIDs(args[2:])
+	if err != nil {
+		return nil, err
+	}
+	n, err := rds.XAck(args[0], args[1], ids...)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
+}
+
+func parseStreamIDs(args [][]byte) ([]ds.StreamID, error) {
+	ids := make([]ds.StreamID, len(args))
+	for i, arg := range args {
+		id, err := parseStreamID(arg, 0)
+		if err != nil {
+			return nil, err
+		}
+		ids[i] = id
+	}
+	return ids, nil
+}
+
+// xpending executes XPENDING key group [[IDLE min-idle-time] start end count [consumer]]
+func xpending(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key, group := args[0], args[1]
+	if len(args) == 2 {
+		summary, err := rds.XPending(key, group)
+		if err != nil {
+			return nil, err
+		}
+		if summary.Count == 0 {
+			return arrayReply{integerReply(0), nullBulkReply, nullBulkReply, nullArrayReply}, nil
+		}
+		consumers := make(arrayReply, len(summary.Consumers))
+		for i, c := range summary.Consumers {
+			consumers[i] = arrayReply{bulkReply(c.Name), bulkReply(strconv.Itoa(c.Pending))}
+		}
+		return arrayReply{
+			integerReply(summary.Count),
+			streamIDReply(summary.MinID),
+			streamIDReply(summary.MaxID),
+			consumers,
+		}, nil
+	}
+
+	var opts ds.XPendingOptions
+	args = args[2:]
+	if strings.ToLower(string(args[0])) == "idle" && len(args) > 1 {
+		n, err := parseInteger(args[1])
+		if err != nil {
+			return nil, err
+		}
+		opts.MinIdle = time.Duration(n) * time.Millisecond
+		args = args[2:]
+	}
+	if len(args) != 3 && len(args) != 4 {
+		return nil, errSyntax
+	}
+	start, startOK, err := parseRangeID(args[0], 0, true)
+	if err != nil {
+		return nil, err
+	}
+	end, endOK, err := parseRangeID(args[1], math.MaxUint64, false)
+	if err != nil {
+		return nil, err
+	}
+	count, err := parseInteger(args[2])
+	if err != nil {
+		return nil, err
+	}
+	opts.Start, opts.End, opts.Count = start, end, int(count)
+	if len(args) == 4 {
+		opts.Consumer = args[3]
+	}
+	if !startOK || !endOK {
+		opts.Count = 0
+	}
+
+	entries, err := rds.XPendingRange(key, group, opts)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	replies := make(arrayReply, len(entries))
+	for i, entry := range entries {
+		replies[i] = arrayReply{
+			streamIDReply(entry.ID),
+			bulkReply(entry.Consumer),
+			integerReply(now.Sub(entry.DeliveryTime).Milliseconds()),
+			integerReply(entry.DeliveryCount),
+		}
+	}
+	return replies, nil
+}
+
+// parseMinIdleTime parses the minimum idle time of XCLAIM and XAUTOCLAIM in milliseconds
+func parseMinIdleTime(commandName string, arg []byte) (time.Duration, error) {
+	n, err := parseInteger(arg)
+	if err != nil || n < 0 || n > math.MaxInt64/int64(time.Millisecond) {
+		return 0, newError("ERR Invalid min-idle-time argument for %s", strings.ToUpper(commandName))
+	}
+	return time.Duration(n) * time.Millisecond, nil
+}
+
+// claimedReply replies claimed entries, or only their IDs with JUSTID
+func claimedReply(entries []ds.StreamEntry, justID bool) arrayReply {
+	if !justID {
+		return streamEntriesReply(entries)
+	}
+	replies := make(arrayReply, len(entries))
+	for i, entry := range entries {
+		replies[i] = streamIDReply(entry.ID)
+	}
+	return replies
+}
+
+// xclaim executes XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID] [LASTID lastid]
+func xclaim(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key, group, consumer := args[0], args[1], args[2]
+	minIdle, err := parseMinIdleTime("xclaim", args[3])
+	if err != nil {
+		return nil, err
+	}
+
+	// IDs are followed by options, which are never valid IDs
+	i := 4
+	var ids []ds.StreamID
+	for ; i < len(args); i++ {
+		id, err := parseStreamID(args[i], 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	var opts ds.XClaimOptions
+	for ; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		hasValue := i+1 < len(args)
+		switch {
+		case option == "force":
+			opts.Force = true
+		case option == "justid":
+			opts.JustID = true
+		case option == "idle" && hasValue:
+			n, err := parseInteger(args[i+1])
+			if err != nil {
+				return nil, newError("ERR Invalid IDLE option argument for XCLAIM")
+			}
+			opts.DeliveryTime = time.Now().Add(-time.Duration(n) * time.Millisecond)
+			i++
+		case option == "time" && hasValue:
+			n, err := parseInteger(args[i+1])
+			if err != nil {
+				return nil, newError("ERR Invalid TIME option argument for XCLAIM")
+			}
+			opts.DeliveryTime = time.UnixMilli(n)
+			i++
+		case option == "retrycount" && hasValue:
+			n, err := parseInteger(args[i+1])
+			if err != nil || n < 0 {
+				return nil, newError("ERR Invalid RETRYCOUNT option argument for XCLAIM")
+			}
+			opts.RetryCount, opts.HasRetryCount = uint64(n), true
+			i++
+		case option == "lastid" && hasValue:
+			id, err := parseStreamID(args[i+1], 0)
+			if err != nil {
+				return nil, err
+			}
+			opts.LastID = id
+			i++
+		default:
+			return nil, newError("ERR Unrecognized XCLAIM option '%s'", args[i])
+		}
+	}
+
+	entries, err := rds.XClaim(key, group, consumer, minIdle, ids, opts)
+	if err != nil {
+		return nil, err
+	}
+	return claimedReply(entries, opts.JustID), nil
+}
+
+// xautoclaim executes XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]
+func xautoclaim(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key, group, consumer := args[0], args[1], args[2]
+	minIdle, err := parseMinIdleTime("xautoclaim", args[3])
+	if err != nil {
+		return nil, err
+	}
+	start, ok, err := parseRangeID(args[4], 0, true)
+	if err != nil {
+		return nil, err
+	}
+
+	count, justID := int64(100), false
+	for i := 5; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		switch {
+		case option == "justid":
+			justID = true
+		case option == "count" && i+1 < len(args):
+			n, err := parseInteger(args[i+1])
+			if err != nil || n < 1 || n > math.MaxInt32/10 {
+				return nil, newError("ERR COUNT must be > 0")
+			}
+			count = n
+			i++
+		default:
+			return nil, errSyntax
+		}
+	}
+
+	if !ok {
+		// nothing is after the maximum ID
+		return arrayReply{streamIDReply(ds.MinStreamID), arrayReply{}, arrayReply{}}, nil
+	}
+	next, entries, deleted, err := rds.XAutoClaim(key, group, consumer, minIdle, start, int(count), justID)
+	if err != nil {
+		return nil, err
+	}
+	deletedReplies := make(arrayReply, len(deleted))
+	for i, id := range deleted {
+		deletedReplies[i] = streamIDReply(id)
+	}
+	return arrayReply{streamIDReply(next), claimedReply(entries, justID), deletedReplies}, nil
+}
+
+// optionalStreamEntryReply replies an entry, or null if there is no entry
+func optionalStreamEntryReply(entry *ds.StreamEntry) Reply {
+	if entry == nil {
+		return nullBulkReply
+	}
+	return streamEntriesReply([]ds.StreamEntry{*entry})[0]
+}
+
+// xinfoStream executes XINFO STREAM key [FULL [COUNT count]]
+func xinfoStream(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[1]
+	var full bool
+	count := int64(10)
+	switch {
+	case len(args) == 2:
+	case strings.ToLower(string(args[2])) != "full":
+		return nil, errSyntax
+	case len(args) == 3:
+		full = true
+	case len(args) == 5 && strings.ToLower(string(args[3])) == "count":
+		n, err := parseInteger(args[4])
+		if err != nil {
+			return nil, err
+		}
+		if n < 0 {
+			n = 0
+		}
+		full, count = true, n
+	default:
+		return nil, errSyntax
+	}
+
+	info, err := rds.XInfoStream(key, full, int(count))
+	if err != nil {
+		return nil, err
+	}
+	reply := mapReply{
+		bulkReply("length"), integerReply(info.Length),
+		bulkReply("last-generated-id"), streamIDReply(info.LastGeneratedID),
+		bulkReply("max-deleted-entry-id"), streamIDReply(info.MaxDeletedID),
+		bulkReply("entries-added"), integerReply(info.EntriesAdded),
+		bulkReply("recorded-first-entry-id"), streamIDReply(info.FirstID),
+	}
+	if !full {
+		return append(reply,
+			bulkReply("groups"), integerReply(info.Groups),
+			bulkReply("first-entry"), optionalStreamEntryReply(info.FirstEntry),
+			bulkReply("last-entry"), optionalStreamEntryReply(info.LastEntry),
+		), nil
+	}
+
+	groups := make(arrayReply, len(info.GroupDetails))
+	for i, g := range info.GroupDetails {
+		pending := make(arrayReply, len(g.PendingEntries))
+		for j, p := range g.PendingEntries {
+			pending[j] = arrayReply{
+				streamIDReply(p.ID),
+				bulkReply(p.Consumer),
+				integerReply(p.DeliveryTime.UnixMilli()),
+				integerReply(p.DeliveryCount),
+			}
+		}
+		consumers := make(arrayReply, len(g.ConsumerDetails))
+		for j, c := range g.ConsumerDetails {
+			consumerPending := make(arrayReply, len(c.PendingEntries))
+			for k, p := range c.PendingEntries {
+				consumerPending[k] = arrayReply{
+					streamIDReply(p.ID),
+					integerReply(p.DeliveryTime.UnixMilli()),
+					integerReply(p.DeliveryCount),
+				}
+			}
+			activeTime := int64(-1)
+			if !c.ActiveTime.IsZero() {
+				activeTime = c.ActiveTime.UnixMilli()
+			}
+			consumers[j] = mapReply{
+				bulkReply("name"), bulkReply(c.Name),
+				bulkReply("seen-time"), integerReply(c.SeenTime.UnixMilli()),
+				bulkReply("active-time"), integerReply(activeTime),
+				bulkReply("pel-count"), integerReply(c.Pending),
+				bulkReply("pending"), consumerPending,
+			}
+		}
+		groups[i] = mapReply{
+			bulkReply("name"), bulkReply(g.Name),
+			bulkReply("last-delivered-id"), streamIDReply(g.LastDeliveredID),
+			bulkReply("entries-read"), optionalCountReply(g.EntriesRead),
+			bulkReply("lag"), optionalCountReply(g.Lag),
+			bulkReply("pel-count"), integerReply(g.Pending),
+			bulkReply("pending"), pending,
+			bulkReply("consumers"), consumers,
+		}
+	}
+	return append(reply,
+		bulkReply("entries"), streamEntriesReply(info.Entries),
+		bulkReply("groups"), groups,
+	), nil
+}
+
+// optionalCountReply replies a count, or null if it is unknown
+func optionalCountReply(n int64) Reply {
+	if n < 0 {
+		return nullBulkReply
+	}
+	return integerReply(n)
+}
+
+// xinfoGroups executes XINFO GROUPS key
+func xinfoGroups(rds *ds.DS, args ...[]byte) (Reply, error) {
+	groups, err := rds.XInfoGroups(args[1])
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(groups))
+	for i, g := range groups {
+		replies[i] = mapReply{
+			bulkReply("name"), bulkReply(g.Name),
+			bulkReply("consumers"), integerReply(g.Consumers),
+			bulkReply("pending"), integerReply(g.Pending),
+			bulkReply("last-delivered-id"), streamIDReply(g.LastDeliveredID),
+			bulkReply("entries-read"), optionalCountReply(g.EntriesRead),
+			bulkReply("lag"), optionalCountReply(g.Lag),
+		}
+	}
+	return replies, nil
+}
+
+// xinfoConsumers executes XINFO CONSUMERS key group
+func xinfoConsumers(rds *ds.DS, args ...[]byte) (Reply, error) {
+	consumers, err := rds.XInfoConsumers(args[1], args[2])
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	replies := make(arrayReply, len(consumers))
+	for i, c := range consumers {
+		inactive := int64(-1)
+		if !c.ActiveTime.IsZero() {
+			inactive = now.Sub(c.ActiveTime).Milliseconds()
+		}
+		replies[i] = mapReply{
+			bulkReply("name"), bulkReply(c.Name),
+			bulkReply("pending"), integerReply(c.Pending),
+			bulkReply("idle"), integerReply(now.Sub(c.SeenTime).Milliseconds()),
+			bulkReply("inactive"), integerReply(inactive),
+		}
+	}
+	return replies, nil
+}
@@ -1,129 +1,374 @@
 package client
 
 import (
-	"github.com/tidwall/redcon"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saint-yellow/baradb/utils"
 
 	"github.com/saint-yellow/baradb-redis/ds"
 )
 
-func set(ds *ds.DS, args ...[]byte) (any, error) {
-	var err error
-	if len(args) != 2 {
-		err = newErrWrongNumberOfArguments("set")
-		return nil, err
+// set executes SET key value [NX | XX] [GET] [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | KEEPTTL]
+func set(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key, value := args[0], args[1]
+	var opts ds.SetOptions
+	var hasExpiration bool
+	for i := 2; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		switch {
+		case option == "nx" && !opts.XX:
+			opts.NX = true
+		case option == "xx" && !opts.NX:
+			opts.XX = true
+		case option == "get":
+			opts.Get = true
+		case option == "keepttl" && !hasExpiration:
+			opts.KeepTTL = true
+			hasExpiration = true
+		case isExpirationOption(option) && !hasExpiration && i+1 < len(args):
+			e, err := parseExpiration("set", option, args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			opts.Expiration = e
+			hasExpiration = true
+			i++
+		default:
+			return nil, errSyntax
+		}
 	}
 
-	key, value := args[0], args[1]
-	err = ds.Set(key, value, 0)
+	oldValue, ok, err := rds.SetWithOptions(key, value, opts)
 	if err != nil {
 		return nil, err
 	}
-	return redcon.SimpleString("OK"), nil
+	switch {
+	case opts.Get && oldValue == nil:
+		return nullBulkReply, nil
+	case opts.Get:
+		return bulkReply(oldValue), nil
+	case !ok:
+		return nullBulkReply, nil
+	default:
+		return okReply, nil
+	}
+}
+
+// setex executes SETEX key seconds value
+func setex(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return setWithExpiration(rds, "setex", "ex", args)
 }
 
-func get(ds *ds.DS, args ...[]byte) (any, error) {
-	var err error
-	if len(args) != 1 {
-		err = newErrWrongNumberOfArguments("get")
+// psetex executes PSETEX key milliseconds value
+func psetex(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return setWithExpiration(rds, "psetex", "px", args)
+}
+
+func setWithExpiration(rds *ds.DS, commandName, unit string, args [][]byte) (Reply, error) {
+	key, value := args[0], args[2]
+	e, err := parseExpiration(commandName, unit, args[1])
+	if err != nil {
 		return nil, err
 	}
-	key := args[0]
-	return ds.Get(key)
+	if _, _, err := rds.SetWithOptions(key, value, ds.SetOptions{Expiration: e}); err != nil {
+		return nil, err
+	}
+	return okReply, nil
 }
 
-func setnx(ds *ds.DS, args ...[]byte) (any, error) {
-	var err error
-	if len(args) != 2 {
-		err = newErrWrongNumberOfArguments("setnx")
-		return nil, err
+// getex executes GETEX key [EX seconds | PX milliseconds | EXAT unix-time-seconds | PXAT unix-time-milliseconds | PERSIST]
+func getex(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	e := ds.Expiration{KeepTTL: true}
+	if len(args) > 1 {
+		option := strings.ToLower(string(args[1]))
+		switch {
+		case option == "persist" && len(args) == 2:
+			e = ds.Expiration{Persist: true}
+		case isExpirationOption(option) && len(args) == 3:
+			var err error
+			e, err = parseExpiration("getex", option, args[2])
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errSyntax
+		}
 	}
 
-	key, value := args[0], args[1]
-	success := ds.SetNx(key, value)
-	if !success {
+	value, err := rds.GetEx(key, e)
+	if err != nil {
 		return nil, err
 	}
-	return redcon.SimpleString("OK"), nil
+	return bulkReply(value), nil
 }
 
-func strlen(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("strlen")
+func isExpirationOption(option string) bool {
+	switch option {
+	case "ex", "px", "exat", "pxat":
+		return true
+	default:
+		return false
 	}
-
-	key := args[0]
-	length := ds.StrLen(key)
-	return length, nil
 }
 
-func strappend(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 2 {
-		return nil, newErrWrongNumberOfArguments("append")
+// parseExpiration parses an expiration given by EX, PX, EXAT or PXAT
+func parseExpiration(commandName, option string, arg []byte) (ds.Expiration, error) {
+	n, err := parseInteger(arg)
+	if err != nil {
+		return ds.Expiration{}, err
+	}
+	if n <= 0 {
+		return ds.Expiration{}, newErrInvalidExpireTime(commandName)
 	}
 
-	key, value := args[0], args[1]
-	return ds.Append(key, value)
+	unit := time.Second
+	if option == "px" || option == "pxat" {
+		unit = time.Millisecond
+	}
+	// the expiration must be representable in Unix time in nanoseconds
+	if n > math.MaxInt64/int64(unit) {
+		return ds.Expiration{}, newErrInvalidExpireTime(commandName)
+	}
+	d := time.Duration(n) * unit
+
+	var e ds.Expiration
+	if option == "ex" || option == "px" {
+		if d > time.Until(time.Unix(0, math.MaxInt64)) {
+			return ds.Expiration{}, newErrInvalidExpireTime(commandName)
+		}
+		e.TTL = d
+	} else {
+		e.ExpireAt = time.Unix(0, int64(d))
+	}
+	return e, nil
 }
 
-func getdel(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("getdel")
+// parseInteger parses an argument as a 64-bit signed integer
+func parseInteger(arg []byte) (int64, error) {
+	n, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil {
+		return 0, ds.ErrInvalidInteger
 	}
+	return n, nil
+}
 
+func get(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
-	return ds.GetDel(key)
+	value, err := ds.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return bulkReply(value), nil
 }
 
-func getset(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 2 {
-		return nil, newErrWrongNumberOfArguments("getset")
+// mget executes MGET key [key ...]
+func mget(rds *ds.DS, args ...[]byte) (Reply, error) {
+	values := rds.MGet(args...)
+	replies := make(arrayReply, len(values))
+	for i, value := range values {
+		if value == nil {
+			replies[i] = nullBulkReply
+		} else {
+			replies[i] = bulkReply(value)
+		}
 	}
+	return replies, nil
+}
 
-	key, value := args[0], args[1]
-	return ds.GetSet(key, value)
+// mset executes MSET key value [key value ...]
+func mset(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args)%2 != 0 {
+		return nil, newErrWrongNumberOfArguments("mset")
+	}
+	if err := rds.MSet(args...); err != nil {
+		return nil, err
+	}
+	return okReply, nil
 }
 
-func incr(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("incr")
+// msetnx executes MSETNX key value [key value ...]
+func msetnx(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args)%2 != 0 {
+		return nil, newErrWrongNumberOfArguments("msetnx")
+	}
+	ok, err := rds.MSetNx(args...)
+	if err != nil {
+		return nil, err
 	}
+	return boolToInteger(ok), nil
+}
+
+func setnx(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key, value := args[0], args[1]
+	success := ds.SetNx(key, value)
+	return boolToInteger(success), nil
+}
 
+func strlen(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
-	return ds.Incr(key)
+	length := ds.StrLen(key)
+	return integerReply(length), nil
+}
+
+// getrange executes GETRANGE key start end
+func getrange(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	start, err := parseInteger(args[1])
+	if err != nil {
+		return nil, err
+	}
+	end, err := parseInteger(args[2])
+	if err != nil {
+		return nil, err
+	}
+	value, err := rds.GetRange(key, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return bulkReply(value), nil
+}
+
+// setrange executes SETRANGE key offset value
+func setrange(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key, value := args[0], args[2]
+	offset, err := parseInteger(args[1])
+	if err != nil {
+		return nil, err
+	}
+	length, err := rds.SetRange(key, offset, value)
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(length), nil
 }
 
-func incrby(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 2 {
-		return nil, newErrWrongNumberOfArguments("incrby")
+// lcs executes LCS key1 key2 [LEN] [IDX] [MINMATCHLEN min-match-len] [WITHMATCHLEN]
+func lcs(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key1, key2 := args[0], args[1]
+	var getLen, getIdx, withMatchLen bool
+	var minMatchLen int64
+	for i := 2; i < len(args); i++ {
+		option := strings.ToLower(string(args[i]))
+		switch {
+		case option == "len":
+			getLen = true
+		case option == "idx":
+			getIdx = true
+		case option == "withmatchlen":
+			withMatchLen = true
+		case option == "minmatchlen" && i+1 < len(args):
+			n, err := parseInteger(args[i+1])
+			if err != nil {
+				return nil, err
+			}
+			minMatchLen = n
+			i++
+		default:
+			return nil, errSyntax
+		}
+	}
+	if getLen && getIdx {
+		return nil, newError("ERR If you want both the length and indexes, please just use IDX.")
 	}
 
-	key, increment := args[0], args[1]
-	return ds.IncrBy(key, increment)
+	result, err := rds.LCS(key1, key2, getIdx)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case getLen:
+		return integerReply(len(result.LCS)), nil
+	case !getIdx:
+		return bulkReply(result.LCS), nil
+	}
+
+	matches := make(arrayReply, 0, len(result.Matches))
+	for _, m := range result.Matches {
+		if int64(m.Len) < minMatchLen {
+			continue
+		}
+		match := arrayReply{
+			arrayReply{integerReply(m.A[0]), integerReply(m.A[1])},
+			arrayReply{integerReply(m.B[0]), integerReply(m.B[1])},
+		}
+		if withMatchLen {
+			match = append(match, integerReply(m.Len))
+		}
+		matches = append(matches, match)
+	}
+	return mapReply{
+		bulkReply("matches"), matches,
+		bulkReply("len"), integerReply(len(result.LCS)),
+	}, nil
 }
 
-func incrbyfloat(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 2 {
-		return nil, newErrWrongNumberOfArguments("incrbyfloat")
+func strappend(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key, value := args[0], args[1]
+	length, err := ds.Append(key, value)
+	if err != nil {
+		return nil, err
 	}
+	return integerReply(length), nil
+}
 
-	key, increment := args[0], args[1]
-	return ds.IncrByFloat(key, increment)
+func getdel(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	value, err := ds.GetDel(key)
+	if err != nil {
+		return nil, err
+	}
+	return bulkReply(value), nil
 }
 
-func decr(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 1 {
-		return nil, newErrWrongNumberOfArguments("decr")
+func getset(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key, value := args[0], args[1]
+	oldValue, err := ds.GetSet(key, value)
+	if err != nil {
+		return nil, err
+	}
+	if oldValue == nil {
+		return nullBulkReply, nil
 	}
+	return bulkReply(oldValue), nil
+}
 
+func incr(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key := args[0]
-	return ds.Decr(key)
+	return integer(ds.Incr(key))
+}
+
+func incrby(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key, increment := args[0], args[1]
+	return integer(ds.IncrBy(key, increment))
 }
 
-func decrby(ds *ds.DS, args ...[]byte) (any, error) {
-	if len(args) != 2 {
-		return nil, newErrWrongNumberOfArguments("decrby")
+func incrbyfloat(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key, increment := args[0], args[1]
+	value, err := ds.IncrByFloat(key, increment)
+	if err != nil {
+		return nil, err
 	}
+	return bulkReply(utils.Float64ToBytes(value)), nil
+}
 
+func decr(ds *ds.DS, args ...[]byte) (Reply, error) {
+	key := args[0]
+	return integer(ds.Decr(key))
+}
+
+func decrby(ds *ds.DS, args ...[]byte) (Reply, error) {
 	key, increment := args[0], args[1]
-	return ds.DecrBy(key, increment)
+	return integer(ds.DecrBy(key, increment))
+}
+
+// integer wraps the result of an integer operation as a reply
+func integer(n int64, err error) (Reply, error) {
+	if err != nil {
+		return nil, err
+	}
+	return integerReply(n), nil
 }
@@ -0,0 +1,37 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+func TestParseExpiration(t *testing.T) {
+	e, err := parseExpiration("set", "ex", []byte("10"))
+	assert.Nil(t, err)
+	assert.Equal(t, ds.Expiration{TTL: 10 * time.Second}, e)
+	e, err = parseExpiration("set", "pxat", []byte("1700000000123"))
+	assert.Nil(t, err)
+	assert.Equal(t, ds.Expiration{ExpireAt: time.UnixMilli(1700000000123)}, e)
+
+	// expirations not representable in Unix time in nanoseconds are invalid
+	tests := []struct {
+		option string
+		arg    string
+	}{
+		{"ex", "0"},
+		{"px", "-1"},
+		{"ex", "10000000000"},
+		{"ex", "9223372036854775"},
+		{"px", "9000000000000"},
+		{"exat", "32503680000"},
+		{"pxat", "9223372036854775807"},
+	}
+	for _, tt := range tests {
+		_, err := parseExpiration("set", tt.option, []byte(tt.arg))
+		assert.Equal(t, newErrInvalidExpireTime("set"), err, "%s %s", tt.option, tt.arg)
+	}
+}
@@ -0,0 +1,529 @@
+package client
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+var (
+	errTSInvalidTimestamp     = newError("ERR TSDB: invalid timestamp, must be a nonnegative integer")
+	errTSInvalidValue         = newError("ERR TSDB: invalid value")
+	errTSInvalidRetention     = newError("ERR TSDB: Couldn't parse RETENTION")
+	errTSInvalidChunkSize     = newError("ERR TSDB: CHUNK_SIZE value must be a multiple of 8 in the range [48 .. 1048576]")
+	errTSInvalidEncoding      = newError("ERR TSDB: unknown ENCODING parameter")
+	errTSInvalidPolicy        = newError("ERR TSDB: Unknown DUPLICATE_POLICY")
+	errTSInvalidLabels        = newError("ERR TSDB: Invalid labels")
+	errTSInvalidFrom          = newError("ERR TSDB: wrong fromTimestamp")
+	errTSInvalidTo            = newError("ERR TSDB: wrong toTimestamp")
+	errTSInvalidCount         = newError("ERR TSDB: Couldn't parse COUNT")
+	errTSInvalidValueFilter   = newError("ERR TSDB: Couldn't parse MIN or MAX")
+	errTSInvalidAggregator    = newError("ERR TSDB: Unknown aggregation type")
+	errTSInvalidBucket        = newError("ERR TSDB: bucketDuration must be greater than zero")
+	errTSInvalidAlign         = newError("ERR TSDB: unknown ALIGN parameter")
+	errTSInvalidBucketTS      = newError("ERR TSDB: unknown BUCKETTIMESTAMP parameter")
+	errTSInvalidFilter        = newError("ERR TSDB: failed parsing labels")
+	errTSMissingFilter        = newError("ERR TSDB: missing FILTER argument")
+	errTSMissingMatcher       = newError("ERR TSDB: please provide at least one matcher")
+	errTSLabelsConflict       = newError("ERR TSDB: cannot accept WITHLABELS and SELECT_LABELS together")
+	errTSDuplicateAggregation = newError("ERR TSDB: AGGREGATION must be given once")
+)
+
+var duplicatePolicies = []string{"block", "first", "last", "min", "max", "sum"}
+
+var tsAggregators = []string{"avg", "sum", "min", "max", "count", "first", "last"}
+
+// parseDuplicatePolicy parses the name of a duplicate policy, which are in the order of ds.DuplicatePolicy
+func parseDuplicatePolicy(arg []byte) (ds.DuplicatePolicy, error) {
+	name := strings.ToLower(string(arg))
+	for i, policy := range duplicatePolicies {
+		if name == policy {
+			return ds.DuplicatePolicy(i), nil
+		}
+	}
+	return 0, errTSInvalidPolicy
+}
+
+// parseTSTimestamp parses a timestamp of a sample, which is the current time if it is *
+func parseTSTimestamp(arg []byte) (int64, error) {
+	if string(arg) == "*" {
+		return time.Now().UnixMilli(), nil
+	}
+	ts, err := strconv.ParseInt(string(arg), 10, 64)
+	if err != nil || ts < 0 {
+		return 0, errTSInvalidTimestamp
+	}
+	return ts, nil
+}
+
+// parseTSOptions parses options of TS.CREATE, or TS.ADD if add is true, from args[i]
+func parseTSOptions(args [][]byte, i int, add bool) (ds.TSAddOptions, error) {
+	opts := ds.TSAddOptions{TSOptions: ds.DefaultTSOptions}
+	for ; i < len(args); i++ {
+		option := strings.ToUpper(string(args[i]))
+		if option == "LABELS" {
+			labels := args[i+1:]
+			if len(labels) == 0 || len(labels)%2 != 0 {
+				return opts, errTSInvalidLabels
+			}
+			for j := 0; j < len(labels); j += 2 {
+				opts.Labels = append(opts.Labels, ds.TSLabel{Name: string(labels[j]), Value: string(labels[j+1])})
+			}
+			return opts, nil
+		}
+		if i+1 >= len(args) {
+			return opts, errSyntax
+		}
+		i++
+		switch option {
+		case "RETENTION":
+			retention, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil || retention < 0 {
+				return opts, errTSInvalidRetention
+			}
+			opts.Retention = retention
+		case "ENCODING":
+			switch strings.ToUpper(string(args[i])) {
+			case "COMPRESSED":
+				opts.Uncompressed = false
+			case "UNCOMPRESSED":
+				opts.Uncompressed = true
+			default:
+				return opts, errTSInvalidEncoding
+			}
+		case "CHUNK_SIZE":
+			size, err := strconv.Atoi(string(args[i]))
+			if err != nil || size < 48 || size > 1048576 || size%8 != 0 {
+				return opts, errTSInvalidChunkSize
+			}
+			opts.ChunkSize = size
+		case "DUPLICATE_POLICY":
+			policy, err := parseDuplicatePolicy(args[i])
+			if err != nil {
+				return opts, err
+			}
+			opts.DuplicatePolicy = policy
+		case "ON_DUPLICATE":
+			if !add {
+				return opts, errSyntax
+			}
+			policy, err := parseDuplicatePolicy(args[i])
+			if err != nil {
+				return opts, err
+			}
+			opts.OnDuplicate = &policy
+		default:
+			return opts, errSyntax
+		}
+	}
+	return opts, nil
+}
+
+// parseTSRangeOptions parses from and to at args[i] and args[i+1], and the following options of TS.RANGE.
+//
+// It stops at an unknown option and returns its index, so that TS.MRANGE can parse its own options.
+func parseTSRangeOptions(args [][]byte, i int) (ds.TSRangeOptions, int, error) {
+	opts := ds.TSRangeOptions{To: math.MaxInt64}
+	var err error
+	if string(args[i]) != "-" {
+		if opts.From, err = strconv.ParseInt(string(args[i]), 10, 64); err != nil || opts.From < 0 {
+			return opts, i, errTSInvalidFrom
+		}
+	}
+	if string(args[i+1]) != "+" {
+		if opts.To, err = strconv.ParseInt(string(args[i+1]), 10, 64); err != nil || opts.To < 0 {
+			return opts, i, errTSInvalidTo
+		}
+	}
+
+	var align []byte
+	for i += 2; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "LATEST":
+			// there are no compaction rules, so all buckets are complete
+		case "FILTER_BY_TS":
+			for ; i+1 < len(args); i++ {
+				ts, err := strconv.ParseInt(string(args[i+1]), 10, 64)
+				if err != nil {
+					break
+				}
+				opts.FilterByTS = append(opts.FilterByTS, ts)
+			}
+			if len(opts.FilterByTS) == 0 {
+				return opts, i, errSyntax
+			}
+		case "FILTER_BY_VALUE":
+			if i+2 >= len(args) {
+				return opts, i, errSyntax
+			}
+			minValue, err1 := strconv.ParseFloat(string(args[i+1]), 64)
+			maxValue, err2 := strconv.ParseFloat(string(args[i+2]), 64)
+			if err1 != nil || err2 != nil {
+				return opts, i, errTSInvalidValueFilter
+			}
+			opts.FilterByValue, opts.MinValue, opts.MaxValue = true, minValue, maxValue
+			i += 2
+		case "COUNT":
+			if i+1 >= len(args) {
+				return opts, i, errSyntax
+			}
+			i++
+			count, err := strconv.Atoi(string(args[i]))
+			if err != nil || count <= 0 {
+				return opts, i, errTSInvalidCount
+			}
+			opts.Count = count
+		case "ALIGN":
+			if i+1 >= len(args) {
+				return opts, i, errSyntax
+			}
+			i++
+			align = args[i]
+		case "AGGREGATION":
+			if opts.Aggregation != nil {
+				return opts, i, errTSDuplicateAggregation
+			}
+			if i+2 >= len(args) {
+				return opts, i, errSyntax
+			}
+			aggregation, err := parseTSAggregation(args[i+1], args[i+2])
+			if err != nil {
+				return opts, i, err
+			}
+			opts.Aggregation = aggregation
+			i += 2
+		case "BUCKETTIMESTAMP":
+			if opts.Aggregation == nil || i+1 >= len(args) {
+				return opts, i, errSyntax
+			}
+			i++
+			switch string(args[i]) {
+			case "-", "start":
+				opts.Aggregation.BucketTimestamp = ds.TSBucketStart
+			case "+", "end":
+				opts.Aggregation.BucketTimestamp = ds.TSBucketEnd
+			case "~", "mid":
+				opts.Aggregation.BucketTimestamp = ds.TSBucketMid
+			default:
+				return opts, i, errTSInvalidBucketTS
+			}
+		default:
+			return opts, i, setTSAlign(&opts, align)
+		}
+	}
+	return opts, i, setTSAlign(&opts, align)
+}
+
+// parseTSAggregation parses an aggregator, whose names are in the order of ds.TSAggregator, and a bucket duration
+func parseTSAggregation(aggregator, bucketDuration []byte) (*ds.TSAggregation, error) {
+	name := strings.ToLower(string(aggregator))
+	for i, a := range tsAggregators {
+		if name != a {
+			continue
+		}
+		duration, err := strconv.ParseInt(string(bucketDuration), 10, 64)
+		if err != nil || duration <= 0 {
+			return nil, errTSInvalidBucket
+		}
+		return &ds.TSAggregation{Aggregator: ds.TSAggregator(i), BucketDuration: duration}, nil
+	}
+	return nil, errTSInvalidAggregator
+}
+
+// setTSAlign sets the alignment of buckets, which is either a timestamp, or the start or the end of the range
+func setTSAlign(opts *ds.TSRangeOptions, align []byte) error {
+	if align == nil {
+		return nil
+	}
+	if opts.Aggregation == nil {
+		return errSyntax
+	}
+	switch string(align) {
+	case "-", "start":
+		opts.Aggregation.Align = opts.From
+	case "+", "end":
+		opts.Aggregation.Align = opts.To
+	default:
+		ts, err := strconv.ParseInt(string(align), 10, 64)
+		if err != nil {
+			return errTSInvalidAlign
+		}
+		opts.Aggregation.Align = ts
+	}
+	return nil
+}
+
+// parseTSFilter parses a matcher of TS.MRANGE, such as label=value, label!=(value1,value2) and label=
+func parseTSFilter(arg []byte) (ds.TSFilter, error) {
+	var f ds.TSFilter
+	expr := string(arg)
+	i := strings.Index(expr, "=")
+	if i <= 0 {
+		return f, errTSInvalidFilter
+	}
+	f.Label = expr[:i]
+	if strings.HasSuffix(f.Label, "!") {
+		f.Label, f.Negate = f.Label[:len(f.Label)-1], true
+	}
+	value := expr[i+1:]
+	if f.Label == "" {
+		return f, errTSInvalidFilter
+	}
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		f.Values = strings.Split(value[1:len(value)-1], ",")
+	} else {
+		f.Values = []string{value}
+	}
+	return f, nil
+}
+
+// tsSampleReply replies a sample as an array of the timestamp and the value
+func tsSampleReply(s ds.TSSample) Reply {
+	return arrayReply{integerReply(s.Timestamp), doubleReply(s.Value)}
+}
+
+func tsSamplesReply(samples []ds.TSSample) arrayReply {
+	replies := make(arrayReply, len(samples))
+	for i, s := range samples {
+		replies[i] = tsSampleReply(s)
+	}
+	return replies
+}
+
+// tsLabelsReply is label-value pairs, which is a map in RESP3 and an array of pairs in RESP2
+type tsLabelsReply [][2]Reply
+
+// newTSLabelsReply replies labels, or only the selected ones if selected is not nil, which are null if missing
+func newTSLabelsReply(labels []ds.TSLabel, selected []string) tsLabelsReply {
+	var r tsLabelsReply
+	if selected == nil {
+		for _, label := range labels {
+			r = append(r, [2]Reply{bulkReply(label.Name), bulkReply(label.Value)})
+		}
+		return r
+	}
+	for _, name := range selected {
+		var value Reply = nullBulkReply
+		for _, label := range labels {
+			if label.Name == name {
+				value = bulkReply(label.Value)
+			}
+		}
+		r = append(r, [2]Reply{bulkReply(name), value})
+	}
+	return r
+}
+
+func (r tsLabelsReply) writeTo(w *ReplyWriter) {
+	if w.protocol == resp3 {
+		w.WriteMap(len(r))
+	} else {
+		w.WriteArray(len(r))
+	}
+	for _, pair := range r {
+		if w.protocol != resp3 {
+			w.WriteArray(2)
+		}
+		pair[0].writeTo(w)
+		pair[1].writeTo(w)
+	}
+}
+
+// tsSeriesReply is time series replied by TS.MRANGE, which is a map of keys and their labels and samples in RESP3,
+// and an array of arrays of keys, labels and samples in RESP2
+type tsSeriesReply struct {
+	series []ds.TSSeries
+	labels []string // the labels replied, all if it is nil
+}
+
+func (r tsSeriesReply) writeTo(w *ReplyWriter) {
+	if w.protocol == resp3 {
+		w.WriteMap(len(r.series))
+	} else {
+		w.WriteArray(len(r.series))
+	}
+	for _, s := range r.series {
+		if w.protocol == resp3 {
+			bulkReply(s.Key).writeTo(w)
+			w.WriteArray(2)
+		} else {
+			w.WriteArray(3)
+			bulkReply(s.Key).writeTo(w)
+		}
+		newTSLabelsReply(s.Labels, r.labels).writeTo(w)
+		tsSamplesReply(s.Samples).writeTo(w)
+	}
+}
+
+// tsCreate executes TS.CREATE key [options]
+func tsCreate(rds *ds.DS, args ...[]byte) (Reply, error) {
+	opts, err := parseTSOptions(args, 1, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := rds.TSCreate(args[0], opts.TSOptions); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// tsAdd executes TS.ADD key timestamp value [options]
+func tsAdd(rds *ds.DS, args ...[]byte) (Reply, error) {
+	timestamp, err := parseTSTimestamp(args[1])
+	if err != nil {
+		return nil, err
+	}
+	value, err := strconv.ParseFloat(string(args[2]), 64)
+	if err != nil || math.IsNaN(value) {
+		return nil, errTSInvalidValue
+	}
+	opts, err := parseTSOptions(args, 3, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := rds.TSAdd(args[0], timestamp, value, opts); err != nil {
+		return nil, err
+	}
+	return integerReply(timestamp), nil
+}
+
+// tsGet executes TS.GET key [LATEST]
+func tsGet(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 || (len(args) == 2 && strings.ToUpper(string(args[1])) != "LATEST") {
+		return nil, errSyntax
+	}
+	sample, err := rds.TSGet(args[0])
+	if err != nil {
+		return nil, err
+	}
+	if sample == nil {
+		return arrayReply{}, nil
+	}
+	return tsSampleReply(*sample), nil
+}
+
+// tsInfo executes TS.INFO key [DEBUG]
+func tsInfo(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) > 2 || (len(args) == 2 && strings.ToUpper(string(args[1])) != "DEBUG") {
+		return nil, errSyntax
+	}
+	info, err := rds.TSInfo(args[0])
+	if err != nil {
+		return nil, err
+	}
+	chunkType := "compressed"
+	if info.Uncompressed {
+		chunkType = "uncompressed"
+	}
+	return mapReply{
+		bulkReply("totalSamples"), integerReply(info.TotalSamples),
+		bulkReply("firstTimestamp"), integerReply(info.FirstTimestamp),
+		bulkReply("lastTimestamp"), integerReply(info.LastTimestamp),
+		bulkReply("retentionTime"), integerReply(info.Retention),
+		bulkReply("chunkCount"), integerReply(info.Chunks),
+		bulkReply("chunkSize"), integerReply(info.ChunkSize),
+		bulkReply("chunkType"), bulkReply(chunkType),
+		bulkReply("duplicatePolicy"), bulkReply(duplicatePolicies[info.DuplicatePolicy]),
+		bulkReply("labels"), newTSLabelsReply(info.Labels, nil),
+	}, nil
+}
+
+// tsRange executes TS.RANGE key fromTimestamp toTimestamp [options]
+func tsRange(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return timeSeriesRange(rds, args, false)
+}
+
+// tsRevRange executes TS.REVRANGE key fromTimestamp toTimestamp [options]
+func tsRevRange(rds *ds.DS, args ...[]byte) (Reply, error) {
+	return timeSeriesRange(rds, args, true)
+}
+
+func timeSeriesRange(rds *ds.DS, args [][]byte, reverse bool) (Reply, error) {
+	opts, i, err := parseTSRangeOptions(args, 1)
+	if err != nil {
+		return nil, err
+	}
+	if i < len(args) {
+		return nil, errSyntax
+	}
+	opts.Reverse = reverse
+	samples, err := rds.TSRange(args[0], opts)
+	if err != nil {
+		return nil, err
+	}
+	return tsSamplesReply(samples), nil
+}
+
+// tsMRange executes TS.MRANGE fromTimestamp toTimestamp [options] FILTER filterExpr...
+func tsMRange(client *RedisClient, args ...[]byte) (Reply, error) {
+	return timeSeriesMRange(client, args, false)
+}
+
+// tsMRevRange executes TS.MREVRANGE fromTimestamp toTimestamp [options] FILTER filterExpr...
+func tsMRevRange(client *RedisClient, args ...[]byte) (Reply, error) {
+	return timeSeriesMRange(client, args, true)
+}
+
+// timeSeriesMRange gets samples of time series matching filters, except time series the client is not allowed to access
+func timeSeriesMRange(client *RedisClient, args [][]byte, reverse bool) (Reply, error) {
+	opts, i, err := parseTSRangeOptions(args, 0)
+	if err != nil {
+		return nil, err
+	}
+	opts.Reverse = reverse
+
+	reply := tsSeriesReply{labels: []string{}}
+	var withLabels bool
+	for ; i < len(args); i++ {
+		switch strings.ToUpper(string(args[i])) {
+		case "WITHLABELS":
+			if len(reply.labels) > 0 {
+				return nil, errTSLabelsConflict
+			}
+			reply.labels, withLabels = nil, true
+		case "SELECTED_LABELS":
+			if withLabels {
+				return nil, errTSLabelsConflict
+			}
+			for ; i+1 < len(args) && strings.ToUpper(string(args[i+1])) != "FILTER"; i++ {
+				reply.labels = append(reply.labels, string(args[i+1]))
+			}
+			if len(reply.labels) == 0 {
+				return nil, errSyntax
+			}
+		case "FILTER":
+			var filters []ds.TSFilter
+			var matched bool
+			for _, arg := range args[i+1:] {
+				f, err := parseTSFilter(arg)
+				if err != nil {
+					return nil, err
+				}
+				matched = matched || (!f.Negate && (len(f.Values) > 1 || f.Values[0] != ""))
+				filters = append(filters, f)
+			}
+			if len(filters) == 0 {
+				return nil, errTSMissingFilter
+			}
+			if !matched {
+				return nil, errTSMissingMatcher
+			}
+			series, err := client.DB.TSMRange(filters, opts)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range series {
+				if client.canAccessKey(s.Key) {
+					reply.series = append(reply.series, s)
+				}
+			}
+			return reply, nil
+		default:
+			return nil, errSyntax
+		}
+	}
+	return nil, errTSMissingFilter
+}
@@ -0,0 +1,28 @@
+package client
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeSeries_ACL(t *testing.T) {
+	acl := NewACL("", NewCommandTable())
+	assert.Nil(t, acl.setUser("alice", []string{"on", ">secret", "~temp:*", "+@all"}))
+	db := newTestingDB(t)
+	admin, conn := newTestingConn(acl, "127.0.0.1:6379"), newTestingConn(acl, "127.0.0.1:6379")
+	admin.Context().(*RedisClient).DB = db
+	conn.Context().(*RedisClient).DB = db
+	assert.Equal(t, ":1", admin.execute("TS.ADD", "temp:1", "1", "20", "LABELS", "sensor", "room"))
+	assert.Equal(t, ":1", admin.execute("TS.ADD", "secret:1", "1", "30", "LABELS", "sensor", "room"))
+	assert.Equal(t, "+OK", conn.execute("AUTH", "alice", "secret"))
+	assert.Equal(t, "-NOPERM No permissions to access a key", conn.execute("TS.GET", "secret:1"))
+
+	// time series of other keys are not replied
+	for _, command := range []string{"TS.MRANGE", "TS.MREVRANGE"} {
+		assert.Equal(t, "*1", conn.execute(command, "-", "+", "FILTER", "sensor=room"))
+		assert.Contains(t, string(conn.replies), "temp:1")
+		assert.NotContains(t, string(conn.replies), "secret:1")
+	}
+	assert.Equal(t, "*2", admin.execute("TS.MRANGE", "-", "+", "FILTER", "sensor=room"))
+}
@@ -0,0 +1,164 @@
+package client
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// topKMaxIncrement is the maximum increment of TOPK.INCRBY, since a counter decays once for each unit of an increment
+const topKMaxIncrement = 100000
+
+var (
+	errTopKInvalidK         = newError("ERR TopK: invalid k")
+	errTopKInvalidWidth     = newError("ERR TopK: invalid width")
+	errTopKInvalidDepth     = newError("ERR TopK: invalid depth")
+	errTopKInvalidDecay     = newError("ERR TopK: invalid decay value. must be '<= 1' & '> 0'")
+	errTopKInvalidIncrement = newError("ERR TopK: increment must be an integer greater or equal to 0 and smaller or equal to 100,000")
+)
+
+// topKExpelledReply replies items expelled from the list, which are null if none
+func topKExpelledReply(expelled [][]byte) Reply {
+	replies := make(arrayReply, len(expelled))
+	for i, item := range expelled {
+		if item == nil {
+			replies[i] = nullBulkReply
+		} else {
+			replies[i] = bulkReply(item)
+		}
+	}
+	return replies
+}
+
+// topkReserve executes TOPK.RESERVE key topk [width depth decay]
+func topkReserve(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args) != 2 && len(args) != 5 {
+		return nil, newErrWrongNumberOfArguments("topk.reserve")
+	}
+	opts := ds.DefaultTopKOptions
+	k, err := strconv.ParseUint(string(args[1]), 10, 32)
+	if err != nil || k == 0 {
+		return nil, errTopKInvalidK
+	}
+	opts.K = uint32(k)
+	if len(args) == 5 {
+		width, err := strconv.ParseUint(string(args[2]), 10, 32)
+		if err != nil || width == 0 {
+			return nil, errTopKInvalidWidth
+		}
+		depth, err := strconv.ParseUint(string(args[3]), 10, 32)
+		if err != nil || depth == 0 {
+			return nil, errTopKInvalidDepth
+		}
+		decay, err := strconv.ParseFloat(string(args[4]), 64)
+		if err != nil || decay <= 0 || decay > 1 {
+			return nil, errTopKInvalidDecay
+		}
+		opts.Width, opts.Depth, opts.Decay = uint32(width), uint32(depth), decay
+	}
+
+	if err := rds.TopKReserve(args[0], opts); err != nil {
+		return nil, err
+	}
+	return okReply, nil
+}
+
+// topkAdd executes TOPK.ADD key item [item ...]
+func topkAdd(rds *ds.DS, args ...[]byte) (Reply, error) {
+	expelled, err := rds.TopKAdd(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	return topKExpelledReply(expelled), nil
+}
+
+// topkIncrBy executes TOPK.INCRBY key item increment [item increment ...]
+func topkIncrBy(rds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args)%2 == 0 {
+		return nil, newErrWrongNumberOfArguments("topk.incrby")
+	}
+	items := make([][]byte, 0, len(args)/2)
+	increments := make([]uint32, 0, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		increment, err := strconv.ParseUint(string(args[i+1]), 10, 32)
+		if err != nil || increment > topKMaxIncrement {
+			return nil, errTopKInvalidIncrement
+		}
+		items = append(items, args[i])
+		increments = append(increments, uint32(increment))
+	}
+
+	expelled, err := rds.TopKIncrBy(args[0], items, increments)
+	if err != nil {
+		return nil, err
+	}
+	return topKExpelledReply(expelled), nil
+}
+
+// topkQuery executes TOPK.QUERY key item [item ...]
+func topkQuery(rds *ds.DS, args ...[]byte) (Reply, error) {
+	results, err := rds.TopKQuery(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(results))
+	for i, ok := range results {
+		replies[i] = boolReply(ok)
+	}
+	return replies, nil
+}
+
+// topkCount executes TOPK.COUNT key item [item ...]
+func topkCount(rds *ds.DS, args ...[]byte) (Reply, error) {
+	counts, err := rds.TopKCount(args[0], args[1:]...)
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, len(counts))
+	for i, n := range counts {
+		replies[i] = integerReply(n)
+	}
+	return replies, nil
+}
+
+// topkList executes TOPK.LIST key [WITHCOUNT]
+func topkList(rds *ds.DS, args ...[]byte) (Reply, error) {
+	var withCount bool
+	if len(args) > 2 {
+		return nil, errSyntax
+	}
+	if len(args) == 2 {
+		if strings.ToUpper(string(args[1])) != "WITHCOUNT" {
+			return nil, errSyntax
+		}
+		withCount = true
+	}
+
+	list, err := rds.TopKList(args[0])
+	if err != nil {
+		return nil, err
+	}
+	replies := make(arrayReply, 0, len(list)*2)
+	for _, item := range list {
+		replies = append(replies, bulkReply(item.Item))
+		if withCount {
+			replies = append(replies, integerReply(item.Count))
+		}
+	}
+	return replies, nil
+}
+
+// topkInfo executes TOPK.INFO key
+func topkInfo(rds *ds.DS, args ...[]byte) (Reply, error) {
+	opts, err := rds.TopKInfo(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return mapReply{
+		bulkReply("k"), integerReply(opts.K),
+		bulkReply("width"), integerReply(opts.Width),
+		bulkReply("depth"), integerReply(opts.Depth),
+		bulkReply("decay"), doubleReply(opts.Decay),
+	}, nil
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"strconv"
+
+	"github.com/saint-yellow/baradb"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+func zadd(ds *ds.DS, args ...[]byte) (Reply, error) {
+	if len(args)%2 != 1 {
+		return nil, newErrWrongNumberOfArguments("zadd")
+	}
+
+	key := args[0]
+	var count int
+	for i := 1; i < len(args); i += 2 {
+		score, err := strconv.ParseFloat(string(args[i]), 64)
+		if err != nil {
+			return nil, newError("ERR value is not a valid float")
+		}
+		isNew, err := ds.ZAdd(key, score, args[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if isNew {
+			count++
+		}
+	}
+	return integerReply(count), nil
+}
+
+func zscore(rds *ds.DS, args ...[]byte) (Reply, error) {
+	key, member := args[0], args[1]
+	if !rds.Exists(key) {
+		return nil, baradb.ErrKeyNotFound
+	}
+	score, err := rds.ZScore(key, member)
+	if err != nil {
+		return nil, err
+	}
+	return doubleReply(score), nil
+}
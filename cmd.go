@@ -1,32 +1,46 @@
 package main
 
 import (
+	"flag"
+	"os"
+	"strconv"
+
 	"github.com/saint-yellow/baradb"
-	"github.com/tidwall/redcon"
 
-	"github.com/saint-yellow/baradb-redis/client"
 	"github.com/saint-yellow/baradb-redis/ds"
 	"github.com/saint-yellow/baradb-redis/server"
 )
 
-const address = "127.0.0.1:6378"
-
 func main() {
+	opts := server.DefaultOptions
+	flag.StringVar(&opts.Address, "address", opts.Address, "TCP address to listen on, empty to disable plain TCP")
+	flag.StringVar(&opts.UnixSocket, "unixsocket", opts.UnixSocket, "path of the Unix domain socket to listen on")
+	flag.Func("unixsocketperm", "permission of the Unix domain socket in octal (default 700)", func(s string) error {
+		perm, err := strconv.ParseUint(s, 8, 32)
+		opts.UnixSocketPerm = os.FileMode(perm)
+		return err
+	})
+	flag.StringVar(&opts.RequirePass, "requirepass", opts.RequirePass, "password clients need to authenticate with")
+	flag.StringVar(&opts.ACLFile, "aclfile", opts.ACLFile, "path of the ACL file loaded at startup")
+	flag.StringVar(&opts.TLSAddress, "tls-address", opts.TLSAddress, "TCP address to listen on with TLS")
+	flag.StringVar(&opts.TLSCertFile, "tls-cert-file", opts.TLSCertFile, "path of the server certificate")
+	flag.StringVar(&opts.TLSKeyFile, "tls-key-file", opts.TLSKeyFile, "path of the server private key")
+	flag.StringVar(&opts.TLSCACertFile, "tls-ca-cert-file", opts.TLSCACertFile, "path of CA certificates to verify clients")
+	flag.BoolVar(&opts.TLSAuthClients, "tls-auth-clients", opts.TLSAuthClients, "require clients to present a certificate")
+	flag.StringVar(&opts.TLSMinVersion, "tls-min-version", opts.TLSMinVersion, "minimum TLS version, TLSv1.2 or TLSv1.3")
+	flag.Parse()
+
 	service, err := ds.New(baradb.DefaultDBOptions)
 	if err != nil {
 		panic(err)
 	}
 
-	rs := server.New(service)
-	innerServer := redcon.NewServer(
-		address,
-		client.ExecuteClientCommand,
-		rs.Accept,
-		func(conn redcon.Conn, err error) {},
-	)
-	rs.Server = innerServer
+	rs, err := server.New(service, opts)
+	if err != nil {
+		panic(err)
+	}
 
 	go rs.Listen()
-	<-rs.Signal
+	rs.WaitForSignal()
 	rs.Stop()
 }
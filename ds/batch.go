@@ -0,0 +1,109 @@
+package ds
+
+import (
+	"time"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// Batch groups writes of strings and deletions of keys, which are committed atomically.
+//
+// Staged writes are invisible until the batch is committed.
+// Like SET and DEL, collections overwritten or deleted by the batch are reclaimed,
+// and hashes are removed from search indexes.
+type Batch struct {
+	ds     *DS
+	keys   [][]byte          // staged keys in order
+	values map[string][]byte // encoded values of staged keys, which are nil for deletions
+}
+
+// NewBatch initializes a write batch
+func (ds *DS) NewBatch() *Batch {
+	return &Batch{
+		ds:     ds,
+		values: make(map[string][]byte),
+	}
+}
+
+// Set stages redis SET
+//
+// If the ttl is 0, then the key will not expire.
+func (b *Batch) Set(key, value []byte, ttl time.Duration) error {
+	expire, err := Expiration{TTL: ttl}.expire(0)
+	if err != nil {
+		return err
+	}
+	return b.stage(key, encodeString(value, expire))
+}
+
+// Del stages redis DEL
+func (b *Batch) Del(key []byte) error {
+	return b.stage(key, nil)
+}
+
+// stage stages a write of a key, where the last write of a key wins
+func (b *Batch) stage(key, encValue []byte) error {
+	if len(key) == 0 {
+		return baradb.ErrKeyIsEmpty
+	}
+	if _, ok := b.values[string(key)]; !ok {
+		b.keys = append(b.keys, key)
+	}
+	b.values[string(key)] = encValue
+	return nil
+}
+
+// Commit writes all staged writes at once
+func (b *Batch) Commit() error {
+	ds := b.ds
+	defer ds.search.locks.lockAll(b.keys)()
+
+	// internal keys of collections and entries of search indexes of hashes
+	// are found by the values before the batch is committed
+	var prefixes [][]byte
+	changes := &searchChanges{}
+	for _, key := range b.keys {
+		prefix, err := ds.collectionPrefix(key)
+		if err != nil {
+			return err
+		}
+		if prefix != nil {
+			prefixes = append(prefixes, prefix)
+		}
+		c, err := ds.hashDeletionChanges(key)
+		if err != nil {
+			return err
+		}
+		changes.puts = append(changes.puts, c.puts...)
+		changes.deletes = append(changes.deletes, c.deletes...)
+	}
+
+	opts := baradb.DefaultWriteBatchOptions
+	if n := len(b.keys) + changes.len(); n > opts.MaxBatchNumber {
+		opts.MaxBatchNumber = n
+	}
+	wb := ds.db.NewWriteBatch(opts)
+	for _, key := range b.keys {
+		var err error
+		if encValue := b.values[string(key)]; encValue != nil {
+			err = wb.Put(key, encValue)
+		} else {
+			err = wb.Delete(key)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	if err := changes.apply(wb); err != nil {
+		return err
+	}
+	if err := wb.Commit(); err != nil {
+		return err
+	}
+
+	for _, prefix := range prefixes {
+		ds.reclaimer.add(prefix)
+	}
+	b.keys, b.values = nil, make(map[string][]byte)
+	return nil
+}
@@ -0,0 +1,80 @@
+package ds
+
+import (
+	"testing"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_Batch(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	var value []byte
+	var err error
+
+	ds.Set([]byte("key-1"), []byte("value-1"), 0)
+
+	batch := ds.NewBatch()
+	err = batch.Set([]byte("key-2"), []byte("value-2"), 0)
+	assert.Nil(t, err)
+	err = batch.Del([]byte("key-1"))
+	assert.Nil(t, err)
+
+	// staged writes are invisible before committing
+	value, err = ds.Get([]byte("key-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-1"), value)
+	assert.False(t, ds.Exists([]byte("key-2")))
+
+	err = batch.Commit()
+	assert.Nil(t, err)
+
+	value, err = ds.Get([]byte("key-1"))
+	assert.Equal(t, baradb.ErrKeyNotFound, err)
+	assert.Nil(t, value)
+	value, err = ds.Get([]byte("key-2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), value)
+}
+
+func TestDS_BatchCollections(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	assert.Nil(t, ds.FTCreate("idx", testingSearchIndexOptions))
+	setSearchItem(t, ds, "item:1", "hello", "new", "10")
+	setSearchItem(t, ds, "item:2", "world", "old", "20")
+	var prefixes [][]byte
+	for _, key := range []string{"item:1", "item:2"} {
+		encValue, err := ds.db.Get([]byte(key))
+		assert.Nil(t, err)
+		prefixes = append(prefixes, internalKeyPrefix([]byte(key), decodeMetadata(encValue).version))
+	}
+
+	// hashes deleted or overwritten by a batch are reclaimed and removed from indexes
+	batch := ds.NewBatch()
+	assert.Nil(t, batch.Del([]byte("item:1")))
+	assert.Nil(t, batch.Set([]byte("item:2"), []byte("value-2"), 0))
+	assert.Nil(t, batch.Commit())
+	ds.reclaimer.drain(ds)
+	for _, prefix := range prefixes {
+		assert.Nil(t, ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+			assert.Fail(t, "internal key is not reclaimed", string(encKey))
+			return false, nil
+		}))
+	}
+	assert.Empty(t, searchKeys(t, ds, "*", SearchOptions{}))
+	idx := ds.search.get("idx")
+	for i := range idx.opts.Fields {
+		prefix := idx.fieldPrefix(&idx.opts.Fields[i])
+		assert.Nil(t, ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+			assert.Fail(t, "entry of index is not deleted", string(encKey))
+			return false, nil
+		}))
+	}
+	value, err := ds.Get([]byte("item:2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value-2"), value)
+}
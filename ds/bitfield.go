@@ -0,0 +1,210 @@
+package ds
+
+import (
+	"math"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// BitFieldType is a type of integers in a bitfield, such as i8 or u16.
+//
+// Signed integers have up to 64 bits, while unsigned integers have up to 63 bits.
+type BitFieldType struct {
+	Signed bool
+	Bits   int
+}
+
+func (t BitFieldType) valid() bool {
+	if t.Signed {
+		return t.Bits >= 1 && t.Bits <= 64
+	}
+	return t.Bits >= 1 && t.Bits <= 63
+}
+
+// limits gets the minimum and the maximum integers of the type
+func (t BitFieldType) limits() (int64, int64) {
+	if !t.Signed {
+		return 0, 1<<t.Bits - 1
+	}
+	if t.Bits == 64 {
+		return math.MinInt64, math.MaxInt64
+	}
+	return -1 << (t.Bits - 1), 1<<(t.Bits-1) - 1
+}
+
+// wrap truncates an integer to the bits of the type, and sign extends it for a signed type
+func (t BitFieldType) wrap(n uint64) int64 {
+	if t.Bits == 64 {
+		return int64(n)
+	}
+	mask := uint64(1)<<t.Bits - 1
+	n &= mask
+	if t.Signed && n&(1<<(t.Bits-1)) != 0 {
+		n |= ^mask
+	}
+	return int64(n)
+}
+
+// add adds an increment to an integer of the type.
+//
+// ok is false if the result overflows while the overflow behavior is OverflowFail.
+func (t BitFieldType) add(n, increment int64, overflow BitFieldOverflow) (int64, bool) {
+	min, max := t.limits()
+	up := increment > 0 && n > max-increment
+	var down bool
+	if increment < 0 {
+		// the minimum of an unsigned type is 0, which could not subtract math.MinInt64
+		down = (!t.Signed && increment == math.MinInt64) || n < min-increment
+	}
+	if !up && !down {
+		return n + increment, true
+	}
+	return t.overflow(uint64(n)+uint64(increment), up, overflow)
+}
+
+// set fits an integer into the type.
+//
+// ok is false if the integer overflows while the overflow behavior is OverflowFail.
+func (t BitFieldType) set(n int64, overflow BitFieldOverflow) (int64, bool) {
+	if !t.Signed && n < 0 {
+		// a negative integer is a huge unsigned integer
+		return t.overflow(uint64(n), true, overflow)
+	}
+	return t.add(0, n, overflow)
+}
+
+func (t BitFieldType) overflow(n uint64, up bool, overflow BitFieldOverflow) (int64, bool) {
+	min, max := t.limits()
+	switch overflow {
+	case OverflowFail:
+		return 0, false
+	case OverflowSat:
+		if up {
+			return max, true
+		}
+		return min, true
+	default:
+		return t.wrap(n), true
+	}
+}
+
+// BitFieldOverflow is a behavior of BITFIELD when an integer overflows
+type BitFieldOverflow int
+
+const (
+	OverflowWrap BitFieldOverflow = iota // wraps around
+	OverflowSat                          // saturates to the minimum or the maximum
+	OverflowFail                         // does nothing and returns nil
+)
+
+// BitFieldSubcommand is a subcommand of BITFIELD
+type BitFieldSubcommand int
+
+const (
+	BitFieldGet BitFieldSubcommand = iota
+	BitFieldSet
+	BitFieldIncrBy
+)
+
+// BitFieldOperation is an operation of BITFIELD on an integer
+type BitFieldOperation struct {
+	Subcommand BitFieldSubcommand
+	Type       BitFieldType
+	Offset     int64 // in bits
+	Value      int64 // the value of SET, or the increment of INCRBY
+	Overflow   BitFieldOverflow
+}
+
+// BitField redis BITFIELD
+//
+// It executes operations in order, and returns the result of each operation:
+// the integer of GET, the original integer of SET, and the new integer of INCRBY.
+// The result is nil if the operation fails due to an overflow.
+// The expire of the key is kept.
+func (ds *DS) BitField(key []byte, ops []BitFieldOperation) ([]*int64, error) {
+	var write bool
+	var length int64
+	for _, op := range ops {
+		if !op.Type.valid() {
+			return nil, ErrBitFieldType
+		}
+		end := op.Offset + int64(op.Type.Bits) - 1
+		if op.Offset < 0 || end > maxBitOffset {
+			return nil, ErrBitOffsetOutOfRange
+		}
+		if op.Subcommand != BitFieldGet {
+			write = true
+			if end>>3 >= length {
+				length = end>>3 + 1
+			}
+		}
+	}
+
+	if write {
+		ds.mu.Lock()
+		defer ds.mu.Unlock()
+	}
+
+	value, expire, err := ds.getString(key)
+	if err != nil && err != baradb.ErrKeyNotFound {
+		return nil, err
+	}
+	if write {
+		if int64(len(value)) > length {
+			length = int64(len(value))
+		}
+		newValue := make([]byte, length)
+		copy(newValue, value)
+		value = newValue
+	}
+
+	results := make([]*int64, len(ops))
+	for i, op := range ops {
+		t := op.Type
+		n := t.wrap(getBits(value, op.Offset, t.Bits))
+		switch op.Subcommand {
+		case BitFieldGet:
+			results[i] = &n
+		case BitFieldSet:
+			if m, ok := t.set(op.Value, op.Overflow); ok {
+				setBits(value, op.Offset, t.Bits, uint64(m))
+				results[i] = &n
+			}
+		case BitFieldIncrBy:
+			if m, ok := t.add(n, op.Value, op.Overflow); ok {
+				setBits(value, op.Offset, t.Bits, uint64(m))
+				results[i] = &m
+			}
+		}
+	}
+
+	if write {
+		if err := ds.putString(key, value, expire); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// getBits reads bits from the offset as an unsigned integer, where bits beyond the bitmap are 0
+func getBits(p []byte, offset int64, bits int) uint64 {
+	var n uint64
+	for i := int64(0); i < int64(bits); i++ {
+		pos := offset + i
+		n <<= 1
+		if index := pos >> 3; index < int64(len(p)) {
+			n |= uint64(p[index] >> (7 - pos&7) & 1)
+		}
+	}
+	return n
+}
+
+// setBits writes the lowest bits of an integer from the offset
+func setBits(p []byte, offset int64, bits int, n uint64) {
+	for i := int64(0); i < int64(bits); i++ {
+		pos := offset + i
+		bit := byte(n >> (int64(bits) - 1 - i) & 1)
+		shift := 7 - pos&7
+		p[pos>>3] = p[pos>>3]&^(1<<shift) | bit<<shift
+	}
+}
@@ -0,0 +1,110 @@
+package ds
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func bitFieldResults(results []*int64) []any {
+	values := make([]any, len(results))
+	for i, result := range results {
+		if result != nil {
+			values[i] = *result
+		}
+	}
+	return values
+}
+
+func TestDS_BitField(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	i5, u4, u2 := BitFieldType{Signed: true, Bits: 5}, BitFieldType{Bits: 4}, BitFieldType{Bits: 2}
+	key := []byte("key001")
+
+	results, err := ds.BitField(key, []BitFieldOperation{
+		{Subcommand: BitFieldIncrBy, Type: i5, Offset: 100, Value: 1},
+		{Subcommand: BitFieldGet, Type: u4, Offset: 0},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []any{int64(1), int64(0)}, bitFieldResults(results))
+
+	// the overflow behavior applies to subsequent operations
+	key = []byte("key002")
+	expected := [][]any{
+		{int64(1), int64(1)},
+		{int64(2), int64(2)},
+		{int64(3), int64(3)},
+		{int64(0), int64(3)},
+	}
+	for _, e := range expected {
+		results, err = ds.BitField(key, []BitFieldOperation{
+			{Subcommand: BitFieldIncrBy, Type: u2, Offset: 100, Value: 1},
+			{Subcommand: BitFieldIncrBy, Type: u2, Offset: 102, Value: 1, Overflow: OverflowSat},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, e, bitFieldResults(results))
+	}
+
+	results, err = ds.BitField(key, []BitFieldOperation{
+		{Subcommand: BitFieldIncrBy, Type: u2, Offset: 102, Value: 1, Overflow: OverflowFail},
+		{Subcommand: BitFieldGet, Type: u2, Offset: 102},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []any{nil, int64(3)}, bitFieldResults(results))
+
+	_, err = ds.BitField(key, []BitFieldOperation{{Subcommand: BitFieldGet, Type: BitFieldType{Bits: 64}}})
+	assert.Equal(t, ErrBitFieldType, err)
+	_, err = ds.BitField(key, []BitFieldOperation{{Subcommand: BitFieldGet, Type: u4, Offset: maxBitOffset}})
+	assert.Equal(t, ErrBitOffsetOutOfRange, err)
+}
+
+func TestDS_BitFieldSet(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	i8, u8 := BitFieldType{Signed: true, Bits: 8}, BitFieldType{Bits: 8}
+	key := []byte("key001")
+
+	results, err := ds.BitField(key, []BitFieldOperation{
+		{Subcommand: BitFieldSet, Type: i8, Offset: 0, Value: -100},
+		{Subcommand: BitFieldGet, Type: u8, Offset: 0},
+		{Subcommand: BitFieldSet, Type: u8, Offset: 8, Value: 300},
+		{Subcommand: BitFieldSet, Type: u8, Offset: 16, Value: -1, Overflow: OverflowSat},
+		{Subcommand: BitFieldSet, Type: i8, Offset: 24, Value: 200, Overflow: OverflowFail},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []any{int64(0), int64(156), int64(0), int64(0), nil}, bitFieldResults(results))
+
+	value, err := ds.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{156, 44, 255, 0}, value)
+}
+
+func TestDS_BitFieldLimits(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	i64, u63 := BitFieldType{Signed: true, Bits: 64}, BitFieldType{Bits: 63}
+	key := []byte("key001")
+
+	results, err := ds.BitField(key, []BitFieldOperation{
+		{Subcommand: BitFieldSet, Type: i64, Offset: 0, Value: math.MaxInt64},
+		{Subcommand: BitFieldIncrBy, Type: i64, Offset: 0, Value: 1},
+		{Subcommand: BitFieldIncrBy, Type: i64, Offset: 0, Value: -1, Overflow: OverflowSat},
+		{Subcommand: BitFieldIncrBy, Type: u63, Offset: 64, Value: -1, Overflow: OverflowSat},
+		{Subcommand: BitFieldIncrBy, Type: u63, Offset: 64, Value: math.MinInt64},
+		{Subcommand: BitFieldIncrBy, Type: u63, Offset: 64, Value: math.MaxInt64, Overflow: OverflowFail},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []any{
+		int64(0),
+		int64(math.MinInt64),
+		int64(math.MinInt64),
+		int64(0),
+		int64(0),
+		int64(math.MaxInt64),
+	}, bitFieldResults(results))
+}
@@ -0,0 +1,296 @@
+package ds
+
+import (
+	"encoding/binary"
+	"math/bits"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// Bitmaps are strings, whose bits are numbered from the most significant bit of the first byte.
+
+// maxBitOffset is the maximum offset of a bit, which keeps a bitmap within the maximum length of a string
+const maxBitOffset = maxStringLength*8 - 1
+
+// BitRange is a range of a bitmap, whose offsets are inclusive and count from the end if negative
+type BitRange struct {
+	Start  int64
+	End    int64 // ignored if HasEnd is false, then the range ends at the end of the bitmap
+	HasEnd bool
+	Bit    bool // the offsets are in bits rather than bytes
+}
+
+// resolve resolves the range within a bitmap of the given length.
+//
+// It returns the offsets of the first and the last bytes, along with masks of bits out of the range in both bytes.
+// ok is false if the range is empty.
+func (r *BitRange) resolve(length int64) (start, end int64, firstMask, lastMask byte, ok bool) {
+	if r == nil {
+		return 0, length - 1, 0, 0, length > 0
+	}
+
+	start, end = r.Start, -1
+	if r.HasEnd {
+		end = r.End
+		if start < 0 && end < 0 && start > end {
+			return 0, 0, 0, 0, false
+		}
+	}
+	total := length
+	if r.Bit {
+		total *= 8
+	}
+	if start < 0 {
+		start += total
+	}
+	if end < 0 {
+		end += total
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= total {
+		end = total - 1
+	}
+	if start > end {
+		return 0, 0, 0, 0, false
+	}
+
+	if r.Bit {
+		firstMask = ^byte(0xff >> (start & 7))
+		lastMask = byte(0x7f >> (end & 7))
+		start, end = start>>3, end>>3
+	}
+	return start, end, firstMask, lastMask, true
+}
+
+// SetBit redis SETBIT
+//
+// It returns the original bit. The string is padded with zero bytes if the offset is beyond its length.
+func (ds *DS) SetBit(key []byte, offset int64, bit byte) (byte, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffsetOutOfRange
+	}
+	if bit > 1 {
+		return 0, ErrBitOutOfRange
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	value, expire, err := ds.getString(key)
+	if err != nil && err != baradb.ErrKeyNotFound {
+		return 0, err
+	}
+
+	index := offset >> 3
+	length := int64(len(value))
+	if length <= index {
+		length = index + 1
+	}
+	newValue := make([]byte, length)
+	copy(newValue, value)
+
+	shift := 7 - offset&7
+	original := newValue[index] >> shift & 1
+	newValue[index] = newValue[index]&^(1<<shift) | bit<<shift
+	if err := ds.putString(key, newValue, expire); err != nil {
+		return 0, err
+	}
+	return original, nil
+}
+
+// GetBit redis GETBIT
+func (ds *DS) GetBit(key []byte, offset int64) (byte, error) {
+	if offset < 0 || offset > maxBitOffset {
+		return 0, ErrBitOffsetOutOfRange
+	}
+
+	value, _, err := ds.getString(key)
+	if err == baradb.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	index := offset >> 3
+	if index >= int64(len(value)) {
+		return 0, nil
+	}
+	return value[index] >> (7 - offset&7) & 1, nil
+}
+
+// BitCount redis BITCOUNT
+//
+// It counts bits set to 1 in the range, or in the whole bitmap if the range is nil.
+func (ds *DS) BitCount(key []byte, r *BitRange) (int64, error) {
+	value, _, err := ds.getString(key)
+	if err == baradb.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	start, end, firstMask, lastMask, ok := r.resolve(int64(len(value)))
+	if !ok {
+		return 0, nil
+	}
+	count := popcount(value[start : end+1])
+	count -= int64(bits.OnesCount8(value[start] & firstMask))
+	count -= int64(bits.OnesCount8(value[end] & lastMask))
+	return count, nil
+}
+
+// BitPos redis BITPOS
+//
+// It finds the first bit set to 1 or 0 in the range, or in the whole bitmap if the range is nil.
+// If no bit is found, it returns -1, except that bits beyond a bitmap are considered as 0
+// when looking for 0 without the end of the range.
+func (ds *DS) BitPos(key []byte, bit byte, r *BitRange) (int64, error) {
+	value, _, err := ds.getString(key)
+	if err == baradb.ErrKeyNotFound {
+		if bit == 1 {
+			return -1, nil
+		}
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	start, end, firstMask, lastMask, ok := r.resolve(int64(len(value)))
+	if !ok {
+		return -1, nil
+	}
+	pos := bitpos(value[start:end+1], bit, firstMask, lastMask)
+	if pos == -1 {
+		return -1, nil
+	}
+	if bit == 0 && pos == (end-start+1)*8 && r != nil && r.HasEnd {
+		return -1, nil
+	}
+	return start*8 + pos, nil
+}
+
+// BitOperation is a bitwise operation of BITOP
+type BitOperation int
+
+const (
+	BitAnd BitOperation = iota
+	BitOr
+	BitXor
+	BitNot
+)
+
+// BitOp redis BITOP
+//
+// Missing keys are considered as strings of zero bytes,
+// and the result is as long as the longest string.
+// The destination key is deleted if the result is empty.
+// It returns the length of the result.
+func (ds *DS) BitOp(op BitOperation, destKey []byte, keys ...[]byte) (int, error) {
+	if op == BitNot && len(keys) != 1 {
+		return 0, ErrBitOpNotSingleKey
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	values := make([][]byte, len(keys))
+	var length int
+	for i, key := range keys {
+		value, _, err := ds.getString(key)
+		if err != nil && err != baradb.ErrKeyNotFound {
+			return 0, err
+		}
+		values[i] = value
+		if len(value) > length {
+			length = len(value)
+		}
+	}
+
+	if length == 0 {
+		return 0, ds.Del(destKey)
+	}
+
+	result := make([]byte, length)
+	copy(result, values[0])
+	for _, value := range values[1:] {
+		for i := range result {
+			var b byte
+			if i < len(value) {
+				b = value[i]
+			}
+			switch op {
+			case BitAnd:
+				result[i] &= b
+			case BitOr:
+				result[i] |= b
+			case BitXor:
+				result[i] ^= b
+			}
+		}
+	}
+	if op == BitNot {
+		for i := range result {
+			result[i] = ^result[i]
+		}
+	}
+
+	if err := ds.putString(destKey, result, 0); err != nil {
+		return 0, err
+	}
+	return length, nil
+}
+
+// popcount counts bits set to 1, a word at a time
+func popcount(p []byte) int64 {
+	var count int
+	for ; len(p) >= 8; p = p[8:] {
+		count += bits.OnesCount64(binary.LittleEndian.Uint64(p))
+	}
+	for _, b := range p {
+		count += bits.OnesCount8(b)
+	}
+	return int64(count)
+}
+
+// bitpos finds the first bit of the given value, ignoring bits in the masks of the first and the last bytes.
+//
+// If no bit is found, it returns -1 when looking for 1, or the number of bits when looking for 0.
+func bitpos(p []byte, bit byte, firstMask, lastMask byte) int64 {
+	// skip is a byte without the bit that is looked for
+	var skip byte
+	if bit == 0 {
+		skip = 0xff
+	}
+	skipWord := uint64(skip) * 0x0101010101010101
+
+	for i := 0; i < len(p); i++ {
+		// skip whole words in the middle
+		for i > 0 && i+8 < len(p) && binary.LittleEndian.Uint64(p[i:]) == skipWord {
+			i += 8
+		}
+
+		b := p[i]
+		if i == 0 {
+			b = b&^firstMask | skip&firstMask
+		}
+		if i == len(p)-1 {
+			b = b&^lastMask | skip&lastMask
+		}
+		if b != skip {
+			return int64(i*8 + bits.LeadingZeros8(b^skip))
+		}
+	}
+
+	if bit == 1 {
+		return -1
+	}
+	return int64(len(p) * 8)
+}
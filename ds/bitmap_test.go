@@ -0,0 +1,158 @@
+package ds
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_SetBit(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("key001")
+	bit, err := ds.SetBit(key, 7, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0), bit)
+
+	bit, err = ds.SetBit(key, 7, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(1), bit)
+
+	_, err = ds.SetBit(key, 17, 1)
+	assert.Nil(t, err)
+	value, err := ds.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{0x00, 0x00, 0x40}, value)
+
+	bit, err = ds.GetBit(key, 17)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(1), bit)
+	bit, err = ds.GetBit(key, 1000)
+	assert.Nil(t, err)
+	assert.Equal(t, byte(0), bit)
+
+	_, err = ds.SetBit(key, -1, 1)
+	assert.Equal(t, ErrBitOffsetOutOfRange, err)
+	_, err = ds.SetBit(key, maxBitOffset+1, 1)
+	assert.Equal(t, ErrBitOffsetOutOfRange, err)
+	_, err = ds.SetBit(key, 1, 2)
+	assert.Equal(t, ErrBitOutOfRange, err)
+}
+
+func TestDS_BitCount(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("key001")
+	err := ds.Set(key, []byte("foobar"), 0)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		r        *BitRange
+		expected int64
+	}{
+		{nil, 26},
+		{&BitRange{Start: 0, End: 0, HasEnd: true}, 4},
+		{&BitRange{Start: 1, End: 1, HasEnd: true}, 6},
+		{&BitRange{Start: 1, End: 1, HasEnd: true, Bit: true}, 1},
+		{&BitRange{Start: 5, End: 30, HasEnd: true, Bit: true}, 17},
+		{&BitRange{Start: -2, End: -1, HasEnd: true}, 7},
+		{&BitRange{Start: -1, End: -2, HasEnd: true}, 0},
+	}
+	for _, c := range cases {
+		count, err := ds.BitCount(key, c.r)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, count)
+	}
+
+	count, err := ds.BitCount([]byte("unknown"), nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), count)
+}
+
+func TestDS_BitPos(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("key001")
+	err := ds.Set(key, []byte{0xff, 0xf0, 0x00}, 0)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		bit      byte
+		r        *BitRange
+		expected int64
+	}{
+		{0, nil, 12},
+		{1, nil, 0},
+		{1, &BitRange{Start: 2}, -1},
+		{1, &BitRange{Start: 2, End: -1, HasEnd: true}, -1},
+		{1, &BitRange{Start: 7, End: 15, HasEnd: true, Bit: true}, 7},
+		{0, &BitRange{Start: 7, End: 11, HasEnd: true, Bit: true}, -1},
+		{0, &BitRange{Start: 7, End: 12, HasEnd: true, Bit: true}, 12},
+	}
+	for _, c := range cases {
+		pos, err := ds.BitPos(key, c.bit, c.r)
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, pos)
+	}
+
+	err = ds.Set(key, []byte{0xff, 0xff, 0xff}, 0)
+	assert.Nil(t, err)
+	pos, err := ds.BitPos(key, 0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(24), pos)
+	pos, err = ds.BitPos(key, 0, &BitRange{Start: 0, End: -1, HasEnd: true})
+	assert.Nil(t, err)
+	assert.Equal(t, int64(-1), pos)
+
+	// bits in the middle are skipped a word at a time
+	long := make([]byte, 100)
+	long[90] = 0x01
+	err = ds.Set(key, long, 0)
+	assert.Nil(t, err)
+	pos, err = ds.BitPos(key, 1, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(90*8+7), pos)
+
+	pos, err = ds.BitPos([]byte("unknown"), 0, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), pos)
+}
+
+func TestDS_BitOp(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	err := ds.MSet([]byte("key1"), []byte{0xf0, 0x0f}, []byte("key2"), []byte{0x3c})
+	assert.Nil(t, err)
+
+	cases := []struct {
+		op       BitOperation
+		keys     [][]byte
+		expected []byte
+	}{
+		{BitAnd, [][]byte{[]byte("key1"), []byte("key2")}, []byte{0x30, 0x00}},
+		{BitOr, [][]byte{[]byte("key1"), []byte("key2")}, []byte{0xfc, 0x0f}},
+		{BitXor, [][]byte{[]byte("key1"), []byte("key2"), []byte("unknown")}, []byte{0xcc, 0x0f}},
+		{BitNot, [][]byte{[]byte("key1")}, []byte{0x0f, 0xf0}},
+	}
+	for _, c := range cases {
+		length, err := ds.BitOp(c.op, []byte("dest"), c.keys...)
+		assert.Nil(t, err)
+		assert.Equal(t, len(c.expected), length)
+		value, err := ds.Get([]byte("dest"))
+		assert.Nil(t, err)
+		assert.Equal(t, c.expected, value)
+	}
+
+	// an empty result deletes the destination key
+	length, err := ds.BitOp(BitAnd, []byte("dest"), []byte("unknown"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, length)
+	assert.False(t, ds.Exists([]byte("dest")))
+
+	_, err = ds.BitOp(BitNot, []byte("dest"), []byte("key1"), []byte("key2"))
+	assert.Equal(t, ErrBitOpNotSingleKey, err)
+}
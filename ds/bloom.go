@@ -0,0 +1,316 @@
+package ds
+
+import (
+	"encoding/binary"
+	"math"
+	"time"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// bloomTighteningRatio is the ratio of the error rate of a sub-filter to the previous one,
+// so that the error rate of a scaled filter stays within the requested one
+const bloomTighteningRatio = 0.5
+
+// BloomOptions options of a Bloom filter
+type BloomOptions struct {
+	ErrorRate  float64 // the desired probability of false positives
+	Capacity   uint64  // the number of items the first sub-filter is sized for
+	Expansion  uint32  // how many times larger a new sub-filter is than the previous one
+	NonScaling bool    // never add sub-filters, so that adding to a full filter fails
+}
+
+// DefaultBloomOptions are options of Bloom filters created by BF.ADD, which are the defaults of RedisBloom
+var DefaultBloomOptions = BloomOptions{
+	ErrorRate: 0.01,
+	Capacity:  100,
+	Expansion: 2,
+}
+
+// BloomInfo information of a Bloom filter replied by BF.INFO
+type BloomInfo struct {
+	Capacity   uint64 // the total capacity of all sub-filters
+	Size       uint64 // the number of bytes of all sub-filters
+	Filters    int
+	Items      uint64
+	Expansion  uint32
+	NonScaling bool
+}
+
+// bloomFilter is a sub-filter of a scalable Bloom filter
+type bloomFilter struct {
+	capacity uint64
+	items    uint64
+	bits     uint64
+	hashes   uint32
+}
+
+func newBloomFilter(capacity uint64, errorRate float64) bloomFilter {
+	bitsPerItem := -math.Log(errorRate) / (math.Ln2 * math.Ln2)
+	bits := uint64(math.Ceil(float64(capacity) * bitsPerItem))
+	if bits == 0 {
+		bits = 1
+	}
+	return bloomFilter{
+		capacity: capacity,
+		bits:     bits,
+		hashes:   uint32(math.Ceil(math.Ln2 * bitsPerItem)),
+	}
+}
+
+// positions calls fn with positions of bits of an item by double hashing, until fn returns false
+func (f *bloomFilter) positions(h1, h2 uint64, fn func(position uint64) (bool, error)) error {
+	for i := uint64(0); i < uint64(f.hashes); i++ {
+		ok, err := fn((h1 + i*h2) % f.bits)
+		if err != nil || !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// contains tells whether all bits of an item are set in the sub-filter at the index
+func (f *bloomFilter) contains(pages *filterPages, index uint32, h1, h2 uint64) (bool, error) {
+	found := true
+	err := f.positions(h1, h2, func(position uint64) (bool, error) {
+		b, err := pages.get(index, position/8)
+		found = err == nil && b&(1<<(position%8)) != 0
+		return found, err
+	})
+	return found, err
+}
+
+// add sets all bits of an item in the sub-filter at the index
+func (f *bloomFilter) add(pages *filterPages, index uint32, h1, h2 uint64) error {
+	f.items++
+	return f.positions(h1, h2, func(position uint64) (bool, error) {
+		b, err := pages.get(index, position/8)
+		if err != nil {
+			return false, err
+		}
+		return true, pages.set(index, position/8, b|1<<(position%8))
+	})
+}
+
+// bloomMetadata is a metadata of a Bloom filter, which begins with the same fields as other collections,
+// and the size is the number of sub-filters
+type bloomMetadata struct {
+	expire     int64
+	version    int64
+	errorRate  float64
+	expansion  uint32
+	nonScaling bool
+	filters    []bloomFilter
+}
+
+func newBloomMetadata(opts BloomOptions) *bloomMetadata {
+	return &bloomMetadata{
+		version:    time.Now().UnixNano(),
+		errorRate:  opts.ErrorRate,
+		expansion:  opts.Expansion,
+		nonScaling: opts.NonScaling,
+		filters:    []bloomFilter{newBloomFilter(opts.Capacity, opts.ErrorRate)},
+	}
+}
+
+func (md *bloomMetadata) encode() []byte {
+	buffer := encodeFilterHeader(Bloom, md.expire, md.version, uint32(len(md.filters)))
+	buffer = binary.BigEndian.AppendUint64(buffer, math.Float64bits(md.errorRate))
+	buffer = binary.AppendUvarint(buffer, uint64(md.expansion))
+	nonScaling := uint64(0)
+	if md.nonScaling {
+		nonScaling = 1
+	}
+	buffer = binary.AppendUvarint(buffer, nonScaling)
+	for _, f := range md.filters {
+		buffer = binary.AppendUvarint(buffer, f.capacity)
+		buffer = binary.AppendUvarint(buffer, f.items)
+		buffer = binary.AppendUvarint(buffer, f.bits)
+		buffer = binary.AppendUvarint(buffer, uint64(f.hashes))
+	}
+	return buffer
+}
+
+func decodeBloomMetadata(buffer []byte) *bloomMetadata {
+	d := &fieldDecoder{buffer: buffer, index: 1}
+	md := &bloomMetadata{}
+	md.expire = d.varint()
+	md.version = d.varint()
+	md.filters = make([]bloomFilter, d.varint())
+	md.errorRate = d.float()
+	md.expansion = uint32(d.uvarint())
+	md.nonScaling = d.uvarint() == 1
+	for i := range md.filters {
+		md.filters[i] = bloomFilter{
+			capacity: d.uvarint(),
+			items:    d.uvarint(),
+			bits:     d.uvarint(),
+			hashes:   uint32(d.uvarint()),
+		}
+	}
+	return md
+}
+
+// getBloomMetadata gets the metadata of a Bloom filter, which is nil if the key does not exist
+func (ds *DS) getBloomMetadata(key []byte) (*bloomMetadata, error) {
+	value, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value[0] != Bloom {
+		return nil, ErrWrongTypeOperation
+	}
+	return decodeBloomMetadata(value), nil
+}
+
+// contains tells whether any sub-filter may contain an item
+func (md *bloomMetadata) contains(pages *filterPages, h1, h2 uint64) (bool, error) {
+	for i := len(md.filters) - 1; i >= 0; i-- {
+		ok, err := md.filters[i].contains(pages, uint32(i), h1, h2)
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// BFReserve redis BF.RESERVE
+//
+// It creates an empty Bloom filter, and fails with ErrFilterExists if the key exists.
+func (ds *DS) BFReserve(key []byte, opts BloomOptions) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, err := ds.getValue(key); err != baradb.ErrKeyNotFound {
+		if err != nil {
+			return err
+		}
+		return ErrFilterExists
+	}
+	return ds.db.Put(key, newBloomMetadata(opts).encode())
+}
+
+// BFAdd redis BF.ADD and BF.MADD
+func (ds *DS) BFAdd(key []byte, items ...[]byte) ([]int64, error) {
+	return ds.BFInsert(key, DefaultBloomOptions, false, items...)
+}
+
+// BFInsert redis BF.INSERT
+//
+// It adds items to a Bloom filter, which is created with the options unless noCreate is true.
+// For each item, it gets 1 if the item is added, 0 if the item may have been added,
+// or -1 if the filter is full and can not scale.
+//
+// A full sub-filter is followed by a new one with a tighter error rate and a larger capacity by the expansion.
+func (ds *DS) BFInsert(key []byte, opts BloomOptions, noCreate bool, items ...[]byte) ([]int64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, err := ds.getBloomMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		if noCreate {
+			return nil, ErrFilterNotFound
+		}
+		md = newBloomMetadata(opts)
+	}
+
+	pages := newFilterPages(ds, key, md.version)
+	results := make([]int64, len(items))
+	for i, item := range items {
+		h1, h2 := hashItem(item)
+		ok, err := md.contains(pages, h1, h2)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			continue
+		}
+
+		last := &md.filters[len(md.filters)-1]
+		if last.items >= last.capacity {
+			if md.nonScaling {
+				results[i] = -1
+				continue
+			}
+			errorRate := md.errorRate * math.Pow(bloomTighteningRatio, float64(len(md.filters)))
+			md.filters = append(md.filters, newBloomFilter(last.capacity*uint64(md.expansion), errorRate))
+			last = &md.filters[len(md.filters)-1]
+		}
+		if err := last.add(pages, uint32(len(md.filters)-1), h1, h2); err != nil {
+			return nil, err
+		}
+		results[i] = 1
+	}
+
+	if err := pages.commit(key, md.encode()); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// BFExists redis BF.EXISTS and BF.MEXISTS
+//
+// It tells whether each item may have been added, and nothing has been added to a missing key.
+func (ds *DS) BFExists(key []byte, items ...[]byte) ([]bool, error) {
+	md, err := ds.getBloomMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]bool, len(items))
+	if md == nil {
+		return results, nil
+	}
+
+	pages := newFilterPages(ds, key, md.version)
+	for i, item := range items {
+		h1, h2 := hashItem(item)
+		if results[i], err = md.contains(pages, h1, h2); err != nil {
+			return nil, err
+		}
+	}
+	return results, nil
+}
+
+// BFCard redis BF.CARD
+//
+// It gets the number of items added to a Bloom filter, which is 0 for a missing key.
+func (ds *DS) BFCard(key []byte) (uint64, error) {
+	md, err := ds.getBloomMetadata(key)
+	if err != nil || md == nil {
+		return 0, err
+	}
+	var items uint64
+	for _, f := range md.filters {
+		items += f.items
+	}
+	return items, nil
+}
+
+// BFInfo redis BF.INFO
+func (ds *DS) BFInfo(key []byte) (*BloomInfo, error) {
+	md, err := ds.getBloomMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, ErrFilterNotFound
+	}
+
+	info := &BloomInfo{
+		Filters:    len(md.filters),
+		Expansion:  md.expansion,
+		NonScaling: md.nonScaling,
+	}
+	for _, f := range md.filters {
+		info.Capacity += f.capacity
+		info.Size += (f.bits + 7) / 8
+		info.Items += f.items
+	}
+	return info, nil
+}
@@ -0,0 +1,105 @@
+package ds
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_BFAdd(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("bloom")
+	results, err := ds.BFExists(key, []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false}, results)
+
+	added, err := ds.BFAdd(key, []byte("a"), []byte("b"), []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 1, 0}, added)
+	results, err = ds.BFExists(key, []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, false}, results)
+	n, err := ds.BFCard(key)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2), n)
+
+	assert.Nil(t, ds.Set([]byte("string"), []byte("value"), 0))
+	_, err = ds.BFAdd([]byte("string"), []byte("a"))
+	assert.Equal(t, ErrWrongTypeOperation, err)
+	assert.Equal(t, ErrFilterExists, ds.BFReserve(key, DefaultBloomOptions))
+	dt, err := ds.Type(key)
+	assert.Nil(t, err)
+	assert.Equal(t, Bloom, dt)
+}
+
+func TestDS_BFScaling(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer func() {
+		destroyDS(ds, testingDBOptions.Directory)
+	}()
+
+	key := []byte("bloom")
+	assert.Nil(t, ds.BFReserve(key, BloomOptions{ErrorRate: 0.001, Capacity: 100, Expansion: 2}))
+	info, err := ds.BFInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, &BloomInfo{Capacity: 100, Size: 180, Filters: 1, Expansion: 2}, info)
+
+	items := make([][]byte, 1000)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+	added, err := ds.BFInsert(key, BloomOptions{}, true, items...)
+	assert.Nil(t, err)
+	var n int
+	for _, a := range added {
+		n += int(a)
+	}
+	// false positives are allowed but rare
+	assert.Greater(t, n, 990)
+
+	info, err = ds.BFInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, info.Filters)
+	assert.Equal(t, uint64(100+200+400+800), info.Capacity)
+	assert.Equal(t, uint64(n), info.Items)
+
+	// all added items exist after a restart
+	assert.Nil(t, ds.Close())
+	ds, _ = New(testingDBOptions)
+	results, err := ds.BFExists(key, items...)
+	assert.Nil(t, err)
+	for _, ok := range results {
+		assert.True(t, ok)
+	}
+	var falsePositives int
+	for i := 0; i < 1000; i++ {
+		results, _ := ds.BFExists(key, []byte(fmt.Sprintf("other-%d", i)))
+		if results[0] {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 10)
+}
+
+func TestDS_BFNonScaling(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("bloom")
+	_, err := ds.BFInsert(key, BloomOptions{}, true, []byte("a"))
+	assert.Equal(t, ErrFilterNotFound, err)
+	_, err = ds.BFInfo(key)
+	assert.Equal(t, ErrFilterNotFound, err)
+
+	opts := BloomOptions{ErrorRate: 0.01, Capacity: 2, NonScaling: true}
+	added, err := ds.BFInsert(key, opts, false, []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 1, -1}, added)
+	info, err := ds.BFInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, info.Filters)
+	assert.True(t, info.NonScaling)
+}
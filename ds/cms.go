@@ -0,0 +1,226 @@
+package ds
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// CMSInfo information of a Count-Min Sketch replied by CMS.INFO
+type CMSInfo struct {
+	Width uint64
+	Depth uint64
+	Count uint64 // the total of all increments
+}
+
+// maxCMSCounters is the maximum of width * depth of a sketch, which takes 16MB
+const maxCMSCounters = 1 << 22
+
+// countMinSketch is a Count-Min Sketch, which is depth rows of width 32-bit counters.
+//
+// A sketch is stored as a single value, so every CMS.INCRBY and CMS.MERGE reads and rewrites all counters.
+// It keeps a sketch consistent without internal keys, at the cost proportional to its size,
+// which is why dimensions are bounded by maxCMSCounters.
+type countMinSketch struct {
+	expire   int64
+	width    uint64
+	depth    uint64
+	count    uint64
+	counters []uint32
+}
+
+// encode encodes a sketch: type + expire + width + depth + count + counters in big endian,
+// which is laid out as a string is
+func (cms *countMinSketch) encode() []byte {
+	buffer := make([]byte, 0, binary.MaxVarintLen64*3+len(cms.counters)*4)
+	buffer = binary.AppendUvarint(buffer, cms.width)
+	buffer = binary.AppendUvarint(buffer, cms.depth)
+	buffer = binary.AppendUvarint(buffer, cms.count)
+	for _, counter := range cms.counters {
+		buffer = binary.BigEndian.AppendUint32(buffer, counter)
+	}
+	encValue := encodeString(buffer, cms.expire)
+	encValue[0] = CountMinSketch
+	return encValue
+}
+
+func decodeCountMinSketch(encValue []byte) *countMinSketch {
+	expire, _ := binary.Varint(encValue[1:])
+	d := &fieldDecoder{buffer: decodeString(encValue)}
+	cms := &countMinSketch{expire: expire}
+	cms.width = d.uvarint()
+	cms.depth = d.uvarint()
+	cms.count = d.uvarint()
+	cms.counters = make([]uint32, cms.width*cms.depth)
+	for i := range cms.counters {
+		cms.counters[i] = binary.BigEndian.Uint32(d.buffer[d.index+i*4:])
+	}
+	return cms
+}
+
+// getCountMinSketch gets the sketch of a key, and fails with ErrCMSKeyNotFound if the key does not exist
+func (ds *DS) getCountMinSketch(key []byte) (*countMinSketch, error) {
+	encValue, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, ErrCMSKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if encValue[0] != CountMinSketch {
+		return nil, ErrWrongTypeOperation
+	}
+	return decodeCountMinSketch(encValue), nil
+}
+
+// indexes calls fn with the index of the counter of an item in each row
+func (cms *countMinSketch) indexes(item []byte, fn func(i uint64)) {
+	h1, h2 := hashItem(item)
+	for row := uint64(0); row < cms.depth; row++ {
+		fn(row*cms.width + (h1+row*h2)%cms.width)
+	}
+}
+
+// query gets the estimated count of an item, which is the minimum of its counters
+func (cms *countMinSketch) query(item []byte) uint32 {
+	estimate := uint32(math.MaxUint32)
+	cms.indexes(item, func(i uint64) {
+		if cms.counters[i] < estimate {
+			estimate = cms.counters[i]
+		}
+	})
+	return estimate
+}
+
+// CMSInitByDim redis CMS.INITBYDIM
+func (ds *DS) CMSInitByDim(key []byte, width, depth uint64) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, err := ds.getValue(key); err != baradb.ErrKeyNotFound {
+		if err != nil {
+			return err
+		}
+		return ErrCMSKeyExists
+	}
+	if width == 0 || depth == 0 || width > maxCMSCounters/depth {
+		return ErrCMSTooLarge
+	}
+	cms := &countMinSketch{width: width, depth: depth, counters: make([]uint32, width*depth)}
+	return ds.db.Put(key, cms.encode())
+}
+
+// CMSInitByProb redis CMS.INITBYPROB
+//
+// It initializes a sketch whose estimates exceed the truth by more than errorRate of the total count
+// with at most the probability.
+func (ds *DS) CMSInitByProb(key []byte, errorRate, probability float64) error {
+	// dimensions are bounded before they are converted to integers, which may overflow
+	width := math.Ceil(2 / errorRate)
+	depth := math.Ceil(math.Log10(probability) / math.Log10(0.5))
+	if !(width >= 1 && depth >= 1 && width*depth <= maxCMSCounters) {
+		return ErrCMSTooLarge
+	}
+	return ds.CMSInitByDim(key, uint64(width), uint64(depth))
+}
+
+// CMSIncrBy redis CMS.INCRBY
+//
+// It increments counts of items, and gets their estimated counts afterwards.
+func (ds *DS) CMSIncrBy(key []byte, items [][]byte, increments []uint32) ([]uint32, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	cms, err := ds.getCountMinSketch(key)
+	if err != nil {
+		return nil, err
+	}
+	for i, item := range items {
+		var overflow bool
+		cms.indexes(item, func(j uint64) {
+			if uint64(cms.counters[j])+uint64(increments[i]) > math.MaxUint32 {
+				overflow = true
+			}
+		})
+		if overflow {
+			return nil, ErrCMSOverflow
+		}
+		cms.indexes(item, func(j uint64) {
+			cms.counters[j] += increments[i]
+		})
+		cms.count += uint64(increments[i])
+	}
+	if err := ds.db.Put(key, cms.encode()); err != nil {
+		return nil, err
+	}
+
+	counts := make([]uint32, len(items))
+	for i, item := range items {
+		counts[i] = cms.query(item)
+	}
+	return counts, nil
+}
+
+// CMSQuery redis CMS.QUERY
+func (ds *DS) CMSQuery(key []byte, items ...[]byte) ([]uint32, error) {
+	cms, err := ds.getCountMinSketch(key)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]uint32, len(items))
+	for i, item := range items {
+		counts[i] = cms.query(item)
+	}
+	return counts, nil
+}
+
+// CMSMerge redis CMS.MERGE
+//
+// It replaces counters of the destination with the weighted sums of counters of sources,
+// where all sketches must have the same dimensions.
+func (ds *DS) CMSMerge(destination []byte, sources [][]byte, weights []int64) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	dest, err := ds.getCountMinSketch(destination)
+	if err != nil {
+		return err
+	}
+	counters := make([]int64, len(dest.counters))
+	var count int64
+	for i, source := range sources {
+		src, err := ds.getCountMinSketch(source)
+		if err != nil {
+			return err
+		}
+		if src.width != dest.width || src.depth != dest.depth {
+			return ErrCMSDimensionMismatch
+		}
+		for j, counter := range src.counters {
+			counters[j] += int64(counter) * weights[i]
+		}
+		count += int64(src.count) * weights[i]
+	}
+
+	for i, counter := range counters {
+		if counter < 0 || counter > math.MaxUint32 {
+			return ErrCMSMergeOverflow
+		}
+		dest.counters[i] = uint32(counter)
+	}
+	if count < 0 {
+		return ErrCMSMergeOverflow
+	}
+	dest.count = uint64(count)
+	return ds.db.Put(destination, dest.encode())
+}
+
+// CMSInfo redis CMS.INFO
+func (ds *DS) CMSInfo(key []byte) (*CMSInfo, error) {
+	cms, err := ds.getCountMinSketch(key)
+	if err != nil {
+		return nil, err
+	}
+	return &CMSInfo{Width: cms.width, Depth: cms.depth, Count: cms.count}, nil
+}
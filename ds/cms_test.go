@@ -0,0 +1,90 @@
+package ds
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_CMSIncrBy(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer func() {
+		destroyDS(ds, testingDBOptions.Directory)
+	}()
+
+	key := []byte("cms")
+	_, err := ds.CMSQuery(key, []byte("a"))
+	assert.Equal(t, ErrCMSKeyNotFound, err)
+	assert.Nil(t, ds.CMSInitByDim(key, 100, 5))
+	assert.Equal(t, ErrCMSKeyExists, ds.CMSInitByDim(key, 100, 5))
+
+	counts, err := ds.CMSIncrBy(key, [][]byte{[]byte("a"), []byte("b"), []byte("a")}, []uint32{3, 2, 4})
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{7, 2, 7}, counts)
+	counts, err = ds.CMSQuery(key, []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{7, 2, 0}, counts)
+
+	_, err = ds.CMSIncrBy(key, [][]byte{[]byte("a")}, []uint32{1<<32 - 1})
+	assert.Equal(t, ErrCMSOverflow, err)
+
+	// counts persist after a restart
+	assert.Nil(t, ds.Close())
+	ds, _ = New(testingDBOptions)
+	info, err := ds.CMSInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, &CMSInfo{Width: 100, Depth: 5, Count: 9}, info)
+	dt, err := ds.Type(key)
+	assert.Nil(t, err)
+	assert.Equal(t, CountMinSketch, dt)
+
+	assert.Nil(t, ds.Set([]byte("string"), []byte("value"), 0))
+	_, err = ds.CMSQuery([]byte("string"), []byte("a"))
+	assert.Equal(t, ErrWrongTypeOperation, err)
+}
+
+func TestDS_CMSInitByProb(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("cms")
+	assert.Nil(t, ds.CMSInitByProb(key, 0.001, 0.01))
+	info, err := ds.CMSInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, &CMSInfo{Width: 2000, Depth: 7}, info)
+
+	// dimensions are bounded, including ones overflowing integers
+	assert.Equal(t, ErrCMSTooLarge, ds.CMSInitByProb([]byte("large"), 1e-300, 0.01))
+	assert.Equal(t, ErrCMSTooLarge, ds.CMSInitByDim([]byte("large"), math.MaxUint32, math.MaxUint32))
+	assert.Equal(t, ErrCMSTooLarge, ds.CMSInitByDim([]byte("large"), 1<<32, 1<<32))
+	assert.Equal(t, ErrCMSTooLarge, ds.CMSInitByDim([]byte("large"), maxCMSCounters+1, 1))
+	assert.Nil(t, ds.CMSInitByDim([]byte("large"), maxCMSCounters, 1))
+}
+
+func TestDS_CMSMerge(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	a, b, dest := []byte("a"), []byte("b"), []byte("dest")
+	for _, key := range [][]byte{a, b, dest} {
+		assert.Nil(t, ds.CMSInitByDim(key, 50, 4))
+	}
+	_, err := ds.CMSIncrBy(a, [][]byte{[]byte("x"), []byte("y")}, []uint32{1, 2})
+	assert.Nil(t, err)
+	_, err = ds.CMSIncrBy(b, [][]byte{[]byte("x")}, []uint32{10})
+	assert.Nil(t, err)
+
+	assert.Nil(t, ds.CMSMerge(dest, [][]byte{a, b}, []int64{3, 1}))
+	counts, err := ds.CMSQuery(dest, []byte("x"), []byte("y"))
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{13, 6}, counts)
+	info, err := ds.CMSInfo(dest)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(19), info.Count)
+
+	assert.Nil(t, ds.CMSInitByDim([]byte("other"), 10, 4))
+	assert.Equal(t, ErrCMSDimensionMismatch, ds.CMSMerge(dest, [][]byte{a, []byte("other")}, []int64{1, 1}))
+	assert.Equal(t, ErrCMSKeyNotFound, ds.CMSMerge([]byte("missing"), [][]byte{a}, []int64{1}))
+	assert.Equal(t, ErrCMSMergeOverflow, ds.CMSMerge(dest, [][]byte{a}, []int64{-1}))
+}
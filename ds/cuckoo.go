@@ -0,0 +1,440 @@
+package ds
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// CuckooOptions options of a Cuckoo filter
+type CuckooOptions struct {
+	Capacity      uint64 // the number of items the first sub-filter is sized for
+	BucketSize    uint32 // the number of fingerprints in each bucket
+	MaxIterations uint32 // the number of fingerprints moved before a sub-filter is declared full
+	Expansion     uint32 // how many times larger a new sub-filter is than the previous one, 0 to never add sub-filters
+}
+
+// DefaultCuckooOptions are options of Cuckoo filters created by CF.ADD, which are the defaults of RedisBloom
+var DefaultCuckooOptions = CuckooOptions{
+	Capacity:      1024,
+	BucketSize:    2,
+	MaxIterations: 20,
+	Expansion:     1,
+}
+
+// CuckooInfo information of a Cuckoo filter replied by CF.INFO
+type CuckooInfo struct {
+	Size          uint64 // the number of bytes of all sub-filters
+	Buckets       uint64 // the number of buckets of all sub-filters
+	Filters       int
+	Items         uint64
+	Deleted       uint64
+	BucketSize    uint32
+	Expansion     uint32
+	MaxIterations uint32
+}
+
+// cuckooMetadata is a metadata of a Cuckoo filter, which begins with the same fields as other collections,
+// and the size is the number of sub-filters.
+//
+// A sub-filter is a power of 2 buckets of one-byte fingerprints, where 0 stands for an empty slot.
+type cuckooMetadata struct {
+	expire        int64
+	version       int64
+	bucketSize    uint32
+	maxIterations uint32
+	expansion     uint32
+	items         uint64
+	deleted       uint64
+	filters       []uint64 // the number of buckets of each sub-filter
+}
+
+func newCuckooMetadata(opts CuckooOptions) *cuckooMetadata {
+	buckets := nextPowerOfTwo(opts.Capacity / uint64(opts.BucketSize))
+	return &cuckooMetadata{
+		version:       time.Now().UnixNano(),
+		bucketSize:    opts.BucketSize,
+		maxIterations: opts.MaxIterations,
+		expansion:     opts.Expansion,
+		filters:       []uint64{buckets},
+	}
+}
+
+func (md *cuckooMetadata) encode() []byte {
+	buffer := encodeFilterHeader(Cuckoo, md.expire, md.version, uint32(len(md.filters)))
+	for _, field := range []uint64{uint64(md.bucketSize), uint64(md.maxIterations), uint64(md.expansion), md.items, md.deleted} {
+		buffer = binary.AppendUvarint(buffer, field)
+	}
+	for _, buckets := range md.filters {
+		buffer = binary.AppendUvarint(buffer, buckets)
+	}
+	return buffer
+}
+
+func decodeCuckooMetadata(buffer []byte) *cuckooMetadata {
+	d := &fieldDecoder{buffer: buffer, index: 1}
+	md := &cuckooMetadata{}
+	md.expire = d.varint()
+	md.version = d.varint()
+	md.filters = make([]uint64, d.varint())
+	md.bucketSize = uint32(d.uvarint())
+	md.maxIterations = uint32(d.uvarint())
+	md.expansion = uint32(d.uvarint())
+	md.items = d.uvarint()
+	md.deleted = d.uvarint()
+	for i := range md.filters {
+		md.filters[i] = d.uvarint()
+	}
+	return md
+}
+
+// getCuckooMetadata gets the metadata of a Cuckoo filter, which is nil if the key does not exist
+func (ds *DS) getCuckooMetadata(key []byte) (*cuckooMetadata, error) {
+	value, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value[0] != Cuckoo {
+		return nil, ErrWrongTypeOperation
+	}
+	return decodeCuckooMetadata(value), nil
+}
+
+// nextPowerOfTwo gets the smallest power of 2 which is not less than n
+func nextPowerOfTwo(n uint64) uint64 {
+	p := uint64(1)
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
+
+// cuckooItem is the fingerprint of an item along with the hash deciding its first bucket
+type cuckooItem struct {
+	fingerprint byte
+	hash        uint64
+}
+
+func newCuckooItem(item []byte) cuckooItem {
+	h1, h2 := hashItem(item)
+	return cuckooItem{fingerprint: byte(h1%255 + 1), hash: h2}
+}
+
+// altBucket gets the other bucket of a fingerprint in a sub-filter, which is symmetric
+func altBucket(bucket uint64, fingerprint byte, buckets uint64) uint64 {
+	return (bucket ^ uint64(fingerprint)*0x5bd1e995) & (buckets - 1)
+}
+
+// buckets gets both buckets of an item in a sub-filter
+func (it cuckooItem) buckets(buckets uint64) (uint64, uint64) {
+	b1 := it.hash & (buckets - 1)
+	return b1, altBucket(b1, it.fingerprint, buckets)
+}
+
+// cuckooFilter accesses slots of a Cuckoo filter through its pages
+type cuckooFilter struct {
+	md    *cuckooMetadata
+	pages *filterPages
+	undo  []cuckooSlot // original values of slots changed by a failed insertion
+}
+
+type cuckooSlot struct {
+	filter      uint32
+	offset      uint64
+	fingerprint byte
+}
+
+func (f *cuckooFilter) slot(bucket uint64, i uint32) uint64 {
+	return bucket*uint64(f.md.bucketSize) + uint64(i)
+}
+
+func (f *cuckooFilter) set(filter uint32, offset uint64, fingerprint byte) error {
+	old, err := f.pages.get(filter, offset)
+	if err != nil {
+		return err
+	}
+	f.undo = append(f.undo, cuckooSlot{filter: filter, offset: offset, fingerprint: old})
+	return f.pages.set(filter, offset, fingerprint)
+}
+
+// find finds the offset of a slot holding a fingerprint in a bucket, or an empty slot if the fingerprint is 0
+func (f *cuckooFilter) find(filter uint32, bucket uint64, fingerprint byte) (uint64, bool, error) {
+	for i := uint32(0); i < f.md.bucketSize; i++ {
+		offset := f.slot(bucket, i)
+		b, err := f.pages.get(filter, offset)
+		if err != nil {
+			return 0, false, err
+		}
+		if b == fingerprint {
+			return offset, true, nil
+		}
+	}
+	return 0, false, nil
+}
+
+// count counts fingerprints of an item in all sub-filters, and stops at the first one unless all is true
+func (f *cuckooFilter) count(it cuckooItem, all bool) (int64, error) {
+	var n int64
+	for i := len(f.md.filters) - 1; i >= 0; i-- {
+		b1, b2 := it.buckets(f.md.filters[i])
+		for _, bucket := range []uint64{b1, b2} {
+			for j := uint32(0); j < f.md.bucketSize; j++ {
+				b, err := f.pages.get(uint32(i), f.slot(bucket, j))
+				if err != nil {
+					return 0, err
+				}
+				if b == it.fingerprint {
+					n++
+					if !all {
+						return n, nil
+					}
+				}
+			}
+			if b1 == b2 {
+				break
+			}
+		}
+	}
+	return n, nil
+}
+
+// insert inserts a fingerprint into an empty slot of either bucket in any sub-filter,
+// or kicks fingerprints to their other buckets in the last sub-filter.
+// If the last sub-filter is full, a new one is added unless the expansion is 0.
+func (f *cuckooFilter) insert(it cuckooItem) (bool, error) {
+	for i := len(f.md.filters) - 1; i >= 0; i-- {
+		b1, b2 := it.buckets(f.md.filters[i])
+		for _, bucket := range []uint64{b1, b2} {
+			offset, ok, err := f.find(uint32(i), bucket, 0)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, f.pages.set(uint32(i), offset, it.fingerprint)
+			}
+		}
+	}
+
+	ok, err := f.kick(it)
+	if err != nil || ok {
+		return ok, err
+	}
+	if f.md.expansion == 0 {
+		return false, nil
+	}
+	last := f.md.filters[len(f.md.filters)-1]
+	f.md.filters = append(f.md.filters, nextPowerOfTwo(last*uint64(f.md.expansion)))
+	return f.insert(it)
+}
+
+// kick moves fingerprints to their other buckets in the last sub-filter to make room for an item,
+// and reverts all moves if there is still no room after max iterations
+func (f *cuckooFilter) kick(it cuckooItem) (bool, error) {
+	filter := uint32(len(f.md.filters) - 1)
+	buckets := f.md.filters[filter]
+	bucket, _ := it.buckets(buckets)
+	fingerprint := it.fingerprint
+	f.undo = f.undo[:0]
+
+	for n := uint32(0); n < f.md.maxIterations; n++ {
+		// swap with a victim, which is chosen in turns
+		offset := f.slot(bucket, n%f.md.bucketSize)
+		victim, err := f.pages.get(filter, offset)
+		if err != nil {
+			return false, err
+		}
+		if err := f.set(filter, offset, fingerprint); err != nil {
+			return false, err
+		}
+		fingerprint = victim
+		bucket = altBucket(bucket, fingerprint, buckets)
+
+		offset, ok, err := f.find(filter, bucket, 0)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, f.pages.set(filter, offset, fingerprint)
+		}
+	}
+
+	for i := len(f.undo) - 1; i >= 0; i-- {
+		slot := f.undo[i]
+		if err := f.pages.set(slot.filter, slot.offset, slot.fingerprint); err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// CFReserve redis CF.RESERVE
+//
+// It creates an empty Cuckoo filter, and fails with ErrFilterExists if the key exists.
+func (ds *DS) CFReserve(key []byte, opts CuckooOptions) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, err := ds.getValue(key); err != baradb.ErrKeyNotFound {
+		if err != nil {
+			return err
+		}
+		return ErrFilterExists
+	}
+	return ds.db.Put(key, newCuckooMetadata(opts).encode())
+}
+
+// CFInsert redis CF.INSERT and CF.INSERTNX
+//
+// It adds items to a Cuckoo filter, which is created with the options unless noCreate is true.
+// An item may be added multiple times unless nx is true.
+// For each item, it gets 1 if the item is added, 0 if nx is true and the item may have been added,
+// or -1 if the filter is full.
+func (ds *DS) CFInsert(key []byte, opts CuckooOptions, noCreate, nx bool, items ...[]byte) ([]int64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, err := ds.getCuckooMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		if noCreate {
+			return nil, ErrFilterNotFound
+		}
+		md = newCuckooMetadata(opts)
+	}
+
+	f := &cuckooFilter{md: md, pages: newFilterPages(ds, key, md.version)}
+	results := make([]int64, len(items))
+	for i, item := range items {
+		it := newCuckooItem(item)
+		if nx {
+			n, err := f.count(it, false)
+			if err != nil {
+				return nil, err
+			}
+			if n > 0 {
+				continue
+			}
+		}
+		ok, err := f.insert(it)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			results[i] = -1
+			continue
+		}
+		md.items++
+		results[i] = 1
+	}
+
+	if err := f.pages.commit(key, md.encode()); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// CFExists redis CF.EXISTS and CF.MEXISTS
+//
+// It tells whether each item may have been added, and nothing has been added to a missing key.
+func (ds *DS) CFExists(key []byte, items ...[]byte) ([]bool, error) {
+	md, err := ds.getCuckooMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]bool, len(items))
+	if md == nil {
+		return results, nil
+	}
+
+	f := &cuckooFilter{md: md, pages: newFilterPages(ds, key, md.version)}
+	for i, item := range items {
+		n, err := f.count(newCuckooItem(item), false)
+		if err != nil {
+			return nil, err
+		}
+		results[i] = n > 0
+	}
+	return results, nil
+}
+
+// CFCount redis CF.COUNT
+//
+// It gets how many times an item may have been added, which may be more than the truth but never less.
+func (ds *DS) CFCount(key []byte, item []byte) (int64, error) {
+	md, err := ds.getCuckooMetadata(key)
+	if err != nil || md == nil {
+		return 0, err
+	}
+	f := &cuckooFilter{md: md, pages: newFilterPages(ds, key, md.version)}
+	return f.count(newCuckooItem(item), true)
+}
+
+// CFDel redis CF.DEL
+//
+// It deletes one fingerprint of an item, and tells whether it is found.
+// Deleting an item which has not been added may delete another item sharing the fingerprint.
+func (ds *DS) CFDel(key []byte, item []byte) (bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, err := ds.getCuckooMetadata(key)
+	if err != nil {
+		return false, err
+	}
+	if md == nil {
+		return false, ErrCuckooNotFound
+	}
+
+	f := &cuckooFilter{md: md, pages: newFilterPages(ds, key, md.version)}
+	it := newCuckooItem(item)
+	for i := len(md.filters) - 1; i >= 0; i-- {
+		b1, b2 := it.buckets(md.filters[i])
+		for _, bucket := range []uint64{b1, b2} {
+			offset, ok, err := f.find(uint32(i), bucket, it.fingerprint)
+			if err != nil {
+				return false, err
+			}
+			if !ok {
+				continue
+			}
+			if err := f.pages.set(uint32(i), offset, 0); err != nil {
+				return false, err
+			}
+			md.items--
+			md.deleted++
+			return true, f.pages.commit(key, md.encode())
+		}
+	}
+	return false, nil
+}
+
+// CFInfo redis CF.INFO
+func (ds *DS) CFInfo(key []byte) (*CuckooInfo, error) {
+	md, err := ds.getCuckooMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, ErrFilterNotFound
+	}
+
+	info := &CuckooInfo{
+		Filters:       len(md.filters),
+		Items:         md.items,
+		Deleted:       md.deleted,
+		BucketSize:    md.bucketSize,
+		Expansion:     md.expansion,
+		MaxIterations: md.maxIterations,
+	}
+	for _, buckets := range md.filters {
+		info.Buckets += buckets
+		info.Size += buckets * uint64(md.bucketSize)
+	}
+	return info, nil
+}
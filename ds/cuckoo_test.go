@@ -0,0 +1,106 @@
+package ds
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_CFInsert(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("cuckoo")
+	results, err := ds.CFExists(key, []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{false}, results)
+	_, err = ds.CFInsert(key, DefaultCuckooOptions, true, false, []byte("a"))
+	assert.Equal(t, ErrFilterNotFound, err)
+
+	added, err := ds.CFInsert(key, DefaultCuckooOptions, false, false, []byte("a"), []byte("a"), []byte("b"))
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{1, 1, 1}, added)
+	added, err = ds.CFInsert(key, DefaultCuckooOptions, false, true, []byte("a"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, []int64{0, 1}, added)
+
+	results, err = ds.CFExists(key, []byte("a"), []byte("b"), []byte("d"))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, true, false}, results)
+	n, err := ds.CFCount(key, []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), n)
+
+	info, err := ds.CFInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, &CuckooInfo{
+		Size: 1024, Buckets: 512, Filters: 1, Items: 4,
+		BucketSize: 2, Expansion: 1, MaxIterations: 20,
+	}, info)
+	assert.Equal(t, ErrFilterExists, ds.CFReserve(key, DefaultCuckooOptions))
+}
+
+func TestDS_CFDel(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("cuckoo")
+	_, err := ds.CFDel(key, []byte("a"))
+	assert.Equal(t, ErrCuckooNotFound, err)
+	_, err = ds.CFInsert(key, DefaultCuckooOptions, false, false, []byte("a"), []byte("a"))
+	assert.Nil(t, err)
+
+	ok, err := ds.CFDel(key, []byte("a"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	n, _ := ds.CFCount(key, []byte("a"))
+	assert.Equal(t, int64(1), n)
+	ok, _ = ds.CFDel(key, []byte("a"))
+	assert.True(t, ok)
+	ok, _ = ds.CFDel(key, []byte("a"))
+	assert.False(t, ok)
+
+	info, err := ds.CFInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), info.Items)
+	assert.Equal(t, uint64(2), info.Deleted)
+}
+
+func TestDS_CFExpansion(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer func() {
+		destroyDS(ds, testingDBOptions.Directory)
+	}()
+
+	items := make([][]byte, 500)
+	for i := range items {
+		items[i] = []byte(fmt.Sprintf("item-%d", i))
+	}
+
+	// a filter which can not expand is full at last
+	key := []byte("fixed")
+	assert.Nil(t, ds.CFReserve(key, CuckooOptions{Capacity: 64, BucketSize: 4, MaxIterations: 10}))
+	added, err := ds.CFInsert(key, CuckooOptions{}, true, false, items...)
+	assert.Nil(t, err)
+	assert.Contains(t, added, int64(-1))
+	info, _ := ds.CFInfo(key)
+	assert.Equal(t, 1, info.Filters)
+	assert.LessOrEqual(t, info.Items, uint64(64))
+
+	key = []byte("scalable")
+	assert.Nil(t, ds.CFReserve(key, CuckooOptions{Capacity: 64, BucketSize: 4, MaxIterations: 10, Expansion: 2}))
+	added, err = ds.CFInsert(key, CuckooOptions{}, true, false, items...)
+	assert.Nil(t, err)
+	assert.NotContains(t, added, int64(-1))
+	info, _ = ds.CFInfo(key)
+	assert.Greater(t, info.Filters, 1)
+	assert.Equal(t, uint64(500), info.Items)
+
+	// all added items exist after a restart
+	assert.Nil(t, ds.Close())
+	ds, _ = New(testingDBOptions)
+	results, err := ds.CFExists(key, items...)
+	assert.Nil(t, err)
+	assert.NotContains(t, results, false)
+}
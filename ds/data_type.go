@@ -9,4 +9,11 @@ const (
 	Set
 	List
 	ZSet
+	Stream
+	JSON
+	Bloom
+	Cuckoo
+	CountMinSketch
+	TopK
+	TimeSeries
 )
@@ -1,14 +1,119 @@
 package ds
 
-import "errors"
+import "fmt"
+
+// Error codes of Redis, which are the first words of error messages
+const (
+	CodeErr        = "ERR"
+	CodeWrongType  = "WRONGTYPE"
+	CodeOOM        = "OOM"
+	CodeInvalidObj = "INVALIDOBJ"
+	CodeBusyGroup  = "BUSYGROUP"
+	CodeNoGroup    = "NOGROUP"
+)
+
+// Error is an error with a Redis error code.
+//
+// Its message is exactly what Redis replies, such as
+// "WRONGTYPE Operation against a key holding the wrong kind of value".
+type Error struct {
+	Code    string
+	Message string
+}
+
+func newError(code, message string) *Error {
+	return &Error{Code: code, Message: message}
+}
+
+func (e *Error) Error() string {
+	return e.Code + " " + e.Message
+}
 
 var (
-	ErrWrongTypeOperation = errors.New(
-		"wrong-type operation against a key holding the wrong kind of value",
+	ErrWrongTypeOperation = newError(
+		CodeWrongType, "Operation against a key holding the wrong kind of value",
 	)
-	ErrExpiredValue         = errors.New("the value is expired")
-	ErrNilValue             = errors.New("the value is nill")
-	ErrUnsupportedOperation = errors.New("unsupported operation")
-	ErrInvalidInteger       = errors.New("value is not a valid integer or out of range")
-	ErrInvalidFloat         = errors.New("value is not a valid float or out of range")
+	ErrUnsupportedOperation = newError(CodeErr, "unsupported operation")
+	ErrInvalidInteger       = newError(CodeErr, "value is not an integer or out of range")
+	ErrInvalidFloat         = newError(CodeErr, "value is not a valid float")
+	ErrIntegerOverflow      = newError(CodeErr, "increment or decrement would overflow")
+	ErrDecrementOverflow    = newError(CodeErr, "decrement would overflow")
+	ErrFloatOverflow        = newError(CodeErr, "increment would produce NaN or Infinity")
+	ErrOffsetOutOfRange     = newError(CodeErr, "offset is out of range")
+	ErrInvalidExpireTime    = newError(CodeErr, "invalid expire time")
+	ErrStringTooLong        = newError(CodeErr, "string exceeds maximum allowed size (proto-max-bulk-len)")
+	ErrLCSNotString         = newError(CodeErr, "The specified keys must contain string values")
+	ErrBitOffsetOutOfRange  = newError(CodeErr, "bit offset is not an integer or out of range")
+	ErrBitOutOfRange        = newError(CodeErr, "bit is not an integer or out of range")
+	ErrBitFieldType         = newError(CodeErr, "Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	ErrBitOpNotSingleKey    = newError(CodeErr, "BITOP NOT must be called with a single source key.")
+	ErrNotHyperLogLog       = newError(CodeWrongType, "Key is not a valid HyperLogLog string value.")
+	ErrCorruptedHyperLogLog = newError(CodeInvalidObj, "Corrupted HLL object detected")
+	ErrGeoMemberNotFound    = newError(CodeErr, "could not decode requested zset member")
+	ErrStreamIDTooSmall     = newError(CodeErr, "The ID specified in XADD is equal or smaller than the target stream top item")
+	ErrStreamIDZero         = newError(CodeErr, "The ID specified in XADD must be greater than 0-0")
+	ErrStreamIDExhausted    = newError(CodeErr, "The stream has exhausted the last possible ID, unable to add more items")
+	ErrStreamKeyRequired    = newError(CodeErr, "The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+	ErrStreamGroupExists    = newError(CodeBusyGroup, "Consumer Group name already exists")
+	ErrNoSuchKey            = newError(CodeErr, "no such key")
+	ErrLCSTooLong           = newError(CodeErr, "Insufficient memory, transient memory for LCS exceeds proto-max-bulk-len")
+	ErrJSONInvalid          = newError(CodeErr, "expected value")
+	ErrJSONPathInvalid      = newError(CodeErr, "invalid JSONPath")
+	ErrJSONNotNumber        = newError(CodeErr, "expected a number")
+	ErrJSONNoKey            = newError(CodeErr, "could not perform this operation on a key that doesn't exist")
+	ErrJSONNewAtRoot        = newError(CodeErr, "new objects must be created at the root")
+	ErrJSONIndexOutOfBounds = newError(CodeErr, "index out of bounds")
+	ErrCorruptedJSON        = newError(CodeErr, "Corrupted JSON document")
+	ErrFilterExists         = newError(CodeErr, "item exists")
+	ErrFilterNotFound       = newError(CodeErr, "not found")
+	ErrBloomFull            = newError(CodeErr, "non scaling filter is full")
+	ErrCuckooFull           = newError(CodeErr, "Filter is full")
+	ErrCuckooNotFound       = newError(CodeErr, "Not found")
+	ErrCMSKeyExists         = newError(CodeErr, "CMS: key already exists")
+	ErrCMSKeyNotFound       = newError(CodeErr, "CMS: key does not exist")
+	ErrCMSOverflow          = newError(CodeErr, "CMS: INCRBY overflow")
+	ErrCMSMergeOverflow     = newError(CodeErr, "CMS: MERGE overflow")
+	ErrCMSDimensionMismatch = newError(CodeErr, "CMS: width/depth is not equal")
+	ErrCMSTooLarge          = newError(CodeErr, "CMS: width * depth is too large")
+	ErrTopKKeyExists        = newError(CodeErr, "TopK: key already exists")
+	ErrTopKKeyNotFound      = newError(CodeErr, "TopK: key does not exist")
+	ErrTopKTooLarge         = newError(CodeErr, "TopK: k or width * depth is too large")
+	ErrTSKeyExists          = newError(CodeErr, "TSDB: key already exists")
+	ErrTSKeyNotFound        = newError(CodeErr, "TSDB: the key does not exist")
+	ErrTSTimestampTooOld    = newError(CodeErr, "TSDB: Timestamp is older than retention")
+	ErrTSDuplicateBlocked   = newError(CodeErr, "TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+	ErrSearchIndexExists    = newError(CodeErr, "Index already exists")
+	ErrSearchIndexNotFound  = newError(CodeErr, "Unknown Index name")
+	ErrSearchSyntax         = newError(CodeErr, "Syntax error")
+	ErrCorruptedSearchIndex = newError(CodeErr, "Corrupted search index definition")
 )
+
+// newErrNoGroup is the error of a missing stream or consumer group, such as what XPENDING replies
+func newErrNoGroup(key, group []byte) *Error {
+	return newError(CodeNoGroup, fmt.Sprintf("No such key '%s' or consumer group '%s'", key, group))
+}
+
+// newErrNoGroupForKey is the error of a missing consumer group of an existing stream, such as what XGROUP SETID replies
+func newErrNoGroupForKey(key, group []byte) *Error {
+	return newError(CodeNoGroup, fmt.Sprintf("No such consumer group '%s' for key name '%s'", group, key))
+}
+
+// newErrJSONPathNotExist is the error of a legacy JSON path which matches nothing
+func newErrJSONPathNotExist(path string) *Error {
+	return newError(CodeErr, fmt.Sprintf("Path '%s' does not exist", path))
+}
+
+// newErrJSONWrongType is the error of a value matched by a legacy JSON path with an unexpected type
+func newErrJSONWrongType(expected, found string) *Error {
+	return newError(CodeWrongType, fmt.Sprintf("wrong type of path value - expected %s but found %s", expected, found))
+}
+
+// newErrSearchUnknownField is the error of a query with an attribute not in the schema of the index
+func newErrSearchUnknownField(attribute string) *Error {
+	return newError(CodeErr, fmt.Sprintf("Unknown field `%s`", attribute))
+}
+
+// newErrSearchSortBy is the error of SORTBY with an attribute not in the schema of the index
+func newErrSearchSortBy(attribute string) *Error {
+	return newError(CodeErr, fmt.Sprintf("Property `%s` not loaded nor in schema", attribute))
+}
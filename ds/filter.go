@@ -0,0 +1,186 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// Bits of Bloom filters and buckets of Cuckoo filters are stored in pages of a fixed size,
+// so that an operation only reads and writes the pages it touches rather than a whole filter.
+const (
+	filterPageSize = 4096
+	filterPageTag  = 'p'
+)
+
+// filterPages caches pages of sub-filters read and written by an operation,
+// where missing pages are filled with zeros
+type filterPages struct {
+	ds     *DS
+	prefix []byte
+	pages  map[uint64][]byte // pages keyed by filter << 32 | page
+	dirty  map[uint64]bool
+}
+
+func newFilterPages(ds *DS, key []byte, version int64) *filterPages {
+	return &filterPages{
+		ds:     ds,
+		prefix: internalKeyPrefix(key, version),
+		pages:  make(map[uint64][]byte),
+		dirty:  make(map[uint64]bool),
+	}
+}
+
+// pageKey gets the internal key of a page: prefix + tag + filter + page
+func (p *filterPages) pageKey(id uint64) []byte {
+	encKey := append(bytes.Clone(p.prefix), filterPageTag)
+	return binary.BigEndian.AppendUint64(encKey, id)
+}
+
+// page gets the page containing an offset of a sub-filter, along with the offset in the page
+func (p *filterPages) page(filter uint32, offset uint64) ([]byte, uint64, error) {
+	id := uint64(filter)<<32 | offset/filterPageSize
+	page, ok := p.pages[id]
+	if !ok {
+		value, err := p.ds.db.Get(p.pageKey(id))
+		switch err {
+		case nil:
+			page = bytes.Clone(value)
+		case baradb.ErrKeyNotFound:
+			page = make([]byte, filterPageSize)
+		default:
+			return nil, 0, err
+		}
+		p.pages[id] = page
+	}
+	return page, offset % filterPageSize, nil
+}
+
+// get gets the byte at an offset of a sub-filter
+func (p *filterPages) get(filter uint32, offset uint64) (byte, error) {
+	page, i, err := p.page(filter, offset)
+	if err != nil {
+		return 0, err
+	}
+	return page[i], nil
+}
+
+// set sets the byte at an offset of a sub-filter
+func (p *filterPages) set(filter uint32, offset uint64, b byte) error {
+	page, i, err := p.page(filter, offset)
+	if err != nil {
+		return err
+	}
+	page[i] = b
+	p.dirty[uint64(filter)<<32|offset/filterPageSize] = true
+	return nil
+}
+
+// commit writes modified pages along with the metadata of the filter in one batch
+func (p *filterPages) commit(key, metadata []byte) error {
+	opts := baradb.DefaultWriteBatchOptions
+	if n := len(p.dirty) + 1; n > opts.MaxBatchNumber {
+		opts.MaxBatchNumber = n
+	}
+	wb := p.ds.db.NewWriteBatch(opts)
+	for id := range p.dirty {
+		if err := wb.Put(p.pageKey(id), p.pages[id]); err != nil {
+			return err
+		}
+	}
+	if err := wb.Put(key, metadata); err != nil {
+		return err
+	}
+	return wb.Commit()
+}
+
+// hashItem hashes an item into two independent 64-bit hashes
+func hashItem(item []byte) (uint64, uint64) {
+	h := fnv.New128a()
+	h.Write(item)
+	sum := h.Sum(nil)
+	return binary.BigEndian.Uint64(sum[:8]), binary.BigEndian.Uint64(sum[8:])
+}
+
+// encodeFilterHeader encodes the fields a filter metadata begins with, which are the same as other collections
+func encodeFilterHeader(dt dataType, expire, version int64, size uint32) []byte {
+	buffer := make([]byte, 1, 1+binary.MaxVarintLen64*3)
+	buffer[0] = dt
+	buffer = binary.AppendVarint(buffer, expire)
+	buffer = binary.AppendVarint(buffer, version)
+	return binary.AppendVarint(buffer, int64(size))
+}
+
+// fieldDecoder decodes fields of an encoded value in order, such as a filter metadata.
+//
+// A truncated buffer makes the decoder invalid, and all following fields are zero values.
+type fieldDecoder struct {
+	buffer  []byte
+	index   int
+	invalid bool
+}
+
+func (d *fieldDecoder) varint() int64 {
+	if d.invalid {
+		return 0
+	}
+	v, n := binary.Varint(d.buffer[d.index:])
+	if n <= 0 {
+		d.invalid = true
+		return 0
+	}
+	d.index += n
+	return v
+}
+
+func (d *fieldDecoder) uvarint() uint64 {
+	if d.invalid {
+		return 0
+	}
+	v, n := binary.Uvarint(d.buffer[d.index:])
+	if n <= 0 {
+		d.invalid = true
+		return 0
+	}
+	d.index += n
+	return v
+}
+
+func (d *fieldDecoder) float() float64 {
+	if d.invalid || len(d.buffer)-d.index < 8 {
+		d.invalid = true
+		return 0
+	}
+	v := binary.BigEndian.Uint64(d.buffer[d.index:])
+	d.index += 8
+	return math.Float64frombits(v)
+}
+
+func (d *fieldDecoder) byte() byte {
+	if d.invalid || d.index >= len(d.buffer) {
+		d.invalid = true
+		return 0
+	}
+	d.index++
+	return d.buffer[d.index-1]
+}
+
+// string decodes a string prefixed by its length
+func (d *fieldDecoder) string() string {
+	n := d.uvarint()
+	if d.invalid || n > uint64(d.remaining()) {
+		d.invalid = true
+		return ""
+	}
+	s := string(d.buffer[d.index : d.index+int(n)])
+	d.index += int(n)
+	return s
+}
+
+// remaining gets the number of bytes not decoded
+func (d *fieldDecoder) remaining() int {
+	return len(d.buffer) - d.index
+}
@@ -1,21 +1,116 @@
 package ds
 
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// reservedPrefix is the prefix of keys used internally, such as indexes of time series and search indexes,
+// which are not accessible by clients
+var reservedPrefix = []byte("\x00baradb-redis\x00")
+
+// IsReservedKey tells whether a key is used internally, which clients are not allowed to access
+func IsReservedKey(key []byte) bool {
+	return bytes.HasPrefix(key, reservedPrefix)
+}
+
 // Del redis DEL
+//
+// It deletes a key along with all internal keys of a collection.
 func (ds *DS) Del(key []byte) error {
-	return ds.db.Delete(key)
+	prefix, err := ds.deleteMetadata(key)
+	if err != nil || prefix == nil {
+		return err
+	}
+	return ds.deleteInternalKeys(prefix)
+}
+
+// Unlink redis UNLINK
+//
+// It deletes a key at once, while internal keys of a collection are deleted in the background.
+func (ds *DS) Unlink(key []byte) (bool, error) {
+	exists := ds.Exists(key)
+	prefix, err := ds.deleteMetadata(key)
+	if err != nil {
+		return false, err
+	}
+	if prefix != nil {
+		ds.reclaimer.add(prefix)
+	}
+	return exists, nil
+}
+
+// Touch redis TOUCH
+//
+// There is no eviction, so it only tells whether the key exists.
+func (ds *DS) Touch(key []byte) bool {
+	return ds.Exists(key)
+}
+
+// deleteMetadata deletes a key, and returns the prefix of internal keys if the key is a collection
+func (ds *DS) deleteMetadata(key []byte) ([]byte, error) {
+	defer ds.search.locks.lock(key).Unlock()
+
+	prefix, err := ds.collectionPrefix(key)
+	if err != nil {
+		return nil, err
+	}
+	// entries of search indexes of a hash are deleted along with the key
+	changes, err := ds.hashDeletionChanges(key)
+	if err != nil {
+		return nil, err
+	}
+	if changes.len() == 0 {
+		return prefix, ds.db.Delete(key)
+	}
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber += changes.len()
+	wb := ds.db.NewWriteBatch(opts)
+	if err := wb.Delete(key); err != nil {
+		return nil, err
+	}
+	if err := changes.apply(wb); err != nil {
+		return nil, err
+	}
+	if err := wb.Commit(); err != nil {
+		return nil, err
+	}
+	return prefix, nil
+}
+
+// collectionPrefix gets the prefix of internal keys of a key, or nil if the key is not a collection.
+//
+// Expired collections have their internal keys too.
+func (ds *DS) collectionPrefix(key []byte) ([]byte, error) {
+	value, err := ds.db.Get(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(value) == 0 {
+		return nil, nil
+	}
+	switch value[0] {
+	case String, CountMinSketch, TopK:
+		return nil, nil
+	}
+	md := decodeMetadata(value)
+	return internalKeyPrefix(key, md.version), nil
 }
 
 // Type redis TYPE
 //
 // It gets the type of the corresponding value of the given key
 func (ds *DS) Type(key []byte) (dataType, error) {
-	value, err := ds.db.Get(key)
+	value, err := ds.getValue(key)
 	if err != nil {
 		return 0, err
 	}
-	if len(value) == 0 {
-		return 0, ErrNilValue
-	}
 
 	dt := value[0]
 	return dt, nil
@@ -24,9 +119,34 @@ func (ds *DS) Type(key []byte) (dataType, error) {
 // Exists redis EXISTS
 func (ds *DS) Exists(key []byte) bool {
 	_, err := ds.Type(key)
+	return err == nil
+}
+
+// getValue gets the encoded value of a key, which is either a value stored in the key alone, such as a string, or a metadata.
+//
+// An expired key and an empty hash, set, list or sorted set are treated as missing keys,
+// so baradb.ErrKeyNotFound is returned for them.
+func (ds *DS) getValue(key []byte) ([]byte, error) {
+	value, err := ds.db.Get(key)
 	if err != nil {
-		return err == ErrNilValue
+		return nil, err
+	}
+	if len(value) == 0 {
+		return nil, baradb.ErrKeyNotFound
+	}
+
+	// both values stored in keys alone and metadata are encoded as type + expire + ...
+	expire, _ := binary.Varint(value[1:])
+	if expire > 0 && expire <= time.Now().UnixNano() {
+		return nil, baradb.ErrKeyNotFound
+	}
+	// empty streams and time series still exist, unlike empty hashes, sets, lists and sorted sets
+	switch value[0] {
+	case Hash, Set, List, ZSet:
+		if decodeMetadata(value).size == 0 {
+			return nil, baradb.ErrKeyNotFound
+		}
 	}
 
-	return true
+	return value, nil
 }
@@ -1,9 +1,13 @@
 package ds
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/saint-yellow/baradb"
+	"github.com/saint-yellow/baradb/index"
+	"github.com/saint-yellow/baradb/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -143,3 +147,80 @@ func TestDS_Exists(t *testing.T) {
 	exists = ds.Exists([]byte("zset-1"))
 	assert.False(t, exists)
 }
+
+func TestDS_Unlink(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	var exists bool
+	var err error
+
+	exists, err = ds.Unlink([]byte("unknown"))
+	assert.False(t, exists)
+	assert.Nil(t, err)
+
+	ds.Set([]byte("string-1"), []byte("value-1"), 0)
+	exists, err = ds.Unlink([]byte("string-1"))
+	assert.True(t, exists)
+	assert.Nil(t, err)
+	assert.False(t, ds.Exists([]byte("string-1")))
+
+	for i := 0; i < 1000; i++ {
+		ds.SAdd([]byte("set-1"), utils.NewKey(i))
+	}
+	md, _ := ds.getMetadata([]byte("set-1"), Set)
+	prefix := internalKeyPrefix([]byte("set-1"), md.version)
+
+	exists, err = ds.Unlink([]byte("set-1"))
+	assert.True(t, exists)
+	assert.Nil(t, err)
+	assert.False(t, ds.Exists([]byte("set-1")))
+
+	// wait for the internal keys to be reclaimed in the background
+	assert.Eventually(t, func() bool {
+		return countKeysWithPrefix(ds, prefix) == 0
+	}, time.Second*5, time.Millisecond*10)
+}
+
+func TestDS_DelInternalKeys(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	for i := 0; i < 300; i++ {
+		ds.HSet([]byte("hash-1"), utils.NewKey(i), utils.NewKey(i))
+	}
+	md, _ := ds.getMetadata([]byte("hash-1"), Hash)
+	prefix := internalKeyPrefix([]byte("hash-1"), md.version)
+	assert.Equal(t, 300, countKeysWithPrefix(ds, prefix))
+
+	err := ds.Del([]byte("hash-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, countKeysWithPrefix(ds, prefix))
+}
+
+func TestDS_Touch(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	assert.False(t, ds.Touch([]byte("unknown")))
+
+	ds.Set([]byte("string-1"), []byte("value-1"), 0)
+	assert.True(t, ds.Touch([]byte("string-1")))
+
+	ds.Set([]byte("string-2"), []byte("value-2"), time.Millisecond*10)
+	time.Sleep(time.Millisecond * 20)
+	assert.False(t, ds.Touch([]byte("string-2")))
+}
+
+func countKeysWithPrefix(ds *DS, prefix []byte) int {
+	opts := index.DefaultIteratorOptions
+	opts.Prefix = prefix
+	iter := ds.db.NewItrerator(opts)
+	defer iter.Close()
+
+	count := 0
+	for iter.Seek(prefix); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		count++
+	}
+	return count
+}
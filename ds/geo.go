@@ -0,0 +1,275 @@
+package ds
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Geospatial indexes are sorted sets, whose scores are 52-bit geohashes of members.
+
+// GeoLocation is a member of a geospatial index
+type GeoLocation struct {
+	Member []byte
+	GeoPoint
+	Hash int64   // the geohash, which is the score of the member
+	Dist float64 // the distance from the center of a search, in the unit of the search
+}
+
+// GeoAddOptions are options of GEOADD
+type GeoAddOptions struct {
+	NX bool // only adds new members
+	XX bool // only updates existing members
+	CH bool // counts changed members as well as added members
+}
+
+// GeoSort is an order of results of a geospatial search
+type GeoSort int
+
+const (
+	GeoUnsorted GeoSort = iota
+	GeoAsc
+	GeoDesc
+)
+
+// GeoSearchOptions are options of GEOSEARCH
+type GeoSearchOptions struct {
+	// the center is the member if it is not nil, or the point otherwise
+	FromMember []byte
+	FromPoint  GeoPoint
+
+	// the area is the box if ByBox is true, or the circle otherwise
+	Radius float64
+	ByBox  bool
+	Width  float64
+	Height float64
+	Unit   float64 // meters of a unit of distances, such as 1000 for kilometers
+
+	Sort  GeoSort
+	Count int  // 0 if there is no limit
+	Any   bool // returns as soon as enough members are found
+}
+
+func newErrInvalidGeoPoint(p GeoPoint) error {
+	return newError(CodeErr, fmt.Sprintf("invalid longitude,latitude pair %f,%f", p.Longitude, p.Latitude))
+}
+
+// geohashScore gets the score of a point in a geospatial index
+func geohashScore(p GeoPoint) float64 {
+	return float64(geohashEncode(p, geoLatitudeMin, geoLatitudeMax))
+}
+
+// GeoAdd redis GEOADD
+//
+// It returns the number of added members, along with changed members if opts.CH is true.
+func (ds *DS) GeoAdd(key []byte, opts GeoAddOptions, locations ...GeoLocation) (int, error) {
+	for _, location := range locations {
+		if !location.valid() {
+			return 0, newErrInvalidGeoPoint(location.GeoPoint)
+		}
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	var count int
+	for _, location := range locations {
+		md, err := ds.getMetadata(key, ZSet)
+		if err != nil {
+			return 0, err
+		}
+		oldScore, exists, err := ds.zsetScore(key, md, location.Member)
+		if err != nil {
+			return 0, err
+		}
+		if (opts.NX && exists) || (opts.XX && !exists) {
+			continue
+		}
+
+		score := geohashScore(location.GeoPoint)
+		added, err := ds.ZAdd(key, score, location.Member)
+		if err != nil {
+			return 0, err
+		}
+		if added || (opts.CH && oldScore != score) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GeoPos redis GEOPOS
+//
+// The point of a missing member is nil.
+func (ds *DS) GeoPos(key []byte, members ...[]byte) ([]*GeoPoint, error) {
+	points := make([]*GeoPoint, len(members))
+	md, err := ds.zsetMetadata(key)
+	if err != nil || md == nil {
+		return points, err
+	}
+
+	for i, member := range members {
+		score, ok, err := ds.zsetScore(key, md, member)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			p := geohashDecode(uint64(score))
+			points[i] = &p
+		}
+	}
+	return points, nil
+}
+
+// GeoDist redis GEODIST
+//
+// It returns the distance in meters, ok is false if either member is missing.
+func (ds *DS) GeoDist(key, member1, member2 []byte) (float64, bool, error) {
+	points, err := ds.GeoPos(key, member1, member2)
+	if err != nil || points[0] == nil || points[1] == nil {
+		return 0, false, err
+	}
+	return geoDistance(*points[0], *points[1]), true, nil
+}
+
+// GeoHash redis GEOHASH
+//
+// The geohash string of a missing member is nil.
+func (ds *DS) GeoHash(key []byte, members ...[]byte) ([][]byte, error) {
+	points, err := ds.GeoPos(key, members...)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([][]byte, len(points))
+	for i, p := range points {
+		if p != nil {
+			hashes[i] = geohashString(*p)
+		}
+	}
+	return hashes, nil
+}
+
+// deltas gets the maximum differences of latitudes and longitudes in degrees from the center to points of the area
+func (opts GeoSearchOptions) deltas(center GeoPoint, unit float64) (float64, float64) {
+	if !opts.ByBox {
+		r := opts.Radius * unit / earthRadiusInMeters
+		sin := math.Sin(r) / math.Cos(degreesToRadians(center.Latitude))
+		// the circle contains a pole, so it contains all longitudes
+		if r >= math.Pi/2 || sin >= 1 {
+			return radiansToDegrees(r), 180
+		}
+		return radiansToDegrees(r), radiansToDegrees(math.Asin(sin))
+	}
+	latitudeDelta := radiansToDegrees(opts.Height * unit / 2 / earthRadiusInMeters)
+	latitude := math.Abs(center.Latitude) + latitudeDelta
+	if latitude >= 90 {
+		return latitudeDelta, 180
+	}
+	// parallels are the shortest at the latitude farthest from the equator
+	sin := math.Sin(opts.Width*unit/4/earthRadiusInMeters) / math.Cos(degreesToRadians(latitude))
+	if sin >= 1 {
+		return latitudeDelta, 180
+	}
+	return latitudeDelta, radiansToDegrees(2 * math.Asin(sin))
+}
+
+// GeoSearch redis GEOSEARCH
+//
+// Members are found in ranges of scores of the box of the center and its neighbours,
+// and filtered by their distances from the center.
+func (ds *DS) GeoSearch(key []byte, opts GeoSearchOptions) ([]GeoLocation, error) {
+	md, err := ds.zsetMetadata(key)
+	if err != nil || md == nil {
+		return nil, err
+	}
+
+	center := opts.FromPoint
+	if opts.FromMember != nil {
+		score, ok, err := ds.zsetScore(key, md, opts.FromMember)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, ErrGeoMemberNotFound
+		}
+		center = geohashDecode(uint64(score))
+	}
+	unit := opts.Unit
+	if unit == 0 {
+		unit = 1
+	}
+
+	var locations []GeoLocation
+	scan := func(member []byte, score float64) bool {
+		p := geohashDecode(uint64(score))
+		var dist float64
+		var ok bool
+		if opts.ByBox {
+			dist, ok = geoDistanceInBox(center, p, opts.Width*unit, opts.Height*unit)
+		} else {
+			dist = geoDistance(center, p)
+			ok = dist <= opts.Radius*unit
+		}
+		if ok {
+			locations = append(locations, GeoLocation{
+				Member:   member,
+				GeoPoint: p,
+				Hash:     int64(score),
+				Dist:     dist / unit,
+			})
+		}
+		return !opts.Any || opts.Count == 0 || len(locations) < opts.Count
+	}
+	latitudeDelta, longitudeDelta := opts.deltas(center, unit)
+	for _, r := range geohashRanges(center, latitudeDelta, longitudeDelta) {
+		if err := ds.zsetScanScores(key, md, float64(r[0]), float64(r[1]), scan); err != nil {
+			return nil, err
+		}
+		if opts.Any && opts.Count > 0 && len(locations) >= opts.Count {
+			break
+		}
+	}
+
+	// the closest members are returned if the number is limited
+	sortBy := opts.Sort
+	if sortBy == GeoUnsorted && opts.Count > 0 && !opts.Any {
+		sortBy = GeoAsc
+	}
+	switch sortBy {
+	case GeoAsc:
+		sort.SliceStable(locations, func(i, j int) bool { return locations[i].Dist < locations[j].Dist })
+	case GeoDesc:
+		sort.SliceStable(locations, func(i, j int) bool { return locations[i].Dist > locations[j].Dist })
+	}
+	if opts.Count > 0 && len(locations) > opts.Count {
+		locations = locations[:opts.Count]
+	}
+	return locations, nil
+}
+
+// GeoSearchStore redis GEOSEARCHSTORE
+//
+// It stores found members in the destination key, with their geohashes as scores,
+// or their distances as scores if storeDist is true. The destination key is deleted if nothing is found.
+func (ds *DS) GeoSearchStore(destKey, key []byte, opts GeoSearchOptions, storeDist bool) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	locations, err := ds.GeoSearch(key, opts)
+	if err != nil {
+		return 0, err
+	}
+	if err := ds.Del(destKey); err != nil {
+		return 0, err
+	}
+	for _, location := range locations {
+		score := float64(location.Hash)
+		if storeDist {
+			score = location.Dist
+		}
+		if _, err := ds.ZAdd(destKey, score, location.Member); err != nil {
+			return 0, err
+		}
+	}
+	return len(locations), nil
+}
@@ -0,0 +1,179 @@
+package ds
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var sicily = []GeoLocation{
+	{Member: []byte("Palermo"), GeoPoint: GeoPoint{Longitude: 13.361389, Latitude: 38.115556}},
+	{Member: []byte("Catania"), GeoPoint: GeoPoint{Longitude: 15.087269, Latitude: 37.502669}},
+}
+
+func TestDS_GeoAdd(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("Sicily")
+	n, err := ds.GeoAdd(key, GeoAddOptions{}, sicily...)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+
+	// the score is the same as Redis
+	score, err := ds.ZScore(key, []byte("Palermo"))
+	assert.Nil(t, err)
+	assert.Equal(t, float64(3479099956230698), score)
+
+	moved := GeoLocation{Member: []byte("Palermo"), GeoPoint: GeoPoint{Longitude: 13.5, Latitude: 38}}
+	n, err = ds.GeoAdd(key, GeoAddOptions{NX: true}, moved)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	n, err = ds.GeoAdd(key, GeoAddOptions{XX: true, CH: true}, moved)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	_, err = ds.GeoAdd(key, GeoAddOptions{}, GeoLocation{Member: []byte("Pole"), GeoPoint: GeoPoint{Longitude: 0, Latitude: 90}})
+	assert.EqualError(t, err, "ERR invalid longitude,latitude pair 0.000000,90.000000")
+}
+
+func TestDS_GeoPos(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("Sicily")
+	_, err := ds.GeoAdd(key, GeoAddOptions{}, sicily...)
+	assert.Nil(t, err)
+
+	points, err := ds.GeoPos(key, []byte("Palermo"), []byte("NonExisting"))
+	assert.Nil(t, err)
+	assert.Equal(t, "13.36138933897018433", fmt.Sprintf("%.17f", points[0].Longitude))
+	assert.Equal(t, "38.11555639549629859", fmt.Sprintf("%.17f", points[0].Latitude))
+	assert.Nil(t, points[1])
+
+	dist, ok, err := ds.GeoDist(key, []byte("Palermo"), []byte("Catania"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "166274.1516", fmt.Sprintf("%.4f", dist))
+	_, ok, err = ds.GeoDist(key, []byte("Palermo"), []byte("NonExisting"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	hashes, err := ds.GeoHash(key, []byte("Palermo"), []byte("Catania"), []byte("NonExisting"))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("sqc8b49rny0"), []byte("sqdtr74hyu0"), nil}, hashes)
+}
+
+func TestDS_GeoSearch(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("Sicily")
+	_, err := ds.GeoAdd(key, GeoAddOptions{}, sicily...)
+	assert.Nil(t, err)
+	_, err = ds.GeoAdd(key, GeoAddOptions{},
+		GeoLocation{Member: []byte("edge1"), GeoPoint: GeoPoint{Longitude: 12.758489, Latitude: 38.788135}},
+		GeoLocation{Member: []byte("edge2"), GeoPoint: GeoPoint{Longitude: 17.241510, Latitude: 38.788135}},
+	)
+	assert.Nil(t, err)
+
+	members := func(locations []GeoLocation) []string {
+		names := make([]string, len(locations))
+		for i, location := range locations {
+			names[i] = string(location.Member)
+		}
+		return names
+	}
+
+	locations, err := ds.GeoSearch(key, GeoSearchOptions{FromPoint: GeoPoint{Longitude: 15, Latitude: 37}, Radius: 200, Unit: 1000, Sort: GeoAsc})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Catania", "Palermo"}, members(locations))
+	assert.Equal(t, "56.4413", fmt.Sprintf("%.4f", locations[0].Dist))
+	assert.Equal(t, "190.4424", fmt.Sprintf("%.4f", locations[1].Dist))
+
+	locations, err = ds.GeoSearch(key, GeoSearchOptions{FromPoint: GeoPoint{Longitude: 15, Latitude: 37}, ByBox: true, Width: 400, Height: 400, Unit: 1000, Sort: GeoDesc})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"edge1", "edge2", "Palermo", "Catania"}, members(locations))
+
+	// the closest one is found if the number is limited
+	locations, err = ds.GeoSearch(key, GeoSearchOptions{FromMember: []byte("Palermo"), Radius: 500, Unit: 1000, Count: 1})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"Palermo"}, members(locations))
+
+	_, err = ds.GeoSearch(key, GeoSearchOptions{FromMember: []byte("NonExisting"), Radius: 500})
+	assert.Equal(t, ErrGeoMemberNotFound, err)
+
+	n, err := ds.GeoSearchStore([]byte("dest"), key, GeoSearchOptions{FromPoint: GeoPoint{Longitude: 15, Latitude: 37}, Radius: 200, Unit: 1000}, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	score, err := ds.ZScore([]byte("dest"), []byte("Catania"))
+	assert.Nil(t, err)
+	assert.InDelta(t, 56.4413, score, 0.0001)
+
+	n, err = ds.GeoSearchStore([]byte("dest"), key, GeoSearchOptions{FromPoint: GeoPoint{Longitude: 0, Latitude: 0}, Radius: 1}, false)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.False(t, ds.Exists([]byte("dest")))
+}
+
+func TestDS_GeoSearchRanges(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	// a grid of points, including ones around the antimeridian and the poles
+	key := []byte("grid")
+	var locations []GeoLocation
+	for longitude := -180.0; longitude <= 180; longitude += 7.5 {
+		for latitude := -85.0; latitude <= 85; latitude += 5 {
+			locations = append(locations, GeoLocation{
+				Member:   []byte(fmt.Sprintf("%v,%v", longitude, latitude)),
+				GeoPoint: GeoPoint{Longitude: longitude, Latitude: latitude},
+			})
+		}
+	}
+	_, err := ds.GeoAdd(key, GeoAddOptions{}, locations...)
+	assert.Nil(t, err)
+
+	// a small area is covered by 9 small boxes, while a huge area is covered by all geohashes
+	opts := GeoSearchOptions{Radius: 100, Unit: 1000}
+	latitudeDelta, longitudeDelta := opts.deltas(GeoPoint{}, 1000)
+	ranges := geohashRanges(GeoPoint{}, latitudeDelta, longitudeDelta)
+	assert.Len(t, ranges, 9)
+	for _, r := range ranges {
+		assert.Less(t, r[1]-r[0], uint64(1)<<40)
+	}
+	opts.Radius = 20000
+	latitudeDelta, longitudeDelta = opts.deltas(GeoPoint{}, 1000)
+	assert.Equal(t, [][2]uint64{{0, 1 << 52}}, geohashRanges(GeoPoint{}, latitudeDelta, longitudeDelta))
+
+	// members found in ranges of scores are the same as members filtered by their distances
+	centers := []GeoPoint{{0, 0}, {179, 10}, {-179.5, -42}, {30, 84}, {-100, -80}}
+	for _, center := range centers {
+		for _, size := range []float64{100, 800, 3000, 20000} {
+			for _, byBox := range []bool{false, true} {
+				opts := GeoSearchOptions{FromPoint: center, Radius: size, ByBox: byBox, Width: size, Height: size / 2, Unit: 1000, Sort: GeoAsc}
+				var expected []string
+				for _, location := range locations {
+					p := geohashDecode(uint64(geohashScore(location.GeoPoint)))
+					var ok bool
+					if byBox {
+						_, ok = geoDistanceInBox(center, p, size*1000, size*1000/2)
+					} else {
+						ok = geoDistance(center, p) <= size*1000
+					}
+					if ok {
+						expected = append(expected, string(location.Member))
+					}
+				}
+				found, err := ds.GeoSearch(key, opts)
+				assert.Nil(t, err)
+				var members []string
+				for _, location := range found {
+					members = append(members, string(location.Member))
+				}
+				assert.ElementsMatch(t, expected, members, "%v %v %v", center, size, byBox)
+			}
+		}
+	}
+}
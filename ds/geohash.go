@@ -0,0 +1,180 @@
+package ds
+
+import "math"
+
+// Geohashes are interleaved bits of latitudes and longitudes, the same as Redis.
+const (
+	geoStep         = 26 // bits of each of the latitude and the longitude, 52 bits in total
+	geoLongitudeMin = -180
+	geoLongitudeMax = 180
+	geoLatitudeMin  = -85.05112878 // limits of EPSG:3857
+	geoLatitudeMax  = 85.05112878
+
+	earthRadiusInMeters = 6372797.560856 // the same as Redis
+)
+
+// geoAlphabet is the alphabet of standard geohash strings
+const geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// GeoPoint is a point of a longitude and a latitude
+type GeoPoint struct {
+	Longitude float64
+	Latitude  float64
+}
+
+func (p GeoPoint) valid() bool {
+	return p.Longitude >= geoLongitudeMin && p.Longitude <= geoLongitudeMax &&
+		p.Latitude >= geoLatitudeMin && p.Latitude <= geoLatitudeMax
+}
+
+// geohashEncode encodes a point to a geohash of 52 bits within the given range of latitudes
+func geohashEncode(p GeoPoint, latitudeMin, latitudeMax float64) uint64 {
+	latitudeOffset := (p.Latitude - latitudeMin) / (latitudeMax - latitudeMin)
+	longitudeOffset := (p.Longitude - geoLongitudeMin) / (geoLongitudeMax - geoLongitudeMin)
+	// the maximum longitude and latitude are in the last boxes, so geohashes are always within 52 bits
+	latitudeOffset = math.Min(latitudeOffset*(1<<geoStep), 1<<geoStep-1)
+	longitudeOffset = math.Min(longitudeOffset*(1<<geoStep), 1<<geoStep-1)
+	return interleave64(uint32(latitudeOffset), uint32(longitudeOffset))
+}
+
+// geohashDecode decodes a geohash of 52 bits to the center of its area
+func geohashDecode(hash uint64) GeoPoint {
+	separated := deinterleave64(hash)
+	latitudeBits, longitudeBits := float64(uint32(separated)), float64(uint32(separated>>32))
+
+	latitudeScale := float64(geoLatitudeMax - geoLatitudeMin)
+	longitudeScale := float64(geoLongitudeMax - geoLongitudeMin)
+	latitudeMin := geoLatitudeMin + latitudeBits/(1<<geoStep)*latitudeScale
+	latitudeMax := geoLatitudeMin + (latitudeBits+1)/(1<<geoStep)*latitudeScale
+	longitudeMin := geoLongitudeMin + longitudeBits/(1<<geoStep)*longitudeScale
+	longitudeMax := geoLongitudeMin + (longitudeBits+1)/(1<<geoStep)*longitudeScale
+
+	return GeoPoint{
+		Longitude: math.Max(geoLongitudeMin, math.Min(geoLongitudeMax, (longitudeMin+longitudeMax)/2)),
+		Latitude:  math.Max(geoLatitudeMin, math.Min(geoLatitudeMax, (latitudeMin+latitudeMax)/2)),
+	}
+}
+
+// geohashString encodes a point to a standard geohash string of 11 characters
+func geohashString(p GeoPoint) []byte {
+	hash := geohashEncode(p, -90, 90)
+	buffer := make([]byte, 11)
+	for i := range buffer {
+		var index uint64
+		// the last character has no bits left
+		if i < 10 {
+			index = hash >> (52 - (i+1)*5) & 0x1f
+		}
+		buffer[i] = geoAlphabet[index]
+	}
+	return buffer
+}
+
+// geohashRanges gets ranges [min, max) of geohashes covering the area within the deltas of degrees from the center.
+//
+// Like Redis, the area is covered by the box of the center and its 8 neighbours,
+// which are the smallest boxes larger than the deltas, and longitudes wrap around.
+func geohashRanges(center GeoPoint, latitudeDelta, longitudeDelta float64) [][2]uint64 {
+	step := geoStep
+	for step > 0 && ((geoLatitudeMax-geoLatitudeMin)/float64(uint64(1)<<step) < latitudeDelta ||
+		(geoLongitudeMax-geoLongitudeMin)/float64(uint64(1)<<step) < longitudeDelta) {
+		step--
+	}
+	n := int64(1) << step
+	boxIndex := func(offset float64) int64 {
+		i := int64(offset * float64(n))
+		if i >= n {
+			return n - 1
+		}
+		if i < 0 {
+			return 0
+		}
+		return i
+	}
+	latitudeIndex := boxIndex((center.Latitude - geoLatitudeMin) / (geoLatitudeMax - geoLatitudeMin))
+	longitudeIndex := boxIndex((center.Longitude - geoLongitudeMin) / (geoLongitudeMax - geoLongitudeMin))
+
+	shift := 2 * (geoStep - step)
+	var ranges [][2]uint64
+	seen := make(map[uint64]bool)
+	for dy := int64(-1); dy <= 1; dy++ {
+		y := latitudeIndex + dy
+		if y < 0 || y >= n {
+			continue
+		}
+		for dx := int64(-1); dx <= 1; dx++ {
+			x := (longitudeIndex + dx + n) % n
+			hash := interleave64(uint32(y), uint32(x))
+			if seen[hash] {
+				continue
+			}
+			seen[hash] = true
+			ranges = append(ranges, [2]uint64{hash << shift, (hash + 1) << shift})
+		}
+	}
+	return ranges
+}
+
+// interleave64 interleaves bits of x and y, where bits of x are at even positions
+func interleave64(x32, y32 uint32) uint64 {
+	masks := []uint64{0x5555555555555555, 0x3333333333333333, 0x0F0F0F0F0F0F0F0F, 0x00FF00FF00FF00FF, 0x0000FFFF0000FFFF}
+	shifts := []uint{1, 2, 4, 8, 16}
+
+	x, y := uint64(x32), uint64(y32)
+	for i := len(shifts) - 1; i >= 0; i-- {
+		x = (x | x<<shifts[i]) & masks[i]
+		y = (y | y<<shifts[i]) & masks[i]
+	}
+	return x | y<<1
+}
+
+// deinterleave64 reverses interleave64, where x is in the low 32 bits and y is in the high 32 bits
+func deinterleave64(interleaved uint64) uint64 {
+	masks := []uint64{0x5555555555555555, 0x3333333333333333, 0x0F0F0F0F0F0F0F0F, 0x00FF00FF00FF00FF, 0x0000FFFF0000FFFF, 0x00000000FFFFFFFF}
+	shifts := []uint{0, 1, 2, 4, 8, 16}
+
+	x, y := interleaved, interleaved>>1
+	for i := range shifts {
+		x = (x | x>>shifts[i]) & masks[i]
+		y = (y | y>>shifts[i]) & masks[i]
+	}
+	return x | y<<32
+}
+
+func degreesToRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func radiansToDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
+
+// geoLatitudeDistance gets the distance between two latitudes in meters
+func geoLatitudeDistance(latitude1, latitude2 float64) float64 {
+	return earthRadiusInMeters * math.Abs(degreesToRadians(latitude2)-degreesToRadians(latitude1))
+}
+
+// geoDistance gets the distance between two points in meters by the haversine formula
+func geoDistance(p1, p2 GeoPoint) float64 {
+	v := math.Sin((degreesToRadians(p2.Longitude) - degreesToRadians(p1.Longitude)) / 2)
+	// the longitudes are practically the same
+	if v == 0 {
+		return geoLatitudeDistance(p1.Latitude, p2.Latitude)
+	}
+	latitude1, latitude2 := degreesToRadians(p1.Latitude), degreesToRadians(p2.Latitude)
+	u := math.Sin((latitude2 - latitude1) / 2)
+	a := u*u + math.Cos(latitude1)*math.Cos(latitude2)*v*v
+	return 2 * earthRadiusInMeters * math.Asin(math.Sqrt(a))
+}
+
+// geoDistanceInBox gets the distance from the center of a box to a point, ok is false if the point is out of the box
+func geoDistanceInBox(center, p GeoPoint, width, height float64) (float64, bool) {
+	// the distance of latitudes is cheaper, so it is checked first
+	if geoLatitudeDistance(p.Latitude, center.Latitude) > height/2 {
+		return 0, false
+	}
+	if geoDistance(p, GeoPoint{Longitude: center.Longitude, Latitude: p.Latitude}) > width/2 {
+		return 0, false
+	}
+	return geoDistance(center, p), true
+}
@@ -1,9 +1,11 @@
 package ds
 
 import (
+	"bytes"
 	"encoding/binary"
 
 	"github.com/saint-yellow/baradb"
+	"github.com/saint-yellow/baradb/index"
 )
 
 type hashInternalKey struct {
@@ -32,6 +34,8 @@ func (hk *hashInternalKey) encode() []byte {
 
 // HSet redis HSET
 func (ds *DS) HSet(key, field, value []byte) (bool, error) {
+	defer ds.search.locks.lock(key).Unlock()
+
 	md, err := ds.getMetadata(key, Hash)
 	if err != nil {
 		return false, err
@@ -45,16 +49,24 @@ func (ds *DS) HSet(key, field, value []byte) (bool, error) {
 	encKey := hk.encode()
 
 	exist := true
-	if _, err := ds.db.Get(encKey); err == baradb.ErrKeyNotFound {
+	oldValue, err := ds.db.Get(encKey)
+	if err == baradb.ErrKeyNotFound {
 		exist = false
 	}
 
-	wb := ds.db.NewWriteBatch(baradb.DefaultWriteBatchOptions)
+	// entries of search indexes are updated in the same batch
+	changes := ds.hashFieldChanges(key, field, oldValue, value)
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber += changes.len()
+	wb := ds.db.NewWriteBatch(opts)
 	if !exist {
 		md.size++
 		wb.Put(key, encodeMetadata(md))
 	}
 	wb.Put(encKey, value)
+	if err := changes.apply(wb); err != nil {
+		return false, err
+	}
 	if err := wb.Commit(); err != nil {
 		return false, err
 	}
@@ -66,7 +78,7 @@ func (ds *DS) HSet(key, field, value []byte) (bool, error) {
 func (ds *DS) HGet(key, field []byte) ([]byte, error) {
 	md, err := ds.getMetadata(key, Hash)
 	if err != nil {
-		return nil, nil
+		return nil, err
 	}
 
 	if md.size == 0 {
@@ -88,6 +100,8 @@ func (ds *DS) HGet(key, field []byte) ([]byte, error) {
 // When the returned error is nil,
 // if the given key exists, then it returns true and false otherwise.
 func (ds *DS) HDel(key, field []byte) (bool, error) {
+	defer ds.search.locks.lock(key).Unlock()
+
 	md, err := ds.getMetadata(key, Hash)
 	if err != nil {
 		return false, err
@@ -105,17 +119,30 @@ func (ds *DS) HDel(key, field []byte) (bool, error) {
 	encKey := hk.encode()
 
 	exist := true
-	_, err = ds.db.Get(encKey)
+	oldValue, err := ds.db.Get(encKey)
 	if err == baradb.ErrKeyNotFound {
 		exist = false
 	}
 
 	if exist {
-		wb := ds.db.NewWriteBatch(baradb.DefaultWriteBatchOptions)
 		md.size--
+		// entries of search indexes are updated in the same batch,
+		// and a hash without fields is no longer indexed
+		changes := ds.hashFieldChanges(key, field, oldValue, nil)
+		if md.size == 0 {
+			if changes, err = ds.hashDeletionChanges(key); err != nil {
+				return false, err
+			}
+		}
+		opts := baradb.DefaultWriteBatchOptions
+		opts.MaxBatchNumber += changes.len()
+		wb := ds.db.NewWriteBatch(opts)
 		encMd := encodeMetadata(md)
 		wb.Put(key, encMd)
 		wb.Delete(encKey)
+		if err := changes.apply(wb); err != nil {
+			return false, err
+		}
 
 		err = wb.Commit()
 		if err != nil {
@@ -125,3 +152,37 @@ func (ds *DS) HDel(key, field []byte) (bool, error) {
 
 	return exist, nil
 }
+
+// HGetAll redis HGETALL
+//
+// It returns fields and values of a hash alternately.
+func (ds *DS) HGetAll(key []byte) ([][]byte, error) {
+	md, err := ds.getMetadata(key, Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if md.size == 0 {
+		return nil, nil
+	}
+
+	// every field of the hash starts with the key and the version
+	prefix := (&hashInternalKey{key: key, version: md.version}).encode()
+
+	pairs := make([][]byte, 0, md.size*2)
+	opts := index.DefaultIteratorOptions
+	opts.Prefix = prefix
+	iter := ds.db.NewItrerator(opts)
+	defer iter.Close()
+	for iter.Seek(prefix); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		value, err := iter.Value()
+		if err != nil {
+			return nil, err
+		}
+		field := make([]byte, len(iter.Key())-len(prefix))
+		copy(field, iter.Key()[len(prefix):])
+		pairs = append(pairs, field, value)
+	}
+
+	return pairs, nil
+}
@@ -90,3 +90,27 @@ func TestDS_HDel(t *testing.T) {
 	assert.False(t, exist)
 	assert.Nil(t, err)
 }
+
+func TestDS_HGetAll(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	var pairs [][]byte
+	var err error
+
+	// unknown key
+	pairs, err = ds.HGetAll([]byte("hash"))
+	assert.Nil(t, err)
+	assert.Empty(t, pairs)
+
+	ds.HSet([]byte("hash"), []byte("field-1"), []byte("value-1"))
+	ds.HSet([]byte("hash"), []byte("field-2"), []byte("value-2"))
+	ds.HSet([]byte("hash-2"), []byte("field-3"), []byte("value-3"))
+
+	pairs, err = ds.HGetAll([]byte("hash"))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{
+		[]byte("field-1"), []byte("value-1"),
+		[]byte("field-2"), []byte("value-2"),
+	}, pairs)
+}
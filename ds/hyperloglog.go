@@ -0,0 +1,436 @@
+package ds
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// HyperLogLogs are strings in the same format as Redis:
+//
+//	+------+----------+---------+-------------------+-----------+
+//	| HYLL | encoding | 3 bytes | cached cardinality | registers |
+//	+------+----------+---------+-------------------+-----------+
+//
+// The cached cardinality is 8 bytes in little endian, which is invalid if its most significant bit is set.
+// There are 16384 registers of 6 bits, which are encoded either densely or sparsely.
+const (
+	hllP              = 14
+	hllQ              = 64 - hllP
+	hllRegisters      = 1 << hllP
+	hllBits           = 6
+	hllRegisterMax    = 1<<hllBits - 1
+	hllHeaderSize     = 16
+	hllDenseSize      = hllHeaderSize + (hllRegisters*hllBits+7)/8
+	hllSparseMaxBytes = 3000 // the sparse encoding is promoted to the dense one beyond this size
+	hllSparseValueMax = 32   // the maximum value of a register in the sparse encoding
+
+	hllEncodingDense  = 0
+	hllEncodingSparse = 1
+
+	hllAlphaInf = 0.721347520444481703680 // constant of the cardinality estimation
+)
+
+var hllMagic = []byte("HYLL")
+
+// hyperLogLog is a decoded HyperLogLog
+type hyperLogLog struct {
+	dense     bool
+	card      [8]byte
+	registers [hllRegisters]uint8
+}
+
+// decodeHyperLogLog decodes a HyperLogLog in either the dense or the sparse encoding
+func decodeHyperLogLog(value []byte) (*hyperLogLog, error) {
+	if len(value) < hllHeaderSize || string(value[:4]) != string(hllMagic) {
+		return nil, ErrNotHyperLogLog
+	}
+
+	h := &hyperLogLog{}
+	copy(h.card[:], value[8:hllHeaderSize])
+	switch value[4] {
+	case hllEncodingDense:
+		if len(value) != hllDenseSize {
+			return nil, ErrNotHyperLogLog
+		}
+		h.dense = true
+		h.decodeDense(value[hllHeaderSize:])
+		return h, nil
+	case hllEncodingSparse:
+		if err := h.decodeSparse(value[hllHeaderSize:]); err != nil {
+			return nil, err
+		}
+		return h, nil
+	default:
+		return nil, ErrNotHyperLogLog
+	}
+}
+
+// decodeDense decodes registers of 6 bits, which are packed from the least significant bits of bytes
+func (h *hyperLogLog) decodeDense(p []byte) {
+	for i := range h.registers {
+		index, shift := i*hllBits/8, uint(i*hllBits%8)
+		n := uint(p[index]) >> shift
+		if index+1 < len(p) {
+			n |= uint(p[index+1]) << (8 - shift)
+		}
+		h.registers[i] = uint8(n & hllRegisterMax)
+	}
+}
+
+// decodeSparse decodes opcodes of the sparse encoding:
+//
+//	ZERO:  00xxxxxx, xxxxxx+1 registers of 0
+//	XZERO: 01xxxxxx yyyyyyyy, xxxxxxyyyyyyyy+1 registers of 0
+//	VAL:   1vvvvvxx, xx+1 registers of vvvvv+1
+func (h *hyperLogLog) decodeSparse(p []byte) error {
+	var index int
+	for i := 0; i < len(p); i++ {
+		switch op := p[i]; op & 0xc0 {
+		case 0x00:
+			index += int(op&0x3f) + 1
+		case 0x40:
+			if i+1 == len(p) {
+				return ErrCorruptedHyperLogLog
+			}
+			i++
+			index += int(op&0x3f)<<8 | int(p[i]) + 1
+		default:
+			value, runLength := op>>2&0x1f+1, int(op&0x03)+1
+			if index+runLength > hllRegisters {
+				return ErrCorruptedHyperLogLog
+			}
+			for j := 0; j < runLength; j++ {
+				h.registers[index+j] = value
+			}
+			index += runLength
+		}
+		if index > hllRegisters {
+			return ErrCorruptedHyperLogLog
+		}
+	}
+	if index != hllRegisters {
+		return ErrCorruptedHyperLogLog
+	}
+	return nil
+}
+
+// encode encodes the HyperLogLog, which is promoted to the dense encoding
+// if registers could not be encoded sparsely within the limit
+func (h *hyperLogLog) encode() []byte {
+	if !h.dense {
+		if p := h.encodeSparse(); p != nil {
+			return h.withHeader(hllEncodingSparse, p)
+		}
+		h.dense = true
+	}
+	return h.withHeader(hllEncodingDense, h.encodeDense())
+}
+
+func (h *hyperLogLog) withHeader(encoding byte, registers []byte) []byte {
+	value := make([]byte, hllHeaderSize+len(registers))
+	copy(value, hllMagic)
+	value[4] = encoding
+	copy(value[8:], h.card[:])
+	copy(value[hllHeaderSize:], registers)
+	return value
+}
+
+func (h *hyperLogLog) encodeDense() []byte {
+	p := make([]byte, hllDenseSize-hllHeaderSize)
+	for i, register := range h.registers {
+		index, shift := i*hllBits/8, uint(i*hllBits%8)
+		p[index] |= register << shift
+		if index+1 < len(p) {
+			p[index+1] |= register >> (8 - shift)
+		}
+	}
+	return p
+}
+
+// encodeSparse returns nil if a register exceeds the maximum value of the sparse encoding,
+// or the encoded HyperLogLog exceeds the maximum size
+func (h *hyperLogLog) encodeSparse() []byte {
+	var p []byte
+	for index := 0; index < hllRegisters; {
+		value := h.registers[index]
+		runLength := 1
+		for index+runLength < hllRegisters && h.registers[index+runLength] == value {
+			runLength++
+		}
+		index += runLength
+
+		if value > hllSparseValueMax {
+			return nil
+		}
+		for runLength > 0 {
+			switch {
+			case value == 0 && runLength > 64:
+				n := runLength
+				if n > hllRegisters {
+					n = hllRegisters
+				}
+				p = append(p, 0x40|byte((n-1)>>8), byte(n-1))
+				runLength -= n
+			case value == 0:
+				p = append(p, byte(runLength-1))
+				runLength = 0
+			default:
+				n := runLength
+				if n > 4 {
+					n = 4
+				}
+				p = append(p, 0x80|(value-1)<<2|byte(n-1))
+				runLength -= n
+			}
+		}
+		if hllHeaderSize+len(p) > hllSparseMaxBytes {
+			return nil
+		}
+	}
+	return p
+}
+
+// add adds an element, and returns whether a register is changed
+func (h *hyperLogLog) add(element []byte) bool {
+	index, count := hllPatternLength(element)
+	if count <= h.registers[index] {
+		return false
+	}
+	h.registers[index] = count
+	return true
+}
+
+// merge merges another HyperLogLog by keeping the maximum of each register
+func (h *hyperLogLog) merge(other *hyperLogLog) {
+	for i, register := range other.registers {
+		if register > h.registers[i] {
+			h.registers[i] = register
+		}
+	}
+}
+
+func (h *hyperLogLog) invalidateCache() {
+	h.card[7] |= 0x80
+}
+
+// cachedCount gets the cached cardinality, ok is false if the cache is invalid
+func (h *hyperLogLog) cachedCount() (int64, bool) {
+	if h.card[7]&0x80 != 0 {
+		return 0, false
+	}
+	return int64(binary.LittleEndian.Uint64(h.card[:])), true
+}
+
+func (h *hyperLogLog) setCachedCount(n int64) {
+	binary.LittleEndian.PutUint64(h.card[:], uint64(n))
+}
+
+// count estimates the cardinality by the improved estimator of Otmar Ertl, the same as Redis
+func (h *hyperLogLog) count() int64 {
+	var histogram [64]int
+	for _, register := range h.registers {
+		histogram[register]++
+	}
+
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for j := hllQ; j >= 1; j-- {
+		z += float64(histogram[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	return int64(math.Round(hllAlphaInf * m * m / z))
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y, z := 1.0, x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y, z := 1.0, 1-x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// hllPatternLength hashes an element, and returns the index of its register
+// along with the length of the pattern 000..1 in the rest bits of the hash
+func hllPatternLength(element []byte) (int, uint8) {
+	hash := murmurHash64A(element, 0xadc83b19)
+	index := int(hash & (hllRegisters - 1))
+	hash >>= hllP
+	hash |= 1 << hllQ // the count is at most hllQ+1
+	return index, uint8(bits.TrailingZeros64(hash) + 1)
+}
+
+// murmurHash64A is MurmurHash2 for 64-bit platforms, reading blocks in little endian as Redis does
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m = 0xc6a4a7935bd1e995
+	const r = 47
+
+	h := seed ^ uint64(len(key))*m
+	for ; len(key) >= 8; key = key[8:] {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+	}
+	if len(key) > 0 {
+		for i := len(key) - 1; i >= 0; i-- {
+			h ^= uint64(key[i]) << (8 * i)
+		}
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// getHyperLogLog gets a HyperLogLog along with the expire of the key
+func (ds *DS) getHyperLogLog(key []byte) (*hyperLogLog, int64, error) {
+	value, expire, err := ds.getString(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	h, err := decodeHyperLogLog(value)
+	if err != nil {
+		return nil, 0, err
+	}
+	return h, expire, nil
+}
+
+// PFAdd redis PFADD
+//
+// It returns whether the key is created or any register is changed.
+func (ds *DS) PFAdd(key []byte, elements ...[]byte) (bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	h, expire, err := ds.getHyperLogLog(key)
+	created := err == baradb.ErrKeyNotFound
+	if created {
+		h = &hyperLogLog{}
+	} else if err != nil {
+		return false, err
+	}
+
+	var changed bool
+	for _, element := range elements {
+		if h.add(element) {
+			changed = true
+		}
+	}
+	if !created && !changed {
+		return false, nil
+	}
+	if changed {
+		h.invalidateCache()
+	}
+	if err := ds.putString(key, h.encode(), expire); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// PFCount redis PFCOUNT
+//
+// The cardinality of a single key is cached in the key.
+// The cardinality of multiple keys is the cardinality of their union.
+func (ds *DS) PFCount(keys ...[]byte) (int64, error) {
+	if len(keys) == 1 {
+		return ds.pfCount(keys[0])
+	}
+
+	union := &hyperLogLog{}
+	for _, key := range keys {
+		h, _, err := ds.getHyperLogLog(key)
+		if err == baradb.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		union.merge(h)
+	}
+	return union.count(), nil
+}
+
+func (ds *DS) pfCount(key []byte) (int64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	h, expire, err := ds.getHyperLogLog(key)
+	if err == baradb.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if n, ok := h.cachedCount(); ok {
+		return n, nil
+	}
+
+	n := h.count()
+	h.setCachedCount(n)
+	if err := ds.putString(key, h.encode(), expire); err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// PFMerge redis PFMERGE
+//
+// It merges HyperLogLogs of source keys into the destination key, which is included in the union if it exists.
+// The result is dense if any of the HyperLogLogs is dense.
+func (ds *DS) PFMerge(destKey []byte, sourceKeys ...[]byte) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	union, expire, err := ds.getHyperLogLog(destKey)
+	if err == baradb.ErrKeyNotFound {
+		union = &hyperLogLog{}
+	} else if err != nil {
+		return err
+	}
+
+	for _, key := range sourceKeys {
+		h, _, err := ds.getHyperLogLog(key)
+		if err == baradb.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		union.merge(h)
+		union.dense = union.dense || h.dense
+	}
+	union.invalidateCache()
+	return ds.putString(destKey, union.encode(), expire)
+}
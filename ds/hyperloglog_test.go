@@ -0,0 +1,133 @@
+package ds
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_PFAdd(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("hll")
+	changed, err := ds.PFAdd(key)
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	// an empty HyperLogLog is exactly the same as the one created by Redis
+	value, err := ds.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff"), value)
+
+	changed, err = ds.PFAdd(key, []byte("a"), []byte("b"), []byte("c"), []byte("d"), []byte("e"), []byte("f"), []byte("g"))
+	assert.Nil(t, err)
+	assert.True(t, changed)
+	changed, err = ds.PFAdd(key, []byte("a"))
+	assert.Nil(t, err)
+	assert.False(t, changed)
+
+	n, err := ds.PFCount(key)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(7), n)
+
+	err = ds.Set([]byte("string"), []byte("value"), 0)
+	assert.Nil(t, err)
+	_, err = ds.PFAdd([]byte("string"), []byte("a"))
+	assert.Equal(t, ErrNotHyperLogLog, err)
+	_, err = ds.PFCount([]byte("string"))
+	assert.Equal(t, ErrNotHyperLogLog, err)
+}
+
+func TestDS_PFCount(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("hll")
+	elements := make([][]byte, 0, 1000)
+	for i := 0; i < 100000; i++ {
+		elements = append(elements, []byte(fmt.Sprintf("element%d", i)))
+		if len(elements) == cap(elements) {
+			_, err := ds.PFAdd(key, elements...)
+			assert.Nil(t, err)
+			elements = elements[:0]
+		}
+	}
+
+	// promoted to the dense encoding
+	value, err := ds.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, hllDenseSize, len(value))
+	assert.Equal(t, byte(hllEncodingDense), value[4])
+
+	n, err := ds.PFCount(key)
+	assert.Nil(t, err)
+	assert.InEpsilon(t, 100000, n, 0.02)
+	// the cardinality is cached
+	value, err = ds.Get(key)
+	assert.Nil(t, err)
+	assert.Zero(t, value[15]&0x80)
+	cached, err := ds.PFCount(key)
+	assert.Nil(t, err)
+	assert.Equal(t, n, cached)
+
+	n, err = ds.PFCount([]byte("unknown"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(0), n)
+}
+
+func TestDS_PFMerge(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	_, err := ds.PFAdd([]byte("hll1"), []byte("foo"), []byte("bar"), []byte("zap"), []byte("a"))
+	assert.Nil(t, err)
+	_, err = ds.PFAdd([]byte("hll2"), []byte("a"), []byte("b"), []byte("c"), []byte("foo"))
+	assert.Nil(t, err)
+
+	n, err := ds.PFCount([]byte("hll1"), []byte("hll2"), []byte("unknown"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), n)
+
+	err = ds.PFMerge([]byte("hll3"), []byte("hll1"), []byte("hll2"))
+	assert.Nil(t, err)
+	n, err = ds.PFCount([]byte("hll3"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(6), n)
+
+	// both source keys are sparse
+	value, err := ds.Get([]byte("hll3"))
+	assert.Nil(t, err)
+	assert.Equal(t, byte(hllEncodingSparse), value[4])
+}
+
+func TestHyperLogLog_Encoding(t *testing.T) {
+	h := &hyperLogLog{}
+	for i := range h.registers {
+		if rand.Intn(100) < 2 {
+			h.registers[i] = uint8(rand.Intn(hllSparseValueMax) + 1)
+		}
+	}
+
+	sparse := h.encode()
+	assert.Equal(t, byte(hllEncodingSparse), sparse[4])
+	decoded, err := decodeHyperLogLog(sparse)
+	assert.Nil(t, err)
+	assert.Equal(t, h.registers, decoded.registers)
+
+	for i := range h.registers {
+		h.registers[i] = uint8(rand.Intn(hllQ + 2))
+	}
+	dense := h.encode()
+	assert.Equal(t, byte(hllEncodingDense), dense[4])
+	assert.Equal(t, hllDenseSize, len(dense))
+	decoded, err = decodeHyperLogLog(dense)
+	assert.Nil(t, err)
+	assert.Equal(t, h.registers, decoded.registers)
+
+	_, err = decodeHyperLogLog([]byte("HYLL\x01\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xfe"))
+	assert.Equal(t, ErrCorruptedHyperLogLog, err)
+	_, err = decodeHyperLogLog([]byte("HYLL\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x7f\xff"))
+	assert.Equal(t, ErrNotHyperLogLog, err)
+}
@@ -0,0 +1,908 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// jsonArray is a mutable array of a JSON document
+type jsonArray struct {
+	id    uint64 // ID of the node storing the array, 0 if it is not stored yet
+	elems []any
+}
+
+// jsonObject is a mutable object of a JSON document, which keeps its keys in insertion order
+type jsonObject struct {
+	id     uint64 // ID of the node storing the object, 0 if it is not stored yet
+	keys   []string
+	values map[string]any
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{values: make(map[string]any)}
+}
+
+// set sets the value of a key, a new key is appended after existing keys
+func (o *jsonObject) set(key string, value any) {
+	if _, ok := o.values[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.values[key] = value
+}
+
+// delete deletes a key, and tells whether the key existed
+func (o *jsonObject) delete(key string) bool {
+	if _, ok := o.values[key]; !ok {
+		return false
+	}
+	delete(o.values, key)
+	for i, k := range o.keys {
+		if k == key {
+			o.keys = append(o.keys[:i], o.keys[i+1:]...)
+			break
+		}
+	}
+	return true
+}
+
+// parseJSON parses a JSON text into a value,
+// which is one of nil, bool, int64, float64, string, *jsonArray and *jsonObject
+func parseJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	value, err := decodeJSONValue(dec)
+	if err != nil {
+		return nil, ErrJSONInvalid
+	}
+	// nothing but spaces may follow the value
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, ErrJSONInvalid
+	}
+	return value, nil
+}
+
+func decodeJSONValue(dec *json.Decoder) (any, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		switch t {
+		case '[':
+			a := &jsonArray{elems: []any{}}
+			for dec.More() {
+				elem, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				a.elems = append(a.elems, elem)
+			}
+			_, err := dec.Token() // ]
+			return a, err
+		case '{':
+			o := newJSONObject()
+			for dec.More() {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				value, err := decodeJSONValue(dec)
+				if err != nil {
+					return nil, err
+				}
+				o.set(key.(string), value)
+			}
+			_, err := dec.Token() // }
+			return o, err
+		default:
+			return nil, ErrJSONInvalid
+		}
+	case json.Number:
+		if n, err := t.Int64(); err == nil {
+			return n, nil
+		}
+		return t.Float64()
+	default:
+		// nil, bool or string
+		return t, nil
+	}
+}
+
+// cloneJSON deeply copies a value, so that it can be put at multiple places
+func cloneJSON(value any) any {
+	switch v := value.(type) {
+	case *jsonArray:
+		a := &jsonArray{elems: make([]any, len(v.elems))}
+		for i, elem := range v.elems {
+			a.elems[i] = cloneJSON(elem)
+		}
+		return a
+	case *jsonObject:
+		o := newJSONObject()
+		for _, k := range v.keys {
+			o.set(k, cloneJSON(v.values[k]))
+		}
+		return o
+	default:
+		return value
+	}
+}
+
+// JSONFormat is the format of JSON texts replied by JSON.GET,
+// where Indent is repeated for each level of nesting, and Space follows each colon
+type JSONFormat struct {
+	Indent  string
+	Newline string
+	Space   string
+}
+
+// appendJSON appends the JSON text of a value
+func appendJSON(buffer []byte, value any, format JSONFormat, depth int) []byte {
+	// newline starts a line with the indent of a level
+	newline := func(buffer []byte, depth int) []byte {
+		buffer = append(buffer, format.Newline...)
+		for i := 0; i < depth; i++ {
+			buffer = append(buffer, format.Indent...)
+		}
+		return buffer
+	}
+
+	switch v := value.(type) {
+	case nil:
+		return append(buffer, "null"...)
+	case bool:
+		return strconv.AppendBool(buffer, v)
+	case int64:
+		return strconv.AppendInt(buffer, v, 10)
+	case float64:
+		return append(buffer, formatJSONFloat(v)...)
+	case string:
+		return appendJSONString(buffer, v)
+	case *jsonArray:
+		if len(v.elems) == 0 {
+			return append(buffer, "[]"...)
+		}
+		buffer = append(buffer, '[')
+		for i, elem := range v.elems {
+			if i > 0 {
+				buffer = append(buffer, ',')
+			}
+			buffer = newline(buffer, depth+1)
+			buffer = appendJSON(buffer, elem, format, depth+1)
+		}
+		buffer = newline(buffer, depth)
+		return append(buffer, ']')
+	case *jsonObject:
+		if len(v.keys) == 0 {
+			return append(buffer, "{}"...)
+		}
+		buffer = append(buffer, '{')
+		for i, k := range v.keys {
+			if i > 0 {
+				buffer = append(buffer, ',')
+			}
+			buffer = newline(buffer, depth+1)
+			buffer = appendJSONString(buffer, k)
+			buffer = append(buffer, ':')
+			buffer = append(buffer, format.Space...)
+			buffer = appendJSON(buffer, v.values[k], format, depth+1)
+		}
+		buffer = newline(buffer, depth)
+		return append(buffer, '}')
+	default:
+		return buffer
+	}
+}
+
+// appendJSONString appends a quoted string, where only quotes, backslashes and control characters are escaped
+func appendJSONString(buffer []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	buffer = append(buffer, '"')
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= utf8.RuneSelf {
+			r, size := utf8.DecodeRuneInString(s[i:])
+			buffer = utf8.AppendRune(buffer, r)
+			i += size
+			continue
+		}
+		switch {
+		case c == '"' || c == '\\':
+			buffer = append(buffer, '\\', c)
+		case c == '\n':
+			buffer = append(buffer, '\\', 'n')
+		case c == '\r':
+			buffer = append(buffer, '\\', 'r')
+		case c == '\t':
+			buffer = append(buffer, '\\', 't')
+		case c < 0x20:
+			buffer = append(buffer, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+		default:
+			buffer = append(buffer, c)
+		}
+		i++
+	}
+	return append(buffer, '"')
+}
+
+// formatJSONFloat formats a float, which always has a fraction or an exponent to be told from integers
+func formatJSONFloat(f float64) string {
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-5 || abs >= 1e16) {
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+		return strings.Replace(strings.Replace(s, "e+", "e", 1), "e-0", "e-", 1)
+	}
+	s := strconv.FormatFloat(f, 'f', -1, 64)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+// jsonTypeName gets the type of a value as JSON.TYPE replies
+func jsonTypeName(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *jsonArray:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+// jsonNumber converts an integer or a float to a float
+func jsonNumber(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// jsonEqual tells whether two values are deeply equal, where integers equal floats of the same value
+func jsonEqual(a, b any) bool {
+	if x, ok := jsonNumber(a); ok {
+		y, ok := jsonNumber(b)
+		return ok && x == y
+	}
+	switch x := a.(type) {
+	case *jsonArray:
+		y, ok := b.(*jsonArray)
+		if !ok || len(x.elems) != len(y.elems) {
+			return false
+		}
+		for i := range x.elems {
+			if !jsonEqual(x.elems[i], y.elems[i]) {
+				return false
+			}
+		}
+		return true
+	case *jsonObject:
+		y, ok := b.(*jsonObject)
+		if !ok || len(x.keys) != len(y.keys) {
+			return false
+		}
+		for k, v := range x.values {
+			if w, ok := y.values[k]; !ok || !jsonEqual(v, w) {
+				return false
+			}
+		}
+		return true
+	default:
+		return a == b
+	}
+}
+
+// findJSON gets the JSON document of a key and values matched by a path in it.
+//
+// A legacy path must match some values.
+func (ds *DS) findJSON(key []byte, path string) (*jsonDocument, *jsonPath, []jsonMatch, error) {
+	jp, err := parseJSONPath(path)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	doc, err := ds.getJSON(key)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	matches := jp.find(doc.root, doc.root)
+	if jp.legacy && len(matches) == 0 {
+		return nil, nil, nil, newErrJSONPathNotExist(path)
+	}
+	return doc, jp, matches, nil
+}
+
+// JSONSetOptions options of JSON.SET
+type JSONSetOptions struct {
+	NX bool // only set values which do not exist yet
+	XX bool // only set values which already exist
+}
+
+// JSONSet redis JSON.SET
+//
+// It sets all values matched by a path, or adds a member to objects if the last part of the path is a missing key.
+// A new key can only be set at the root. It tells whether the document is changed.
+func (ds *DS) JSONSet(key []byte, path string, value []byte, opts JSONSetOptions) (bool, error) {
+	v, err := parseJSON(value)
+	if err != nil {
+		return false, err
+	}
+	jp, err := parseJSONPath(path)
+	if err != nil {
+		return false, err
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	doc, err := ds.getJSON(key)
+	if err == baradb.ErrKeyNotFound {
+		if len(jp.segments) > 0 {
+			return false, ErrJSONNewAtRoot
+		}
+		if opts.XX {
+			return false, nil
+		}
+		return true, ds.newJSON(key, v, 0)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if matches := jp.find(doc.root, doc.root); len(matches) > 0 {
+		if opts.NX {
+			return false, nil
+		}
+		if len(jp.segments) == 0 {
+			// a new root is a new document with the expire of the old one
+			return true, ds.newJSON(key, v, doc.md.expire)
+		}
+		for _, m := range matches {
+			doc.replace(m, cloneJSON(v))
+		}
+		return true, ds.putJSON(doc)
+	}
+
+	if opts.XX {
+		return false, nil
+	}
+	parent, name, ok := jp.parent()
+	if !ok {
+		return false, nil
+	}
+	var added bool
+	for _, m := range parent.find(doc.root, doc.root) {
+		if o, ok := m.value.(*jsonObject); ok {
+			o.set(name, cloneJSON(v))
+			doc.touch(o)
+			added = true
+		}
+	}
+	if !added {
+		return false, nil
+	}
+	return true, ds.putJSON(doc)
+}
+
+// newJSON puts a new document, and reclaims internal keys if the key held a collection
+func (ds *DS) newJSON(key []byte, root any, expire int64) error {
+	prefix, err := ds.collectionPrefix(key)
+	if err != nil {
+		return err
+	}
+	md := &jsonMetadata{expire: expire, version: time.Now().UnixNano()}
+	if err := ds.putJSON(newJSONDocument(key, md, root)); err != nil {
+		return err
+	}
+	if prefix != nil {
+		ds.reclaimer.add(prefix)
+	}
+	return nil
+}
+
+// JSONGet redis JSON.GET
+//
+// With a single path, it gets the JSON text of the value matched by a legacy path,
+// or an array of all values matched by a JSONPath.
+// With multiple paths, it gets an object keyed by the paths,
+// where all paths are treated as JSONPaths unless all of them are legacy paths.
+func (ds *DS) JSONGet(key []byte, format JSONFormat, paths ...string) ([]byte, error) {
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	legacy := true
+	jps := make([]*jsonPath, len(paths))
+	for i, path := range paths {
+		jp, err := parseJSONPath(path)
+		if err != nil {
+			return nil, err
+		}
+		jps[i] = jp
+		legacy = legacy && jp.legacy
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	doc, err := ds.getJSON(key)
+	if err != nil {
+		return nil, err
+	}
+
+	result := func(jp *jsonPath) (any, error) {
+		matches := jp.find(doc.root, doc.root)
+		if legacy {
+			if len(matches) == 0 {
+				return nil, newErrJSONPathNotExist(jp.raw)
+			}
+			return matches[0].value, nil
+		}
+		a := &jsonArray{elems: make([]any, len(matches))}
+		for i, m := range matches {
+			a.elems[i] = m.value
+		}
+		return a, nil
+	}
+
+	if len(jps) == 1 {
+		value, err := result(jps[0])
+		if err != nil {
+			return nil, err
+		}
+		return appendJSON(nil, value, format, 0), nil
+	}
+	o := newJSONObject()
+	for _, jp := range jps {
+		value, err := result(jp)
+		if err != nil {
+			return nil, err
+		}
+		o.set(jp.raw, value)
+	}
+	return appendJSON(nil, o, format, 0), nil
+}
+
+// JSONDel redis JSON.DEL
+//
+// It deletes all values matched by a path, and deletes the key if the path is the root.
+// It gets the number of deleted values.
+func (ds *DS) JSONDel(key []byte, path string) (int, error) {
+	jp, err := parseJSONPath(path)
+	if err != nil {
+		return 0, err
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	doc, err := ds.getJSON(key)
+	if err == baradb.ErrKeyNotFound {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(jp.segments) == 0 {
+		prefix, err := ds.deleteMetadata(key)
+		if err != nil {
+			return 0, err
+		}
+		ds.reclaimer.add(prefix)
+		return 1, nil
+	}
+
+	// indexes of arrays are deleted at last, so that matched indexes stay valid
+	var deleted int
+	indexes := make(map[*jsonArray]map[int]bool)
+	for _, m := range jp.find(doc.root, doc.root) {
+		switch parent := m.parent.(type) {
+		case *jsonObject:
+			if parent.delete(m.key) {
+				doc.remove(m.value)
+				doc.touch(parent)
+				deleted++
+			}
+		case *jsonArray:
+			if indexes[parent] == nil {
+				indexes[parent] = make(map[int]bool)
+			}
+			if !indexes[parent][m.index] {
+				indexes[parent][m.index] = true
+				doc.remove(m.value)
+				doc.touch(parent)
+				deleted++
+			}
+		}
+	}
+	for a, deletedIndexes := range indexes {
+		elems := a.elems[:0]
+		for i, elem := range a.elems {
+			if !deletedIndexes[i] {
+				elems = append(elems, elem)
+			}
+		}
+		a.elems = elems
+	}
+
+	if deleted == 0 {
+		return 0, nil
+	}
+	return deleted, ds.putJSON(doc)
+}
+
+// JSONNumIncrBy redis JSON.NUMINCRBY
+//
+// It increments all numbers matched by a path, and gets the JSON text of the new value for a legacy path,
+// or an array of new values for a JSONPath, where null stands for values which are not numbers.
+// The sum of integers is an integer unless it overflows.
+func (ds *DS) JSONNumIncrBy(key []byte, path string, increment []byte) ([]byte, error) {
+	n, err := parseJSON(increment)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := jsonNumber(n); !ok {
+		return nil, ErrJSONNotNumber
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	doc, jp, matches, err := ds.findJSON(key, path)
+	if err == baradb.ErrKeyNotFound {
+		return nil, ErrJSONNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	results := &jsonArray{elems: make([]any, len(matches))}
+	for i, m := range matches {
+		if _, ok := jsonNumber(m.value); !ok {
+			if jp.legacy {
+				return nil, newErrJSONWrongType("number", jsonTypeName(m.value))
+			}
+			continue
+		}
+		sum, err := addJSONNumbers(m.value, n)
+		if err != nil {
+			return nil, err
+		}
+		doc.replace(m, sum)
+		results.elems[i] = sum
+	}
+	if err := ds.putJSON(doc); err != nil {
+		return nil, err
+	}
+	if jp.legacy {
+		return appendJSON(nil, results.elems[0], JSONFormat{}, 0), nil
+	}
+	return appendJSON(nil, results, JSONFormat{}, 0), nil
+}
+
+// addJSONNumbers adds two numbers, the sum of two integers is a float if it overflows
+func addJSONNumbers(a, b any) (any, error) {
+	x, xIsInt := a.(int64)
+	y, yIsInt := b.(int64)
+	if xIsInt && yIsInt {
+		if sum := x + y; (sum > x) == (y > 0) {
+			return sum, nil
+		}
+	}
+	f, _ := jsonNumber(a)
+	g, _ := jsonNumber(b)
+	sum := f + g
+	if math.IsNaN(sum) || math.IsInf(sum, 0) {
+		return nil, ErrFloatOverflow
+	}
+	return sum, nil
+}
+
+// parseJSONValues parses multiple JSON texts
+func parseJSONValues(texts [][]byte) ([]any, error) {
+	values := make([]any, len(texts))
+	for i, text := range texts {
+		value, err := parseJSON(text)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	return values, nil
+}
+
+// updateJSONArrays calls fn with each array matched by a path, and saves the document.
+//
+// It gets new lengths of the arrays, which are nil for values other than arrays.
+// A legacy path must match only arrays, and has the only result of the first array.
+// Elements removed by fn must be removed from the document too.
+func (ds *DS) updateJSONArrays(key []byte, path string, fn func(doc *jsonDocument, a *jsonArray) error) ([]*int64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	doc, jp, matches, err := ds.findJSON(key, path)
+	if err == baradb.ErrKeyNotFound {
+		return nil, ErrJSONNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	lengths := make([]*int64, len(matches))
+	for i, m := range matches {
+		a, ok := m.value.(*jsonArray)
+		if !ok {
+			if jp.legacy {
+				return nil, newErrJSONWrongType("array", jsonTypeName(m.value))
+			}
+			continue
+		}
+		if err := fn(doc, a); err != nil {
+			return nil, err
+		}
+		doc.touch(a)
+		length := int64(len(a.elems))
+		lengths[i] = &length
+	}
+	if err := ds.putJSON(doc); err != nil {
+		return nil, err
+	}
+	if jp.legacy {
+		return lengths[:1], nil
+	}
+	return lengths, nil
+}
+
+// JSONArrAppend redis JSON.ARRAPPEND
+//
+// It appends values to all arrays matched by a path, and gets their new lengths.
+func (ds *DS) JSONArrAppend(key []byte, path string, values ...[]byte) ([]*int64, error) {
+	elems, err := parseJSONValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return ds.updateJSONArrays(key, path, func(doc *jsonDocument, a *jsonArray) error {
+		for _, elem := range elems {
+			a.elems = append(a.elems, cloneJSON(elem))
+		}
+		return nil
+	})
+}
+
+// JSONArrInsert redis JSON.ARRINSERT
+//
+// It inserts values before the index of all arrays matched by a path, and gets their new lengths.
+// A negative index counts from the end, and the index may be the length to append values.
+func (ds *DS) JSONArrInsert(key []byte, path string, index int64, values ...[]byte) ([]*int64, error) {
+	elems, err := parseJSONValues(values)
+	if err != nil {
+		return nil, err
+	}
+	return ds.updateJSONArrays(key, path, func(doc *jsonDocument, a *jsonArray) error {
+		i := index
+		if i < 0 {
+			i += int64(len(a.elems))
+		}
+		if i < 0 || i > int64(len(a.elems)) {
+			return ErrJSONIndexOutOfBounds
+		}
+		inserted := make([]any, 0, len(a.elems)+len(elems))
+		inserted = append(inserted, a.elems[:i]...)
+		for _, elem := range elems {
+			inserted = append(inserted, cloneJSON(elem))
+		}
+		a.elems = append(inserted, a.elems[i:]...)
+		return nil
+	})
+}
+
+// JSONArrTrim redis JSON.ARRTRIM
+//
+// It trims all arrays matched by a path to the inclusive range, as LTRIM does, and gets their new lengths.
+func (ds *DS) JSONArrTrim(key []byte, path string, start, stop int64) ([]*int64, error) {
+	return ds.updateJSONArrays(key, path, func(doc *jsonDocument, a *jsonArray) error {
+		n := int64(len(a.elems))
+		from, to := start, stop
+		if from < 0 {
+			from += n
+		}
+		if from < 0 {
+			from = 0
+		}
+		if to < 0 {
+			to += n
+		}
+		if to >= n {
+			to = n - 1
+		}
+		if from >= n || from > to {
+			from, to = n, n-1
+		}
+		for j, elem := range a.elems {
+			if int64(j) < from || int64(j) > to {
+				doc.remove(elem)
+			}
+		}
+		a.elems = append([]any{}, a.elems[from:to+1]...)
+		return nil
+	})
+}
+
+// JSONArrPop redis JSON.ARRPOP
+//
+// It removes the element at the index of all arrays matched by a path, where a negative index counts from the end,
+// and an index out of range stands for the first or the last element.
+// It gets JSON texts of removed elements, which are nil for empty arrays and values other than arrays.
+func (ds *DS) JSONArrPop(key []byte, path string, index int64) ([][]byte, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	doc, jp, matches, err := ds.findJSON(key, path)
+	if err == baradb.ErrKeyNotFound {
+		return nil, ErrJSONNoKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	popped := make([][]byte, len(matches))
+	for i, m := range matches {
+		a, ok := m.value.(*jsonArray)
+		if !ok {
+			if jp.legacy {
+				return nil, newErrJSONWrongType("array", jsonTypeName(m.value))
+			}
+			continue
+		}
+		n := int64(len(a.elems))
+		if n == 0 {
+			continue
+		}
+		j := index
+		if j < 0 {
+			j += n
+		}
+		if j < 0 {
+			j = 0
+		}
+		if j >= n {
+			j = n - 1
+		}
+		popped[i] = appendJSON(nil, a.elems[j], JSONFormat{}, 0)
+		doc.remove(a.elems[j])
+		a.elems = append(a.elems[:j], a.elems[j+1:]...)
+		doc.touch(a)
+	}
+	if err := ds.putJSON(doc); err != nil {
+		return nil, err
+	}
+	if jp.legacy {
+		return popped[:1], nil
+	}
+	return popped, nil
+}
+
+// jsonLengths gets lengths of values of a type matched by a path, which are nil for values of other types.
+//
+// A legacy path must match only values of the type, and has the only result of the first value.
+func (ds *DS) jsonLengths(key []byte, path string, typeName string, length func(value any) int) ([]*int64, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	_, jp, matches, err := ds.findJSON(key, path)
+	if err != nil {
+		return nil, err
+	}
+	lengths := make([]*int64, len(matches))
+	for i, m := range matches {
+		if jsonTypeName(m.value) != typeName {
+			if jp.legacy {
+				return nil, newErrJSONWrongType(typeName, jsonTypeName(m.value))
+			}
+			continue
+		}
+		n := int64(length(m.value))
+		lengths[i] = &n
+	}
+	if jp.legacy {
+		return lengths[:1], nil
+	}
+	return lengths, nil
+}
+
+// JSONArrLen redis JSON.ARRLEN
+func (ds *DS) JSONArrLen(key []byte, path string) ([]*int64, error) {
+	return ds.jsonLengths(key, path, "array", func(value any) int {
+		return len(value.(*jsonArray).elems)
+	})
+}
+
+// JSONObjLen redis JSON.OBJLEN
+func (ds *DS) JSONObjLen(key []byte, path string) ([]*int64, error) {
+	return ds.jsonLengths(key, path, "object", func(value any) int {
+		return len(value.(*jsonObject).keys)
+	})
+}
+
+// JSONStrLen redis JSON.STRLEN
+func (ds *DS) JSONStrLen(key []byte, path string) ([]*int64, error) {
+	return ds.jsonLengths(key, path, "string", func(value any) int {
+		return len(value.(string))
+	})
+}
+
+// JSONObjKeys redis JSON.OBJKEYS
+//
+// It gets keys of all objects matched by a path in insertion order, which are nil for values other than objects.
+// A legacy path must match only objects, and has the only result of the first object.
+func (ds *DS) JSONObjKeys(key []byte, path string) ([][]string, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	_, jp, matches, err := ds.findJSON(key, path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([][]string, len(matches))
+	for i, m := range matches {
+		o, ok := m.value.(*jsonObject)
+		if !ok {
+			if jp.legacy {
+				return nil, newErrJSONWrongType("object", jsonTypeName(m.value))
+			}
+			continue
+		}
+		keys[i] = append([]string{}, o.keys...)
+	}
+	if jp.legacy {
+		return keys[:1], nil
+	}
+	return keys, nil
+}
+
+// JSONType redis JSON.TYPE
+//
+// It gets types of values matched by a path, which are null, boolean, integer, number, string, array and object.
+// A legacy path has at most one result of the first value.
+func (ds *DS) JSONType(key []byte, path string) ([]string, error) {
+	jp, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	doc, err := ds.getJSON(key)
+	if err != nil {
+		return nil, err
+	}
+	matches := jp.find(doc.root, doc.root)
+	if jp.legacy && len(matches) > 1 {
+		matches = matches[:1]
+	}
+	types := make([]string, len(matches))
+	for i, m := range matches {
+		types[i] = jsonTypeName(m.value)
+	}
+	return types, nil
+}
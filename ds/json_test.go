@@ -0,0 +1,393 @@
+package ds
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/stretchr/testify/assert"
+)
+
+const testingJSON = `{"store":{"book":[` +
+	`{"title":"Sayings of the Century","price":8.95},` +
+	`{"title":"Sword of Honour","price":12.99,"isbn":"0-553-21311-3"},` +
+	`{"title":"Moby Dick","price":8}],` +
+	`"bicycle":{"color":"red","price":19.95}}}`
+
+func TestParseJSONPath(t *testing.T) {
+	root, err := parseJSON([]byte(testingJSON))
+	assert.Nil(t, err)
+
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"$", `[` + testingJSON + `]`},
+		{"$.store.book[0].title", `["Sayings of the Century"]`},
+		{"$['store']['bicycle'].color", `["red"]`},
+		{"$.store.book[-1].price", `[8]`},
+		{"$.store.book[*].price", `[8.95,12.99,8]`},
+		{"$.store.book[0,2].price", `[8.95,8]`},
+		{"$.store.book[1:].price", `[12.99,8]`},
+		{"$.store.book[::-2].price", `[8,8.95]`},
+		{"$..price", `[8.95,12.99,8,19.95]`},
+		{"$.store.book[?(@.isbn)].title", `["Sword of Honour"]`},
+		{"$.store.book[?(@.price < 10)].title", `["Sayings of the Century","Moby Dick"]`},
+		{"$.store.book[?(@.price > 10 || @.title == 'Moby Dick')].price", `[12.99,8]`},
+		{"$.store.book[?(@.title =~ '^S.*')].price", `[8.95,12.99]`},
+		{"$.store.nothing", `[]`},
+		{".store.bicycle.color", `["red"]`},
+		{"store.book[1].isbn", `["0-553-21311-3"]`},
+	}
+	for _, tc := range testCases {
+		jp, err := parseJSONPath(tc.path)
+		assert.Nil(t, err, tc.path)
+		a := &jsonArray{elems: []any{}}
+		for _, m := range jp.find(root, root) {
+			a.elems = append(a.elems, m.value)
+		}
+		assert.Equal(t, tc.expected, string(appendJSON(nil, a, JSONFormat{}, 0)), tc.path)
+	}
+
+	for _, path := range []string{"$.", "$[", "$[?(@.a ==)]", "$.a]", "$['a"} {
+		_, err := parseJSONPath(path)
+		assert.Equal(t, ErrJSONPathInvalid, err, path)
+	}
+}
+
+func TestDS_JSONSet(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("doc")
+	_, err := ds.JSONSet(key, "$.a", []byte(`1`), JSONSetOptions{})
+	assert.Equal(t, ErrJSONNewAtRoot, err)
+	ok, err := ds.JSONSet(key, "$", []byte(`{"a":1}`), JSONSetOptions{XX: true})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = ds.JSONSet(key, "$", []byte(` {"a": 1, "b": [true, null]} `), JSONSetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	_, err = ds.JSONSet(key, "$", []byte(`{"a":`), JSONSetOptions{})
+	assert.Equal(t, ErrJSONInvalid, err)
+
+	ok, err = ds.JSONSet(key, "$.a", []byte(`"x"`), JSONSetOptions{NX: true})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = ds.JSONSet(key, "$.c", []byte(`{}`), JSONSetOptions{XX: true})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = ds.JSONSet(key, "$.c", []byte(`{}`), JSONSetOptions{NX: true})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = ds.JSONSet(key, "$.c.d.e", []byte(`1`), JSONSetOptions{})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	ok, err = ds.JSONSet(key, "$.b[*]", []byte(`0`), JSONSetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	value, err := ds.JSONGet(key, JSONFormat{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":1,"b":[0,0],"c":{}}`, string(value))
+
+	// other commands do not touch JSON documents
+	_, err = ds.Get(key)
+	assert.Equal(t, ErrWrongTypeOperation, err)
+	assert.Nil(t, ds.Set([]byte("string"), []byte("value"), 0))
+	_, err = ds.JSONSet([]byte("string"), "$", []byte(`1`), JSONSetOptions{})
+	assert.Equal(t, ErrWrongTypeOperation, err)
+	dt, err := ds.Type(key)
+	assert.Nil(t, err)
+	assert.Equal(t, JSON, dt)
+}
+
+func TestDS_JSONGet(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("doc")
+	_, err := ds.JSONGet(key, JSONFormat{})
+	assert.Equal(t, baradb.ErrKeyNotFound, err)
+	_, err = ds.JSONSet(key, ".", []byte(`{"a":[1,2.5e20,"é\n"],"b":{"a":3}}`), JSONSetOptions{})
+	assert.Nil(t, err)
+
+	value, err := ds.JSONGet(key, JSONFormat{}, ".a")
+	assert.Nil(t, err)
+	assert.Equal(t, `[1,2.5e20,"é\n"]`, string(value))
+	value, err = ds.JSONGet(key, JSONFormat{}, "$..a")
+	assert.Nil(t, err)
+	assert.Equal(t, `[[1,2.5e20,"é\n"],3]`, string(value))
+	_, err = ds.JSONGet(key, JSONFormat{}, ".c")
+	assert.Equal(t, newErrJSONPathNotExist(".c"), err)
+
+	value, err = ds.JSONGet(key, JSONFormat{}, ".b.a", ".a[0]")
+	assert.Nil(t, err)
+	assert.Equal(t, `{".b.a":3,".a[0]":1}`, string(value))
+	value, err = ds.JSONGet(key, JSONFormat{}, "$.b.a", ".c")
+	assert.Nil(t, err)
+	assert.Equal(t, `{"$.b.a":[3],".c":[]}`, string(value))
+
+	value, err = ds.JSONGet(key, JSONFormat{Indent: "  ", Newline: "\n", Space: " "}, "$.b")
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  {\n    \"a\": 3\n  }\n]", string(value))
+}
+
+func TestDS_JSONDel(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("doc")
+	n, err := ds.JSONDel(key, "$")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	_, err = ds.JSONSet(key, "$", []byte(`{"a":[1,2,3,4],"b":{"a":1},"c":2}`), JSONSetOptions{})
+	assert.Nil(t, err)
+
+	n, err = ds.JSONDel(key, "$.a[?(@ > 1)]")
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	n, err = ds.JSONDel(key, "$..a")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	n, err = ds.JSONDel(key, ".d")
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	value, err := ds.JSONGet(key, JSONFormat{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"b":{},"c":2}`, string(value))
+
+	n, err = ds.JSONDel(key, ".")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.False(t, ds.Exists(key))
+}
+
+func TestDS_JSONNumIncrBy(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("doc")
+	_, err := ds.JSONNumIncrBy(key, "$.a", []byte("1"))
+	assert.Equal(t, ErrJSONNoKey, err)
+	_, err = ds.JSONSet(key, "$", []byte(`{"a":1,"b":{"a":"x"},"c":9223372036854775807}`), JSONSetOptions{})
+	assert.Nil(t, err)
+
+	value, err := ds.JSONNumIncrBy(key, "$..a", []byte("2"))
+	assert.Nil(t, err)
+	assert.Equal(t, `[3,null]`, string(value))
+	value, err = ds.JSONNumIncrBy(key, ".a", []byte("0.5"))
+	assert.Nil(t, err)
+	assert.Equal(t, `3.5`, string(value))
+	value, err = ds.JSONNumIncrBy(key, ".a", []byte("-0.5"))
+	assert.Nil(t, err)
+	assert.Equal(t, `3.0`, string(value))
+	value, err = ds.JSONNumIncrBy(key, ".c", []byte("1"))
+	assert.Nil(t, err)
+	assert.Equal(t, `9.223372036854776e18`, string(value))
+
+	_, err = ds.JSONNumIncrBy(key, ".b.a", []byte("1"))
+	assert.Equal(t, newErrJSONWrongType("number", "string"), err)
+	_, err = ds.JSONNumIncrBy(key, ".a", []byte(`"1"`))
+	assert.Equal(t, ErrJSONNotNumber, err)
+	_, err = ds.JSONNumIncrBy(key, ".a", []byte("1e308"))
+	assert.Nil(t, err)
+	_, err = ds.JSONNumIncrBy(key, ".a", []byte("1e308"))
+	assert.Equal(t, ErrFloatOverflow, err)
+}
+
+func TestDS_JSONArr(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("doc")
+	_, err := ds.JSONArrAppend(key, "$", []byte("1"))
+	assert.Equal(t, ErrJSONNoKey, err)
+	_, err = ds.JSONSet(key, "$", []byte(`{"a":[],"b":{"a":[1]},"c":"x"}`), JSONSetOptions{})
+	assert.Nil(t, err)
+
+	lengths, err := ds.JSONArrAppend(key, "$..a", []byte(`{"x":1}`), []byte("2"))
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(lengths))
+	assert.Equal(t, int64(2), *lengths[0])
+	assert.Equal(t, int64(3), *lengths[1])
+	lengths, err = ds.JSONArrAppend(key, "$.*", []byte("3"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(3), *lengths[0])
+	assert.Nil(t, lengths[1])
+	assert.Nil(t, lengths[2])
+	_, err = ds.JSONArrAppend(key, ".c", []byte("3"))
+	assert.Equal(t, newErrJSONWrongType("array", "string"), err)
+
+	// appended values are copied
+	_, err = ds.JSONSet(key, "$.a[0].x", []byte("0"), JSONSetOptions{})
+	assert.Nil(t, err)
+	value, _ := ds.JSONGet(key, JSONFormat{}, "$..a")
+	assert.Equal(t, `[[{"x":0},2,3],[1,{"x":1},2]]`, string(value))
+
+	lengths, err = ds.JSONArrInsert(key, ".a", -1, []byte(`"y"`))
+	assert.Nil(t, err)
+	assert.Equal(t, []*int64{newInt64(4)}, lengths)
+	_, err = ds.JSONArrInsert(key, ".a", 5, []byte(`"y"`))
+	assert.Equal(t, ErrJSONIndexOutOfBounds, err)
+	lengths, err = ds.JSONArrLen(key, "$.*")
+	assert.Nil(t, err)
+	assert.Equal(t, []*int64{newInt64(4), nil, nil}, lengths)
+
+	popped, err := ds.JSONArrPop(key, ".a", 0)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte(`{"x":0}`)}, popped)
+	popped, err = ds.JSONArrPop(key, "$..a", 100)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("3"), []byte("2")}, popped)
+
+	lengths, err = ds.JSONArrTrim(key, "$..a", 1, -1)
+	assert.Nil(t, err)
+	assert.Equal(t, []*int64{newInt64(1), newInt64(1)}, lengths)
+	lengths, err = ds.JSONArrTrim(key, ".a", 1, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, []*int64{newInt64(0)}, lengths)
+	popped, err = ds.JSONArrPop(key, ".a", -1)
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{nil}, popped)
+
+	value, _ = ds.JSONGet(key, JSONFormat{})
+	assert.Equal(t, `{"a":[],"b":{"a":[{"x":1}]},"c":"x"}`, string(value))
+}
+
+func TestDS_JSONObj(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("doc")
+	_, err := ds.JSONObjKeys(key, ".")
+	assert.Equal(t, baradb.ErrKeyNotFound, err)
+	_, err = ds.JSONSet(key, "$", []byte(`{"z":1,"a":{"y":true,"x":null},"s":"abc"}`), JSONSetOptions{})
+	assert.Nil(t, err)
+
+	keys, err := ds.JSONObjKeys(key, ".")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{{"z", "a", "s"}}, keys)
+	keys, err = ds.JSONObjKeys(key, "$.*")
+	assert.Nil(t, err)
+	assert.Equal(t, [][]string{nil, {"y", "x"}, nil}, keys)
+	_, err = ds.JSONObjKeys(key, ".z")
+	assert.Equal(t, newErrJSONWrongType("object", "integer"), err)
+
+	lengths, err := ds.JSONObjLen(key, "$..a")
+	assert.Nil(t, err)
+	assert.Equal(t, []*int64{newInt64(2)}, lengths)
+	lengths, err = ds.JSONStrLen(key, ".s")
+	assert.Nil(t, err)
+	assert.Equal(t, []*int64{newInt64(3)}, lengths)
+
+	types, err := ds.JSONType(key, "$..*")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"integer", "object", "string", "boolean", "null"}, types)
+	types, err = ds.JSONType(key, ".")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"object"}, types)
+	types, err = ds.JSONType(key, ".b")
+	assert.Nil(t, err)
+	assert.Empty(t, types)
+}
+
+// jsonNodes gets all nodes of a document by their IDs
+func jsonNodes(t *testing.T, ds *DS, key []byte) map[uint64]string {
+	encValue, err := ds.db.Get(key)
+	assert.Nil(t, err)
+	md, err := decodeJSONMetadata(encValue)
+	assert.Nil(t, err)
+	prefix := internalKeyPrefix(key, md.version)
+	nodes := make(map[uint64]string)
+	assert.Nil(t, ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		nodes[binary.BigEndian.Uint64(encKey[len(prefix):])] = string(value)
+		return true, nil
+	}))
+	assert.Equal(t, int(md.size), len(nodes))
+	return nodes
+}
+
+func TestDS_JSONNodes(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer func() {
+		destroyDS(ds, testingDBOptions.Directory)
+	}()
+
+	// every array and object is a node
+	key := []byte("doc")
+	_, err := ds.JSONSet(key, "$", []byte(testingJSON), JSONSetOptions{})
+	assert.Nil(t, err)
+	nodes := jsonNodes(t, ds, key)
+	assert.Len(t, nodes, 7)
+
+	// a change only rewrites the node containing the changed value
+	doc, err := ds.getJSON(key)
+	assert.Nil(t, err)
+	jp, _ := parseJSONPath("$.store.book[1].price")
+	m := jp.find(doc.root, doc.root)[0]
+	doc.replace(m, int64(10))
+	assert.Equal(t, map[uint64]any{m.parent.(*jsonObject).id: m.parent}, doc.changed)
+	assert.Empty(t, doc.removed)
+	_, err = ds.JSONNumIncrBy(key, "$.store.book[1].price", []byte("1"))
+	assert.Nil(t, err)
+	changed := jsonNodes(t, ds, key)
+	var diff int
+	for id, node := range nodes {
+		if changed[id] != node {
+			diff++
+		}
+	}
+	assert.Equal(t, 1, diff)
+
+	// nodes of removed values are deleted, and nodes of new values are added
+	n, err := ds.JSONDel(key, "$.store.book[0,1]")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.Len(t, jsonNodes(t, ds, key), 5)
+	_, err = ds.JSONArrAppend(key, "$.store.book", []byte(`{"tags":["a"]}`))
+	assert.Nil(t, err)
+	assert.Len(t, jsonNodes(t, ds, key), 7)
+	_, err = ds.JSONArrTrim(key, "$.store.book", 1, 1)
+	assert.Nil(t, err)
+	_, err = ds.JSONArrPop(key, "$.store.book", 0)
+	assert.Nil(t, err)
+	assert.Len(t, jsonNodes(t, ds, key), 4)
+
+	// documents persist after a restart
+	assert.Nil(t, ds.Close())
+	ds, _ = New(testingDBOptions)
+	value, err := ds.JSONGet(key, JSONFormat{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"store":{"book":[],"bicycle":{"color":"red","price":19.95}}}`, string(value))
+
+	// nodes of a replaced or deleted document are reclaimed
+	encValue, err := ds.db.Get(key)
+	assert.Nil(t, err)
+	prefix := internalKeyPrefix(key, decodeMetadata(encValue).version)
+	_, err = ds.JSONSet(key, "$", []byte(`[[1]]`), JSONSetOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, jsonNodes(t, ds, key), 2)
+	_, err = ds.JSONDel(key, "$")
+	assert.Nil(t, err)
+	ds.reclaimer.drain(ds)
+	assert.Nil(t, ds.scanInternalKeys(prefix[:len(key)], prefix[:len(key)], func(encKey, value []byte) (bool, error) {
+		assert.Equal(t, key, encKey)
+		return true, nil
+	}))
+
+	// a corrupted document is not decoded
+	_, err = ds.JSONSet(key, "$", []byte(`{"a":[1]}`), JSONSetOptions{})
+	assert.Nil(t, err)
+	encValue, _ = ds.db.Get(key)
+	prefix = internalKeyPrefix(key, decodeMetadata(encValue).version)
+	for id := range jsonNodes(t, ds, key) {
+		assert.Nil(t, ds.db.Put(jsonNodeKey(prefix, id), []byte{jsonTagArray, 2, jsonTagRef, 1}))
+	}
+	_, err = ds.JSONGet(key, JSONFormat{})
+	assert.Equal(t, ErrCorruptedJSON, err)
+}
+
+func newInt64(n int64) *int64 {
+	return &n
+}
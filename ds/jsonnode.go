@@ -0,0 +1,347 @@
+package ds
+
+import (
+	"encoding/binary"
+	"math"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// A JSON document is stored as a collection of nodes.
+//
+// Its metadata holds the encoded root value, and every array or object is a node stored in an internal key of its ID,
+// where scalars are encoded inline while nested arrays and objects are referred to by their IDs.
+// So a change of a document only rewrites the nodes of arrays and objects containing changed values.
+
+// Tags of encoded JSON values and nodes
+const (
+	jsonTagNull byte = iota
+	jsonTagFalse
+	jsonTagTrue
+	jsonTagInteger
+	jsonTagFloat
+	jsonTagString
+	jsonTagRef    // an array or object stored in another node
+	jsonTagArray  // a node of an array
+	jsonTagObject // a node of an object
+)
+
+// jsonMetadata is a metadata of a JSON document, which begins with the same fields as other collections
+type jsonMetadata struct {
+	expire  int64
+	version int64
+	size    uint32 // the number of nodes
+	nextID  uint64 // the ID of the next added node
+	root    []byte // the encoded root value
+}
+
+func (md *jsonMetadata) encode() []byte {
+	buffer := make([]byte, 1, 1+binary.MaxVarintLen64*4+len(md.root))
+	buffer[0] = JSON
+	buffer = binary.AppendVarint(buffer, md.expire)
+	buffer = binary.AppendVarint(buffer, md.version)
+	buffer = binary.AppendVarint(buffer, int64(md.size))
+	buffer = binary.AppendUvarint(buffer, md.nextID)
+	return append(buffer, md.root...)
+}
+
+func decodeJSONMetadata(buffer []byte) (*jsonMetadata, error) {
+	d := &fieldDecoder{buffer: buffer, index: 1}
+	md := &jsonMetadata{
+		expire:  d.varint(),
+		version: d.varint(),
+		size:    uint32(d.varint()),
+		nextID:  d.uvarint(),
+	}
+	if d.invalid {
+		return nil, ErrCorruptedJSON
+	}
+	md.root = buffer[d.index:]
+	return md, nil
+}
+
+// jsonNodeKey gets the internal key of a node
+func jsonNodeKey(prefix []byte, id uint64) []byte {
+	return binary.BigEndian.AppendUint64(append([]byte(nil), prefix...), id)
+}
+
+// jsonNodeDecoder decodes values of a document from its nodes
+type jsonNodeDecoder struct {
+	nodes   map[uint64][]byte // nodes not decoded yet
+	invalid bool
+}
+
+// value decodes a value, and the arrays and objects it refers to
+func (d *jsonNodeDecoder) value(fd *fieldDecoder) any {
+	switch fd.byte() {
+	case jsonTagNull:
+		return nil
+	case jsonTagFalse:
+		return false
+	case jsonTagTrue:
+		return true
+	case jsonTagInteger:
+		return fd.varint()
+	case jsonTagFloat:
+		return fd.float()
+	case jsonTagString:
+		return fd.string()
+	case jsonTagRef:
+		return d.node(fd.uvarint())
+	default:
+		fd.invalid = true
+		return nil
+	}
+}
+
+// node decodes the array or object of a node
+func (d *jsonNodeDecoder) node(id uint64) any {
+	buffer, ok := d.nodes[id]
+	if !ok || d.invalid {
+		d.invalid = true
+		return nil
+	}
+	// a node is referred to only once, which also stops cycles of corrupted documents
+	delete(d.nodes, id)
+
+	fd := &fieldDecoder{buffer: buffer}
+	var value any
+	switch fd.byte() {
+	case jsonTagArray:
+		n := fd.uvarint()
+		if n > uint64(fd.remaining()) {
+			d.invalid = true
+			return nil
+		}
+		a := &jsonArray{id: id, elems: make([]any, 0, n)}
+		for i := uint64(0); i < n && !fd.invalid; i++ {
+			a.elems = append(a.elems, d.value(fd))
+		}
+		value = a
+	case jsonTagObject:
+		n := fd.uvarint()
+		if n > uint64(fd.remaining()) {
+			d.invalid = true
+			return nil
+		}
+		o := newJSONObject()
+		o.id = id
+		for i := uint64(0); i < n && !fd.invalid; i++ {
+			key := fd.string()
+			o.set(key, d.value(fd))
+		}
+		value = o
+	default:
+		fd.invalid = true
+	}
+	if fd.invalid || fd.remaining() != 0 {
+		d.invalid = true
+	}
+	return value
+}
+
+// jsonNodeEncoder encodes changed nodes of a document, and stores new arrays and objects as new nodes
+type jsonNodeEncoder struct {
+	doc    *jsonDocument
+	prefix []byte
+	puts   [][2][]byte // internal keys and values of nodes to put
+}
+
+// appendValue appends an encoded value, where a new array or object is stored as a new node
+func (e *jsonNodeEncoder) appendValue(buffer []byte, value any) []byte {
+	switch v := value.(type) {
+	case nil:
+		return append(buffer, jsonTagNull)
+	case bool:
+		if v {
+			return append(buffer, jsonTagTrue)
+		}
+		return append(buffer, jsonTagFalse)
+	case int64:
+		return binary.AppendVarint(append(buffer, jsonTagInteger), v)
+	case float64:
+		return binary.BigEndian.AppendUint64(append(buffer, jsonTagFloat), math.Float64bits(v))
+	case string:
+		buffer = binary.AppendUvarint(append(buffer, jsonTagString), uint64(len(v)))
+		return append(buffer, v...)
+	case *jsonArray:
+		if v.id == 0 {
+			v.id = e.newID()
+			e.putNode(v.id, v)
+		}
+		return binary.AppendUvarint(append(buffer, jsonTagRef), v.id)
+	case *jsonObject:
+		if v.id == 0 {
+			v.id = e.newID()
+			e.putNode(v.id, v)
+		}
+		return binary.AppendUvarint(append(buffer, jsonTagRef), v.id)
+	default:
+		return buffer
+	}
+}
+
+// newID allocates the ID of a new node
+func (e *jsonNodeEncoder) newID() uint64 {
+	e.doc.md.nextID++
+	e.doc.md.size++
+	return e.doc.md.nextID
+}
+
+// putNode encodes the node of an array or object
+func (e *jsonNodeEncoder) putNode(id uint64, value any) {
+	var buffer []byte
+	switch v := value.(type) {
+	case *jsonArray:
+		buffer = binary.AppendUvarint([]byte{jsonTagArray}, uint64(len(v.elems)))
+		for _, elem := range v.elems {
+			buffer = e.appendValue(buffer, elem)
+		}
+	case *jsonObject:
+		buffer = binary.AppendUvarint([]byte{jsonTagObject}, uint64(len(v.keys)))
+		for _, k := range v.keys {
+			buffer = binary.AppendUvarint(buffer, uint64(len(k)))
+			buffer = append(buffer, k...)
+			buffer = e.appendValue(buffer, v.values[k])
+		}
+	}
+	e.puts = append(e.puts, [2][]byte{jsonNodeKey(e.prefix, id), buffer})
+}
+
+// jsonDocument is a JSON document of a key, which tracks arrays and objects changed by commands
+type jsonDocument struct {
+	key     []byte
+	md      *jsonMetadata
+	root    any
+	changed map[uint64]any  // stored arrays and objects changed in place
+	removed map[uint64]bool // stored arrays and objects which are not in the document any more
+}
+
+func newJSONDocument(key []byte, md *jsonMetadata, root any) *jsonDocument {
+	return &jsonDocument{
+		key:     key,
+		md:      md,
+		root:    root,
+		changed: make(map[uint64]any),
+		removed: make(map[uint64]bool),
+	}
+}
+
+// touch marks an array or object changed in place
+func (doc *jsonDocument) touch(value any) {
+	switch v := value.(type) {
+	case *jsonArray:
+		if v.id != 0 {
+			doc.changed[v.id] = v
+		}
+	case *jsonObject:
+		if v.id != 0 {
+			doc.changed[v.id] = v
+		}
+	}
+}
+
+// remove marks stored arrays and objects of a value removed from the document
+func (doc *jsonDocument) remove(value any) {
+	switch v := value.(type) {
+	case *jsonArray:
+		if v.id != 0 {
+			doc.removed[v.id] = true
+		}
+		for _, elem := range v.elems {
+			doc.remove(elem)
+		}
+	case *jsonObject:
+		if v.id != 0 {
+			doc.removed[v.id] = true
+		}
+		for _, elem := range v.values {
+			doc.remove(elem)
+		}
+	}
+}
+
+// replace replaces a matched value with another value
+func (doc *jsonDocument) replace(m jsonMatch, value any) {
+	doc.remove(m.value)
+	switch parent := m.parent.(type) {
+	case *jsonArray:
+		parent.elems[m.index] = value
+	case *jsonObject:
+		parent.values[m.key] = value
+	default:
+		doc.root = value
+	}
+	doc.touch(m.parent)
+}
+
+// getJSON gets the JSON document of a key.
+//
+// Nodes of a document are written by a batch, so the caller must hold ds.mu to read them consistently.
+func (ds *DS) getJSON(key []byte) (*jsonDocument, error) {
+	encValue, err := ds.getValue(key)
+	if err != nil {
+		return nil, err
+	}
+	if encValue[0] != JSON {
+		return nil, ErrWrongTypeOperation
+	}
+	md, err := decodeJSONMetadata(encValue)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := internalKeyPrefix(key, md.version)
+	d := &jsonNodeDecoder{nodes: make(map[uint64][]byte, md.size)}
+	err = ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		// internal keys of other keys may share the prefix
+		if len(encKey) == len(prefix)+8 {
+			d.nodes[binary.BigEndian.Uint64(encKey[len(prefix):])] = value
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	fd := &fieldDecoder{buffer: md.root}
+	root := d.value(fd)
+	if d.invalid || fd.invalid || fd.remaining() != 0 {
+		return nil, ErrCorruptedJSON
+	}
+	return newJSONDocument(key, md, root), nil
+}
+
+// putJSON writes changed nodes, new nodes and the metadata of a document at once, and deletes removed nodes
+func (ds *DS) putJSON(doc *jsonDocument) error {
+	e := &jsonNodeEncoder{doc: doc, prefix: internalKeyPrefix(doc.key, doc.md.version)}
+	for id, value := range doc.changed {
+		if !doc.removed[id] {
+			e.putNode(id, value)
+		}
+	}
+	doc.md.root = e.appendValue(nil, doc.root)
+	doc.md.size -= uint32(len(doc.removed))
+
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = len(e.puts) + len(doc.removed) + 1
+	wb := ds.db.NewWriteBatch(opts)
+	for _, put := range e.puts {
+		if err := wb.Put(put[0], put[1]); err != nil {
+			return err
+		}
+	}
+	for id := range doc.removed {
+		if err := wb.Delete(jsonNodeKey(e.prefix, id)); err != nil {
+			return err
+		}
+	}
+	if err := wb.Put(doc.key, doc.md.encode()); err != nil {
+		return err
+	}
+	if err := wb.Commit(); err != nil {
+		return err
+	}
+	doc.changed = make(map[uint64]any)
+	doc.removed = make(map[uint64]bool)
+	return nil
+}
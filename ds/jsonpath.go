@@ -0,0 +1,676 @@
+package ds
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a compiled path of a JSON document.
+//
+// It is either a JSONPath starting with $, such as $.store.book[?(@.price < 10)].title,
+// or a legacy path, such as .store.book[0].title, where . alone is the root.
+type jsonPath struct {
+	raw      string
+	legacy   bool
+	segments []jsonSegment
+}
+
+// jsonSegment selects children of each matched value, or descendants with recursive descent such as ..name
+type jsonSegment struct {
+	recursive bool
+	selectors []jsonSelector
+}
+
+type jsonSelectorKind byte
+
+const (
+	jsonSelectName jsonSelectorKind = iota
+	jsonSelectIndex
+	jsonSelectWildcard
+	jsonSelectSlice
+	jsonSelectFilter
+)
+
+// jsonSelector is a selector of a segment, such as name, 0, *, 1:5:2 or ?(@.a > 1)
+type jsonSelector struct {
+	kind   jsonSelectorKind
+	name   string
+	index  int
+	slice  [3]int  // start, end and step
+	bounds [2]bool // whether the start and the end of a slice are given
+	filter jsonExpr
+}
+
+// jsonMatch is a value matched by a path along with its location
+type jsonMatch struct {
+	value  any
+	parent any    // *jsonArray or *jsonObject, nil for the root
+	key    string // key in the parent object
+	index  int    // index in the parent array
+}
+
+// IsLegacyJSONPath tells whether a path is a legacy path, which matches a single value, rather than a JSONPath
+func IsLegacyJSONPath(path string) bool {
+	return !strings.HasPrefix(path, "$")
+}
+
+// parseJSONPath compiles a path
+func parseJSONPath(path string) (*jsonPath, error) {
+	p := &jsonPathParser{s: path}
+	jp := &jsonPath{raw: path, legacy: IsLegacyJSONPath(path)}
+	switch {
+	case !jp.legacy:
+		p.pos = 1
+	case path == ".":
+		return jp, nil
+	case path != "" && path[0] != '.' && path[0] != '[':
+		// a legacy path may start with a name without a dot
+		name := p.name()
+		if name == "" {
+			return nil, ErrJSONPathInvalid
+		}
+		jp.segments = append(jp.segments, jsonSegment{selectors: []jsonSelector{{kind: jsonSelectName, name: name}}})
+	}
+
+	segments, err := p.segments(false)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos != len(p.s) {
+		return nil, ErrJSONPathInvalid
+	}
+	jp.segments = append(jp.segments, segments...)
+	return jp, nil
+}
+
+type jsonPathParser struct {
+	s   string
+	pos int
+}
+
+func (p *jsonPathParser) peek() byte {
+	if p.pos < len(p.s) {
+		return p.s[p.pos]
+	}
+	return 0
+}
+
+func (p *jsonPathParser) skipSpaces() {
+	for p.pos < len(p.s) && p.s[p.pos] == ' ' {
+		p.pos++
+	}
+}
+
+// segments parses segments until a character which can not start a segment,
+// names inside filters also end at spaces and operators
+func (p *jsonPathParser) segments(inFilter bool) ([]jsonSegment, error) {
+	var segments []jsonSegment
+	for {
+		var segment jsonSegment
+		switch {
+		case strings.HasPrefix(p.s[p.pos:], ".."):
+			p.pos += 2
+			segment.recursive = true
+			if p.peek() == '[' {
+				break
+			}
+			fallthrough
+		case p.peek() == '.':
+			if !segment.recursive {
+				p.pos++
+			}
+			if p.peek() == '*' {
+				p.pos++
+				segment.selectors = []jsonSelector{{kind: jsonSelectWildcard}}
+				break
+			}
+			name := p.name()
+			if inFilter {
+				name = strings.TrimRight(name, " ")
+			}
+			if name == "" {
+				return nil, ErrJSONPathInvalid
+			}
+			segment.selectors = []jsonSelector{{kind: jsonSelectName, name: name}}
+		case p.peek() == '[':
+		default:
+			return segments, nil
+		}
+
+		if segment.selectors == nil {
+			selectors, err := p.bracket()
+			if err != nil {
+				return nil, err
+			}
+			segment.selectors = selectors
+		}
+		segments = append(segments, segment)
+	}
+}
+
+// name parses a member name without quotes
+func (p *jsonPathParser) name() string {
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(".[]()=!<>&|, ", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	return p.s[start:p.pos]
+}
+
+// bracket parses selectors inside brackets, such as ['a', 'b'], [0, -1], [*], [1:5:2] and [?(@.a > 1)]
+func (p *jsonPathParser) bracket() ([]jsonSelector, error) {
+	p.pos++ // [
+	var selectors []jsonSelector
+	for {
+		p.skipSpaces()
+		selector, err := p.selector()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, selector)
+		p.skipSpaces()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ']':
+			p.pos++
+			return selectors, nil
+		default:
+			return nil, ErrJSONPathInvalid
+		}
+	}
+}
+
+func (p *jsonPathParser) selector() (jsonSelector, error) {
+	switch c := p.peek(); {
+	case c == '\'' || c == '"':
+		s, err := p.quoted()
+		return jsonSelector{kind: jsonSelectName, name: s}, err
+	case c == '*':
+		p.pos++
+		return jsonSelector{kind: jsonSelectWildcard}, nil
+	case c == '?':
+		p.pos++
+		p.skipSpaces()
+		if p.peek() != '(' {
+			return jsonSelector{}, ErrJSONPathInvalid
+		}
+		p.pos++
+		expr, err := p.orExpr()
+		if err != nil {
+			return jsonSelector{}, err
+		}
+		p.skipSpaces()
+		if p.peek() != ')' {
+			return jsonSelector{}, ErrJSONPathInvalid
+		}
+		p.pos++
+		return jsonSelector{kind: jsonSelectFilter, filter: expr}, nil
+	}
+
+	// an index or a slice
+	var selector jsonSelector
+	for i := 0; i < 3; i++ {
+		p.skipSpaces()
+		if n, ok := p.integer(); ok {
+			selector.slice[i] = n
+			if i < 2 {
+				selector.bounds[i] = true
+			}
+		} else if i == 2 {
+			selector.slice[i] = 1
+		}
+		p.skipSpaces()
+		if p.peek() != ':' {
+			if i == 0 {
+				if !selector.bounds[0] {
+					return jsonSelector{}, ErrJSONPathInvalid
+				}
+				return jsonSelector{kind: jsonSelectIndex, index: selector.slice[0]}, nil
+			}
+			if i == 1 {
+				selector.slice[2] = 1
+			}
+			break
+		}
+		p.pos++
+	}
+	selector.kind = jsonSelectSlice
+	return selector, nil
+}
+
+// integer parses an optionally signed integer
+func (p *jsonPathParser) integer() (int, bool) {
+	start := p.pos
+	if c := p.peek(); c == '-' || c == '+' {
+		p.pos++
+	}
+	for p.pos < len(p.s) && p.s[p.pos] >= '0' && p.s[p.pos] <= '9' {
+		p.pos++
+	}
+	n, err := strconv.Atoi(p.s[start:p.pos])
+	if err != nil {
+		p.pos = start
+		return 0, false
+	}
+	return n, true
+}
+
+// quoted parses a string quoted by single or double quotes
+func (p *jsonPathParser) quoted() (string, error) {
+	quote := p.s[p.pos]
+	p.pos++
+	var b strings.Builder
+	for p.pos < len(p.s) {
+		c := p.s[p.pos]
+		p.pos++
+		switch {
+		case c == quote:
+			return b.String(), nil
+		case c == '\\' && p.pos < len(p.s):
+			b.WriteByte(p.s[p.pos])
+			p.pos++
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", ErrJSONPathInvalid
+}
+
+// jsonExpr is an expression of a filter, which is evaluated with @ as the current value
+type jsonExpr interface {
+	eval(current, root any) bool
+}
+
+type jsonOrExpr []jsonExpr
+
+func (e jsonOrExpr) eval(current, root any) bool {
+	for _, expr := range e {
+		if expr.eval(current, root) {
+			return true
+		}
+	}
+	return false
+}
+
+type jsonAndExpr []jsonExpr
+
+func (e jsonAndExpr) eval(current, root any) bool {
+	for _, expr := range e {
+		if !expr.eval(current, root) {
+			return false
+		}
+	}
+	return true
+}
+
+type jsonNotExpr struct{ expr jsonExpr }
+
+func (e jsonNotExpr) eval(current, root any) bool {
+	return !e.expr.eval(current, root)
+}
+
+// jsonOperand is either a path relative to @ or $, or a literal
+type jsonOperand struct {
+	path     *jsonPath
+	relative bool
+	literal  any
+}
+
+// values gets values of the operand, which are nothing if a path matches nothing
+func (o *jsonOperand) values(current, root any) []any {
+	if o.path == nil {
+		return []any{o.literal}
+	}
+	start := root
+	if o.relative {
+		start = current
+	}
+	matches := o.path.find(start, root)
+	values := make([]any, len(matches))
+	for i, m := range matches {
+		values[i] = m.value
+	}
+	return values
+}
+
+// jsonComparison compares two operands, or tests existence of a path if there is no operator
+type jsonComparison struct {
+	left     jsonOperand
+	operator string
+	right    jsonOperand
+	pattern  *regexp.Regexp // compiled pattern of =~
+}
+
+func (e *jsonComparison) eval(current, root any) bool {
+	left := e.left.values(current, root)
+	if e.operator == "" {
+		return len(left) > 0
+	}
+	right := e.right.values(current, root)
+	if len(left) == 0 || len(right) == 0 {
+		return false
+	}
+	a, b := left[0], right[0]
+
+	switch e.operator {
+	case "==":
+		return jsonEqual(a, b)
+	case "!=":
+		return !jsonEqual(a, b)
+	case "=~":
+		s, ok := a.(string)
+		return ok && e.pattern != nil && e.pattern.MatchString(s)
+	}
+
+	var cmp int
+	if x, ok := jsonNumber(a); ok {
+		y, ok := jsonNumber(b)
+		if !ok {
+			return false
+		}
+		switch {
+		case x < y:
+			cmp = -1
+		case x > y:
+			cmp = 1
+		}
+	} else if x, ok := a.(string); ok {
+		y, ok := b.(string)
+		if !ok {
+			return false
+		}
+		cmp = strings.Compare(x, y)
+	} else {
+		return false
+	}
+
+	switch e.operator {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	default:
+		return cmp >= 0
+	}
+}
+
+func (p *jsonPathParser) orExpr() (jsonExpr, error) {
+	var exprs jsonOrExpr
+	for {
+		expr, err := p.andExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		p.skipSpaces()
+		if !strings.HasPrefix(p.s[p.pos:], "||") {
+			break
+		}
+		p.pos += 2
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *jsonPathParser) andExpr() (jsonExpr, error) {
+	var exprs jsonAndExpr
+	for {
+		expr, err := p.unaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+		p.skipSpaces()
+		if !strings.HasPrefix(p.s[p.pos:], "&&") {
+			break
+		}
+		p.pos += 2
+	}
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return exprs, nil
+}
+
+func (p *jsonPathParser) unaryExpr() (jsonExpr, error) {
+	p.skipSpaces()
+	switch {
+	case p.peek() == '!' && !strings.HasPrefix(p.s[p.pos:], "!="):
+		p.pos++
+		expr, err := p.unaryExpr()
+		if err != nil {
+			return nil, err
+		}
+		return jsonNotExpr{expr: expr}, nil
+	case p.peek() == '(':
+		p.pos++
+		expr, err := p.orExpr()
+		if err != nil {
+			return nil, err
+		}
+		p.skipSpaces()
+		if p.peek() != ')' {
+			return nil, ErrJSONPathInvalid
+		}
+		p.pos++
+		return expr, nil
+	}
+
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	comparison := &jsonComparison{left: left}
+	for _, operator := range []string{"==", "!=", "<=", ">=", "=~", "<", ">"} {
+		if strings.HasPrefix(p.s[p.pos:], operator) {
+			comparison.operator = operator
+			p.pos += len(operator)
+			break
+		}
+	}
+	if comparison.operator == "" {
+		if left.path == nil {
+			return nil, ErrJSONPathInvalid
+		}
+		return comparison, nil
+	}
+
+	p.skipSpaces()
+	if comparison.right, err = p.operand(); err != nil {
+		return nil, err
+	}
+	if comparison.operator == "=~" {
+		pattern, ok := comparison.right.literal.(string)
+		if !ok {
+			return nil, ErrJSONPathInvalid
+		}
+		if comparison.pattern, err = regexp.Compile(pattern); err != nil {
+			return nil, ErrJSONPathInvalid
+		}
+	}
+	return comparison, nil
+}
+
+func (p *jsonPathParser) operand() (jsonOperand, error) {
+	switch c := p.peek(); {
+	case c == '@' || c == '$':
+		p.pos++
+		segments, err := p.segments(true)
+		if err != nil {
+			return jsonOperand{}, err
+		}
+		return jsonOperand{path: &jsonPath{segments: segments}, relative: c == '@'}, nil
+	case c == '\'' || c == '"':
+		s, err := p.quoted()
+		return jsonOperand{literal: s}, err
+	}
+
+	start := p.pos
+	for p.pos < len(p.s) && !strings.ContainsRune(" )=!<>&|", rune(p.s[p.pos])) {
+		p.pos++
+	}
+	literal, err := parseJSON([]byte(p.s[start:p.pos]))
+	if err != nil {
+		return jsonOperand{}, ErrJSONPathInvalid
+	}
+	return jsonOperand{literal: literal}, nil
+}
+
+// find finds values matched by the path in a value, where root is the root of the document for filters
+func (jp *jsonPath) find(value, root any) []jsonMatch {
+	matches := []jsonMatch{{value: value}}
+	for _, segment := range jp.segments {
+		var next []jsonMatch
+		for _, m := range matches {
+			if !segment.recursive {
+				next = append(next, segment.apply(m, root)...)
+				continue
+			}
+			for _, d := range jsonDescendants(m, nil) {
+				next = append(next, segment.apply(d, root)...)
+			}
+		}
+		matches = next
+	}
+	return matches
+}
+
+// jsonDescendants lists a value and all its descendants in document order
+func jsonDescendants(m jsonMatch, list []jsonMatch) []jsonMatch {
+	list = append(list, m)
+	for _, child := range jsonChildren(m.value) {
+		list = jsonDescendants(child, list)
+	}
+	return list
+}
+
+// jsonChildren lists elements of an array or values of an object
+func jsonChildren(value any) []jsonMatch {
+	switch v := value.(type) {
+	case *jsonArray:
+		children := make([]jsonMatch, len(v.elems))
+		for i, elem := range v.elems {
+			children[i] = jsonMatch{value: elem, parent: v, index: i}
+		}
+		return children
+	case *jsonObject:
+		children := make([]jsonMatch, len(v.keys))
+		for i, k := range v.keys {
+			children[i] = jsonMatch{value: v.values[k], parent: v, key: k}
+		}
+		return children
+	default:
+		return nil
+	}
+}
+
+func (s *jsonSegment) apply(m jsonMatch, root any) []jsonMatch {
+	var matches []jsonMatch
+	for i := range s.selectors {
+		matches = append(matches, s.selectors[i].apply(m, root)...)
+	}
+	return matches
+}
+
+func (s *jsonSelector) apply(m jsonMatch, root any) []jsonMatch {
+	switch s.kind {
+	case jsonSelectName:
+		if o, ok := m.value.(*jsonObject); ok {
+			if v, ok := o.values[s.name]; ok {
+				return []jsonMatch{{value: v, parent: o, key: s.name}}
+			}
+		}
+	case jsonSelectIndex:
+		if a, ok := m.value.(*jsonArray); ok {
+			i := s.index
+			if i < 0 {
+				i += len(a.elems)
+			}
+			if i >= 0 && i < len(a.elems) {
+				return []jsonMatch{{value: a.elems[i], parent: a, index: i}}
+			}
+		}
+	case jsonSelectWildcard:
+		return jsonChildren(m.value)
+	case jsonSelectSlice:
+		if a, ok := m.value.(*jsonArray); ok {
+			return s.applySlice(a)
+		}
+	case jsonSelectFilter:
+		var matches []jsonMatch
+		for _, child := range jsonChildren(m.value) {
+			if s.filter.eval(child.value, root) {
+				matches = append(matches, child)
+			}
+		}
+		return matches
+	}
+	return nil
+}
+
+func (s *jsonSelector) applySlice(a *jsonArray) []jsonMatch {
+	n := len(a.elems)
+	step := s.slice[2]
+	if step == 0 {
+		return nil
+	}
+	// normalize makes a negative bound count from the end, and clamps it into [lo, hi]
+	normalize := func(i, lo, hi int) int {
+		if i < 0 {
+			i += n
+		}
+		if i < lo {
+			return lo
+		}
+		if i > hi {
+			return hi
+		}
+		return i
+	}
+
+	var start, end int
+	if step > 0 {
+		start, end = 0, n
+		if s.bounds[0] {
+			start = normalize(s.slice[0], 0, n)
+		}
+		if s.bounds[1] {
+			end = normalize(s.slice[1], 0, n)
+		}
+	} else {
+		start, end = n-1, -1
+		if s.bounds[0] {
+			start = normalize(s.slice[0], -1, n-1)
+		}
+		if s.bounds[1] {
+			end = normalize(s.slice[1], -1, n-1)
+		}
+	}
+
+	var matches []jsonMatch
+	for i := start; (step > 0 && i < end) || (step < 0 && i > end); i += step {
+		matches = append(matches, jsonMatch{value: a.elems[i], parent: a, index: i})
+	}
+	return matches
+}
+
+// parent gets the path to the parent of the last segment along with the name selected by the last segment,
+// ok is false if the last segment does not select a single name, such as $.a[0]
+func (jp *jsonPath) parent() (*jsonPath, string, bool) {
+	if len(jp.segments) == 0 {
+		return nil, "", false
+	}
+	last := jp.segments[len(jp.segments)-1]
+	if last.recursive || len(last.selectors) != 1 || last.selectors[0].kind != jsonSelectName {
+		return nil, "", false
+	}
+	parent := &jsonPath{raw: jp.raw, legacy: jp.legacy, segments: jp.segments[:len(jp.segments)-1]}
+	return parent, last.selectors[0].name, true
+}
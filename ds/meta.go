@@ -78,7 +78,7 @@ func decodeMetadata(buffer []byte) *metadata {
 
 // getMetadata
 func (ds *DS) getMetadata(key []byte, dt dataType) (*metadata, error) {
-	metaBuf, err := ds.db.Get(key)
+	metaBuf, err := ds.getValue(key)
 	if err != nil && err != baradb.ErrKeyNotFound {
 		return nil, err
 	}
@@ -92,9 +92,6 @@ func (ds *DS) getMetadata(key []byte, dt dataType) (*metadata, error) {
 		if md.dataType != dt {
 			return nil, ErrWrongTypeOperation
 		}
-		if md.expire != 0 && md.expire <= time.Now().UnixNano() {
-			exist = false
-		}
 	}
 
 	if !exist {
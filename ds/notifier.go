@@ -0,0 +1,58 @@
+package ds
+
+import "sync"
+
+// notifier notifies watchers of keys when the keys are changed, such as readers blocked on streams
+type notifier struct {
+	mu       sync.Mutex
+	watchers map[string][]chan struct{}
+}
+
+func newNotifier() *notifier {
+	return &notifier{
+		watchers: make(map[string][]chan struct{}),
+	}
+}
+
+// watch watches keys, and returns a channel receiving a signal when any of the keys is changed,
+// along with a function to stop watching
+func (n *notifier) watch(keys ...[]byte) (<-chan struct{}, func()) {
+	signal := make(chan struct{}, 1)
+	n.mu.Lock()
+	for _, key := range keys {
+		n.watchers[string(key)] = append(n.watchers[string(key)], signal)
+	}
+	n.mu.Unlock()
+
+	stop := func() {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+		for _, key := range keys {
+			watchers := n.watchers[string(key)]
+			for i, watcher := range watchers {
+				if watcher == signal {
+					watchers = append(watchers[:i], watchers[i+1:]...)
+					break
+				}
+			}
+			if len(watchers) == 0 {
+				delete(n.watchers, string(key))
+			} else {
+				n.watchers[string(key)] = watchers
+			}
+		}
+	}
+	return signal, stop
+}
+
+// notify signals all watchers of a key
+func (n *notifier) notify(key []byte) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for _, signal := range n.watchers[string(key)] {
+		select {
+		case signal <- struct{}{}:
+		default:
+		}
+	}
+}
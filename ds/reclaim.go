@@ -0,0 +1,149 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/saint-yellow/baradb/index"
+)
+
+// reclaimer deletes internal keys of removed collections in the background,
+// so removing a huge collection doesn't block the caller.
+// It also trims samples of time series expired by their retention, which are found when the time series are accessed.
+//
+// Internal keys that are not reclaimed yet when the process crashes are left in the DB engine,
+// but they are never visible since the metadata has been removed.
+type reclaimer struct {
+	mu       sync.Mutex
+	draining sync.Mutex       // serializes draining, so drain returns after pending work taken by others is done
+	prefixes [][]byte         // prefixes of internal keys pending to be deleted
+	series   map[string]int64 // keys and versions of time series pending to be trimmed
+	signal   chan struct{}    // notifies the worker of new prefixes
+	done     chan struct{}    // closed when the worker exits
+}
+
+func newReclaimer() *reclaimer {
+	return &reclaimer{
+		series: make(map[string]int64),
+		signal: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+}
+
+// add adds a prefix of internal keys to be deleted
+func (r *reclaimer) add(prefix []byte) {
+	r.mu.Lock()
+	r.prefixes = append(r.prefixes, prefix)
+	r.mu.Unlock()
+	r.notify()
+}
+
+// trim adds a time series of the version to be trimmed
+func (r *reclaimer) trim(key []byte, version int64) {
+	r.mu.Lock()
+	r.series[string(key)] = version
+	r.mu.Unlock()
+	r.notify()
+}
+
+// notify notifies the worker without blocking
+func (r *reclaimer) notify() {
+	select {
+	case r.signal <- struct{}{}:
+	default:
+	}
+}
+
+// next takes the next prefix, or nil if none is pending
+func (r *reclaimer) next() []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.prefixes) == 0 {
+		return nil
+	}
+	prefix := r.prefixes[0]
+	r.prefixes = r.prefixes[1:]
+	return prefix
+}
+
+// nextSeries takes the next time series, or false if none is pending
+func (r *reclaimer) nextSeries() ([]byte, int64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, version := range r.series {
+		delete(r.series, key)
+		return []byte(key), version, true
+	}
+	return nil, 0, false
+}
+
+// run deletes internal keys until the reclaimer is stopped,
+// and then deletes the remaining ones before it exits
+func (r *reclaimer) run(ds *DS) {
+	defer close(r.done)
+	for range r.signal {
+		r.drain(ds)
+	}
+	r.drain(ds)
+}
+
+// drain deletes pending internal keys and trims pending time series
+func (r *reclaimer) drain(ds *DS) {
+	r.draining.Lock()
+	defer r.draining.Unlock()
+	for prefix := r.next(); prefix != nil; prefix = r.next() {
+		// there is nobody to report the error to, and the keys are invisible anyway
+		_ = ds.deleteInternalKeys(prefix)
+	}
+	for key, version, ok := r.nextSeries(); ok; key, version, ok = r.nextSeries() {
+		// expired samples are filtered by reads, and they are found again by the next access
+		_ = ds.trimTimeSeries(key, version)
+	}
+}
+
+// stop stops the worker and waits for it to exit
+func (r *reclaimer) stop() {
+	close(r.signal)
+	<-r.done
+}
+
+// internalKeyPrefix gets the prefix of all internal keys of a collection,
+// which is the key followed by the version
+func internalKeyPrefix(key []byte, version int64) []byte {
+	prefix := make([]byte, len(key)+8)
+	copy(prefix, key)
+	binary.LittleEndian.PutUint64(prefix[len(key):], uint64(version))
+	return prefix
+}
+
+// deleteInternalKeys deletes all internal keys starting with a prefix in batches
+func (ds *DS) deleteInternalKeys(prefix []byte) error {
+	batchSize := baradb.DefaultWriteBatchOptions.MaxBatchNumber
+	for {
+		keys := make([][]byte, 0, batchSize)
+		opts := index.DefaultIteratorOptions
+		opts.Prefix = prefix
+		iter := ds.db.NewItrerator(opts)
+		for iter.Seek(prefix); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix) && len(keys) < batchSize; iter.Next() {
+			key := make([]byte, len(iter.Key()))
+			copy(key, iter.Key())
+			keys = append(keys, key)
+		}
+		iter.Close()
+
+		if len(keys) == 0 {
+			return nil
+		}
+		wb := ds.db.NewWriteBatch(baradb.DefaultWriteBatchOptions)
+		for _, key := range keys {
+			if err := wb.Delete(key); err != nil {
+				return err
+			}
+		}
+		if err := wb.Commit(); err != nil {
+			return err
+		}
+	}
+}
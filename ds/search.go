@@ -0,0 +1,608 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// Search indexes of hashes are stored in keys with a reserved prefix, which clients can not access:
+//
+//	prefix + 'i' + name -> definition
+//	prefix + 'e' + version + 'k' + key -> empty, for every indexed hash
+//	prefix + 'e' + version + 't' + attribute + tag + key -> empty
+//	prefix + 'e' + version + 'x' + attribute + term + '\x00' + key -> empty
+//	prefix + 'e' + version + 'n' + attribute + number + key -> empty
+//
+// Attributes and tags are prefixed by their lengths, and numbers are in an order-preserving binary form,
+// so that entries can be scanned by prefixes and ranges.
+//
+// Entries are updated along with hashes by HSET, HDEL and deletions of keys. Hashes changed in other ways,
+// such as being overwritten by other types, leave stale entries, which are filtered out by FT.SEARCH,
+// since matched hashes are always checked against the query.
+var searchPrefix = append(bytes.Clone(reservedPrefix), "search\x00"...)
+
+const (
+	searchDefinitionTag = 'i'
+	searchEntryTag      = 'e'
+	searchDocumentTag   = 'k'
+	searchTagTag        = 't'
+	searchTextTag       = 'x'
+	searchNumericTag    = 'n'
+)
+
+// SearchFieldType is the type of a field of a search index
+type SearchFieldType byte
+
+const (
+	SearchText    SearchFieldType = iota // words matched by terms and prefixes case-insensitively
+	SearchTag                            // separated tags matched exactly
+	SearchNumeric                        // numbers matched by ranges
+)
+
+// SearchField is a field of hashes in a search index
+type SearchField struct {
+	Name          string // the field of hashes
+	Alias         string // the attribute in queries, which is the name if it is empty
+	Type          SearchFieldType
+	Separator     byte // the separator of tags
+	CaseSensitive bool // whether tags are case-sensitive
+	Sortable      bool
+}
+
+func (f *SearchField) attribute() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// SearchIndexOptions options of a search index
+type SearchIndexOptions struct {
+	Prefixes []string // hashes with keys starting with any of these prefixes are indexed, or all hashes if it is empty
+	Fields   []SearchField
+}
+
+// searchIndex is the definition of a search index
+type searchIndex struct {
+	name    string
+	version int64
+	opts    SearchIndexOptions
+}
+
+func (idx *searchIndex) encode() []byte {
+	buffer := binary.AppendVarint(nil, idx.version)
+	buffer = binary.AppendUvarint(buffer, uint64(len(idx.opts.Prefixes)))
+	for _, prefix := range idx.opts.Prefixes {
+		buffer = appendSearchString(buffer, prefix)
+	}
+	buffer = binary.AppendUvarint(buffer, uint64(len(idx.opts.Fields)))
+	for _, f := range idx.opts.Fields {
+		buffer = appendSearchString(buffer, f.Name)
+		buffer = appendSearchString(buffer, f.Alias)
+		var flags byte
+		if f.CaseSensitive {
+			flags |= 1
+		}
+		if f.Sortable {
+			flags |= 2
+		}
+		buffer = append(buffer, byte(f.Type), f.Separator, flags)
+	}
+	return buffer
+}
+
+// decodeSearchIndex decodes a definition, which fails if the definition is truncated or has invalid fields
+func decodeSearchIndex(name string, buffer []byte) (*searchIndex, error) {
+	d := &fieldDecoder{buffer: buffer}
+	idx := &searchIndex{name: name, version: d.varint()}
+	// every prefix takes a byte at least, and every field takes 5 bytes at least
+	n := d.uvarint()
+	if d.invalid || n > uint64(d.remaining()) {
+		return nil, ErrCorruptedSearchIndex
+	}
+	idx.opts.Prefixes = make([]string, n)
+	for i := range idx.opts.Prefixes {
+		idx.opts.Prefixes[i] = d.string()
+	}
+	n = d.uvarint()
+	if d.invalid || n > uint64(d.remaining()/5) {
+		return nil, ErrCorruptedSearchIndex
+	}
+	idx.opts.Fields = make([]SearchField, n)
+	for i := range idx.opts.Fields {
+		f := &idx.opts.Fields[i]
+		f.Name = d.string()
+		f.Alias = d.string()
+		f.Type = SearchFieldType(d.byte())
+		f.Separator = d.byte()
+		flags := d.byte()
+		f.CaseSensitive = flags&1 != 0
+		f.Sortable = flags&2 != 0
+		if f.Type > SearchNumeric {
+			return nil, ErrCorruptedSearchIndex
+		}
+	}
+	if d.invalid || d.remaining() > 0 {
+		return nil, ErrCorruptedSearchIndex
+	}
+	return idx, nil
+}
+
+// appendSearchString appends a string prefixed by its length
+func appendSearchString(buffer []byte, s string) []byte {
+	buffer = binary.AppendUvarint(buffer, uint64(len(s)))
+	return append(buffer, s...)
+}
+
+// matches tells whether a hash is indexed by its key
+func (idx *searchIndex) matches(key []byte) bool {
+	if len(idx.opts.Prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range idx.opts.Prefixes {
+		if bytes.HasPrefix(key, []byte(prefix)) {
+			return true
+		}
+	}
+	return false
+}
+
+// field gets the field of an attribute, which is nil if the index does not have the attribute
+func (idx *searchIndex) field(attribute string) *SearchField {
+	for i := range idx.opts.Fields {
+		if idx.opts.Fields[i].attribute() == attribute {
+			return &idx.opts.Fields[i]
+		}
+	}
+	return nil
+}
+
+func searchDefinitionKey(name string) []byte {
+	return append(append(bytes.Clone(searchPrefix), searchDefinitionTag), name...)
+}
+
+func (idx *searchIndex) entryPrefix() []byte {
+	prefix := append(bytes.Clone(searchPrefix), searchEntryTag)
+	return binary.BigEndian.AppendUint64(prefix, uint64(idx.version))
+}
+
+func (idx *searchIndex) documentKey(key []byte) []byte {
+	return append(append(idx.entryPrefix(), searchDocumentTag), key...)
+}
+
+// fieldPrefix gets the prefix of entries of a field
+func (idx *searchIndex) fieldPrefix(f *SearchField) []byte {
+	tags := [...]byte{SearchText: searchTextTag, SearchTag: searchTagTag, SearchNumeric: searchNumericTag}
+	prefix := append(idx.entryPrefix(), tags[f.Type])
+	return appendSearchString(prefix, f.attribute())
+}
+
+// entries gets keys of entries of a field of a hash with the value
+func (idx *searchIndex) entries(key []byte, f *SearchField, value []byte) [][]byte {
+	prefix := idx.fieldPrefix(f)
+	var entries [][]byte
+	switch f.Type {
+	case SearchText:
+		for _, term := range searchTerms(string(value)) {
+			entry := append(bytes.Clone(prefix), term...)
+			entries = append(entries, append(append(entry, 0), key...))
+		}
+	case SearchTag:
+		for _, tag := range f.tags(string(value)) {
+			entry := appendSearchString(bytes.Clone(prefix), tag)
+			entries = append(entries, append(entry, key...))
+		}
+	case SearchNumeric:
+		if n, err := strconv.ParseFloat(string(value), 64); err == nil && !math.IsNaN(n) {
+			entry := appendSearchNumber(bytes.Clone(prefix), n)
+			entries = append(entries, append(entry, key...))
+		}
+	}
+	return entries
+}
+
+// searchTerms splits a text into distinct words in lower case
+func searchTerms(text string) []string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	seen := make(map[string]bool, len(words))
+	terms := words[:0]
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+	return terms
+}
+
+// tags splits a value into distinct tags
+func (f *SearchField) tags(value string) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	for _, tag := range strings.Split(value, string(f.Separator)) {
+		tag = f.normalizeTag(tag)
+		if tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func (f *SearchField) normalizeTag(tag string) string {
+	tag = strings.TrimSpace(tag)
+	if !f.CaseSensitive {
+		tag = strings.ToLower(tag)
+	}
+	return tag
+}
+
+// appendSearchNumber appends a number in big endian with the sign bit flipped, or all bits flipped if it is negative,
+// so that numbers are ordered as their binary forms
+func appendSearchNumber(buffer []byte, n float64) []byte {
+	b := math.Float64bits(n)
+	if b>>63 == 0 {
+		b |= 1 << 63
+	} else {
+		b = ^b
+	}
+	return binary.BigEndian.AppendUint64(buffer, b)
+}
+
+func decodeSearchNumber(buffer []byte) float64 {
+	b := binary.BigEndian.Uint64(buffer)
+	if b>>63 == 1 {
+		b &^= 1 << 63
+	} else {
+		b = ^b
+	}
+	return math.Float64frombits(b)
+}
+
+// searchIndexes are definitions of search indexes, which are cached in memory
+// since they are looked up by every write of hashes
+type searchIndexes struct {
+	mu      sync.RWMutex
+	indexes map[string]*searchIndex
+	locks   searchKeyLocks
+}
+
+// matching gets indexes of a hash by its key
+func (s *searchIndexes) matching(key []byte) []*searchIndex {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var indexes []*searchIndex
+	for _, idx := range s.indexes {
+		if idx.matches(key) {
+			indexes = append(indexes, idx)
+		}
+	}
+	return indexes
+}
+
+func (s *searchIndexes) get(name string) *searchIndex {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.indexes[name]
+}
+
+// create stores the definition of a new index, and registers it
+func (s *searchIndexes) create(ds *DS, name string, opts SearchIndexOptions) (*searchIndex, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.indexes[name] != nil {
+		return nil, ErrSearchIndexExists
+	}
+	idx := &searchIndex{name: name, version: time.Now().UnixNano(), opts: opts}
+	if err := ds.db.Put(searchDefinitionKey(name), idx.encode()); err != nil {
+		return nil, err
+	}
+	s.indexes[name] = idx
+	return idx, nil
+}
+
+// drop deletes the definition of an index, and unregisters it
+func (s *searchIndexes) drop(ds *DS, name string) (*searchIndex, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	idx := s.indexes[name]
+	if idx == nil {
+		return nil, ErrSearchIndexNotFound
+	}
+	if err := ds.db.Delete(searchDefinitionKey(name)); err != nil {
+		return nil, err
+	}
+	delete(s.indexes, name)
+	return idx, nil
+}
+
+// searchKeyLocks serializes writes of hashes by keys, so that entries of indexes computed from old values
+// are committed before others read the values again
+type searchKeyLocks [256]sync.Mutex
+
+func (l *searchKeyLocks) lock(key []byte) *sync.Mutex {
+	mu := &l[l.index(key)]
+	mu.Lock()
+	return mu
+}
+
+// lockAll locks keys in the order of their locks, so that writers of several keys don't deadlock each other,
+// and returns a function unlocking them
+func (l *searchKeyLocks) lockAll(keys [][]byte) func() {
+	locked := make(map[int]bool)
+	var indexes []int
+	for _, key := range keys {
+		if i := l.index(key); !locked[i] {
+			locked[i] = true
+			indexes = append(indexes, i)
+		}
+	}
+	sort.Ints(indexes)
+	for _, i := range indexes {
+		l[i].Lock()
+	}
+	return func() {
+		for _, i := range indexes {
+			l[i].Unlock()
+		}
+	}
+}
+
+func (l *searchKeyLocks) index(key []byte) int {
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(len(l)))
+}
+
+// loadSearchIndexes loads definitions of search indexes
+func (ds *DS) loadSearchIndexes() error {
+	ds.search = &searchIndexes{indexes: make(map[string]*searchIndex)}
+	prefix := append(bytes.Clone(searchPrefix), searchDefinitionTag)
+	return ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		name := string(encKey[len(prefix):])
+		idx, err := decodeSearchIndex(name, value)
+		if err != nil {
+			return false, err
+		}
+		ds.search.indexes[name] = idx
+		return true, nil
+	})
+}
+
+// searchChanges are entries to be put and deleted along with a write of a hash
+type searchChanges struct {
+	puts    [][]byte
+	deletes [][]byte
+}
+
+func (c *searchChanges) len() int {
+	return len(c.puts) + len(c.deletes)
+}
+
+// add adds changes of entries of a field of a hash from the old value to the new one, which are nil if missing
+func (c *searchChanges) add(idx *searchIndex, key, field, oldValue, newValue []byte) {
+	for i := range idx.opts.Fields {
+		f := &idx.opts.Fields[i]
+		if f.Name != string(field) {
+			continue
+		}
+		newEntries := make(map[string]bool)
+		if newValue != nil {
+			for _, entry := range idx.entries(key, f, newValue) {
+				newEntries[string(entry)] = true
+				c.puts = append(c.puts, entry)
+			}
+		}
+		if oldValue != nil {
+			for _, entry := range idx.entries(key, f, oldValue) {
+				if !newEntries[string(entry)] {
+					c.deletes = append(c.deletes, entry)
+				}
+			}
+		}
+	}
+}
+
+// apply stages changes in a write batch
+func (c *searchChanges) apply(wb *baradb.WriteBatch) error {
+	for _, entry := range c.deletes {
+		if err := wb.Delete(entry); err != nil {
+			return err
+		}
+	}
+	for _, entry := range c.puts {
+		if err := wb.Put(entry, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashFieldChanges gets changes of entries of all indexes of a hash by a write of a field
+func (ds *DS) hashFieldChanges(key, field, oldValue, newValue []byte) *searchChanges {
+	c := &searchChanges{}
+	for _, idx := range ds.search.matching(key) {
+		if newValue != nil {
+			c.puts = append(c.puts, idx.documentKey(key))
+		}
+		c.add(idx, key, field, oldValue, newValue)
+	}
+	return c
+}
+
+// hashDeletionChanges gets changes of entries of all indexes of a key by its deletion, if it is a hash
+func (ds *DS) hashDeletionChanges(key []byte) (*searchChanges, error) {
+	c := &searchChanges{}
+	indexes := ds.search.matching(key)
+	if len(indexes) == 0 {
+		return c, nil
+	}
+	value, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound || (err == nil && value[0] != Hash) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	pairs, err := ds.HGetAll(key)
+	if err != nil {
+		return nil, err
+	}
+	for _, idx := range indexes {
+		c.deletes = append(c.deletes, idx.documentKey(key))
+		for i := 0; i < len(pairs); i += 2 {
+			c.add(idx, key, pairs[i], pairs[i+1], nil)
+		}
+	}
+	return c, nil
+}
+
+// FTCreate redis FT.CREATE
+//
+// It creates a search index of hashes, and indexes existing hashes.
+//
+// Hashes written after the index is registered are indexed by themselves, so existing hashes are indexed
+// without blocking other writes, while every hash is locked only when it is indexed.
+func (ds *DS) FTCreate(name string, opts SearchIndexOptions) error {
+	idx, err := ds.search.create(ds, name, opts)
+	if err != nil {
+		return err
+	}
+	return ds.indexExistingHashes(idx)
+}
+
+// indexExistingHashes indexes hashes which have been written before an index is created
+func (ds *DS) indexExistingHashes(idx *searchIndex) error {
+	prefixes := idx.opts.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	for _, prefix := range prefixes {
+		// internal keys of a collection follow its key, and they are skipped,
+		// since their values are not metadata even if they start with the type of hashes
+		var keys, internals [][]byte
+		err := ds.scanInternalKeys([]byte(prefix), []byte(prefix), func(encKey, value []byte) (bool, error) {
+			// prefixes of internal keys which have been scanned past are dropped
+			pending := internals[:0]
+			for _, internal := range internals {
+				if bytes.HasPrefix(encKey, internal) {
+					return true, nil
+				}
+				if bytes.Compare(encKey, internal) < 0 {
+					pending = append(pending, internal)
+				}
+			}
+			internals = pending
+			if IsReservedKey(encKey) || len(value) == 0 {
+				return true, nil
+			}
+			switch value[0] {
+			case String, CountMinSketch, TopK:
+				return true, nil
+			case Hash:
+				keys = append(keys, bytes.Clone(encKey))
+			}
+			internals = append(internals, internalKeyPrefix(encKey, decodeMetadata(value).version))
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := ds.indexExistingHash(idx, key); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// indexExistingHash indexes a hash which may have been changed since it is scanned
+func (ds *DS) indexExistingHash(idx *searchIndex, key []byte) error {
+	defer ds.search.locks.lock(key).Unlock()
+
+	// the index may have been dropped meanwhile
+	if ds.search.get(idx.name) != idx {
+		return nil
+	}
+	if _, err := ds.getValue(key); err != nil {
+		return nil
+	}
+	pairs, err := ds.HGetAll(key)
+	if err != nil || len(pairs) == 0 {
+		return nil
+	}
+	c := &searchChanges{puts: [][]byte{idx.documentKey(key)}}
+	for i := 0; i < len(pairs); i += 2 {
+		c.add(idx, key, pairs[i], nil, pairs[i+1])
+	}
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = c.len()
+	wb := ds.db.NewWriteBatch(opts)
+	if err := c.apply(wb); err != nil {
+		return err
+	}
+	return wb.Commit()
+}
+
+// FTDropIndex redis FT.DROPINDEX
+//
+// It drops a search index, and deletes indexed hashes as well if deleteHashes is true,
+// except hashes of keys keyFilter returns false for if it is not nil.
+func (ds *DS) FTDropIndex(name string, deleteHashes bool, keyFilter func(key []byte) bool) error {
+	idx, err := ds.search.drop(ds, name)
+	if err != nil {
+		return err
+	}
+
+	if deleteHashes {
+		prefix := idx.documentKey(nil)
+		var keys [][]byte
+		err := ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+			keys = append(keys, bytes.Clone(encKey[len(prefix):]))
+			return true, nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			if keyFilter != nil && !keyFilter(key) {
+				continue
+			}
+			if value, err := ds.getValue(key); err == nil && value[0] == Hash {
+				if err := ds.Del(key); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	ds.reclaimer.add(idx.entryPrefix())
+	return nil
+}
+
+// FTList redis FT._LIST
+func (ds *DS) FTList() []string {
+	ds.search.mu.RLock()
+	defer ds.search.mu.RUnlock()
+	names := make([]string, 0, len(ds.search.indexes))
+	for name := range ds.search.indexes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,537 @@
+package ds
+
+import (
+	"bytes"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SearchOptions are options of FT.SEARCH
+type SearchOptions struct {
+	SortBy     string // sorts documents by an attribute, or by keys if it is empty
+	Descending bool
+	Offset     int
+	Limit      int      // the maximum number of documents
+	Return     []string // returns these attributes only if it is not empty
+	NoContent  bool     // returns keys only
+
+	// KeyFilter skips documents of keys it returns false for, such as keys a client is not allowed to access
+	KeyFilter func(key []byte) bool
+}
+
+// SearchDocument is a hash matched by FT.SEARCH
+type SearchDocument struct {
+	Key    []byte
+	Fields [][]byte // fields and values alternately
+}
+
+// searchClause is a part of a query, which are all matched by documents
+type searchClause struct {
+	field *SearchField // a text clause without a field matches any text field
+
+	// text
+	term   string
+	prefix bool
+
+	// tag
+	tags []string
+
+	// numeric
+	min, max                   float64
+	minExclusive, maxExclusive bool
+}
+
+// searchQuery is a parsed query, which matches all documents if it has no clauses
+type searchQuery struct {
+	idx     *searchIndex
+	clauses []*searchClause
+}
+
+// parseSearchQuery parses a query, such as:
+//
+//	*
+//	hello wor*
+//	@title:hello @body:(hello world)
+//	@tags:{red | green}
+//	@price:[10 (20] @rating:[-inf +inf]
+func parseSearchQuery(idx *searchIndex, query string) (*searchQuery, error) {
+	q := &searchQuery{idx: idx}
+	p := &searchParser{query: query}
+	for p.skipSpaces(); p.index < len(query); p.skipSpaces() {
+		switch c := query[p.index]; {
+		case c == '*':
+			p.index++
+		case c == '@':
+			p.index++
+			attribute := p.word()
+			if attribute == "" || !p.consume(':') {
+				return nil, ErrSearchSyntax
+			}
+			f := idx.field(attribute)
+			if f == nil {
+				return nil, newErrSearchUnknownField(attribute)
+			}
+			clauses, err := p.fieldClauses(f)
+			if err != nil {
+				return nil, err
+			}
+			q.clauses = append(q.clauses, clauses...)
+		default:
+			clauses, err := p.textClauses(nil)
+			if err != nil {
+				return nil, err
+			}
+			q.clauses = append(q.clauses, clauses...)
+		}
+	}
+	return q, nil
+}
+
+// searchParser is a cursor of a query
+type searchParser struct {
+	query string
+	index int
+}
+
+func (p *searchParser) skipSpaces() {
+	for p.index < len(p.query) && p.query[p.index] == ' ' {
+		p.index++
+	}
+}
+
+func (p *searchParser) consume(c byte) bool {
+	if p.index < len(p.query) && p.query[p.index] == c {
+		p.index++
+		return true
+	}
+	return false
+}
+
+// word reads a word of letters, digits and underscores
+func (p *searchParser) word() string {
+	start := p.index
+	for p.index < len(p.query) && isSearchWordByte(p.query[p.index]) {
+		p.index++
+	}
+	return p.query[start:p.index]
+}
+
+func isSearchWordByte(c byte) bool {
+	return c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= 0x80
+}
+
+// textClauses reads a term or a prefix of a text field, or of all text fields if f is nil
+func (p *searchParser) textClauses(f *SearchField) ([]*searchClause, error) {
+	start := p.index
+	for p.index < len(p.query) && !strings.ContainsRune(" ()[]{}@|", rune(p.query[p.index])) {
+		p.index++
+	}
+	token := p.query[start:p.index]
+	prefix := strings.HasSuffix(token, "*")
+	terms := searchTerms(token)
+	if len(terms) == 0 {
+		return nil, ErrSearchSyntax
+	}
+	clauses := make([]*searchClause, len(terms))
+	for i, term := range terms {
+		clauses[i] = &searchClause{field: f, term: term}
+	}
+	clauses[len(clauses)-1].prefix = prefix
+	return clauses, nil
+}
+
+// fieldClauses reads clauses after @attribute:
+func (p *searchParser) fieldClauses(f *SearchField) ([]*searchClause, error) {
+	switch f.Type {
+	case SearchTag:
+		if !p.consume('{') {
+			return nil, ErrSearchSyntax
+		}
+		return p.tagClauses(f)
+	case SearchNumeric:
+		if !p.consume('[') {
+			return nil, ErrSearchSyntax
+		}
+		return p.numericClauses(f)
+	}
+
+	if !p.consume('(') {
+		return p.textClauses(f)
+	}
+	var clauses []*searchClause
+	for p.skipSpaces(); !p.consume(')'); p.skipSpaces() {
+		if p.index == len(p.query) {
+			return nil, ErrSearchSyntax
+		}
+		terms, err := p.textClauses(f)
+		if err != nil {
+			return nil, err
+		}
+		clauses = append(clauses, terms...)
+	}
+	if len(clauses) == 0 {
+		return nil, ErrSearchSyntax
+	}
+	return clauses, nil
+}
+
+// tagClauses reads tags separated by | until }, where special characters can be escaped by backslashes
+func (p *searchParser) tagClauses(f *SearchField) ([]*searchClause, error) {
+	clause := &searchClause{field: f}
+	var tag strings.Builder
+	for {
+		if p.index == len(p.query) {
+			return nil, ErrSearchSyntax
+		}
+		c := p.query[p.index]
+		p.index++
+		switch {
+		case c == '\\' && p.index < len(p.query):
+			tag.WriteByte(p.query[p.index])
+			p.index++
+		case c == '|' || c == '}':
+			if t := f.normalizeTag(tag.String()); t != "" {
+				clause.tags = append(clause.tags, t)
+			}
+			tag.Reset()
+			if c == '}' {
+				if len(clause.tags) == 0 {
+					return nil, ErrSearchSyntax
+				}
+				return []*searchClause{clause}, nil
+			}
+		default:
+			tag.WriteByte(c)
+		}
+	}
+}
+
+// numericClauses reads a range like min max], where bounds can be exclusive with ( and infinite with -inf and +inf
+func (p *searchParser) numericClauses(f *SearchField) ([]*searchClause, error) {
+	end := strings.IndexByte(p.query[p.index:], ']')
+	if end < 0 {
+		return nil, ErrSearchSyntax
+	}
+	bounds := strings.Fields(p.query[p.index : p.index+end])
+	p.index += end + 1
+	if len(bounds) != 2 {
+		return nil, ErrSearchSyntax
+	}
+	clause := &searchClause{field: f}
+	var err error
+	if clause.min, clause.minExclusive, err = parseSearchBound(bounds[0]); err != nil {
+		return nil, err
+	}
+	if clause.max, clause.maxExclusive, err = parseSearchBound(bounds[1]); err != nil {
+		return nil, err
+	}
+	return []*searchClause{clause}, nil
+}
+
+func parseSearchBound(bound string) (float64, bool, error) {
+	exclusive := strings.HasPrefix(bound, "(")
+	if exclusive {
+		bound = bound[1:]
+	}
+	n, err := strconv.ParseFloat(bound, 64)
+	if err != nil || math.IsNaN(n) {
+		return 0, false, ErrSearchSyntax
+	}
+	return n, exclusive, nil
+}
+
+// matchValue tells whether a value of the field of a clause matches the clause
+func (c *searchClause) matchValue(f *SearchField, value []byte) bool {
+	switch f.Type {
+	case SearchText:
+		for _, term := range searchTerms(string(value)) {
+			if term == c.term || (c.prefix && strings.HasPrefix(term, c.term)) {
+				return true
+			}
+		}
+	case SearchTag:
+		for _, tag := range f.tags(string(value)) {
+			for _, t := range c.tags {
+				if tag == t {
+					return true
+				}
+			}
+		}
+	case SearchNumeric:
+		n, err := strconv.ParseFloat(string(value), 64)
+		return err == nil && c.inRange(n)
+	}
+	return false
+}
+
+func (c *searchClause) inRange(n float64) bool {
+	if n < c.min || (c.minExclusive && n == c.min) {
+		return false
+	}
+	return n < c.max || (!c.maxExclusive && n == c.max)
+}
+
+// fields gets fields matched by a clause
+func (c *searchClause) fields(idx *searchIndex) []*SearchField {
+	if c.field != nil {
+		return []*SearchField{c.field}
+	}
+	var fields []*SearchField
+	for i := range idx.opts.Fields {
+		if idx.opts.Fields[i].Type == SearchText {
+			fields = append(fields, &idx.opts.Fields[i])
+		}
+	}
+	return fields
+}
+
+// match tells whether a hash with fields and values matches the clause
+func (c *searchClause) match(idx *searchIndex, values map[string][]byte) bool {
+	for _, f := range c.fields(idx) {
+		if value, ok := values[f.Name]; ok && c.matchValue(f, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// searchCandidates gets keys of hashes which may match a clause by scanning entries
+func (ds *DS) searchCandidates(idx *searchIndex, c *searchClause) (map[string]bool, error) {
+	keys := make(map[string]bool)
+	for _, f := range c.fields(idx) {
+		prefix := idx.fieldPrefix(f)
+		switch f.Type {
+		case SearchText:
+			prefix = append(prefix, c.term...)
+			if !c.prefix {
+				prefix = append(prefix, 0)
+			}
+			err := ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+				rest := encKey[len(prefix):]
+				if c.prefix {
+					// terms consist of letters and digits, so the first zero ends the term
+					rest = rest[bytes.IndexByte(rest, 0)+1:]
+				}
+				keys[string(rest)] = true
+				return true, nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		case SearchTag:
+			for _, tag := range c.tags {
+				tagPrefix := appendSearchString(bytes.Clone(prefix), tag)
+				err := ds.scanInternalKeys(tagPrefix, tagPrefix, func(encKey, value []byte) (bool, error) {
+					keys[string(encKey[len(tagPrefix):])] = true
+					return true, nil
+				})
+				if err != nil {
+					return nil, err
+				}
+			}
+		case SearchNumeric:
+			seek := appendSearchNumber(bytes.Clone(prefix), c.min)
+			err := ds.scanInternalKeys(prefix, seek, func(encKey, value []byte) (bool, error) {
+				rest := encKey[len(prefix):]
+				n := decodeSearchNumber(rest)
+				if n > c.max {
+					return false, nil
+				}
+				if c.inRange(n) {
+					keys[string(rest[8:])] = true
+				}
+				return true, nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return keys, nil
+}
+
+// searchQueryCandidates gets keys of hashes which may match a query, which are intersected from candidates of all clauses,
+// or all indexed hashes if there are no clauses
+func (ds *DS) searchQueryCandidates(q *searchQuery) ([][]byte, error) {
+	var keys map[string]bool
+	if len(q.clauses) == 0 {
+		keys = make(map[string]bool)
+		prefix := q.idx.documentKey(nil)
+		err := ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+			keys[string(encKey[len(prefix):])] = true
+			return true, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, c := range q.clauses {
+		candidates, err := ds.searchCandidates(q.idx, c)
+		if err != nil {
+			return nil, err
+		}
+		if keys == nil {
+			keys = candidates
+			continue
+		}
+		for key := range keys {
+			if !candidates[key] {
+				delete(keys, key)
+			}
+		}
+	}
+
+	results := make([][]byte, 0, len(keys))
+	for key := range keys {
+		results = append(results, []byte(key))
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return bytes.Compare(results[i], results[j]) < 0
+	})
+	return results, nil
+}
+
+// FTSearch redis FT.SEARCH
+//
+// It returns the number of all matched hashes, and the hashes within the offset and the limit.
+// Candidates found by entries of the index are checked against the query again,
+// so that stale entries of hashes which have been changed are ignored.
+func (ds *DS) FTSearch(name, query string, opts SearchOptions) (int, []SearchDocument, error) {
+	idx := ds.search.get(name)
+	if idx == nil {
+		return 0, nil, ErrSearchIndexNotFound
+	}
+	q, err := parseSearchQuery(idx, query)
+	if err != nil {
+		return 0, nil, err
+	}
+	var sortBy *SearchField
+	if opts.SortBy != "" {
+		if sortBy = idx.field(opts.SortBy); sortBy == nil {
+			return 0, nil, newErrSearchSortBy(opts.SortBy)
+		}
+	}
+
+	keys, err := ds.searchQueryCandidates(q)
+	if err != nil {
+		return 0, nil, err
+	}
+	var docs []SearchDocument
+	var values []map[string][]byte
+	for _, key := range keys {
+		if !idx.matches(key) || (opts.KeyFilter != nil && !opts.KeyFilter(key)) {
+			continue
+		}
+		pairs, err := ds.HGetAll(key)
+		if err == ErrWrongTypeOperation {
+			continue
+		}
+		if err != nil {
+			return 0, nil, err
+		}
+		fields := make(map[string][]byte, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			fields[string(pairs[i])] = pairs[i+1]
+		}
+		matched := len(pairs) > 0
+		for _, c := range q.clauses {
+			if matched = matched && c.match(idx, fields); !matched {
+				break
+			}
+		}
+		if matched {
+			docs = append(docs, SearchDocument{Key: key, Fields: pairs})
+			values = append(values, fields)
+		}
+	}
+
+	if sortBy != nil {
+		sortSearchDocuments(docs, values, sortBy, opts.Descending)
+	}
+	total := len(docs)
+	if opts.Offset < len(docs) {
+		docs = docs[opts.Offset:]
+	} else {
+		docs = nil
+	}
+	if opts.Limit < len(docs) {
+		docs = docs[:opts.Limit]
+	}
+	for i := range docs {
+		switch {
+		case opts.NoContent:
+			docs[i].Fields = nil
+		case len(opts.Return) > 0:
+			docs[i].Fields = returnSearchFields(idx, docs[i].Fields, opts.Return)
+		}
+	}
+	return total, docs, nil
+}
+
+// sortSearchDocuments sorts documents by a field stably, where documents without the field are always the last
+func sortSearchDocuments(docs []SearchDocument, values []map[string][]byte, f *SearchField, descending bool) {
+	type sortKey struct {
+		missing bool
+		number  float64
+		value   []byte
+	}
+	keys := make([]sortKey, len(docs))
+	for i := range docs {
+		value, ok := values[i][f.Name]
+		keys[i] = sortKey{missing: !ok, value: value}
+		if ok && f.Type == SearchNumeric {
+			n, err := strconv.ParseFloat(string(value), 64)
+			keys[i].missing, keys[i].number = err != nil, n
+		}
+	}
+	indexes := make([]int, len(docs))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		a, b := keys[indexes[i]], keys[indexes[j]]
+		if a.missing || b.missing {
+			return !a.missing && b.missing
+		}
+		var cmp int
+		if f.Type == SearchNumeric {
+			switch {
+			case a.number < b.number:
+				cmp = -1
+			case a.number > b.number:
+				cmp = 1
+			}
+		} else {
+			cmp = bytes.Compare(a.value, b.value)
+		}
+		if descending {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+	sorted := make([]SearchDocument, len(docs))
+	for i, index := range indexes {
+		sorted[i] = docs[index]
+	}
+	copy(docs, sorted)
+}
+
+// returnSearchFields picks fields of attributes in order, which are fields of hashes if they are not in the schema
+func returnSearchFields(idx *searchIndex, pairs [][]byte, attributes []string) [][]byte {
+	var fields [][]byte
+	for _, attribute := range attributes {
+		name := attribute
+		if f := idx.field(attribute); f != nil {
+			name = f.Name
+		}
+		for i := 0; i < len(pairs); i += 2 {
+			if string(pairs[i]) == name {
+				fields = append(fields, []byte(attribute), pairs[i+1])
+				break
+			}
+		}
+	}
+	return fields
+}
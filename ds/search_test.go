@@ -0,0 +1,226 @@
+package ds
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testingSearchIndexOptions = SearchIndexOptions{
+	Prefixes: []string{"item:"},
+	Fields: []SearchField{
+		{Name: "title", Type: SearchText},
+		{Name: "tags", Type: SearchTag, Separator: ','},
+		{Name: "price", Alias: "cost", Type: SearchNumeric, Sortable: true},
+	},
+}
+
+func setSearchItem(t *testing.T, ds *DS, key, title, tags, price string) {
+	for _, pair := range [][2]string{{"title", title}, {"tags", tags}, {"price", price}} {
+		_, err := ds.HSet([]byte(key), []byte(pair[0]), []byte(pair[1]))
+		assert.Nil(t, err)
+	}
+}
+
+func searchKeys(t *testing.T, ds *DS, query string, opts SearchOptions) []string {
+	if opts.Limit == 0 {
+		opts.Limit = 10
+	}
+	total, docs, err := ds.FTSearch("idx", query, opts)
+	assert.Nil(t, err)
+	keys := []string{}
+	for _, doc := range docs {
+		keys = append(keys, string(doc.Key))
+	}
+	if opts.Offset == 0 && opts.Limit == 10 {
+		assert.Equal(t, total, len(keys))
+	}
+	return keys
+}
+
+func TestDS_FTCreate(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer func() {
+		destroyDS(ds, testingDBOptions.Directory)
+	}()
+
+	// existing hashes are indexed, while other keys and collections are not
+	setSearchItem(t, ds, "item:1", "Red apple", "fruit,red", "3")
+	setSearchItem(t, ds, "other:1", "Red apple", "fruit,red", "3")
+	_, err := ds.SAdd([]byte("item:set"), []byte("\x02red"))
+	assert.Nil(t, err)
+	assert.Nil(t, ds.Set([]byte("item:string"), []byte("red"), 0))
+
+	assert.Nil(t, ds.FTCreate("idx", testingSearchIndexOptions))
+	assert.Equal(t, ErrSearchIndexExists, ds.FTCreate("idx", testingSearchIndexOptions))
+	assert.Equal(t, []string{"item:1"}, searchKeys(t, ds, "red", SearchOptions{}))
+
+	// hashes written later are indexed
+	setSearchItem(t, ds, "item:2", "Green apple", "fruit,green", "2")
+	assert.Equal(t, []string{"item:1", "item:2"}, searchKeys(t, ds, "*", SearchOptions{}))
+
+	// indexes persist after a restart
+	assert.Nil(t, ds.Close())
+	ds, _ = New(testingDBOptions)
+	assert.Equal(t, []string{"idx"}, ds.FTList())
+	assert.Equal(t, []string{"item:2"}, searchKeys(t, ds, "green", SearchOptions{}))
+
+	// hashes are deleted along with the index
+	assert.Nil(t, ds.FTDropIndex("idx", true, nil))
+	assert.Equal(t, ErrSearchIndexNotFound, ds.FTDropIndex("idx", false, nil))
+	_, _, err = ds.FTSearch("idx", "*", SearchOptions{})
+	assert.Equal(t, ErrSearchIndexNotFound, err)
+	assert.False(t, ds.Exists([]byte("item:1")))
+	assert.True(t, ds.Exists([]byte("other:1")))
+}
+
+func TestDS_FTSearch(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	assert.Nil(t, ds.FTCreate("idx", testingSearchIndexOptions))
+	setSearchItem(t, ds, "item:1", "Red apple", "Fruit, Red", "3")
+	setSearchItem(t, ds, "item:2", "Green apple pie", "dessert,green", "12.5")
+	setSearchItem(t, ds, "item:3", "Red wine", "drink,red", "-20")
+	setSearchItem(t, ds, "item:4", "Banana", "fruit,yellow", "unknown")
+
+	tests := []struct {
+		query string
+		keys  []string
+	}{
+		{"apple", []string{"item:1", "item:2"}},
+		{"RED apple", []string{"item:1"}},
+		{"app*", []string{"item:1", "item:2"}},
+		{"@title:(red wine)", []string{"item:3"}},
+		{"@tags:{fruit}", []string{"item:1", "item:4"}},
+		{"@tags:{yellow | green}", []string{"item:2", "item:4"}},
+		{"@cost:[0 +inf]", []string{"item:1", "item:2"}},
+		{"@cost:[-inf (3]", []string{"item:3"}},
+		{"@cost:[3 12.5]", []string{"item:1", "item:2"}},
+		{"@tags:{red} @cost:[-100 100]", []string{"item:1", "item:3"}},
+		{"pear", []string{}},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.keys, searchKeys(t, ds, tt.query, SearchOptions{}), tt.query)
+	}
+
+	_, _, err := ds.FTSearch("idx", "@missing:foo", SearchOptions{})
+	assert.Equal(t, newErrSearchUnknownField("missing"), err)
+	_, _, err = ds.FTSearch("idx", "@tags:{red", SearchOptions{})
+	assert.Equal(t, ErrSearchSyntax, err)
+	_, _, err = ds.FTSearch("idx", "@cost:[1]", SearchOptions{})
+	assert.Equal(t, ErrSearchSyntax, err)
+
+	// hashes are indexed again by HSET and HDEL, and removed by deletions
+	_, err = ds.HSet([]byte("item:1"), []byte("title"), []byte("Yellow pear"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"item:1"}, searchKeys(t, ds, "pear", SearchOptions{}))
+	assert.Equal(t, []string{"item:2"}, searchKeys(t, ds, "apple", SearchOptions{}))
+	_, err = ds.HDel([]byte("item:3"), []byte("tags"))
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"item:1"}, searchKeys(t, ds, "@tags:{red}", SearchOptions{}))
+	assert.Nil(t, ds.Del([]byte("item:4")))
+	assert.Equal(t, []string{"item:1"}, searchKeys(t, ds, "@tags:{fruit}", SearchOptions{}))
+	// a hash overwritten by another type is no longer matched
+	assert.Nil(t, ds.Set([]byte("item:2"), []byte("apple"), 0))
+	assert.Equal(t, []string{}, searchKeys(t, ds, "apple", SearchOptions{}))
+}
+
+func TestDS_FTSearchOptions(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	assert.Nil(t, ds.FTCreate("idx", testingSearchIndexOptions))
+	setSearchItem(t, ds, "item:1", "a", "x", "30")
+	setSearchItem(t, ds, "item:2", "b", "y", "-5")
+	setSearchItem(t, ds, "item:3", "c", "z", "100")
+	_, err := ds.HSet([]byte("item:4"), []byte("title"), []byte("d"))
+	assert.Nil(t, err)
+
+	assert.Equal(t, []string{"item:2", "item:1", "item:3", "item:4"}, searchKeys(t, ds, "*", SearchOptions{SortBy: "cost"}))
+	assert.Equal(t, []string{"item:3", "item:1", "item:2", "item:4"}, searchKeys(t, ds, "*", SearchOptions{SortBy: "cost", Descending: true}))
+	assert.Equal(t, []string{"item:4", "item:3"}, searchKeys(t, ds, "*", SearchOptions{SortBy: "title", Descending: true, Limit: 2}))
+	assert.Equal(t, []string{"item:2", "item:3"}, searchKeys(t, ds, "*", SearchOptions{Offset: 1, Limit: 2}))
+	_, _, err = ds.FTSearch("idx", "*", SearchOptions{SortBy: "missing"})
+	assert.Equal(t, newErrSearchSortBy("missing"), err)
+
+	total, docs, err := ds.FTSearch("idx", "*", SearchOptions{Offset: 3, Limit: 10, Return: []string{"cost", "title", "missing"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, []SearchDocument{{Key: []byte("item:4"), Fields: [][]byte{[]byte("title"), []byte("d")}}}, docs)
+	total, docs, err = ds.FTSearch("idx", "@cost:[30 30]", SearchOptions{Limit: 10, Return: []string{"cost"}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, [][]byte{[]byte("cost"), []byte("30")}, docs[0].Fields)
+	total, docs, err = ds.FTSearch("idx", "*", SearchOptions{Limit: 10, NoContent: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 4, total)
+	assert.Nil(t, docs[0].Fields)
+}
+
+func TestDS_FTCorruptedIndex(t *testing.T) {
+	idx := &searchIndex{name: "idx", version: 1, opts: testingSearchIndexOptions}
+	encoded := idx.encode()
+	decoded, err := decodeSearchIndex("idx", encoded)
+	assert.Nil(t, err)
+	assert.Equal(t, idx, decoded)
+	for i := 0; i < len(encoded); i++ {
+		_, err := decodeSearchIndex("idx", encoded[:i])
+		assert.Equal(t, ErrCorruptedSearchIndex, err)
+	}
+	_, err = decodeSearchIndex("idx", []byte("\xff\xff\xff\xff\xff\xff\xff\xff\x7f"))
+	assert.Equal(t, ErrCorruptedSearchIndex, err)
+
+	// definitions are reserved keys, and a corrupted definition fails to open the service instead of panicking
+	assert.True(t, IsReservedKey(searchDefinitionKey("idx")))
+	ds, _ := New(testingDBOptions)
+	assert.Nil(t, ds.db.Put(searchDefinitionKey("idx"), encoded[:len(encoded)-1]))
+	assert.Nil(t, ds.Close())
+	ds, err = New(testingDBOptions)
+	assert.Nil(t, ds)
+	assert.Equal(t, ErrCorruptedSearchIndex, err)
+	os.RemoveAll(testingDBOptions.Directory)
+}
+
+func TestDS_FTConcurrentWrites(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	// hashes written while the index is being created are indexed either by themselves or by the creation
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := ds.HSet([]byte(fmt.Sprintf("item:%02d", i)), []byte("tags"), []byte("new"))
+			assert.Nil(t, err)
+		}(i)
+	}
+	assert.Nil(t, ds.FTCreate("idx", testingSearchIndexOptions))
+	wg.Wait()
+	assert.Len(t, searchKeys(t, ds, "@tags:{new}", SearchOptions{Limit: 100}), 50)
+
+	// concurrent writes of a field leave the entry of the last value only
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := ds.HSet([]byte("item:00"), []byte("tags"), []byte(fmt.Sprintf("tag%d", i)))
+			assert.Nil(t, err)
+		}(i)
+	}
+	wg.Wait()
+	idx := ds.search.get("idx")
+	prefix := idx.fieldPrefix(idx.field("tags"))
+	var entries int
+	assert.Nil(t, ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		if string(encKey[len(encKey)-len("item:00"):]) == "item:00" {
+			entries++
+		}
+		return true, nil
+	}))
+	assert.Equal(t, 1, entries)
+}
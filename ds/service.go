@@ -1,10 +1,18 @@
 package ds
 
-import "github.com/saint-yellow/baradb"
+import (
+	"sync"
+
+	"github.com/saint-yellow/baradb"
+)
 
 // DS represents a Redis data structure service
 type DS struct {
-	db *baradb.DB // DB engine
+	db        *baradb.DB  // DB engine
+	reclaimer *reclaimer  // background worker deleting internal keys of unlinked collections
+	streams   *notifier   // notifies readers blocked on streams of new entries
+	mu        *sync.Mutex // serializes read-modify-write operations of strings
+	search    *searchIndexes
 }
 
 // New initializes a Redis data strucure
@@ -14,14 +22,24 @@ func New(opts baradb.DBOptions) (*DS, error) {
 		return nil, err
 	}
 	ds := &DS{
-		db: db,
+		db:        db,
+		reclaimer: newReclaimer(),
+		streams:   newNotifier(),
+		mu:        new(sync.Mutex),
+	}
+	if err := ds.loadSearchIndexes(); err != nil {
+		db.Close()
+		return nil, err
 	}
+	go ds.reclaimer.run(ds)
 	return ds, nil
 }
 
 // Close closes a Redis data structure service.
 //
-// Actually, it only closes the DB engine of the service.
+// It waits for pending internal keys of unlinked collections to be deleted,
+// and then closes the DB engine of the service.
 func (ds *DS) Close() error {
+	ds.reclaimer.stop()
 	return ds.db.Close()
 }
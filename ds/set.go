@@ -1,6 +1,7 @@
 package ds
 
 import (
+	"bytes"
 	"encoding/binary"
 
 	"github.com/saint-yellow/baradb"
@@ -137,15 +138,16 @@ func (ds *DS) SMembers(key []byte) ([][]byte, error) {
 
 	members := make([][]byte, 0)
 
+	// every member of the set starts with the key and the version
+	prefix := (&setInternalKey{key: key, version: md.version}).encode()
+	prefix = prefix[:len(key)+8]
+
 	opts := index.DefaultIteratorOptions
-	opts.Prefix = key
+	opts.Prefix = prefix
 	iter := ds.db.NewItrerator(opts)
-	for iter.Rewind(); iter.Valid(); iter.Next() {
-		encKey := iter.Key()
-		if len(encKey) == 0 || len(encKey) == len(key) {
-			continue
-		}
-		sk := decodeSetInternalKey(encKey)
+	defer iter.Close()
+	for iter.Seek(prefix); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		sk := decodeSetInternalKey(iter.Key())
 		members = append(members, sk.member)
 	}
 
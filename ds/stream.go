@@ -0,0 +1,498 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/saint-yellow/baradb/index"
+)
+
+// Streams are stored as a metadata along with internal keys of entries:
+//
+//	key + version + 'e' + milliseconds + sequence number -> field-value pairs
+//
+// Both parts of IDs are in big endian, so that entries are ordered by IDs.
+const streamEntryTag = 'e'
+
+// streamNodeMaxEntries is the number of entries trimmed at a time by approximate trimming,
+// the same as stream-node-max-entries of Redis
+const streamNodeMaxEntries = 100
+
+// StreamID is an ID of a stream entry
+type StreamID struct {
+	Ms  uint64 // Unix time in milliseconds
+	Seq uint64 // sequence number within the millisecond
+}
+
+var (
+	MinStreamID = StreamID{}
+	MaxStreamID = StreamID{Ms: math.MaxUint64, Seq: math.MaxUint64}
+)
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%d-%d", id.Ms, id.Seq)
+}
+
+// Less tells whether the ID is less than another one
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+
+// Next gets the smallest ID greater than the ID, ok is false if the ID is the maximum
+func (id StreamID) Next() (StreamID, bool) {
+	switch {
+	case id.Seq < math.MaxUint64:
+		return StreamID{Ms: id.Ms, Seq: id.Seq + 1}, true
+	case id.Ms < math.MaxUint64:
+		return StreamID{Ms: id.Ms + 1}, true
+	default:
+		return id, false
+	}
+}
+
+// Prev gets the greatest ID less than the ID, ok is false if the ID is the minimum
+func (id StreamID) Prev() (StreamID, bool) {
+	switch {
+	case id.Seq > 0:
+		return StreamID{Ms: id.Ms, Seq: id.Seq - 1}, true
+	case id.Ms > 0:
+		return StreamID{Ms: id.Ms - 1, Seq: math.MaxUint64}, true
+	default:
+		return id, false
+	}
+}
+
+// StreamEntry is an entry of a stream
+type StreamEntry struct {
+	ID     StreamID
+	Fields [][]byte // fields and values stored alternately
+}
+
+// StreamEntries are entries read from a stream
+type StreamEntries struct {
+	Key     []byte
+	Entries []StreamEntry
+}
+
+// streamMetadata is a metadata of a stream, which begins with the same fields as other collections
+type streamMetadata struct {
+	expire       int64
+	version      int64
+	size         uint32
+	lastID       StreamID // the ID of the last added entry, even if it has been deleted
+	maxDeletedID StreamID // the maximum ID of entries deleted by XDEL
+	entriesAdded uint64   // the number of entries ever added
+}
+
+func (md *streamMetadata) encode() []byte {
+	buffer := make([]byte, 1+binary.MaxVarintLen64*8)
+	buffer[0] = Stream
+	index := 1
+	index += binary.PutVarint(buffer[index:], md.expire)
+	index += binary.PutVarint(buffer[index:], md.version)
+	index += binary.PutVarint(buffer[index:], int64(md.size))
+	index += binary.PutUvarint(buffer[index:], md.lastID.Ms)
+	index += binary.PutUvarint(buffer[index:], md.lastID.Seq)
+	index += binary.PutUvarint(buffer[index:], md.maxDeletedID.Ms)
+	index += binary.PutUvarint(buffer[index:], md.maxDeletedID.Seq)
+	index += binary.PutUvarint(buffer[index:], md.entriesAdded)
+	return buffer[:index]
+}
+
+func decodeStreamMetadata(buffer []byte) *streamMetadata {
+	md := &streamMetadata{}
+	index := 1
+	var n int
+	var size int64
+	md.expire, n = binary.Varint(buffer[index:])
+	index += n
+	md.version, n = binary.Varint(buffer[index:])
+	index += n
+	size, n = binary.Varint(buffer[index:])
+	index += n
+	md.size = uint32(size)
+	fields := []*uint64{&md.lastID.Ms, &md.lastID.Seq, &md.maxDeletedID.Ms, &md.maxDeletedID.Seq, &md.entriesAdded}
+	for _, field := range fields {
+		*field, n = binary.Uvarint(buffer[index:])
+		index += n
+	}
+	return md
+}
+
+// getStreamMetadata gets the metadata of a stream, which is nil if the key does not exist
+func (ds *DS) getStreamMetadata(key []byte) (*streamMetadata, error) {
+	value, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value[0] != Stream {
+		return nil, ErrWrongTypeOperation
+	}
+	return decodeStreamMetadata(value), nil
+}
+
+func streamEntryKey(key []byte, version int64, id StreamID) []byte {
+	return appendStreamID(append(internalKeyPrefix(key, version), streamEntryTag), id)
+}
+
+// appendStreamID appends an ID in big endian, so that keys ending with IDs are ordered by IDs
+func appendStreamID(buffer []byte, id StreamID) []byte {
+	buffer = binary.BigEndian.AppendUint64(buffer, id.Ms)
+	return binary.BigEndian.AppendUint64(buffer, id.Seq)
+}
+
+// decodeStreamID decodes an ID at the end of an internal key
+func decodeStreamID(encKey []byte) StreamID {
+	n := len(encKey)
+	return StreamID{
+		Ms:  binary.BigEndian.Uint64(encKey[n-16:]),
+		Seq: binary.BigEndian.Uint64(encKey[n-8:]),
+	}
+}
+
+func encodeStreamFields(fields [][]byte) []byte {
+	size := binary.MaxVarintLen64
+	for _, field := range fields {
+		size += binary.MaxVarintLen64 + len(field)
+	}
+	buffer := make([]byte, size)
+	index := binary.PutUvarint(buffer, uint64(len(fields)))
+	for _, field := range fields {
+		index += binary.PutUvarint(buffer[index:], uint64(len(field)))
+		index += copy(buffer[index:], field)
+	}
+	return buffer[:index]
+}
+
+func decodeStreamFields(buffer []byte) [][]byte {
+	count, index := binary.Uvarint(buffer)
+	fields := make([][]byte, count)
+	for i := range fields {
+		length, n := binary.Uvarint(buffer[index:])
+		index += n
+		fields[i] = buffer[index : index+int(length)]
+		index += int(length)
+	}
+	return fields
+}
+
+// XAddOptions are options of XADD
+type XAddOptions struct {
+	ID         StreamID
+	AutoID     bool // generates the whole ID, such as *
+	AutoSeq    bool // generates the sequence number of ID.Ms, such as 1526919030474-*
+	NoMkStream bool // does not create the stream if it does not exist
+	Trim       *StreamTrim
+}
+
+// StreamTrim is a strategy of trimming a stream
+type StreamTrim struct {
+	MaxLen  int64    // keeps at most MaxLen entries if ByMinID is false
+	MinID   StreamID // evicts entries with IDs less than MinID if ByMinID is true
+	ByMinID bool
+	Approx  bool  // evicts entries in whole nodes of streamNodeMaxEntries entries, which is more efficient
+	Limit   int64 // the maximum number of entries evicted by approximate trimming, 0 if there is no limit
+}
+
+// nextID gets the ID of a new entry
+func (md *streamMetadata) nextID(opts XAddOptions) (StreamID, error) {
+	id := opts.ID
+	switch {
+	case opts.AutoID:
+		now := uint64(time.Now().UnixMilli())
+		if now > md.lastID.Ms {
+			return StreamID{Ms: now}, nil
+		}
+		next, ok := md.lastID.Next()
+		if !ok {
+			return id, ErrStreamIDExhausted
+		}
+		return next, nil
+	case opts.AutoSeq && id.Ms == md.lastID.Ms:
+		if md.lastID.Seq == math.MaxUint64 {
+			return id, ErrStreamIDTooSmall
+		}
+		id.Seq = md.lastID.Seq + 1
+	case opts.AutoSeq:
+		id.Seq = 0
+	}
+
+	if id == MinStreamID {
+		return id, ErrStreamIDZero
+	}
+	if !md.lastID.Less(id) {
+		return id, ErrStreamIDTooSmall
+	}
+	return id, nil
+}
+
+// XAdd redis XADD
+//
+// It returns the ID of the added entry, ok is false if the stream does not exist while opts.NoMkStream is true.
+func (ds *DS) XAdd(key []byte, opts XAddOptions, fields ...[]byte) (StreamID, bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, err := ds.getStreamMetadata(key)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	if md == nil {
+		if opts.NoMkStream {
+			return StreamID{}, false, nil
+		}
+		md = &streamMetadata{version: time.Now().UnixNano()}
+	}
+
+	id, err := md.nextID(opts)
+	if err != nil {
+		return StreamID{}, false, err
+	}
+	md.lastID = id
+	md.size++
+	md.entriesAdded++
+
+	wb := ds.db.NewWriteBatch(baradb.DefaultWriteBatchOptions)
+	if err := wb.Put(streamEntryKey(key, md.version, id), encodeStreamFields(fields)); err != nil {
+		return StreamID{}, false, err
+	}
+	if err := wb.Put(key, md.encode()); err != nil {
+		return StreamID{}, false, err
+	}
+	if err := wb.Commit(); err != nil {
+		return StreamID{}, false, err
+	}
+
+	if opts.Trim != nil {
+		if _, err := ds.trimStream(key, md, *opts.Trim); err != nil {
+			return StreamID{}, false, err
+		}
+	}
+	ds.streams.notify(key)
+	return id, true, nil
+}
+
+// XLen redis XLEN
+func (ds *DS) XLen(key []byte) (uint32, error) {
+	md, err := ds.getStreamMetadata(key)
+	if err != nil || md == nil {
+		return 0, err
+	}
+	return md.size, nil
+}
+
+// XRange redis XRANGE and XREVRANGE
+//
+// It gets entries with IDs between start and end, both inclusive.
+// Entries are in descending order of IDs if reverse is true. count is 0 if there is no limit.
+func (ds *DS) XRange(key []byte, start, end StreamID, count int, reverse bool) ([]StreamEntry, error) {
+	md, err := ds.getStreamMetadata(key)
+	if err != nil || md == nil || end.Less(start) {
+		return nil, err
+	}
+	return ds.streamRange(key, md, start, end, count, reverse)
+}
+
+func (ds *DS) streamRange(key []byte, md *streamMetadata, start, end StreamID, count int, reverse bool) ([]StreamEntry, error) {
+	prefix := append(internalKeyPrefix(key, md.version), streamEntryTag)
+	opts := index.DefaultIteratorOptions
+	opts.Prefix = prefix
+	opts.Reverse = reverse
+	iter := ds.db.NewItrerator(opts)
+	defer iter.Close()
+
+	seek := streamEntryKey(key, md.version, start)
+	if reverse {
+		seek = streamEntryKey(key, md.version, end)
+	}
+	var entries []StreamEntry
+	for iter.Seek(seek); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		if count > 0 && len(entries) == count {
+			break
+		}
+		id := decodeStreamID(iter.Key())
+		if (!reverse && end.Less(id)) || (reverse && id.Less(start)) {
+			break
+		}
+		value, err := iter.Value()
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, StreamEntry{ID: id, Fields: decodeStreamFields(value)})
+	}
+	return entries, nil
+}
+
+// XDel redis XDEL
+//
+// It returns the number of deleted entries.
+func (ds *DS) XDel(key []byte, ids ...StreamID) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, err := ds.getStreamMetadata(key)
+	if err != nil || md == nil {
+		return 0, err
+	}
+
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = len(ids) + 1
+	wb := ds.db.NewWriteBatch(opts)
+	deleted := make(map[StreamID]bool)
+	for _, id := range ids {
+		encKey := streamEntryKey(key, md.version, id)
+		if deleted[id] {
+			continue
+		}
+		if _, err := ds.db.Get(encKey); err == baradb.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if err := wb.Delete(encKey); err != nil {
+			return 0, err
+		}
+		deleted[id] = true
+		md.size--
+		if md.maxDeletedID.Less(id) {
+			md.maxDeletedID = id
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	if err := wb.Put(key, md.encode()); err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
+
+// XTrim redis XTRIM
+//
+// It returns the number of evicted entries.
+func (ds *DS) XTrim(key []byte, trim StreamTrim) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, err := ds.getStreamMetadata(key)
+	if err != nil || md == nil {
+		return 0, err
+	}
+	return ds.trimStream(key, md, trim)
+}
+
+// trimStream evicts the oldest entries of a stream by the strategy
+func (ds *DS) trimStream(key []byte, md *streamMetadata, trim StreamTrim) (int, error) {
+	var ids []StreamID
+	prefix := append(internalKeyPrefix(key, md.version), streamEntryTag)
+	opts := index.DefaultIteratorOptions
+	opts.Prefix = prefix
+	iter := ds.db.NewItrerator(opts)
+	for iter.Seek(prefix); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		if !trim.ByMinID && int64(md.size)-int64(len(ids)) <= trim.MaxLen {
+			break
+		}
+		id := decodeStreamID(iter.Key())
+		if trim.ByMinID && !id.Less(trim.MinID) {
+			break
+		}
+		ids = append(ids, id)
+	}
+	iter.Close()
+
+	if trim.Approx {
+		n := int64(len(ids))
+		if trim.Limit > 0 && n > trim.Limit {
+			n = trim.Limit
+		}
+		ids = ids[:n-n%streamNodeMaxEntries]
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	wbOpts := baradb.DefaultWriteBatchOptions
+	wbOpts.MaxBatchNumber = len(ids) + 1
+	wb := ds.db.NewWriteBatch(wbOpts)
+	for _, id := range ids {
+		if err := wb.Delete(streamEntryKey(key, md.version, id)); err != nil {
+			return 0, err
+		}
+	}
+	md.size -= uint32(len(ids))
+	if err := wb.Put(key, md.encode()); err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(ids), nil
+}
+
+// StreamLastID gets the ID of the last added entry of a stream, which is 0-0 if the stream does not exist
+func (ds *DS) StreamLastID(key []byte) (StreamID, error) {
+	md, err := ds.getStreamMetadata(key)
+	if err != nil || md == nil {
+		return StreamID{}, err
+	}
+	return md.lastID, nil
+}
+
+// XRead redis XREAD
+//
+// It reads entries with IDs greater than the given IDs from streams. count is 0 if there is no limit.
+// Streams without such entries are omitted.
+func (ds *DS) XRead(keys [][]byte, ids []StreamID, count int) ([]StreamEntries, error) {
+	var results []StreamEntries
+	for i, key := range keys {
+		start, ok := ids[i].Next()
+		if !ok {
+			continue
+		}
+		entries, err := ds.XRange(key, start, MaxStreamID, count, false)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 {
+			results = append(results, StreamEntries{Key: key, Entries: entries})
+		}
+	}
+	return results, nil
+}
+
+// XReadBlock redis XREAD BLOCK
+//
+// It is the same as XRead, except that it waits for new entries if there is nothing to read.
+// It waits forever if timeout is 0, and returns nil if it times out.
+func (ds *DS) XReadBlock(keys [][]byte, ids []StreamID, count int, timeout time.Duration) ([]StreamEntries, error) {
+	signal, stop := ds.streams.watch(keys...)
+	defer stop()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		results, err := ds.XRead(keys, ids, count)
+		if err != nil || len(results) > 0 {
+			return results, err
+		}
+		select {
+		case <-signal:
+		case <-expired:
+			return nil, nil
+		}
+	}
+}
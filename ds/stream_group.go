@@ -0,0 +1,1155 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/saint-yellow/baradb/index"
+)
+
+// Consumer groups are stored as internal keys of streams besides entries:
+//
+//	key + version + 'g' + group -> last delivered ID + number of entries read
+//	key + version + 'c' + group length + group + consumer -> seen time + active time
+//	key + version + 'p' + group length + group + ID -> delivery time + delivery count + consumer
+//
+// Group lengths are 4 bytes in big endian, so that internal keys of a group never mix with another one's.
+const (
+	streamGroupTag    = 'g'
+	streamConsumerTag = 'c'
+	streamPendingTag  = 'p'
+)
+
+// StreamGroup is a consumer group of a stream
+type StreamGroup struct {
+	Name            []byte
+	Consumers       int
+	Pending         int // the number of pending entries
+	LastDeliveredID StreamID
+	EntriesRead     int64 // the logical number of entries read by the group, -1 if it is unknown
+	Lag             int64 // the number of entries not delivered to the group yet, -1 if it is unknown
+
+	PendingEntries  []StreamPendingEntry // only filled by XInfoStream with full
+	ConsumerDetails []StreamConsumer     // only filled by XInfoStream with full
+}
+
+// StreamConsumer is a consumer of a consumer group
+type StreamConsumer struct {
+	Name       []byte
+	Pending    int       // the number of pending entries
+	SeenTime   time.Time // the last time the consumer attempted to read or claim entries
+	ActiveTime time.Time // the last time the consumer read or claimed entries, zero if it never did
+
+	PendingEntries []StreamPendingEntry // only filled by XInfoStream with full
+}
+
+// StreamPendingEntry is an entry delivered to a consumer but not acknowledged yet
+type StreamPendingEntry struct {
+	ID            StreamID
+	Consumer      []byte
+	DeliveryTime  time.Time
+	DeliveryCount uint64
+}
+
+// StreamPendingSummary is a summary of pending entries of a consumer group
+type StreamPendingSummary struct {
+	Count     int
+	MinID     StreamID
+	MaxID     StreamID
+	Consumers []StreamConsumer // consumers with pending entries, with only names and numbers of pending entries
+}
+
+// StreamInfo is information of a stream
+type StreamInfo struct {
+	Length          uint32
+	LastGeneratedID StreamID
+	MaxDeletedID    StreamID
+	EntriesAdded    uint64
+	FirstID         StreamID // the ID of the first entry, 0-0 if the stream is empty
+	Groups          int
+	FirstEntry      *StreamEntry // nil if the stream is empty, not filled with full
+	LastEntry       *StreamEntry // nil if the stream is empty, not filled with full
+
+	Entries      []StreamEntry // only filled with full
+	GroupDetails []StreamGroup // only filled with full
+}
+
+// streamGroup is the value of a consumer group
+type streamGroup struct {
+	lastID      StreamID
+	entriesRead int64
+}
+
+func (g *streamGroup) encode() []byte {
+	buffer := make([]byte, binary.MaxVarintLen64*3)
+	index := binary.PutUvarint(buffer, g.lastID.Ms)
+	index += binary.PutUvarint(buffer[index:], g.lastID.Seq)
+	index += binary.PutVarint(buffer[index:], g.entriesRead)
+	return buffer[:index]
+}
+
+func decodeStreamGroup(buffer []byte) streamGroup {
+	var g streamGroup
+	var n, index int
+	g.lastID.Ms, n = binary.Uvarint(buffer)
+	index += n
+	g.lastID.Seq, n = binary.Uvarint(buffer[index:])
+	index += n
+	g.entriesRead, _ = binary.Varint(buffer[index:])
+	return g
+}
+
+// streamConsumer is the value of a consumer, times are Unix time in milliseconds
+type streamConsumer struct {
+	seenTime   int64
+	activeTime int64 // -1 if the consumer never read or claimed entries
+}
+
+func (c *streamConsumer) encode() []byte {
+	buffer := make([]byte, binary.MaxVarintLen64*2)
+	index := binary.PutVarint(buffer, c.seenTime)
+	index += binary.PutVarint(buffer[index:], c.activeTime)
+	return buffer[:index]
+}
+
+func decodeStreamConsumer(buffer []byte) streamConsumer {
+	var c streamConsumer
+	var n int
+	c.seenTime, n = binary.Varint(buffer)
+	c.activeTime, _ = binary.Varint(buffer[n:])
+	return c
+}
+
+func (c *streamConsumer) toConsumer(name []byte) StreamConsumer {
+	consumer := StreamConsumer{Name: name, SeenTime: time.UnixMilli(c.seenTime)}
+	if c.activeTime >= 0 {
+		consumer.ActiveTime = time.UnixMilli(c.activeTime)
+	}
+	return consumer
+}
+
+// streamPending is the value of a pending entry, the delivery time is Unix time in milliseconds
+type streamPending struct {
+	deliveryTime  int64
+	deliveryCount uint64
+	consumer      []byte
+}
+
+func (p *streamPending) encode() []byte {
+	buffer := make([]byte, binary.MaxVarintLen64*2+len(p.consumer))
+	index := binary.PutVarint(buffer, p.deliveryTime)
+	index += binary.PutUvarint(buffer[index:], p.deliveryCount)
+	index += copy(buffer[index:], p.consumer)
+	return buffer[:index]
+}
+
+func decodeStreamPending(buffer []byte) streamPending {
+	var p streamPending
+	var n, index int
+	p.deliveryTime, n = binary.Varint(buffer)
+	index += n
+	p.deliveryCount, n = binary.Uvarint(buffer[index:])
+	index += n
+	p.consumer = bytes.Clone(buffer[index:])
+	return p
+}
+
+func (p *streamPending) toPendingEntry(id StreamID) StreamPendingEntry {
+	return StreamPendingEntry{
+		ID:            id,
+		Consumer:      p.consumer,
+		DeliveryTime:  time.UnixMilli(p.deliveryTime),
+		DeliveryCount: p.deliveryCount,
+	}
+}
+
+// consumerGroup is a consumer group along with the metadata of its stream
+type consumerGroup struct {
+	streamGroup
+	key     []byte
+	name    []byte
+	md      *streamMetadata
+	firstID *StreamID // the ID of the first entry of the stream, which is loaded lazily
+}
+
+func (cg *consumerGroup) groupKey() []byte {
+	return append(append(internalKeyPrefix(cg.key, cg.md.version), streamGroupTag), cg.name...)
+}
+
+// prefix gets the prefix of internal keys of consumers or pending entries of the group
+func (cg *consumerGroup) prefix(tag byte) []byte {
+	prefix := append(internalKeyPrefix(cg.key, cg.md.version), tag)
+	prefix = binary.BigEndian.AppendUint32(prefix, uint32(len(cg.name)))
+	return append(prefix, cg.name...)
+}
+
+func (cg *consumerGroup) consumerKey(consumer []byte) []byte {
+	return append(cg.prefix(streamConsumerTag), consumer...)
+}
+
+func (cg *consumerGroup) pendingKey(id StreamID) []byte {
+	return appendStreamID(cg.prefix(streamPendingTag), id)
+}
+
+// getConsumerGroup gets a consumer group of a stream.
+//
+// The metadata is nil if the stream does not exist, and the group is nil if either the stream or the group does not exist.
+func (ds *DS) getConsumerGroup(key, group []byte) (*streamMetadata, *consumerGroup, error) {
+	md, err := ds.getStreamMetadata(key)
+	if err != nil || md == nil {
+		return nil, nil, err
+	}
+	cg := &consumerGroup{key: key, name: group, md: md}
+	value, err := ds.db.Get(cg.groupKey())
+	if err == baradb.ErrKeyNotFound {
+		return md, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	cg.streamGroup = decodeStreamGroup(value)
+	return md, cg, nil
+}
+
+// consumerGroups gets all consumer groups of a stream ordered by names
+func (ds *DS) consumerGroups(key []byte, md *streamMetadata) ([]*consumerGroup, error) {
+	prefix := append(internalKeyPrefix(key, md.version), streamGroupTag)
+	var groups []*consumerGroup
+	err := ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		groups = append(groups, &consumerGroup{
+			streamGroup: decodeStreamGroup(value),
+			key:         key,
+			name:        bytes.Clone(encKey[len(prefix):]),
+			md:          md,
+		})
+		return true, nil
+	})
+	return groups, err
+}
+
+// scanInternalKeys calls fn with internal keys starting with a prefix in order from seek, until fn returns false
+func (ds *DS) scanInternalKeys(prefix, seek []byte, fn func(encKey, value []byte) (bool, error)) error {
+	opts := index.DefaultIteratorOptions
+	opts.Prefix = prefix
+	iter := ds.db.NewItrerator(opts)
+	defer iter.Close()
+	for iter.Seek(seek); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		value, err := iter.Value()
+		if err != nil {
+			return err
+		}
+		if ok, err := fn(iter.Key(), value); err != nil || !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// streamFirstID gets the ID of the first entry of a stream, which is 0-0 if the stream is empty
+func (ds *DS) streamFirstID(cg *consumerGroup) (StreamID, error) {
+	if cg.firstID != nil {
+		return *cg.firstID, nil
+	}
+	entries, err := ds.streamRange(cg.key, cg.md, MinStreamID, MaxStreamID, 1, false)
+	if err != nil {
+		return StreamID{}, err
+	}
+	var id StreamID
+	if len(entries) > 0 {
+		id = entries[0].ID
+	}
+	cg.firstID = &id
+	return id, nil
+}
+
+// hasTombstones tells whether entries with IDs not less than start have been deleted by XDEL
+func (md *streamMetadata) hasTombstones(start StreamID) bool {
+	return md.size > 0 && md.maxDeletedID != MinStreamID && !md.maxDeletedID.Less(start)
+}
+
+// estimateEntriesRead estimates the logical number of entries up to an ID, which is -1 if it can not be estimated
+func (md *streamMetadata) estimateEntriesRead(firstID, id StreamID) int64 {
+	switch {
+	case md.entriesAdded == 0:
+		return 0
+	case md.size == 0 && !md.lastID.Less(id), id == md.lastID:
+		return int64(md.entriesAdded)
+	case md.lastID.Less(id):
+		return -1
+	}
+
+	// the estimation is exact only if no entry has been deleted since the first one
+	if md.maxDeletedID == MinStreamID || md.maxDeletedID.Less(firstID) {
+		switch {
+		case id.Less(firstID):
+			return int64(md.entriesAdded) - int64(md.size)
+		case id == firstID:
+			return int64(md.entriesAdded) - int64(md.size) + 1
+		}
+	}
+	return -1
+}
+
+// lag gets the number of entries not delivered to the group yet, which is -1 if it is unknown
+func (ds *DS) lag(cg *consumerGroup) (int64, error) {
+	if cg.md.entriesAdded == 0 {
+		return 0, nil
+	}
+	if cg.entriesRead >= 0 && !cg.md.hasTombstones(cg.lastID) {
+		return int64(cg.md.entriesAdded) - cg.entriesRead, nil
+	}
+	firstID, err := ds.streamFirstID(cg)
+	if err != nil {
+		return 0, err
+	}
+	read := cg.md.estimateEntriesRead(firstID, cg.lastID)
+	if read < 0 {
+		return -1, nil
+	}
+	return int64(cg.md.entriesAdded) - read, nil
+}
+
+// deliver moves the last delivered ID of the group forward to an ID
+func (ds *DS) deliver(cg *consumerGroup, id StreamID) error {
+	if !cg.lastID.Less(id) {
+		return nil
+	}
+	switch {
+	case cg.entriesRead >= 0 && !cg.md.hasTombstones(id):
+		cg.entriesRead++
+	case cg.md.entriesAdded > 0:
+		firstID, err := ds.streamFirstID(cg)
+		if err != nil {
+			return err
+		}
+		cg.entriesRead = cg.md.estimateEntriesRead(firstID, id)
+	}
+	cg.lastID = id
+	return nil
+}
+
+// XGroupOptions are options of XGROUP CREATE and XGROUP SETID
+type XGroupOptions struct {
+	ID          StreamID
+	Latest      bool  // uses the last ID of the stream instead of ID, such as $
+	MkStream    bool  // creates an empty stream if the stream does not exist, only for XGROUP CREATE
+	EntriesRead int64 // the logical number of entries read by the group, -1 if it is unknown
+}
+
+// XGroupCreate redis XGROUP CREATE
+func (ds *DS) XGroupCreate(key, group []byte, opts XGroupOptions) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, cg, err := ds.getConsumerGroup(key, group)
+	if err != nil {
+		return err
+	}
+	if cg != nil {
+		return ErrStreamGroupExists
+	}
+	created := md == nil
+	if created {
+		if !opts.MkStream {
+			return ErrStreamKeyRequired
+		}
+		md = &streamMetadata{version: time.Now().UnixNano()}
+	}
+
+	cg = &consumerGroup{
+		streamGroup: streamGroup{lastID: opts.ID, entriesRead: opts.EntriesRead},
+		key:         key,
+		name:        group,
+		md:          md,
+	}
+	if opts.Latest {
+		cg.lastID = md.lastID
+	}
+	wb := ds.db.NewWriteBatch(baradb.DefaultWriteBatchOptions)
+	if err := wb.Put(cg.groupKey(), cg.encode()); err != nil {
+		return err
+	}
+	if created {
+		if err := wb.Put(key, md.encode()); err != nil {
+			return err
+		}
+	}
+	return wb.Commit()
+}
+
+// XGroupSetID redis XGROUP SETID
+func (ds *DS) XGroupSetID(key, group []byte, opts XGroupOptions) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, cg, err := ds.getConsumerGroup(key, group)
+	if err != nil {
+		return err
+	}
+	if md == nil {
+		return ErrStreamKeyRequired
+	}
+	if cg == nil {
+		return newErrNoGroupForKey(key, group)
+	}
+	cg.lastID = opts.ID
+	if opts.Latest {
+		cg.lastID = md.lastID
+	}
+	cg.entriesRead = opts.EntriesRead
+	return ds.db.Put(cg.groupKey(), cg.encode())
+}
+
+// XGroupDestroy redis XGROUP DESTROY
+//
+// It returns false if the group does not exist.
+func (ds *DS) XGroupDestroy(key, group []byte) (bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, cg, err := ds.getConsumerGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	if md == nil {
+		return false, ErrStreamKeyRequired
+	}
+	if cg == nil {
+		return false, nil
+	}
+
+	if err := ds.deleteInternalKeys(cg.prefix(streamPendingTag)); err != nil {
+		return false, err
+	}
+	if err := ds.deleteInternalKeys(cg.prefix(streamConsumerTag)); err != nil {
+		return false, err
+	}
+	if err := ds.db.Delete(cg.groupKey()); err != nil {
+		return false, err
+	}
+	// wakes up consumers blocked on the group
+	ds.streams.notify(key)
+	return true, nil
+}
+
+// XGroupCreateConsumer redis XGROUP CREATECONSUMER
+//
+// It returns false if the consumer already exists.
+func (ds *DS) XGroupCreateConsumer(key, group, consumer []byte) (bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	cg, err := ds.getExistingGroup(key, group)
+	if err != nil {
+		return false, err
+	}
+	if _, err := ds.db.Get(cg.consumerKey(consumer)); err == nil {
+		return false, nil
+	} else if err != baradb.ErrKeyNotFound {
+		return false, err
+	}
+
+	c := streamConsumer{seenTime: time.Now().UnixMilli(), activeTime: -1}
+	if err := ds.db.Put(cg.consumerKey(consumer), c.encode()); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// XGroupDelConsumer redis XGROUP DELCONSUMER
+//
+// It returns the number of pending entries of the consumer, which are deleted along with the consumer.
+func (ds *DS) XGroupDelConsumer(key, group, consumer []byte) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	cg, err := ds.getExistingGroup(key, group)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := ds.db.Get(cg.consumerKey(consumer)); err == baradb.ErrKeyNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var pendingKeys [][]byte
+	prefix := cg.prefix(streamPendingTag)
+	err = ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		if p := decodeStreamPending(value); bytes.Equal(p.consumer, consumer) {
+			pendingKeys = append(pendingKeys, bytes.Clone(encKey))
+		}
+		return true, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = len(pendingKeys) + 1
+	wb := ds.db.NewWriteBatch(opts)
+	for _, encKey := range pendingKeys {
+		if err := wb.Delete(encKey); err != nil {
+			return 0, err
+		}
+	}
+	if err := wb.Delete(cg.consumerKey(consumer)); err != nil {
+		return 0, err
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(pendingKeys), nil
+}
+
+// getExistingGroup gets a consumer group of a stream, both of which must exist, such as what XGROUP and XINFO require
+func (ds *DS) getExistingGroup(key, group []byte) (*consumerGroup, error) {
+	md, cg, err := ds.getConsumerGroup(key, group)
+	switch {
+	case err != nil:
+		return nil, err
+	case md == nil:
+		return nil, ErrStreamKeyRequired
+	case cg == nil:
+		return nil, newErrNoGroupForKey(key, group)
+	default:
+		return cg, nil
+	}
+}
+
+// getConsumer gets a consumer of a group, which is a new one if it does not exist
+func (ds *DS) getConsumer(cg *consumerGroup, consumer []byte) (streamConsumer, bool, error) {
+	value, err := ds.db.Get(cg.consumerKey(consumer))
+	if err == baradb.ErrKeyNotFound {
+		return streamConsumer{activeTime: -1}, false, nil
+	}
+	if err != nil {
+		return streamConsumer{}, false, err
+	}
+	return decodeStreamConsumer(value), true, nil
+}
+
+// streamEntry gets the fields of an entry, which are nil if the entry does not exist
+func (ds *DS) streamEntry(key []byte, md *streamMetadata, id StreamID) ([][]byte, error) {
+	value, err := ds.db.Get(streamEntryKey(key, md.version, id))
+	if err == baradb.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return decodeStreamFields(value), nil
+}
+
+// XReadGroup redis XREADGROUP
+//
+// An ID of nil reads new entries never delivered to the group, such as >, which are added to the pending entries of the consumer
+// unless noAck is true. Other IDs read pending entries of the consumer with IDs greater than them,
+// and entries deleted from the stream are read with nil fields.
+// count is 0 if there is no limit. Streams without new entries are omitted.
+func (ds *DS) XReadGroup(group, consumer []byte, keys [][]byte, ids []*StreamID, count int, noAck bool) ([]StreamEntries, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	groups := make([]*consumerGroup, len(keys))
+	for i, key := range keys {
+		_, cg, err := ds.getConsumerGroup(key, group)
+		if err != nil {
+			return nil, err
+		}
+		if cg == nil {
+			message := fmt.Sprintf("No such key '%s' or consumer group '%s' in XREADGROUP with GROUP option", key, group)
+			return nil, newError(CodeNoGroup, message)
+		}
+		groups[i] = cg
+	}
+
+	var results []StreamEntries
+	for i, cg := range groups {
+		entries, err := ds.readGroup(cg, consumer, ids[i], count, noAck)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) > 0 || ids[i] != nil {
+			results = append(results, StreamEntries{Key: cg.key, Entries: entries})
+		}
+	}
+	return results, nil
+}
+
+func (ds *DS) readGroup(cg *consumerGroup, consumer []byte, id *StreamID, count int, noAck bool) ([]StreamEntry, error) {
+	c, _, err := ds.getConsumer(cg, consumer)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UnixMilli()
+	c.seenTime = now
+
+	var entries []StreamEntry
+	if id != nil {
+		entries, err = ds.consumerPendingEntries(cg, consumer, *id, count)
+	} else if start, ok := cg.lastID.Next(); ok {
+		entries, err = ds.streamRange(cg.key, cg.md, start, MaxStreamID, count, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = len(entries) + 2
+	wb := ds.db.NewWriteBatch(opts)
+	if id == nil && len(entries) > 0 {
+		c.activeTime = now
+		for _, entry := range entries {
+			if err := ds.deliver(cg, entry.ID); err != nil {
+				return nil, err
+			}
+			if noAck {
+				continue
+			}
+			p := streamPending{deliveryTime: now, deliveryCount: 1, consumer: consumer}
+			if err := wb.Put(cg.pendingKey(entry.ID), p.encode()); err != nil {
+				return nil, err
+			}
+		}
+		if err := wb.Put(cg.groupKey(), cg.encode()); err != nil {
+			return nil, err
+		}
+	}
+	if err := wb.Put(cg.consumerKey(consumer), c.encode()); err != nil {
+		return nil, err
+	}
+	if err := wb.Commit(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// consumerPendingEntries gets pending entries of a consumer with IDs greater than an ID
+func (ds *DS) consumerPendingEntries(cg *consumerGroup, consumer []byte, after StreamID, count int) ([]StreamEntry, error) {
+	start, ok := after.Next()
+	if !ok {
+		return nil, nil
+	}
+	var ids []StreamID
+	err := ds.scanInternalKeys(cg.prefix(streamPendingTag), cg.pendingKey(start), func(encKey, value []byte) (bool, error) {
+		if p := decodeStreamPending(value); bytes.Equal(p.consumer, consumer) {
+			ids = append(ids, decodeStreamID(encKey))
+		}
+		return count <= 0 || len(ids) < count, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]StreamEntry, len(ids))
+	for i, id := range ids {
+		fields, err := ds.streamEntry(cg.key, cg.md, id)
+		if err != nil {
+			return nil, err
+		}
+		entries[i] = StreamEntry{ID: id, Fields: fields}
+	}
+	return entries, nil
+}
+
+// XReadGroupBlock redis XREADGROUP BLOCK
+//
+// It is the same as XReadGroup, except that it waits for new entries if there is nothing to read.
+// It waits forever if timeout is 0, and returns nil if it times out.
+func (ds *DS) XReadGroupBlock(group, consumer []byte, keys [][]byte, ids []*StreamID, count int, noAck bool, timeout time.Duration) ([]StreamEntries, error) {
+	signal, stop := ds.streams.watch(keys...)
+	defer stop()
+
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		results, err := ds.XReadGroup(group, consumer, keys, ids, count, noAck)
+		if err != nil || len(results) > 0 {
+			return results, err
+		}
+		select {
+		case <-signal:
+		case <-expired:
+			return nil, nil
+		}
+	}
+}
+
+// XAck redis XACK
+//
+// It returns the number of acknowledged entries, which are removed from pending entries of the group.
+func (ds *DS) XAck(key, group []byte, ids ...StreamID) (int, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	_, cg, err := ds.getConsumerGroup(key, group)
+	if err != nil || cg == nil {
+		return 0, err
+	}
+
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = len(ids)
+	wb := ds.db.NewWriteBatch(opts)
+	acked := make(map[StreamID]bool)
+	for _, id := range ids {
+		if acked[id] {
+			continue
+		}
+		if _, err := ds.db.Get(cg.pendingKey(id)); err == baradb.ErrKeyNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if err := wb.Delete(cg.pendingKey(id)); err != nil {
+			return 0, err
+		}
+		acked[id] = true
+	}
+	if len(acked) == 0 {
+		return 0, nil
+	}
+	if err := wb.Commit(); err != nil {
+		return 0, err
+	}
+	return len(acked), nil
+}
+
+// XPending redis XPENDING in the summary form
+func (ds *DS) XPending(key, group []byte) (*StreamPendingSummary, error) {
+	_, cg, err := ds.getConsumerGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	if cg == nil {
+		return nil, newErrNoGroup(key, group)
+	}
+
+	summary := &StreamPendingSummary{}
+	counts := make(map[string]int)
+	prefix := cg.prefix(streamPendingTag)
+	err = ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		id := decodeStreamID(encKey)
+		if summary.Count == 0 {
+			summary.MinID = id
+		}
+		summary.MaxID = id
+		summary.Count++
+		counts[string(decodeStreamPending(value).consumer)]++
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for name, n := range counts {
+		summary.Consumers = append(summary.Consumers, StreamConsumer{Name: []byte(name), Pending: n})
+	}
+	sort.Slice(summary.Consumers, func(i, j int) bool {
+		return bytes.Compare(summary.Consumers[i].Name, summary.Consumers[j].Name) < 0
+	})
+	return summary, nil
+}
+
+// XPendingOptions are options of XPENDING in the extended form
+type XPendingOptions struct {
+	Start    StreamID
+	End      StreamID
+	Count    int
+	MinIdle  time.Duration
+	Consumer []byte // only gets pending entries of the consumer if it is not nil
+}
+
+// XPendingRange redis XPENDING in the extended form
+func (ds *DS) XPendingRange(key, group []byte, opts XPendingOptions) ([]StreamPendingEntry, error) {
+	_, cg, err := ds.getConsumerGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	if cg == nil {
+		return nil, newErrNoGroup(key, group)
+	}
+	if opts.Count <= 0 || opts.End.Less(opts.Start) {
+		return nil, nil
+	}
+
+	var entries []StreamPendingEntry
+	now := time.Now()
+	err = ds.scanInternalKeys(cg.prefix(streamPendingTag), cg.pendingKey(opts.Start), func(encKey, value []byte) (bool, error) {
+		id := decodeStreamID(encKey)
+		if opts.End.Less(id) {
+			return false, nil
+		}
+		p := decodeStreamPending(value)
+		if opts.Consumer != nil && !bytes.Equal(p.consumer, opts.Consumer) {
+			return true, nil
+		}
+		if now.Sub(time.UnixMilli(p.deliveryTime)) < opts.MinIdle {
+			return true, nil
+		}
+		entries = append(entries, p.toPendingEntry(id))
+		return len(entries) < opts.Count, nil
+	})
+	return entries, err
+}
+
+// XClaimOptions are options of XCLAIM
+type XClaimOptions struct {
+	DeliveryTime  time.Time // the delivery time of claimed entries given by IDLE or TIME, now if it is zero
+	RetryCount    uint64    // the delivery count of claimed entries if HasRetryCount is true
+	HasRetryCount bool
+	Force         bool     // claims entries not pending yet if they exist in the stream
+	JustID        bool     // claims entries without increasing delivery counts, and returns IDs only
+	LastID        StreamID // the last delivered ID of the group is updated to it if it is greater
+}
+
+// claim changes the owner of a pending entry to a consumer, and returns the entry, or nil if the entry has been deleted
+func (ds *DS) claim(wb *baradb.WriteBatch, cg *consumerGroup, consumer []byte, id StreamID, p *streamPending, deliveryTime int64, justID bool) (*StreamEntry, error) {
+	fields, err := ds.streamEntry(cg.key, cg.md, id)
+	if err != nil {
+		return nil, err
+	}
+	if fields == nil {
+		// entries deleted from the stream are removed from pending entries as well
+		return nil, wb.Delete(cg.pendingKey(id))
+	}
+
+	p.consumer = consumer
+	p.deliveryTime = deliveryTime
+	if err := wb.Put(cg.pendingKey(id), p.encode()); err != nil {
+		return nil, err
+	}
+	if justID {
+		return &StreamEntry{ID: id}, nil
+	}
+	return &StreamEntry{ID: id, Fields: fields}, nil
+}
+
+// updateConsumer updates the seen time and the active time of a consumer after it claimed entries.
+//
+// A consumer claiming nothing is not created.
+func (ds *DS) updateConsumer(wb *baradb.WriteBatch, cg *consumerGroup, consumer []byte, now int64, claimed bool) error {
+	c, exists, err := ds.getConsumer(cg, consumer)
+	if err != nil || (!exists && !claimed) {
+		return err
+	}
+	c.seenTime = now
+	if claimed {
+		c.activeTime = now
+	}
+	return wb.Put(cg.consumerKey(consumer), c.encode())
+}
+
+// XClaim redis XCLAIM
+//
+// It returns claimed entries, which have nil fields with opts.JustID.
+func (ds *DS) XClaim(key, group, consumer []byte, minIdle time.Duration, ids []StreamID, opts XClaimOptions) ([]StreamEntry, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	_, cg, err := ds.getConsumerGroup(key, group)
+	if err != nil {
+		return nil, err
+	}
+	if cg == nil {
+		return nil, newErrNoGroup(key, group)
+	}
+
+	now := time.Now().UnixMilli()
+	deliveryTime := now
+	if t := opts.DeliveryTime.UnixMilli(); !opts.DeliveryTime.IsZero() && t >= 0 && t < now {
+		deliveryTime = t
+	}
+
+	wbOpts := baradb.DefaultWriteBatchOptions
+	wbOpts.MaxBatchNumber = len(ids) + 2
+	wb := ds.db.NewWriteBatch(wbOpts)
+	if cg.lastID.Less(opts.LastID) {
+		cg.lastID = opts.LastID
+		if err := wb.Put(cg.groupKey(), cg.encode()); err != nil {
+			return nil, err
+		}
+	}
+
+	var claimed []StreamEntry
+	seen := make(map[StreamID]bool)
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+
+		var p streamPending
+		value, err := ds.db.Get(cg.pendingKey(id))
+		switch {
+		case err == baradb.ErrKeyNotFound && !opts.Force:
+			continue
+		case err == baradb.ErrKeyNotFound:
+			// the idle time is meaningless for entries not pending yet
+		case err != nil:
+			return nil, err
+		default:
+			p = decodeStreamPending(value)
+			if time.Duration(now-p.deliveryTime)*time.Millisecond < minIdle {
+				continue
+			}
+		}
+
+		switch {
+		case opts.HasRetryCount:
+			p.deliveryCount = opts.RetryCount
+		case !opts.JustID || p.deliveryCount == 0:
+			p.deliveryCount++
+		}
+		entry, err := ds.claim(wb, cg, consumer, id, &p, deliveryTime, opts.JustID)
+		if err != nil {
+			return nil, err
+		}
+		if entry != nil {
+			claimed = append(claimed, *entry)
+		}
+	}
+
+	if err := ds.updateConsumer(wb, cg, consumer, now, len(claimed) > 0); err != nil {
+		return nil, err
+	}
+	if err := wb.Commit(); err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// XAutoClaim redis XAUTOCLAIM
+//
+// It scans at most 10 times count pending entries from start, and claims at most count ones idle for at least minIdle,
+// where deleted entries count as well.
+// It returns the ID to continue the scan with, which is 0-0 if the scan is complete,
+// claimed entries, which have nil fields with justID, and IDs of entries deleted from the stream,
+// which are removed from pending entries as well.
+func (ds *DS) XAutoClaim(key, group, consumer []byte, minIdle time.Duration, start StreamID, count int, justID bool) (StreamID, []StreamEntry, []StreamID, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	_, cg, err := ds.getConsumerGroup(key, group)
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+	if cg == nil {
+		return StreamID{}, nil, nil, newErrNoGroup(key, group)
+	}
+
+	type candidate struct {
+		id StreamID
+		p  streamPending
+	}
+	var candidates []candidate
+	var next StreamID
+	now := time.Now().UnixMilli()
+	attempts := count * 10
+	err = ds.scanInternalKeys(cg.prefix(streamPendingTag), cg.pendingKey(start), func(encKey, value []byte) (bool, error) {
+		id := decodeStreamID(encKey)
+		if attempts == 0 || len(candidates) == count {
+			next = id
+			return false, nil
+		}
+		attempts--
+		p := decodeStreamPending(value)
+		if time.Duration(now-p.deliveryTime)*time.Millisecond >= minIdle {
+			candidates = append(candidates, candidate{id: id, p: p})
+		}
+		return true, nil
+	})
+	if err != nil {
+		return StreamID{}, nil, nil, err
+	}
+
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = len(candidates) + 1
+	wb := ds.db.NewWriteBatch(opts)
+	var claimed []StreamEntry
+	var deleted []StreamID
+	for _, c := range candidates {
+		if !justID {
+			c.p.deliveryCount++
+		}
+		entry, err := ds.claim(wb, cg, consumer, c.id, &c.p, now, justID)
+		if err != nil {
+			return StreamID{}, nil, nil, err
+		}
+		if entry == nil {
+			deleted = append(deleted, c.id)
+		} else {
+			claimed = append(claimed, *entry)
+		}
+	}
+
+	if err := ds.updateConsumer(wb, cg, consumer, now, len(claimed) > 0); err != nil {
+		return StreamID{}, nil, nil, err
+	}
+	if err := wb.Commit(); err != nil {
+		return StreamID{}, nil, nil, err
+	}
+	return next, claimed, deleted, nil
+}
+
+// describeGroup gets information of a consumer group and its consumers.
+//
+// With full, it gets at most count pending entries of the group and each consumer, count is 0 if there is no limit.
+func (ds *DS) describeGroup(cg *consumerGroup, full bool, count int) (StreamGroup, []StreamConsumer, error) {
+	lag, err := ds.lag(cg)
+	if err != nil {
+		return StreamGroup{}, nil, err
+	}
+	info := StreamGroup{
+		Name:            cg.name,
+		LastDeliveredID: cg.lastID,
+		EntriesRead:     cg.entriesRead,
+		Lag:             lag,
+	}
+
+	var consumers []StreamConsumer
+	indexes := make(map[string]int)
+	prefix := cg.prefix(streamConsumerTag)
+	err = ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		name := bytes.Clone(encKey[len(prefix):])
+		c := decodeStreamConsumer(value)
+		indexes[string(name)] = len(consumers)
+		consumers = append(consumers, c.toConsumer(name))
+		return true, nil
+	})
+	if err != nil {
+		return StreamGroup{}, nil, err
+	}
+
+	prefix = cg.prefix(streamPendingTag)
+	err = ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		p := decodeStreamPending(value)
+		entry := p.toPendingEntry(decodeStreamID(encKey))
+		info.Pending++
+		if full && (count <= 0 || len(info.PendingEntries) < count) {
+			info.PendingEntries = append(info.PendingEntries, entry)
+		}
+		i, ok := indexes[string(p.consumer)]
+		if !ok {
+			return true, nil
+		}
+		c := &consumers[i]
+		c.Pending++
+		if full && (count <= 0 || len(c.PendingEntries) < count) {
+			c.PendingEntries = append(c.PendingEntries, entry)
+		}
+		return true, nil
+	})
+	if err != nil {
+		return StreamGroup{}, nil, err
+	}
+
+	info.Consumers = len(consumers)
+	if full {
+		info.ConsumerDetails = consumers
+	}
+	return info, consumers, nil
+}
+
+// XInfoStream redis XINFO STREAM
+//
+// With full, it gets at most count entries, and at most count pending entries of each group and consumer
+// instead of the first and the last entries. count is 0 if there is no limit.
+func (ds *DS) XInfoStream(key []byte, full bool, count int) (*StreamInfo, error) {
+	md, err := ds.getStreamMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, ErrNoSuchKey
+	}
+
+	info := &StreamInfo{
+		Length:          md.size,
+		LastGeneratedID: md.lastID,
+		MaxDeletedID:    md.maxDeletedID,
+		EntriesAdded:    md.entriesAdded,
+	}
+	first, err := ds.streamRange(key, md, MinStreamID, MaxStreamID, 1, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(first) > 0 {
+		info.FirstID = first[0].ID
+	}
+
+	groups, err := ds.consumerGroups(key, md)
+	if err != nil {
+		return nil, err
+	}
+	info.Groups = len(groups)
+
+	if !full {
+		last, err := ds.streamRange(key, md, MinStreamID, MaxStreamID, 1, true)
+		if err != nil {
+			return nil, err
+		}
+		if len(first) > 0 && len(last) > 0 {
+			info.FirstEntry, info.LastEntry = &first[0], &last[0]
+		}
+		return info, nil
+	}
+
+	info.Entries, err = ds.streamRange(key, md, MinStreamID, MaxStreamID, count, false)
+	if err != nil {
+		return nil, err
+	}
+	info.GroupDetails = make([]StreamGroup, len(groups))
+	for i, cg := range groups {
+		if info.GroupDetails[i], _, err = ds.describeGroup(cg, true, count); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// XInfoGroups redis XINFO GROUPS
+func (ds *DS) XInfoGroups(key []byte) ([]StreamGroup, error) {
+	md, err := ds.getStreamMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, ErrNoSuchKey
+	}
+
+	groups, err := ds.consumerGroups(key, md)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]StreamGroup, len(groups))
+	for i, cg := range groups {
+		if infos[i], _, err = ds.describeGroup(cg, false, 0); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+// XInfoConsumers redis XINFO CONSUMERS
+func (ds *DS) XInfoConsumers(key, group []byte) ([]StreamConsumer, error) {
+	md, cg, err := ds.getConsumerGroup(key, group)
+	switch {
+	case err != nil:
+		return nil, err
+	case md == nil:
+		return nil, ErrNoSuchKey
+	case cg == nil:
+		return nil, newErrNoGroupForKey(key, group)
+	}
+	_, consumers, err := ds.describeGroup(cg, false, 0)
+	return consumers, err
+}
@@ -0,0 +1,337 @@
+package ds
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestingStream adds n entries with IDs from 1-0 to n-0 to a stream
+func newTestingStream(ds *DS, key []byte, n int) {
+	for i := 1; i <= n; i++ {
+		ds.XAdd(key, XAddOptions{ID: StreamID{Ms: uint64(i)}}, []byte("n"), []byte(fmt.Sprint(i)))
+	}
+}
+
+func TestDS_XGroupCreate(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	err := ds.XGroupCreate(key, group, XGroupOptions{Latest: true})
+	assert.Equal(t, ErrStreamKeyRequired, err)
+	err = ds.XGroupCreate(key, group, XGroupOptions{Latest: true, MkStream: true, EntriesRead: -1})
+	assert.Nil(t, err)
+	err = ds.XGroupCreate(key, group, XGroupOptions{})
+	assert.Equal(t, ErrStreamGroupExists, err)
+
+	n, err := ds.XLen(key)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), n)
+
+	groups, err := ds.XInfoGroups(key)
+	assert.Nil(t, err)
+	assert.Len(t, groups, 1)
+	assert.Equal(t, group, groups[0].Name)
+	assert.Equal(t, MinStreamID, groups[0].LastDeliveredID)
+	assert.Equal(t, int64(0), groups[0].Lag)
+
+	err = ds.XGroupSetID(key, []byte("missing"), XGroupOptions{})
+	assert.EqualError(t, err, "NOGROUP No such consumer group 'missing' for key name 'stream'")
+
+	ok, err := ds.XGroupDestroy(key, group)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = ds.XGroupDestroy(key, group)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	groups, _ = ds.XInfoGroups(key)
+	assert.Empty(t, groups)
+}
+
+func TestDS_XReadGroup(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	newTestingStream(ds, key, 3)
+	ds.XGroupCreate(key, group, XGroupOptions{EntriesRead: -1})
+	keys := [][]byte{key}
+
+	_, err := ds.XReadGroup([]byte("missing"), []byte("alice"), keys, []*StreamID{nil}, 0, false)
+	assert.EqualError(t, err, "NOGROUP No such key 'stream' or consumer group 'missing' in XREADGROUP with GROUP option")
+
+	results, err := ds.XReadGroup(group, []byte("alice"), keys, []*StreamID{nil}, 2, false)
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Len(t, results[0].Entries, 2)
+	results, err = ds.XReadGroup(group, []byte("bob"), keys, []*StreamID{nil}, 0, false)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamID{Ms: 3}, results[0].Entries[0].ID)
+	results, err = ds.XReadGroup(group, []byte("bob"), keys, []*StreamID{nil}, 0, false)
+	assert.Nil(t, err)
+	assert.Empty(t, results)
+
+	// the history of a consumer includes deleted entries with nil fields
+	ds.XDel(key, StreamID{Ms: 1})
+	results, err = ds.XReadGroup(group, []byte("alice"), keys, []*StreamID{&MinStreamID}, 0, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []StreamEntry{
+		{ID: StreamID{Ms: 1}},
+		{ID: StreamID{Ms: 2}, Fields: [][]byte{[]byte("n"), []byte("2")}},
+	}, results[0].Entries)
+
+	groups, _ := ds.XInfoGroups(key)
+	assert.Equal(t, 2, groups[0].Consumers)
+	assert.Equal(t, 3, groups[0].Pending)
+	assert.Equal(t, StreamID{Ms: 3}, groups[0].LastDeliveredID)
+	assert.Equal(t, int64(3), groups[0].EntriesRead)
+	assert.Equal(t, int64(0), groups[0].Lag)
+
+	// entries read without acknowledgement are not pending
+	ds.XAdd(key, XAddOptions{ID: StreamID{Ms: 4}}, []byte("n"), []byte("4"))
+	results, err = ds.XReadGroup(group, []byte("bob"), keys, []*StreamID{nil}, 0, true)
+	assert.Nil(t, err)
+	assert.Len(t, results[0].Entries, 1)
+	summary, _ := ds.XPending(key, group)
+	assert.Equal(t, 3, summary.Count)
+}
+
+func TestDS_XReadGroupBlock(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	ds.XGroupCreate(key, group, XGroupOptions{Latest: true, MkStream: true, EntriesRead: -1})
+	keys := [][]byte{key}
+
+	results, err := ds.XReadGroupBlock(group, []byte("alice"), keys, []*StreamID{nil}, 0, false, 50*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Nil(t, results)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ds.XAdd(key, XAddOptions{ID: StreamID{Ms: 1}}, []byte("n"), []byte("1"))
+	}()
+	results, err = ds.XReadGroupBlock(group, []byte("alice"), keys, []*StreamID{nil}, 0, false, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamID{Ms: 1}, results[0].Entries[0].ID)
+}
+
+func TestDS_XAck(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	newTestingStream(ds, key, 3)
+	ds.XGroupCreate(key, group, XGroupOptions{EntriesRead: -1})
+	ds.XReadGroup(group, []byte("alice"), [][]byte{key}, []*StreamID{nil}, 0, false)
+
+	n, err := ds.XAck(key, group, StreamID{Ms: 1}, StreamID{Ms: 1}, StreamID{Ms: 9})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+	n, err = ds.XAck(key, []byte("missing"), StreamID{Ms: 2})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	summary, err := ds.XPending(key, group)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, summary.Count)
+	assert.Equal(t, StreamID{Ms: 2}, summary.MinID)
+	assert.Equal(t, StreamID{Ms: 3}, summary.MaxID)
+	assert.Equal(t, []StreamConsumer{{Name: []byte("alice"), Pending: 2}}, summary.Consumers)
+}
+
+func TestDS_XPendingRange(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	newTestingStream(ds, key, 4)
+	ds.XGroupCreate(key, group, XGroupOptions{EntriesRead: -1})
+	ds.XReadGroup(group, []byte("alice"), [][]byte{key}, []*StreamID{nil}, 2, false)
+	ds.XReadGroup(group, []byte("bob"), [][]byte{key}, []*StreamID{nil}, 2, false)
+
+	entries, err := ds.XPendingRange(key, group, XPendingOptions{Start: StreamID{Ms: 2}, End: MaxStreamID, Count: 2})
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, StreamID{Ms: 2}, entries[0].ID)
+	assert.Equal(t, []byte("alice"), entries[0].Consumer)
+	assert.Equal(t, uint64(1), entries[0].DeliveryCount)
+
+	entries, err = ds.XPendingRange(key, group, XPendingOptions{End: MaxStreamID, Count: 10, Consumer: []byte("bob")})
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, StreamID{Ms: 3}, entries[0].ID)
+
+	entries, err = ds.XPendingRange(key, group, XPendingOptions{End: MaxStreamID, Count: 10, MinIdle: time.Hour})
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	_, err = ds.XPendingRange(key, []byte("missing"), XPendingOptions{End: MaxStreamID, Count: 10})
+	assert.EqualError(t, err, "NOGROUP No such key 'stream' or consumer group 'missing'")
+}
+
+func TestDS_XClaim(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	newTestingStream(ds, key, 3)
+	ds.XGroupCreate(key, group, XGroupOptions{EntriesRead: -1})
+	ds.XReadGroup(group, []byte("alice"), [][]byte{key}, []*StreamID{nil}, 2, false)
+
+	ids := []StreamID{{Ms: 1}, {Ms: 2}, {Ms: 3}}
+	entries, err := ds.XClaim(key, group, []byte("bob"), time.Hour, ids, XClaimOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	entries, err = ds.XClaim(key, group, []byte("bob"), 0, ids, XClaimOptions{})
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, [][]byte{[]byte("n"), []byte("1")}, entries[0].Fields)
+
+	// FORCE claims entries not pending yet
+	entries, err = ds.XClaim(key, group, []byte("carol"), 0, ids, XClaimOptions{Force: true, JustID: true, LastID: StreamID{Ms: 3}})
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+	assert.Nil(t, entries[0].Fields)
+
+	pending, _ := ds.XPendingRange(key, group, XPendingOptions{End: MaxStreamID, Count: 10})
+	assert.Len(t, pending, 3)
+	assert.Equal(t, []byte("carol"), pending[0].Consumer)
+	assert.Equal(t, uint64(2), pending[0].DeliveryCount)
+	assert.Equal(t, uint64(1), pending[2].DeliveryCount)
+
+	deliveryTime := time.Now().Add(-time.Hour)
+	_, err = ds.XClaim(key, group, []byte("carol"), 0, ids[:1], XClaimOptions{DeliveryTime: deliveryTime, RetryCount: 5, HasRetryCount: true})
+	assert.Nil(t, err)
+	pending, _ = ds.XPendingRange(key, group, XPendingOptions{End: MaxStreamID, Count: 1})
+	assert.Equal(t, uint64(5), pending[0].DeliveryCount)
+	assert.Equal(t, deliveryTime.UnixMilli(), pending[0].DeliveryTime.UnixMilli())
+
+	groups, _ := ds.XInfoGroups(key)
+	assert.Equal(t, StreamID{Ms: 3}, groups[0].LastDeliveredID)
+
+	// deleted entries are removed from pending entries
+	ds.XDel(key, StreamID{Ms: 2})
+	entries, err = ds.XClaim(key, group, []byte("bob"), 0, ids[1:2], XClaimOptions{})
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+	summary, _ := ds.XPending(key, group)
+	assert.Equal(t, 2, summary.Count)
+}
+
+func TestDS_XAutoClaim(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	newTestingStream(ds, key, 5)
+	ds.XGroupCreate(key, group, XGroupOptions{EntriesRead: -1})
+	ds.XReadGroup(group, []byte("alice"), [][]byte{key}, []*StreamID{nil}, 0, false)
+	ds.XDel(key, StreamID{Ms: 2})
+
+	// deleted entries count towards the limit as well
+	next, entries, deleted, err := ds.XAutoClaim(key, group, []byte("bob"), 0, MinStreamID, 2, false)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamID{Ms: 3}, next)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, StreamID{Ms: 1}, entries[0].ID)
+	assert.Equal(t, []StreamID{{Ms: 2}}, deleted)
+
+	next, entries, deleted, err = ds.XAutoClaim(key, group, []byte("bob"), 0, next, 2, true)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamID{Ms: 5}, next)
+	assert.Len(t, entries, 2)
+	assert.Nil(t, entries[0].Fields)
+	assert.Empty(t, deleted)
+
+	next, entries, _, err = ds.XAutoClaim(key, group, []byte("carol"), time.Hour, MinStreamID, 10, false)
+	assert.Nil(t, err)
+	assert.Equal(t, MinStreamID, next)
+	assert.Empty(t, entries)
+
+	// a consumer claiming nothing is not created
+	consumers, err := ds.XInfoConsumers(key, group)
+	assert.Nil(t, err)
+	assert.Len(t, consumers, 2)
+	assert.Equal(t, []byte("alice"), consumers[0].Name)
+	assert.Equal(t, 1, consumers[0].Pending)
+	assert.Equal(t, []byte("bob"), consumers[1].Name)
+	assert.Equal(t, 3, consumers[1].Pending)
+}
+
+func TestDS_XGroupConsumers(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	newTestingStream(ds, key, 3)
+	ds.XGroupCreate(key, group, XGroupOptions{EntriesRead: -1})
+
+	ok, err := ds.XGroupCreateConsumer(key, group, []byte("alice"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	ok, err = ds.XGroupCreateConsumer(key, group, []byte("alice"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	consumers, err := ds.XInfoConsumers(key, group)
+	assert.Nil(t, err)
+	assert.Len(t, consumers, 1)
+	assert.True(t, consumers[0].ActiveTime.IsZero())
+
+	ds.XReadGroup(group, []byte("alice"), [][]byte{key}, []*StreamID{nil}, 0, false)
+	n, err := ds.XGroupDelConsumer(key, group, []byte("alice"))
+	assert.Nil(t, err)
+	assert.Equal(t, 3, n)
+	n, err = ds.XGroupDelConsumer(key, group, []byte("alice"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	summary, _ := ds.XPending(key, group)
+	assert.Equal(t, 0, summary.Count)
+
+	_, err = ds.XInfoConsumers([]byte("missing"), group)
+	assert.Equal(t, ErrNoSuchKey, err)
+}
+
+func TestDS_XInfoStream(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key, group := []byte("stream"), []byte("group")
+	_, err := ds.XInfoStream(key, false, 0)
+	assert.Equal(t, ErrNoSuchKey, err)
+
+	newTestingStream(ds, key, 3)
+	ds.XGroupCreate(key, group, XGroupOptions{EntriesRead: -1})
+	ds.XReadGroup(group, []byte("alice"), [][]byte{key}, []*StreamID{nil}, 1, false)
+	ds.XDel(key, StreamID{Ms: 2})
+
+	info, err := ds.XInfoStream(key, false, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), info.Length)
+	assert.Equal(t, StreamID{Ms: 3}, info.LastGeneratedID)
+	assert.Equal(t, StreamID{Ms: 2}, info.MaxDeletedID)
+	assert.Equal(t, uint64(3), info.EntriesAdded)
+	assert.Equal(t, StreamID{Ms: 1}, info.FirstID)
+	assert.Equal(t, 1, info.Groups)
+	assert.Equal(t, StreamID{Ms: 1}, info.FirstEntry.ID)
+	assert.Equal(t, StreamID{Ms: 3}, info.LastEntry.ID)
+
+	info, err = ds.XInfoStream(key, true, 1)
+	assert.Nil(t, err)
+	assert.Len(t, info.Entries, 1)
+	assert.Len(t, info.GroupDetails, 1)
+	g := info.GroupDetails[0]
+	assert.Equal(t, 1, g.Pending)
+	assert.Equal(t, int64(1), g.EntriesRead)
+	// the lag is unknown since an entry after the last delivered one has been deleted
+	assert.Equal(t, int64(-1), g.Lag)
+	assert.Len(t, g.PendingEntries, 1)
+	assert.Len(t, g.ConsumerDetails, 1)
+	assert.Len(t, g.ConsumerDetails[0].PendingEntries, 1)
+}
@@ -0,0 +1,184 @@
+package ds
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_XAdd(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("stream")
+	fields := [][]byte{[]byte("name"), []byte("Sara")}
+
+	_, ok, err := ds.XAdd(key, XAddOptions{AutoID: true, NoMkStream: true}, fields...)
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	id, ok, err := ds.XAdd(key, XAddOptions{ID: StreamID{Ms: 5, Seq: 1}}, fields...)
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "5-1", id.String())
+
+	_, _, err = ds.XAdd(key, XAddOptions{ID: StreamID{Ms: 5, Seq: 1}}, fields...)
+	assert.Equal(t, ErrStreamIDTooSmall, err)
+	_, _, err = ds.XAdd([]byte("empty"), XAddOptions{ID: MinStreamID}, fields...)
+	assert.Equal(t, ErrStreamIDZero, err)
+
+	id, _, err = ds.XAdd(key, XAddOptions{ID: StreamID{Ms: 5}, AutoSeq: true}, fields...)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamID{Ms: 5, Seq: 2}, id)
+	id, _, err = ds.XAdd(key, XAddOptions{ID: StreamID{Ms: 7}, AutoSeq: true}, fields...)
+	assert.Nil(t, err)
+	assert.Equal(t, StreamID{Ms: 7}, id)
+
+	id, _, err = ds.XAdd(key, XAddOptions{AutoID: true}, fields...)
+	assert.Nil(t, err)
+	assert.Less(t, uint64(time.Now().Add(-time.Minute).UnixMilli()), id.Ms)
+
+	n, err := ds.XLen(key)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(4), n)
+
+	// the last ID is kept after deleting the last entry
+	_, err = ds.XDel(key, id)
+	assert.Nil(t, err)
+	_, _, err = ds.XAdd(key, XAddOptions{ID: id}, fields...)
+	assert.Equal(t, ErrStreamIDTooSmall, err)
+
+	ds.Set([]byte("string"), []byte("value"), 0)
+	_, _, err = ds.XAdd([]byte("string"), XAddOptions{AutoID: true}, fields...)
+	assert.Equal(t, ErrWrongTypeOperation, err)
+}
+
+func TestDS_XRange(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("stream")
+	for i := 1; i <= 5; i++ {
+		_, _, err := ds.XAdd(key, XAddOptions{ID: StreamID{Ms: uint64(i)}}, []byte("n"), []byte(fmt.Sprint(i)))
+		assert.Nil(t, err)
+	}
+
+	entries, err := ds.XRange(key, MinStreamID, MaxStreamID, 0, false)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 5)
+	assert.Equal(t, [][]byte{[]byte("n"), []byte("1")}, entries[0].Fields)
+
+	entries, err = ds.XRange(key, StreamID{Ms: 2}, StreamID{Ms: 4}, 2, false)
+	assert.Nil(t, err)
+	assert.Equal(t, []StreamEntry{
+		{ID: StreamID{Ms: 2}, Fields: [][]byte{[]byte("n"), []byte("2")}},
+		{ID: StreamID{Ms: 3}, Fields: [][]byte{[]byte("n"), []byte("3")}},
+	}, entries)
+
+	entries, err = ds.XRange(key, StreamID{Ms: 2}, StreamID{Ms: 4}, 0, true)
+	assert.Nil(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, StreamID{Ms: 4}, entries[0].ID)
+	assert.Equal(t, StreamID{Ms: 2}, entries[2].ID)
+
+	entries, err = ds.XRange(key, StreamID{Ms: 4}, StreamID{Ms: 2}, 0, false)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+	entries, err = ds.XRange([]byte("missing"), MinStreamID, MaxStreamID, 0, false)
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestDS_XDel(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("stream")
+	for i := 1; i <= 3; i++ {
+		ds.XAdd(key, XAddOptions{ID: StreamID{Ms: uint64(i)}}, []byte("n"), []byte(fmt.Sprint(i)))
+	}
+
+	n, err := ds.XDel(key, StreamID{Ms: 2}, StreamID{Ms: 2}, StreamID{Ms: 9})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, n)
+
+	entries, _ := ds.XRange(key, MinStreamID, MaxStreamID, 0, false)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, StreamID{Ms: 3}, entries[1].ID)
+
+	// a stream remains after all entries are deleted
+	n, err = ds.XDel(key, StreamID{Ms: 1}, StreamID{Ms: 3})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, n)
+	assert.True(t, ds.Exists(key))
+	length, _ := ds.XLen(key)
+	assert.Equal(t, uint32(0), length)
+}
+
+func TestDS_XTrim(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("stream")
+	for i := 1; i <= 250; i++ {
+		ds.XAdd(key, XAddOptions{ID: StreamID{Ms: uint64(i)}}, []byte("n"), []byte(fmt.Sprint(i)))
+	}
+
+	// approximate trimming evicts whole nodes only
+	n, err := ds.XTrim(key, StreamTrim{MaxLen: 120, Approx: true})
+	assert.Nil(t, err)
+	assert.Equal(t, 100, n)
+	n, err = ds.XTrim(key, StreamTrim{MaxLen: 120, Approx: true, Limit: 10})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = ds.XTrim(key, StreamTrim{MaxLen: 120})
+	assert.Nil(t, err)
+	assert.Equal(t, 30, n)
+
+	n, err = ds.XTrim(key, StreamTrim{ByMinID: true, MinID: StreamID{Ms: 200}})
+	assert.Nil(t, err)
+	assert.Equal(t, 69, n)
+
+	entries, _ := ds.XRange(key, MinStreamID, MaxStreamID, 1, false)
+	assert.Equal(t, StreamID{Ms: 200}, entries[0].ID)
+	length, _ := ds.XLen(key)
+	assert.Equal(t, uint32(51), length)
+
+	_, _, err = ds.XAdd(key, XAddOptions{ID: StreamID{Ms: 300}, Trim: &StreamTrim{MaxLen: 2}}, []byte("n"), []byte("300"))
+	assert.Nil(t, err)
+	entries, _ = ds.XRange(key, MinStreamID, MaxStreamID, 0, false)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, StreamID{Ms: 250}, entries[0].ID)
+}
+
+func TestDS_XRead(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key1, key2 := []byte("stream1"), []byte("stream2")
+	ds.XAdd(key1, XAddOptions{ID: StreamID{Ms: 1}}, []byte("n"), []byte("1"))
+	ds.XAdd(key1, XAddOptions{ID: StreamID{Ms: 2}}, []byte("n"), []byte("2"))
+	ds.XAdd(key2, XAddOptions{ID: StreamID{Ms: 1}}, []byte("n"), []byte("1"))
+
+	results, err := ds.XRead([][]byte{key1, key2}, []StreamID{{Ms: 1}, {Ms: 1}}, 0)
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, key1, results[0].Key)
+	assert.Equal(t, StreamID{Ms: 2}, results[0].Entries[0].ID)
+
+	results, err = ds.XReadBlock([][]byte{key2}, []StreamID{{Ms: 1}}, 0, 50*time.Millisecond)
+	assert.Nil(t, err)
+	assert.Nil(t, results)
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		ds.XAdd(key2, XAddOptions{ID: StreamID{Ms: 2}}, []byte("n"), []byte("2"))
+	}()
+	results, err = ds.XReadBlock([][]byte{key2}, []StreamID{{Ms: 1}}, 0, 0)
+	assert.Nil(t, err)
+	assert.Len(t, results, 1)
+	assert.Equal(t, StreamID{Ms: 2}, results[0].Entries[0].ID)
+}
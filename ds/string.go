@@ -1,8 +1,8 @@
 package ds
 
 import (
+	"bytes"
 	"encoding/binary"
-	"errors"
 	"math"
 	"strconv"
 	"time"
@@ -11,50 +11,155 @@ import (
 	"github.com/saint-yellow/baradb/utils"
 )
 
+// Expiration tells how to set the expire of a key
+type Expiration struct {
+	TTL      time.Duration // time to live from now, 0 if not given
+	ExpireAt time.Time     // absolute time to expire, zero if not given
+	KeepTTL  bool          // keeps the current expire of the key
+	Persist  bool          // removes the expire of the key
+}
+
+// range of expires, which are Unix time in nanoseconds
+var (
+	minExpireAt = time.Unix(0, math.MinInt64)
+	maxExpireAt = time.Unix(0, math.MaxInt64)
+)
+
+// expire gets the encoded expire based on the current one, 0 means the key will not expire.
+//
+// An expire not representable in Unix time in nanoseconds, such as one after the year 2262, is invalid.
+func (e Expiration) expire(current int64) (int64, error) {
+	switch {
+	case e.KeepTTL:
+		return current, nil
+	case e.Persist:
+		return 0, nil
+	case !e.ExpireAt.IsZero():
+		return expireAt(e.ExpireAt)
+	case e.TTL != 0:
+		now := time.Now()
+		if e.TTL > maxExpireAt.Sub(now) || e.TTL < minExpireAt.Sub(now) {
+			return 0, ErrInvalidExpireTime
+		}
+		return expireAt(now.Add(e.TTL))
+	default:
+		return 0, nil
+	}
+}
+
+// expireAt encodes an absolute expire
+func expireAt(t time.Time) (int64, error) {
+	if t.After(maxExpireAt) || t.Before(minExpireAt) {
+		return 0, ErrInvalidExpireTime
+	}
+	return t.UnixNano(), nil
+}
+
+// maxStringLength is the maximum length of a string, which is proto-max-bulk-len of Redis
+const maxStringLength = 512 << 20
+
+// SetOptions are options of redis SET
+type SetOptions struct {
+	Expiration
+	NX  bool // only sets the key if it does not exist
+	XX  bool // only sets the key if it already exists
+	Get bool // gets the old string, fails if the key holds another type
+}
+
 // Set redis SET
+//
+// If the ttl is 0, then the key will not expire.
 func (ds *DS) Set(key []byte, value []byte, ttl time.Duration) error {
-	if len(value) == 0 {
-		return nil
+	_, _, err := ds.SetWithOptions(key, value, SetOptions{
+		Expiration: Expiration{TTL: ttl},
+	})
+	return err
+}
+
+// SetWithOptions redis SET [NX | XX] [GET] [EX | PX | EXAT | PXAT | KEEPTTL]
+//
+// It returns the old string if opts.Get is true, and whether the key is set.
+// The check of NX and XX and the write are atomic.
+func (ds *DS) SetWithOptions(key, value []byte, opts SetOptions) ([]byte, bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	oldEncValue, err := ds.getValue(key)
+	if err != nil && err != baradb.ErrKeyNotFound {
+		return nil, false, err
 	}
+	exists := err == nil
 
-	// Encode value: type + expire + payload
-	buffer := make([]byte, binary.MaxVarintLen64+1)
-	buffer[0] = String
-	index := 1
+	var oldValue []byte
+	var oldExpire int64
+	if exists {
+		if opts.Get && oldEncValue[0] != String {
+			return nil, false, ErrWrongTypeOperation
+		}
+		oldExpire, _ = binary.Varint(oldEncValue[1:])
+		if oldEncValue[0] == String {
+			oldValue = decodeString(oldEncValue)
+		}
+	}
 
-	// If the ttl is 0, then the key will not expire.
-	var expire int64
-	if ttl != 0 {
-		expire = time.Now().Add(ttl).UnixNano()
+	if (opts.NX && exists) || (opts.XX && !exists) {
+		return oldValue, false, nil
 	}
+	expire, err := opts.expire(oldExpire)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := ds.putString(key, value, expire); err != nil {
+		return nil, false, err
+	}
+	return oldValue, true, nil
+}
 
+// putString puts a string, and reclaims internal keys if the key held a collection
+func (ds *DS) putString(key, value []byte, expire int64) error {
+	prefix, err := ds.collectionPrefix(key)
+	if err != nil {
+		return err
+	}
+	if err := ds.db.Put(key, encodeString(value, expire)); err != nil {
+		return err
+	}
+	if prefix != nil {
+		ds.reclaimer.add(prefix)
+	}
+	return nil
+}
+
+// encodeString encodes a value of a string: type + expire + payload
+//
+// If the expire is 0, then the key will not expire.
+func encodeString(value []byte, expire int64) []byte {
+	buffer := make([]byte, binary.MaxVarintLen64+1)
+	buffer[0] = String
+	index := 1
 	index += binary.PutVarint(buffer[index:], expire)
+
 	encValue := make([]byte, index+len(value))
 	copy(encValue[:index], buffer[:index])
 	copy(encValue[index:], value)
+	return encValue
+}
 
-	// Put the key and the encoded value to the DB engine
-	return ds.db.Put(key, encValue)
+// decodeString decodes the payload of an encoded string
+func decodeString(encValue []byte) []byte {
+	_, n := binary.Varint(encValue[1:])
+	return encValue[1+n:]
 }
 
 // SetNx redis SETNX
 func (ds *DS) SetNx(key []byte, value []byte) bool {
-	_, err := ds.db.Get(key)
-	if err == nil {
-		return false
-	}
-
-	if err != baradb.ErrKeyNotFound {
-		return false
-	}
-
-	err = ds.Set(key, value, 0)
-	return err == nil
+	_, ok, err := ds.SetWithOptions(key, value, SetOptions{NX: true})
+	return err == nil && ok
 }
 
 // Get redis GET
 func (ds *DS) Get(key []byte) ([]byte, error) {
-	encValue, err := ds.db.Get(key)
+	encValue, err := ds.getValue(key)
 	if err != nil {
 		return nil, err
 	}
@@ -64,19 +169,39 @@ func (ds *DS) Get(key []byte) ([]byte, error) {
 	if dataType != String {
 		return nil, ErrWrongTypeOperation
 	}
-	index := 1
-	expire, n := binary.Varint(encValue[index:])
-	if expire > 0 && expire <= time.Now().UnixNano() {
-		return nil, ErrExpiredValue
+	return decodeString(encValue), nil
+}
+
+// GetEx redis GETEX
+//
+// It gets a string and sets its expire, which is kept if e.KeepTTL is true.
+func (ds *DS) GetEx(key []byte, e Expiration) ([]byte, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	value, err := ds.Get(key)
+	if err != nil || e.KeepTTL {
+		return value, err
+	}
+	expire, err := e.expire(0)
+	if err != nil {
+		return nil, err
 	}
-	index += n
-	payload := encValue[index:]
-	return payload, nil
+	if err := ds.db.Put(key, encodeString(value, expire)); err != nil {
+		return nil, err
+	}
+	return value, nil
 }
 
 // GetDel redis GETDEL
 func (ds *DS) GetDel(key []byte) ([]byte, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
 	value, err := ds.Get(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -89,12 +214,90 @@ func (ds *DS) GetDel(key []byte) ([]byte, error) {
 
 // GetSet redis GETSET
 func (ds *DS) GetSet(key, value []byte) ([]byte, error) {
-	oldValue, _ := ds.Get(key)
-	err := ds.Set(key, value, 0)
-	if err != nil {
-		return nil, err
+	oldValue, _, err := ds.SetWithOptions(key, value, SetOptions{Get: true})
+	return oldValue, err
+}
+
+// MGet redis MGET
+//
+// The value of a missing key or a key holding another type is nil.
+func (ds *DS) MGet(keys ...[]byte) [][]byte {
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		value, err := ds.Get(key)
+		if err == nil {
+			values[i] = value
+		}
+	}
+	return values
+}
+
+// MSet redis MSET
+//
+// The arguments are pairs of keys and values, which are written in one batch.
+func (ds *DS) MSet(pairs ...[]byte) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	return ds.putStrings(pairs)
+}
+
+// MSetNx redis MSETNX
+//
+// None of the pairs is set if any of the keys exists.
+func (ds *DS) MSetNx(pairs ...[]byte) (bool, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	for i := 0; i < len(pairs); i += 2 {
+		_, err := ds.getValue(pairs[i])
+		if err == nil {
+			return false, nil
+		}
+		if err != baradb.ErrKeyNotFound {
+			return false, err
+		}
+	}
+	if err := ds.putStrings(pairs); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// putStrings puts pairs of keys and values in one batch,
+// and reclaims internal keys of collections that are overwritten
+func (ds *DS) putStrings(pairs [][]byte) error {
+	if len(pairs)%2 != 0 {
+		return ErrUnsupportedOperation
+	}
+
+	opts := baradb.DefaultWriteBatchOptions
+	if n := len(pairs) / 2; n > opts.MaxBatchNumber {
+		opts.MaxBatchNumber = n
+	}
+	wb := ds.db.NewWriteBatch(opts)
+	var prefixes [][]byte
+	for i := 0; i < len(pairs); i += 2 {
+		key, value := pairs[i], pairs[i+1]
+		prefix, err := ds.collectionPrefix(key)
+		if err != nil {
+			return err
+		}
+		if prefix != nil {
+			prefixes = append(prefixes, prefix)
+		}
+		if err := wb.Put(key, encodeString(value, 0)); err != nil {
+			return err
+		}
+	}
+	if err := wb.Commit(); err != nil {
+		return err
 	}
-	return oldValue, nil
+
+	for _, prefix := range prefixes {
+		ds.reclaimer.add(prefix)
+	}
+	return nil
 }
 
 // StrLen redis STRLEN
@@ -107,34 +310,276 @@ func (ds *DS) StrLen(key []byte) int {
 }
 
 // Append redis APPEND
+//
+// The expire of the key is kept.
 func (ds *DS) Append(key, value []byte) (int, error) {
-	exists := ds.Exists(key)
-	if !exists {
-		err := ds.Set(key, value, 0)
-		if err != nil {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	oldValue, expire, err := ds.getString(key)
+	if err == baradb.ErrKeyNotFound {
+		if err := ds.db.Put(key, encodeString(value, 0)); err != nil {
 			return 0, err
 		}
 		return len(value), nil
 	}
-
-	oldValue, err := ds.Get(key)
 	if err != nil {
 		return 0, err
 	}
 
-	err = ds.Set(key, append(oldValue, value...), 0)
+	newValue := make([]byte, 0, len(oldValue)+len(value))
+	newValue = append(append(newValue, oldValue...), value...)
+	if err := ds.db.Put(key, encodeString(newValue, expire)); err != nil {
+		return 0, err
+	}
+	return len(newValue), nil
+}
+
+// GetRange redis GETRANGE
+//
+// Negative offsets count from the end of the string, and both offsets are inclusive.
+func (ds *DS) GetRange(key []byte, start, end int64) ([]byte, error) {
+	value, _, err := ds.getString(key)
+	if err == baradb.ErrKeyNotFound {
+		return []byte{}, nil
+	}
 	if err != nil {
+		return nil, err
+	}
+
+	if start < 0 && end < 0 && start > end {
+		return []byte{}, nil
+	}
+	length := int64(len(value))
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end || length == 0 {
+		return []byte{}, nil
+	}
+	return value[start : end+1], nil
+}
+
+// SetRange redis SETRANGE
+//
+// The string is padded with zero bytes if the offset is beyond its length.
+// The expire of the key is kept.
+func (ds *DS) SetRange(key []byte, offset int64, value []byte) (int, error) {
+	if offset < 0 {
+		return 0, ErrOffsetOutOfRange
+	}
+
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	oldValue, expire, err := ds.getString(key)
+	if err != nil && err != baradb.ErrKeyNotFound {
 		return 0, err
 	}
-	return len(oldValue) + len(value), nil
+	if len(value) == 0 {
+		return len(oldValue), nil
+	}
+	if offset+int64(len(value)) > maxStringLength {
+		return 0, ErrStringTooLong
+	}
+
+	length := int(offset) + len(value)
+	if len(oldValue) > length {
+		length = len(oldValue)
+	}
+	newValue := make([]byte, length)
+	copy(newValue, oldValue)
+	copy(newValue[offset:], value)
+	if err := ds.putString(key, newValue, expire); err != nil {
+		return 0, err
+	}
+	return len(newValue), nil
+}
+
+// LCSMatch is a range of the longest common subsequence matched in both strings
+type LCSMatch struct {
+	A   [2]int // start and end offsets in the first string, both inclusive
+	B   [2]int // start and end offsets in the second string, both inclusive
+	Len int
+}
+
+// LCSResult is the longest common subsequence of two strings
+type LCSResult struct {
+	LCS     []byte
+	Matches []LCSMatch // ordered from the end of the strings
+}
+
+// maxLCSTableSize is the maximum memory in bytes of the table to find matched ranges of LCS
+const maxLCSTableSize = 128 << 20
+
+// LCS redis LCS
+//
+// Missing keys are treated as empty strings.
+// Matched ranges are found only if withMatches is true, which takes memory of the product of the lengths of the strings,
+// while the LCS alone takes linear memory.
+func (ds *DS) LCS(key1, key2 []byte, withMatches bool) (*LCSResult, error) {
+	a, err := ds.lcsString(key1)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ds.lcsString(key2)
+	if err != nil {
+		return nil, err
+	}
+	if !withMatches {
+		return &LCSResult{LCS: lcsLinear(a, b, nil)}, nil
+	}
+	return lcs(a, b)
+}
+
+func (ds *DS) lcsString(key []byte) ([]byte, error) {
+	value, _, err := ds.getString(key)
+	switch err {
+	case nil:
+		return value, nil
+	case baradb.ErrKeyNotFound:
+		return nil, nil
+	case ErrWrongTypeOperation:
+		return nil, ErrLCSNotString
+	default:
+		return nil, err
+	}
+}
+
+// lcs computes the longest common subsequence by dynamic programming,
+// then walks back from the end of both strings to collect matched ranges.
+func lcs(a, b []byte) (*LCSResult, error) {
+	rows, cols := len(a)+1, len(b)+1
+	if uint64(rows)*uint64(cols) > maxLCSTableSize/4 {
+		return nil, ErrLCSTooLong
+	}
+
+	// table[i*cols+j] is the length of the LCS of a[:i] and b[:j]
+	table := make([]uint32, rows*cols)
+	for i := 1; i < rows; i++ {
+		for j := 1; j < cols; j++ {
+			switch {
+			case a[i-1] == b[j-1]:
+				table[i*cols+j] = table[(i-1)*cols+j-1] + 1
+			case table[(i-1)*cols+j] > table[i*cols+j-1]:
+				table[i*cols+j] = table[(i-1)*cols+j]
+			default:
+				table[i*cols+j] = table[i*cols+j-1]
+			}
+		}
+	}
+
+	n := table[rows*cols-1]
+	result := &LCSResult{LCS: make([]byte, n)}
+	var match *LCSMatch
+	for i, j := len(a), len(b); i > 0 && j > 0; {
+		if a[i-1] != b[j-1] {
+			if table[(i-1)*cols+j] > table[i*cols+j-1] {
+				i--
+			} else {
+				j--
+			}
+			continue
+		}
+
+		n--
+		result.LCS[n] = a[i-1]
+		if match != nil && match.A[0] == i && match.B[0] == j {
+			match.A[0]--
+			match.B[0]--
+			match.Len++
+		} else {
+			if match != nil {
+				result.Matches = append(result.Matches, *match)
+			}
+			match = &LCSMatch{A: [2]int{i - 1, i - 1}, B: [2]int{j - 1, j - 1}, Len: 1}
+		}
+		i--
+		j--
+	}
+	if match != nil {
+		result.Matches = append(result.Matches, *match)
+	}
+	return result, nil
+}
+
+// lcsLengths gets the lengths of the LCS of a and every prefix of b with two rows of the table,
+// or of reversed a and every prefix of reversed b if reverse is true
+func lcsLengths(a, b []byte, reverse bool) []uint32 {
+	prev, cur := make([]uint32, len(b)+1), make([]uint32, len(b)+1)
+	for i := range a {
+		x := a[i]
+		if reverse {
+			x = a[len(a)-1-i]
+		}
+		for j := range b {
+			y := b[j]
+			if reverse {
+				y = b[len(b)-1-j]
+			}
+			switch {
+			case x == y:
+				cur[j+1] = prev[j] + 1
+			case prev[j+1] > cur[j]:
+				cur[j+1] = prev[j+1]
+			default:
+				cur[j+1] = cur[j]
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+// lcsLinear appends the longest common subsequence by Hirschberg's algorithm,
+// which splits b where the halves of a have the longest common subsequences in total
+func lcsLinear(a, b []byte, buffer []byte) []byte {
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		return buffer
+	case len(a) == 1:
+		if bytes.IndexByte(b, a[0]) >= 0 {
+			buffer = append(buffer, a[0])
+		}
+		return buffer
+	}
+
+	mid := len(a) / 2
+	head, tail := lcsLengths(a[:mid], b, false), lcsLengths(a[mid:], b, true)
+	var split int
+	var longest uint32
+	for j := range head {
+		if n := head[j] + tail[len(b)-j]; n > longest {
+			split, longest = j, n
+		}
+	}
+	buffer = lcsLinear(a[:mid], b[:split], buffer)
+	return lcsLinear(a[mid:], b[split:], buffer)
 }
 
 // DecrBy redis DECRBY
+//
+// The decrement could not be the minimum integer, whose negation overflows.
 func (ds *DS) DecrBy(key, increment []byte) (int64, error) {
 	n, err := strconv.ParseInt(string(increment), 10, 64)
 	if err != nil {
 		return 0, ErrInvalidInteger
 	}
+	if n == math.MinInt64 {
+		return 0, ErrDecrementOverflow
+	}
 	return ds.setInteger(key, -n)
 }
 
@@ -169,72 +614,79 @@ func (ds *DS) IncrByFloat(key, increment []byte) (float64, error) {
 	return ds.setFloat(key, n)
 }
 
+// setInteger increments an integer by n, and keeps the expire of the key
 func (ds *DS) setInteger(key []byte, n int64) (int64, error) {
-	var number int64
-	var err error
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
 
-	value, err := ds.Get(key)
-	if err != nil && !errors.Is(err, baradb.ErrKeyNotFound) {
+	value, expire, err := ds.getString(key)
+	if err != nil && err != baradb.ErrKeyNotFound {
 		return 0, err
 	}
 
-	if len(value) == 0 {
-		number = 0
-	} else {
+	var number int64
+	if err == nil {
 		number, err = strconv.ParseInt(string(value), 10, 64)
 		if err != nil {
 			return 0, ErrInvalidInteger
 		}
 	}
 
-	if n < math.MinInt64 || n > math.MaxInt64 {
-		return 0, ErrInvalidInteger
-	}
-
 	condition1 := n < 0 && number < 0 && n < math.MinInt64-number
 	condition2 := n > 0 && number > 0 && n > math.MaxInt64-number
 	if condition1 || condition2 {
-		return 0, ErrInvalidInteger
+		return 0, ErrIntegerOverflow
 	}
 
 	number += n
 	buffer := []byte(strconv.FormatInt(number, 10))
-	err = ds.Set(key, buffer, 0)
-	if err != nil {
+	if err := ds.db.Put(key, encodeString(buffer, expire)); err != nil {
 		return 0, err
 	}
 	return number, nil
 }
 
+// setFloat increments a float by n, and keeps the expire of the key
 func (ds *DS) setFloat(key []byte, n float64) (float64, error) {
-	var number float64
-	var err error
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
 
-	value, err := ds.Get(key)
-	if err != nil && !errors.Is(err, baradb.ErrKeyNotFound) {
+	value, expire, err := ds.getString(key)
+	if err != nil && err != baradb.ErrKeyNotFound {
 		return 0, err
 	}
 
-	if len(value) == 0 {
-		number = 0
-	} else {
+	var number float64
+	if err == nil {
 		number, err = strconv.ParseFloat(string(value), 64)
 		if err != nil {
 			return 0, ErrInvalidFloat
 		}
 	}
 
-	condition1 := n < 0 && number < 0 && n < math.SmallestNonzeroFloat64-number
+	condition1 := n < 0 && number < 0 && n < -math.MaxFloat64-number
 	condition2 := n > 0 && number > 0 && n > math.MaxFloat64-number
 	if condition1 || condition2 {
-		return 0, ErrInvalidFloat
+		return 0, ErrFloatOverflow
 	}
 
 	number += n
 	buffer := utils.Float64ToBytes(number)
-	err = ds.Set(key, buffer, 0)
-	if err != nil {
+	if err := ds.db.Put(key, encodeString(buffer, expire)); err != nil {
 		return 0, err
 	}
 	return number, nil
 }
+
+// getString gets a string along with its encoded expire
+func (ds *DS) getString(key []byte) ([]byte, int64, error) {
+	encValue, err := ds.getValue(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	if encValue[0] != String {
+		return nil, 0, ErrWrongTypeOperation
+	}
+	expire, _ := binary.Varint(encValue[1:])
+	return decodeString(encValue), expire, nil
+}
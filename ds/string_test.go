@@ -1,7 +1,10 @@
 package ds
 
 import (
+	"bytes"
+	"fmt"
 	"math"
+	"math/rand"
 	"os"
 	"strconv"
 	"testing"
@@ -22,7 +25,7 @@ func init() {
 
 // destroyDS a teardown method for clearing resources after testing
 func destroyDS(ds *DS, dir string) {
-	ds.db.Close()
+	ds.Close()
 	os.RemoveAll(dir)
 }
 
@@ -78,7 +81,7 @@ func TestDS_Get(t *testing.T) {
 	assert.Nil(t, err)
 	assert.NotNil(t, value)
 	value, err = ds.Get(utils.NewKey(514))
-	assert.Equal(t, ErrExpiredValue, err)
+	assert.Equal(t, baradb.ErrKeyNotFound, err)
 	assert.Nil(t, value)
 
 	value, err = ds.Get(utils.NewKey(1919))
@@ -243,7 +246,7 @@ func TestDS_Incr(t *testing.T) {
 	assert.Nil(t, err)
 
 	value, err = ds.Incr(key2)
-	assert.ErrorIs(t, err, ErrInvalidInteger)
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
 	assert.Equal(t, int64(0), value)
 }
 
@@ -279,7 +282,7 @@ func TestDS_IncrBy(t *testing.T) {
 	assert.Nil(t, err)
 
 	value, err = ds.IncrBy(key2, []byte("2"))
-	assert.ErrorIs(t, err, ErrInvalidInteger)
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
 	assert.Equal(t, int64(0), value)
 }
 
@@ -319,14 +322,14 @@ func TestDS_IncrByFloat(t *testing.T) {
 	assert.Nil(t, err)
 
 	value, err = ds.IncrByFloat(key2, []byte("2.718"))
-	assert.ErrorIs(t, err, ErrInvalidFloat)
+	assert.ErrorIs(t, err, ErrFloatOverflow)
 	assert.Equal(t, float64(0), value)
 
 	err = ds.Set(key2, utils.Float64ToBytes(-math.MaxFloat64), 0)
 	assert.Nil(t, err)
 
 	value, err = ds.IncrByFloat(key2, []byte("-2.718"))
-	assert.ErrorIs(t, err, ErrInvalidFloat)
+	assert.ErrorIs(t, err, ErrFloatOverflow)
 	assert.Equal(t, float64(0), value)
 }
 
@@ -362,7 +365,7 @@ func TestDS_Decr(t *testing.T) {
 	assert.Nil(t, err)
 
 	value, err = ds.Decr(key2)
-	assert.ErrorIs(t, err, ErrInvalidInteger)
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
 	assert.Equal(t, int64(0), value)
 }
 
@@ -398,6 +401,317 @@ func TestDS_DecrBy(t *testing.T) {
 	assert.Nil(t, err)
 
 	value, err = ds.DecrBy(key2, []byte("2"))
-	assert.ErrorIs(t, err, ErrInvalidInteger)
+	assert.ErrorIs(t, err, ErrIntegerOverflow)
 	assert.Equal(t, int64(0), value)
+
+	// the minimum integer could not be negated
+	value, err = ds.DecrBy(key1, []byte(strconv.FormatInt(math.MinInt64, 10)))
+	assert.ErrorIs(t, err, ErrDecrementOverflow)
+	assert.Equal(t, "ERR decrement would overflow", err.Error())
+	assert.Equal(t, int64(0), value)
+	value, err = ds.IncrBy(key1, []byte("0"))
+	assert.Nil(t, err)
+	assert.Equal(t, int64(12), value)
+}
+
+func TestDS_SetWithOptions(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	var oldValue, value []byte
+	var ok bool
+	var err error
+
+	key := []byte("key001")
+
+	// XX fails for a missing key
+	oldValue, ok, err = ds.SetWithOptions(key, []byte("value001"), SetOptions{XX: true})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Nil(t, oldValue)
+	assert.False(t, ds.Exists(key))
+
+	// NX succeeds for a missing key
+	oldValue, ok, err = ds.SetWithOptions(key, []byte("value001"), SetOptions{
+		NX:         true,
+		Expiration: Expiration{TTL: 50 * time.Millisecond},
+	})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Nil(t, oldValue)
+
+	// NX fails for an existing key, but still gets the old value
+	oldValue, ok, err = ds.SetWithOptions(key, []byte("value002"), SetOptions{NX: true, Get: true})
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, []byte("value001"), oldValue)
+
+	// KEEPTTL keeps the expire
+	oldValue, ok, err = ds.SetWithOptions(key, []byte("value003"), SetOptions{
+		XX:         true,
+		Get:        true,
+		Expiration: Expiration{KeepTTL: true},
+	})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value001"), oldValue)
+	time.Sleep(time.Millisecond * 60)
+	value, err = ds.Get(key)
+	assert.Equal(t, baradb.ErrKeyNotFound, err)
+	assert.Nil(t, value)
+
+	// an expire in the past makes the key missing
+	_, ok, err = ds.SetWithOptions(key, []byte("value004"), SetOptions{
+		Expiration: Expiration{ExpireAt: time.Now().Add(-time.Second)},
+	})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.False(t, ds.Exists(key))
+
+	// an expire after the year 2262 is invalid
+	for _, e := range []Expiration{
+		{TTL: math.MaxInt64},
+		{TTL: 9000000000000 * time.Millisecond},
+		{ExpireAt: time.Unix(32503680000, 0)},
+	} {
+		_, ok, err = ds.SetWithOptions(key, []byte("value004"), SetOptions{Expiration: e})
+		assert.Equal(t, ErrInvalidExpireTime, err)
+		assert.False(t, ok)
+	}
+	assert.Equal(t, ErrInvalidExpireTime, ds.NewBatch().Set(key, []byte("value004"), math.MaxInt64))
+
+	// GET fails for a key holding another type
+	ds.SAdd([]byte("set-1"), []byte("member-1"))
+	oldValue, ok, err = ds.SetWithOptions([]byte("set-1"), []byte("value005"), SetOptions{Get: true})
+	assert.ErrorIs(t, err, ErrWrongTypeOperation)
+	assert.False(t, ok)
+	assert.Nil(t, oldValue)
+
+	// otherwise SET overwrites a key holding another type
+	_, ok, err = ds.SetWithOptions([]byte("set-1"), []byte("value005"), SetOptions{})
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	value, err = ds.Get([]byte("set-1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value005"), value)
+
+	// an empty string is a valid value
+	err = ds.Set(key, []byte{}, 0)
+	assert.Nil(t, err)
+	value, err = ds.Get(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{}, value)
+}
+
+func TestDS_GetEx(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	var value []byte
+	var err error
+
+	key := []byte("key001")
+
+	value, err = ds.GetEx(key, Expiration{TTL: time.Second})
+	assert.Equal(t, baradb.ErrKeyNotFound, err)
+	assert.Nil(t, value)
+
+	ds.Set(key, []byte("value001"), time.Millisecond*500)
+
+	// PERSIST removes the expire
+	value, err = ds.GetEx(key, Expiration{Persist: true})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value001"), value)
+	time.Sleep(time.Millisecond * 600)
+	assert.True(t, ds.Exists(key))
+
+	value, err = ds.GetEx(key, Expiration{TTL: time.Millisecond * 100})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("value001"), value)
+	time.Sleep(time.Millisecond * 200)
+	assert.False(t, ds.Exists(key))
+}
+
+func TestDS_MSet(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	// more pairs than the default maximum of a write batch
+	pairs := make([][]byte, 0, 300)
+	keys := make([][]byte, 0, 150)
+	for i := 0; i < 150; i++ {
+		key := []byte(fmt.Sprintf("key%03d", i))
+		pairs = append(pairs, key, []byte(fmt.Sprintf("value%03d", i)))
+		keys = append(keys, key)
+	}
+	err := ds.MSet(pairs...)
+	assert.Nil(t, err)
+
+	values := ds.MGet(keys...)
+	assert.Equal(t, 150, len(values))
+	assert.EqualValues(t, "value000", values[0])
+	assert.EqualValues(t, "value149", values[149])
+
+	_, err = ds.LPush([]byte("list"), []byte("a"))
+	assert.Nil(t, err)
+	values = ds.MGet([]byte("key000"), []byte("unknown"), []byte("list"))
+	assert.EqualValues(t, [][]byte{[]byte("value000"), nil, nil}, values)
+
+	err = ds.MSet([]byte("key000"))
+	assert.Equal(t, ErrUnsupportedOperation, err)
+}
+
+func TestDS_MSetNx(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	ok, err := ds.MSetNx([]byte("key001"), []byte("value001"), []byte("key002"), []byte("value002"))
+	assert.Nil(t, err)
+	assert.True(t, ok)
+
+	ok, err = ds.MSetNx([]byte("key003"), []byte("value003"), []byte("key002"), []byte("value004"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.False(t, ds.Exists([]byte("key003")))
+
+	value, err := ds.Get([]byte("key002"))
+	assert.Nil(t, err)
+	assert.EqualValues(t, "value002", value)
+}
+
+func TestDS_GetRange(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("key001")
+	err := ds.Set(key, []byte("This is a string"), 0)
+	assert.Nil(t, err)
+
+	cases := []struct {
+		start, end int64
+		expected   string
+	}{
+		{0, 3, "This"},
+		{-3, -1, "ing"},
+		{0, -1, "This is a string"},
+		{10, 100, "string"},
+		{-1, -5, ""},
+		{5, 3, ""},
+	}
+	for _, c := range cases {
+		value, err := ds.GetRange(key, c.start, c.end)
+		assert.Nil(t, err)
+		assert.EqualValues(t, c.expected, value)
+	}
+
+	value, err := ds.GetRange([]byte("unknown"), 0, -1)
+	assert.Nil(t, err)
+	assert.Empty(t, value)
+}
+
+func TestDS_SetRange(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("key001")
+	_, _, err := ds.SetWithOptions(key, []byte("Hello World"), SetOptions{Expiration: Expiration{TTL: time.Hour}})
+	assert.Nil(t, err)
+
+	n, err := ds.SetRange(key, 6, []byte("Redis"))
+	assert.Nil(t, err)
+	assert.Equal(t, 11, n)
+	value, err := ds.Get(key)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "Hello Redis", value)
+	// the expire is kept
+	_, expire, _ := ds.getString(key)
+	assert.NotZero(t, expire)
+
+	// padded with zero bytes
+	key = []byte("key002")
+	n, err = ds.SetRange(key, 3, []byte("abc"))
+	assert.Nil(t, err)
+	assert.Equal(t, 6, n)
+	value, err = ds.Get(key)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "\x00\x00\x00abc", value)
+
+	// an empty value does not create the key
+	n, err = ds.SetRange([]byte("key003"), 3, []byte{})
+	assert.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.False(t, ds.Exists([]byte("key003")))
+
+	_, err = ds.SetRange(key, -1, []byte("abc"))
+	assert.Equal(t, ErrOffsetOutOfRange, err)
+	_, err = ds.SetRange(key, maxStringLength, []byte("abc"))
+	assert.Equal(t, ErrStringTooLong, err)
+}
+
+func TestDS_LCS(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	err := ds.MSet([]byte("key1"), []byte("ohmytext"), []byte("key2"), []byte("mynewtext"))
+	assert.Nil(t, err)
+
+	result, err := ds.LCS([]byte("key1"), []byte("key2"), false)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "mytext", result.LCS)
+	assert.Empty(t, result.Matches)
+
+	result, err = ds.LCS([]byte("key1"), []byte("key2"), true)
+	assert.Nil(t, err)
+	assert.EqualValues(t, "mytext", result.LCS)
+	assert.Equal(t, []LCSMatch{
+		{A: [2]int{4, 7}, B: [2]int{5, 8}, Len: 4},
+		{A: [2]int{2, 3}, B: [2]int{0, 1}, Len: 2},
+	}, result.Matches)
+
+	result, err = ds.LCS([]byte("key1"), []byte("unknown"), true)
+	assert.Nil(t, err)
+	assert.Empty(t, result.LCS)
+	assert.Empty(t, result.Matches)
+
+	_, err = ds.LPush([]byte("list"), []byte("a"))
+	assert.Nil(t, err)
+	_, err = ds.LCS([]byte("key1"), []byte("list"), false)
+	assert.Equal(t, ErrLCSNotString, err)
+
+	// the LCS of long strings takes linear memory, while their matched ranges take too much
+	long1, long2 := bytes.Repeat([]byte("ab"), 3000), bytes.Repeat([]byte("ba"), 3000)
+	assert.Nil(t, ds.MSet([]byte("long1"), long1, []byte("long2"), long2))
+	result, err = ds.LCS([]byte("long1"), []byte("long2"), false)
+	assert.Nil(t, err)
+	assert.Len(t, result.LCS, 5999)
+	_, err = ds.LCS([]byte("long1"), []byte("long2"), true)
+	assert.Equal(t, ErrLCSTooLong, err)
+}
+
+func TestLCSLinear(t *testing.T) {
+	r := rand.New(rand.NewSource(0))
+	for i := 0; i < 200; i++ {
+		a, b := make([]byte, r.Intn(50)), make([]byte, r.Intn(50))
+		for _, s := range [][]byte{a, b} {
+			for j := range s {
+				s[j] = "abcd"[r.Intn(4)]
+			}
+		}
+		result, err := lcs(a, b)
+		assert.Nil(t, err)
+		common := lcsLinear(a, b, nil)
+		assert.Len(t, common, len(result.LCS), "%s %s", a, b)
+		assert.Equal(t, uint32(len(common)), lcsLengths(a, b, false)[len(b)])
+		assert.True(t, isSubsequence(common, a) && isSubsequence(common, b), "%s %s %s", common, a, b)
+	}
+}
+
+// isSubsequence tells whether s is a subsequence of t
+func isSubsequence(s, t []byte) bool {
+	for _, c := range t {
+		if len(s) > 0 && s[0] == c {
+			s = s[1:]
+		}
+	}
+	return len(s) == 0
 }
@@ -0,0 +1,540 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"sort"
+	"time"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/saint-yellow/baradb/index"
+)
+
+// Time series are stored as a metadata along with internal keys of chunks:
+//
+//	key + version + 'c' + the timestamp of the first sample -> samples of the chunk
+//
+// Timestamps are in big endian, so that chunks are ordered by timestamps.
+const timeSeriesChunkTag = 'c'
+
+// timeSeriesIndexPrefix is the prefix of keys indexing time series for TS.MRANGE:
+//
+//	prefix + key -> version
+//
+// Entries of removed time series are skipped by comparing versions, and are dropped by TS.MRANGE.
+// The prefix is reserved, so that clients can not change the index.
+var timeSeriesIndexPrefix = append(bytes.Clone(reservedPrefix), "timeseries\x00"...)
+
+// encodings of chunks, which are the first bytes of chunks
+const (
+	tsChunkCompressed byte = iota
+	tsChunkUncompressed
+)
+
+// DuplicatePolicy is how a sample is added at the timestamp of an existing sample
+type DuplicatePolicy byte
+
+const (
+	DuplicateBlock DuplicatePolicy = iota // fails
+	DuplicateFirst                        // keeps the existing value
+	DuplicateLast                         // replaces the existing value
+	DuplicateMin                          // keeps the smaller value
+	DuplicateMax                          // keeps the larger value
+	DuplicateSum                          // adds the value to the existing one
+)
+
+// resolve gets the value at a timestamp after a value is added at the timestamp
+func (p DuplicatePolicy) resolve(existing, value float64) (float64, error) {
+	switch p {
+	case DuplicateFirst:
+		return existing, nil
+	case DuplicateLast:
+		return value, nil
+	case DuplicateMin:
+		return math.Min(existing, value), nil
+	case DuplicateMax:
+		return math.Max(existing, value), nil
+	case DuplicateSum:
+		return existing + value, nil
+	default:
+		return 0, ErrTSDuplicateBlocked
+	}
+}
+
+// TSLabel is a label of a time series
+type TSLabel struct {
+	Name  string
+	Value string
+}
+
+// TSSample is a sample of a time series
+type TSSample struct {
+	Timestamp int64 // Unix time in milliseconds
+	Value     float64
+}
+
+// TSOptions options of a time series
+type TSOptions struct {
+	Retention       int64 // the maximum age in milliseconds of samples compared to the last one, 0 if samples never expire
+	ChunkSize       int   // the size in bytes of a chunk, which is exceeded by a chunk of a single sample
+	Uncompressed    bool
+	DuplicatePolicy DuplicatePolicy
+	Labels          []TSLabel
+}
+
+// DefaultTSOptions are the defaults of a time series, which are the defaults of RedisTimeSeries
+var DefaultTSOptions = TSOptions{
+	ChunkSize:       4096,
+	DuplicatePolicy: DuplicateBlock,
+}
+
+// TSAddOptions are options of TS.ADD
+type TSAddOptions struct {
+	TSOptions                    // options of the time series if it is created
+	OnDuplicate *DuplicatePolicy // overrides the duplicate policy of the time series
+}
+
+// TSInfo information of a time series replied by TS.INFO
+type TSInfo struct {
+	TSOptions
+	TotalSamples   uint32
+	FirstTimestamp int64
+	LastTimestamp  int64
+	Chunks         int
+}
+
+// timeSeriesMetadata is a metadata of a time series, which begins with the same fields as other collections
+type timeSeriesMetadata struct {
+	expire         int64
+	version        int64
+	size           uint32 // the number of samples, including expired ones not trimmed yet
+	lastTimestamp  int64  // the timestamp of the last sample if the time series is not empty
+	firstTimestamp int64  // the timestamp of the first sample, including expired ones not trimmed yet
+	opts           TSOptions
+}
+
+func (md *timeSeriesMetadata) encode() []byte {
+	buffer := make([]byte, 1, 64)
+	buffer[0] = TimeSeries
+	buffer = binary.AppendVarint(buffer, md.expire)
+	buffer = binary.AppendVarint(buffer, md.version)
+	buffer = binary.AppendVarint(buffer, int64(md.size))
+	buffer = binary.AppendVarint(buffer, md.lastTimestamp)
+	buffer = binary.AppendVarint(buffer, md.firstTimestamp)
+	buffer = binary.AppendVarint(buffer, md.opts.Retention)
+	buffer = binary.AppendUvarint(buffer, uint64(md.opts.ChunkSize))
+	uncompressed := byte(0)
+	if md.opts.Uncompressed {
+		uncompressed = 1
+	}
+	buffer = append(buffer, uncompressed, byte(md.opts.DuplicatePolicy))
+	buffer = binary.AppendUvarint(buffer, uint64(len(md.opts.Labels)))
+	for _, label := range md.opts.Labels {
+		buffer = binary.AppendUvarint(buffer, uint64(len(label.Name)))
+		buffer = append(buffer, label.Name...)
+		buffer = binary.AppendUvarint(buffer, uint64(len(label.Value)))
+		buffer = append(buffer, label.Value...)
+	}
+	return buffer
+}
+
+func decodeTimeSeriesMetadata(buffer []byte) *timeSeriesMetadata {
+	d := &fieldDecoder{buffer: buffer, index: 1}
+	md := &timeSeriesMetadata{}
+	md.expire = d.varint()
+	md.version = d.varint()
+	md.size = uint32(d.varint())
+	md.lastTimestamp = d.varint()
+	md.firstTimestamp = d.varint()
+	md.opts.Retention = d.varint()
+	md.opts.ChunkSize = int(d.uvarint())
+	md.opts.Uncompressed = buffer[d.index] == 1
+	md.opts.DuplicatePolicy = DuplicatePolicy(buffer[d.index+1])
+	d.index += 2
+	md.opts.Labels = make([]TSLabel, d.uvarint())
+	for i := range md.opts.Labels {
+		md.opts.Labels[i].Name = d.string()
+		md.opts.Labels[i].Value = d.string()
+	}
+	return md
+}
+
+// getTimeSeriesMetadata gets the metadata of a time series, which is nil if the key does not exist.
+//
+// Like internal keys of expired collections, samples expired by the retention are found here,
+// and they are trimmed by the reclaimer in the background.
+func (ds *DS) getTimeSeriesMetadata(key []byte) (*timeSeriesMetadata, error) {
+	value, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if value[0] != TimeSeries {
+		return nil, ErrWrongTypeOperation
+	}
+	md := decodeTimeSeriesMetadata(value)
+	if md.expired() {
+		ds.reclaimer.trim(key, md.version)
+	}
+	return md, nil
+}
+
+// minTimestamp gets the minimum timestamp of samples not expired by the retention
+func (md *timeSeriesMetadata) minTimestamp() int64 {
+	if md.opts.Retention == 0 || md.size == 0 || md.lastTimestamp < md.opts.Retention {
+		return 0
+	}
+	return md.lastTimestamp - md.opts.Retention
+}
+
+// expired tells whether some samples are expired by the retention but not trimmed yet
+func (md *timeSeriesMetadata) expired() bool {
+	return md.size > 0 && md.firstTimestamp < md.minTimestamp()
+}
+
+// label gets the value of a label, which is empty if the time series does not have the label
+func (md *timeSeriesMetadata) label(name string) string {
+	for _, label := range md.opts.Labels {
+		if label.Name == name {
+			return label.Value
+		}
+	}
+	return ""
+}
+
+func timeSeriesChunkPrefix(key []byte, version int64) []byte {
+	return append(internalKeyPrefix(key, version), timeSeriesChunkTag)
+}
+
+func timeSeriesChunkKey(key []byte, version int64, timestamp int64) []byte {
+	return binary.BigEndian.AppendUint64(timeSeriesChunkPrefix(key, version), uint64(timestamp))
+}
+
+func timeSeriesIndexKey(key []byte) []byte {
+	return append(bytes.Clone(timeSeriesIndexPrefix), key...)
+}
+
+// encodeTSChunk encodes samples of a chunk: encoding + the number of samples + samples.
+//
+// Compressed samples are encoded like Gorilla of Facebook, but in bytes rather than bits:
+// a timestamp is encoded as the delta of the delta from the previous one,
+// and a value is XORed with the previous one and reversed in bits,
+// so that both of them are small varints for regular samples.
+func encodeTSChunk(samples []TSSample, uncompressed bool) []byte {
+	if uncompressed {
+		buffer := make([]byte, 0, 1+binary.MaxVarintLen32+len(samples)*16)
+		buffer = append(buffer, tsChunkUncompressed)
+		buffer = binary.AppendUvarint(buffer, uint64(len(samples)))
+		for _, s := range samples {
+			buffer = binary.BigEndian.AppendUint64(buffer, uint64(s.Timestamp))
+			buffer = binary.BigEndian.AppendUint64(buffer, math.Float64bits(s.Value))
+		}
+		return buffer
+	}
+
+	buffer := make([]byte, 0, 1+binary.MaxVarintLen32+len(samples)*4)
+	buffer = append(buffer, tsChunkCompressed)
+	buffer = binary.AppendUvarint(buffer, uint64(len(samples)))
+	var previous, delta int64
+	var previousBits uint64
+	for i, s := range samples {
+		if i == 0 {
+			buffer = binary.AppendVarint(buffer, s.Timestamp)
+		} else {
+			buffer = binary.AppendVarint(buffer, s.Timestamp-previous-delta)
+			delta = s.Timestamp - previous
+		}
+		previous = s.Timestamp
+		valueBits := math.Float64bits(s.Value)
+		buffer = binary.AppendUvarint(buffer, bits.Reverse64(valueBits^previousBits))
+		previousBits = valueBits
+	}
+	return buffer
+}
+
+func decodeTSChunk(buffer []byte) []TSSample {
+	d := &fieldDecoder{buffer: buffer, index: 1}
+	samples := make([]TSSample, d.uvarint())
+	if buffer[0] == tsChunkUncompressed {
+		for i := range samples {
+			samples[i].Timestamp = int64(binary.BigEndian.Uint64(buffer[d.index:]))
+			samples[i].Value = math.Float64frombits(binary.BigEndian.Uint64(buffer[d.index+8:]))
+			d.index += 16
+		}
+		return samples
+	}
+
+	var previous, delta int64
+	var previousBits uint64
+	for i := range samples {
+		if i == 0 {
+			samples[i].Timestamp = d.varint()
+		} else {
+			samples[i].Timestamp = previous + delta + d.varint()
+			delta = samples[i].Timestamp - previous
+		}
+		previous = samples[i].Timestamp
+		previousBits ^= bits.Reverse64(d.uvarint())
+		samples[i].Value = math.Float64frombits(previousBits)
+	}
+	return samples
+}
+
+// tsChunkSize gets the number of samples of a chunk without decoding it
+func tsChunkSize(buffer []byte) uint32 {
+	n, _ := binary.Uvarint(buffer[1:])
+	return uint32(n)
+}
+
+// tsChunk is a chunk of a time series found by its internal key
+type tsChunk struct {
+	encKey  []byte // nil if the chunk does not exist yet
+	samples []TSSample
+}
+
+// findTSChunk finds the chunk where a sample at the timestamp belongs,
+// which is the last chunk starting at or before the timestamp, or the first chunk if there is none
+func (ds *DS) findTSChunk(key []byte, md *timeSeriesMetadata, timestamp int64) (*tsChunk, error) {
+	prefix := timeSeriesChunkPrefix(key, md.version)
+	for _, reverse := range []bool{true, false} {
+		opts := index.DefaultIteratorOptions
+		opts.Prefix = prefix
+		opts.Reverse = reverse
+		iter := ds.db.NewItrerator(opts)
+		iter.Seek(timeSeriesChunkKey(key, md.version, timestamp))
+		if iter.Valid() && bytes.HasPrefix(iter.Key(), prefix) {
+			value, err := iter.Value()
+			encKey := bytes.Clone(iter.Key())
+			iter.Close()
+			if err != nil {
+				return nil, err
+			}
+			return &tsChunk{encKey: encKey, samples: decodeTSChunk(value)}, nil
+		}
+		iter.Close()
+	}
+	return &tsChunk{}, nil
+}
+
+// TSCreate redis TS.CREATE
+func (ds *DS) TSCreate(key []byte, opts TSOptions) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, err := ds.getTimeSeriesMetadata(key)
+	if err != nil {
+		return err
+	}
+	if md != nil {
+		return ErrTSKeyExists
+	}
+	md = &timeSeriesMetadata{version: time.Now().UnixNano(), opts: opts}
+	wb := ds.db.NewWriteBatch(baradb.DefaultWriteBatchOptions)
+	if err := wb.Put(key, md.encode()); err != nil {
+		return err
+	}
+	if err := wb.Put(timeSeriesIndexKey(key), binary.AppendVarint(nil, md.version)); err != nil {
+		return err
+	}
+	return wb.Commit()
+}
+
+// TSAdd redis TS.ADD
+//
+// It adds a sample to a time series, which is created with opts if it does not exist.
+func (ds *DS) TSAdd(key []byte, timestamp int64, value float64, opts TSAddOptions) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	md, err := ds.getTimeSeriesMetadata(key)
+	if err != nil {
+		return err
+	}
+	wb := ds.db.NewWriteBatch(baradb.DefaultWriteBatchOptions)
+	if md == nil {
+		md = &timeSeriesMetadata{version: time.Now().UnixNano(), opts: opts.TSOptions}
+		if err := wb.Put(timeSeriesIndexKey(key), binary.AppendVarint(nil, md.version)); err != nil {
+			return err
+		}
+	}
+	if timestamp < md.minTimestamp() {
+		return ErrTSTimestampTooOld
+	}
+	policy := md.opts.DuplicatePolicy
+	if opts.OnDuplicate != nil {
+		policy = *opts.OnDuplicate
+	}
+
+	chunk, err := ds.findTSChunk(key, md, timestamp)
+	if err != nil {
+		return err
+	}
+	samples := chunk.samples
+	i := sort.Search(len(samples), func(i int) bool {
+		return samples[i].Timestamp >= timestamp
+	})
+	if i < len(samples) && samples[i].Timestamp == timestamp {
+		if samples[i].Value, err = policy.resolve(samples[i].Value, value); err != nil {
+			return err
+		}
+	} else {
+		samples = append(samples, TSSample{})
+		copy(samples[i+1:], samples[i:])
+		samples[i] = TSSample{Timestamp: timestamp, Value: value}
+		md.size++
+	}
+	appended := md.size == 1 || timestamp > md.lastTimestamp
+	if appended {
+		md.lastTimestamp = timestamp
+	}
+	if md.size == 1 || timestamp < md.firstTimestamp {
+		md.firstTimestamp = timestamp
+	}
+
+	// a full chunk is split in halves, or a new chunk is started if the sample is appended
+	chunks := [][]TSSample{samples}
+	if len(samples) > 1 && len(encodeTSChunk(samples, md.opts.Uncompressed)) > md.opts.ChunkSize {
+		split := len(samples) / 2
+		if appended {
+			split = len(samples) - 1
+		}
+		chunks = [][]TSSample{samples[:split], samples[split:]}
+	}
+	if chunk.encKey != nil && samples[0].Timestamp != int64(binary.BigEndian.Uint64(chunk.encKey[len(chunk.encKey)-8:])) {
+		if err := wb.Delete(chunk.encKey); err != nil {
+			return err
+		}
+	}
+	for _, c := range chunks {
+		if err := wb.Put(timeSeriesChunkKey(key, md.version, c[0].Timestamp), encodeTSChunk(c, md.opts.Uncompressed)); err != nil {
+			return err
+		}
+	}
+	if err := wb.Put(key, md.encode()); err != nil {
+		return err
+	}
+	if err := wb.Commit(); err != nil {
+		return err
+	}
+	if md.expired() {
+		ds.reclaimer.trim(key, md.version)
+	}
+	return nil
+}
+
+// trimTimeSeries deletes samples expired by the retention of a time series of the version,
+// where chunks with only expired samples are deleted, and the chunk with the first sample not expired is rewritten
+func (ds *DS) trimTimeSeries(key []byte, version int64) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	value, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil
+	}
+	if err != nil || value[0] != TimeSeries {
+		return err
+	}
+	md := decodeTimeSeriesMetadata(value)
+	if md.version != version || !md.expired() {
+		return nil
+	}
+
+	minTimestamp := md.minTimestamp()
+	var encKeys [][]byte
+	var kept []TSSample
+	var trimmed uint32
+	prefix := timeSeriesChunkPrefix(key, md.version)
+	err = ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		if int64(binary.BigEndian.Uint64(encKey[len(encKey)-8:])) >= minTimestamp {
+			return false, nil
+		}
+		encKeys = append(encKeys, bytes.Clone(encKey))
+		samples := decodeTSChunk(value)
+		i := sort.Search(len(samples), func(i int) bool {
+			return samples[i].Timestamp >= minTimestamp
+		})
+		trimmed += uint32(i)
+		kept = samples[i:]
+		return len(kept) == 0, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = len(encKeys) + 2
+	wb := ds.db.NewWriteBatch(opts)
+	for _, encKey := range encKeys {
+		if err := wb.Delete(encKey); err != nil {
+			return err
+		}
+	}
+	md.firstTimestamp = minTimestamp
+	if len(kept) > 0 {
+		md.firstTimestamp = kept[0].Timestamp
+		if err := wb.Put(timeSeriesChunkKey(key, md.version, kept[0].Timestamp), encodeTSChunk(kept, md.opts.Uncompressed)); err != nil {
+			return err
+		}
+	}
+	md.size -= trimmed
+	if err := wb.Put(key, md.encode()); err != nil {
+		return err
+	}
+	return wb.Commit()
+}
+
+// TSGet redis TS.GET
+//
+// It gets the last sample of a time series, which is nil if the time series is empty.
+func (ds *DS) TSGet(key []byte) (*TSSample, error) {
+	md, err := ds.getTimeSeriesMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, ErrTSKeyNotFound
+	}
+	if md.size == 0 {
+		return nil, nil
+	}
+	chunk, err := ds.findTSChunk(key, md, md.lastTimestamp)
+	if err != nil {
+		return nil, err
+	}
+	return &chunk.samples[len(chunk.samples)-1], nil
+}
+
+// TSInfo redis TS.INFO
+func (ds *DS) TSInfo(key []byte) (*TSInfo, error) {
+	md, err := ds.getTimeSeriesMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, ErrTSKeyNotFound
+	}
+
+	info := &TSInfo{TSOptions: md.opts, TotalSamples: md.size}
+	if md.size > 0 {
+		info.LastTimestamp = md.lastTimestamp
+		samples, err := ds.TSRange(key, TSRangeOptions{From: 0, To: math.MaxInt64, Count: 1})
+		if err != nil {
+			return nil, err
+		}
+		info.FirstTimestamp = samples[0].Timestamp
+	}
+	prefix := timeSeriesChunkPrefix(key, md.version)
+	err = ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		info.Chunks++
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, nil
+}
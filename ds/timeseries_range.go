@@ -0,0 +1,294 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// TSAggregator is how samples in a time bucket are aggregated
+type TSAggregator byte
+
+const (
+	TSAggregateAvg TSAggregator = iota
+	TSAggregateSum
+	TSAggregateMin
+	TSAggregateMax
+	TSAggregateCount
+	TSAggregateFirst
+	TSAggregateLast
+)
+
+// TSBucketTimestamp is which timestamp of a time bucket is reported
+type TSBucketTimestamp byte
+
+const (
+	TSBucketStart TSBucketTimestamp = iota
+	TSBucketEnd
+	TSBucketMid
+)
+
+// TSAggregation aggregates samples in time buckets
+type TSAggregation struct {
+	Aggregator      TSAggregator
+	BucketDuration  int64 // milliseconds
+	Align           int64 // buckets start at Align + n * BucketDuration
+	BucketTimestamp TSBucketTimestamp
+}
+
+// TSRangeOptions are options of TS.RANGE and TS.MRANGE
+type TSRangeOptions struct {
+	From          int64 // inclusive
+	To            int64 // inclusive
+	Reverse       bool
+	FilterByTS    []int64 // keeps samples at these timestamps only if it is not empty
+	FilterByValue bool    // keeps samples with values between MinValue and MaxValue, both inclusive
+	MinValue      float64
+	MaxValue      float64
+	Count         int // the maximum number of samples, or buckets if aggregated, 0 if there is no limit
+	Aggregation   *TSAggregation
+}
+
+// TSFilter is a matcher of labels of time series for TS.MRANGE,
+// such as label=value, label!=value, label=(value1,value2) and label!=(value1,value2).
+//
+// A missing label has an empty value, so label= matches time series without the label,
+// and label!= matches ones with the label.
+type TSFilter struct {
+	Label  string
+	Values []string
+	Negate bool
+}
+
+func (f TSFilter) match(md *timeSeriesMetadata) bool {
+	value := md.label(f.Label)
+	for _, v := range f.Values {
+		if v == value {
+			return !f.Negate
+		}
+	}
+	return f.Negate
+}
+
+// TSSeries is samples of a time series replied by TS.MRANGE
+type TSSeries struct {
+	Key     []byte
+	Labels  []TSLabel
+	Samples []TSSample
+}
+
+// tsBucket aggregates samples in a time bucket
+type tsBucket struct {
+	start int64
+	count int
+	value float64
+}
+
+func (b *tsBucket) add(aggregator TSAggregator, value float64) {
+	switch {
+	case b.count == 0:
+		b.value = value
+	case aggregator == TSAggregateSum || aggregator == TSAggregateAvg:
+		b.value += value
+	case aggregator == TSAggregateMin:
+		b.value = math.Min(b.value, value)
+	case aggregator == TSAggregateMax:
+		b.value = math.Max(b.value, value)
+	case aggregator == TSAggregateLast:
+		b.value = value
+	}
+	b.count++
+}
+
+func (b *tsBucket) result(aggregator TSAggregator) float64 {
+	switch aggregator {
+	case TSAggregateAvg:
+		return b.value / float64(b.count)
+	case TSAggregateCount:
+		return float64(b.count)
+	default:
+		return b.value
+	}
+}
+
+// aggregate aggregates samples in ascending order of timestamps
+func (a *TSAggregation) aggregate(samples []TSSample) []TSSample {
+	var results []TSSample
+	var bucket *tsBucket
+	flush := func() {
+		if bucket == nil {
+			return
+		}
+		timestamp := bucket.start
+		switch a.BucketTimestamp {
+		case TSBucketEnd:
+			timestamp += a.BucketDuration
+		case TSBucketMid:
+			timestamp += a.BucketDuration / 2
+		}
+		results = append(results, TSSample{Timestamp: timestamp, Value: bucket.result(a.Aggregator)})
+	}
+	for _, s := range samples {
+		offset := (s.Timestamp - a.Align) % a.BucketDuration
+		if offset < 0 {
+			offset += a.BucketDuration
+		}
+		if start := s.Timestamp - offset; bucket == nil || bucket.start != start {
+			flush()
+			bucket = &tsBucket{start: start}
+		}
+		bucket.add(a.Aggregator, s.Value)
+	}
+	flush()
+	return results
+}
+
+// TSRange redis TS.RANGE and TS.REVRANGE
+func (ds *DS) TSRange(key []byte, opts TSRangeOptions) ([]TSSample, error) {
+	md, err := ds.getTimeSeriesMetadata(key)
+	if err != nil {
+		return nil, err
+	}
+	if md == nil {
+		return nil, ErrTSKeyNotFound
+	}
+	return ds.timeSeriesRange(key, md, opts)
+}
+
+func (ds *DS) timeSeriesRange(key []byte, md *timeSeriesMetadata, opts TSRangeOptions) ([]TSSample, error) {
+	from := opts.From
+	if minTimestamp := md.minTimestamp(); from < minTimestamp {
+		from = minTimestamp
+	}
+	if md.size == 0 || opts.To < from {
+		return nil, nil
+	}
+	var timestamps map[int64]bool
+	if len(opts.FilterByTS) > 0 {
+		timestamps = make(map[int64]bool, len(opts.FilterByTS))
+		for _, ts := range opts.FilterByTS {
+			timestamps[ts] = true
+		}
+	}
+	// samples can be limited while they are scanned only if they are neither aggregated nor reversed
+	limit := 0
+	if opts.Aggregation == nil && !opts.Reverse {
+		limit = opts.Count
+	}
+
+	chunk, err := ds.findTSChunk(key, md, from)
+	if err != nil {
+		return nil, err
+	}
+	var samples []TSSample
+	prefix := timeSeriesChunkPrefix(key, md.version)
+	err = ds.scanInternalKeys(prefix, chunk.encKey, func(encKey, value []byte) (bool, error) {
+		if int64(binary.BigEndian.Uint64(encKey[len(encKey)-8:])) > opts.To {
+			return false, nil
+		}
+		for _, s := range decodeTSChunk(value) {
+			if s.Timestamp < from || s.Timestamp > opts.To {
+				continue
+			}
+			if timestamps != nil && !timestamps[s.Timestamp] {
+				continue
+			}
+			if opts.FilterByValue && (s.Value < opts.MinValue || s.Value > opts.MaxValue) {
+				continue
+			}
+			samples = append(samples, s)
+			if limit > 0 && len(samples) == limit {
+				return false, nil
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Aggregation != nil {
+		samples = opts.Aggregation.aggregate(samples)
+	}
+	if opts.Reverse {
+		for i, j := 0, len(samples)-1; i < j; i, j = i+1, j-1 {
+			samples[i], samples[j] = samples[j], samples[i]
+		}
+	}
+	if opts.Count > 0 && len(samples) > opts.Count {
+		samples = samples[:opts.Count]
+	}
+	return samples, nil
+}
+
+// TSMRange redis TS.MRANGE and TS.MREVRANGE
+//
+// It gets samples of all time series matching all filters, in the order of keys.
+func (ds *DS) TSMRange(filters []TSFilter, opts TSRangeOptions) ([]TSSeries, error) {
+	var results []TSSeries
+	var stale [][]byte
+	err := ds.scanInternalKeys(timeSeriesIndexPrefix, timeSeriesIndexPrefix, func(encKey, value []byte) (bool, error) {
+		key := bytes.Clone(encKey[len(timeSeriesIndexPrefix):])
+		version, _ := binary.Varint(value)
+		md, err := ds.getTimeSeriesMetadata(key)
+		if err == ErrWrongTypeOperation || (err == nil && (md == nil || md.version != version)) {
+			stale = append(stale, key)
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		for _, f := range filters {
+			if !f.match(md) {
+				return true, nil
+			}
+		}
+
+		samples, err := ds.timeSeriesRange(key, md, opts)
+		if err != nil {
+			return false, err
+		}
+		results = append(results, TSSeries{Key: key, Labels: md.opts.Labels, Samples: samples})
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := ds.dropTimeSeriesIndex(stale); err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// dropTimeSeriesIndex drops index entries of keys which are no longer the indexed time series,
+// unless they have been indexed again since
+func (ds *DS) dropTimeSeriesIndex(keys [][]byte) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	opts := baradb.DefaultWriteBatchOptions
+	opts.MaxBatchNumber = len(keys)
+	wb := ds.db.NewWriteBatch(opts)
+	for _, key := range keys {
+		value, err := ds.db.Get(timeSeriesIndexKey(key))
+		if err == baradb.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		version, _ := binary.Varint(value)
+		if md, err := ds.getTimeSeriesMetadata(key); err == nil && md != nil && md.version == version {
+			continue
+		}
+		if err := wb.Delete(timeSeriesIndexKey(key)); err != nil {
+			return err
+		}
+	}
+	return wb.Commit()
+}
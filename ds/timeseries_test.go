@@ -0,0 +1,280 @@
+package ds
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_TSAdd(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer func() {
+		destroyDS(ds, testingDBOptions.Directory)
+	}()
+
+	key := []byte("ts")
+	_, err := ds.TSRange(key, TSRangeOptions{To: math.MaxInt64})
+	assert.Equal(t, ErrTSKeyNotFound, err)
+	assert.Nil(t, ds.TSCreate(key, DefaultTSOptions))
+	assert.Equal(t, ErrTSKeyExists, ds.TSCreate(key, DefaultTSOptions))
+	sample, err := ds.TSGet(key)
+	assert.Nil(t, err)
+	assert.Nil(t, sample)
+
+	// out of order samples are inserted in order
+	for _, ts := range []int64{30, 10, 20} {
+		assert.Nil(t, ds.TSAdd(key, ts, float64(ts)/10, TSAddOptions{}))
+	}
+	assert.Equal(t, ErrTSDuplicateBlocked, ds.TSAdd(key, 20, 5, TSAddOptions{}))
+	sum := DuplicateSum
+	assert.Nil(t, ds.TSAdd(key, 20, 5, TSAddOptions{OnDuplicate: &sum}))
+
+	samples, err := ds.TSRange(key, TSRangeOptions{To: math.MaxInt64})
+	assert.Nil(t, err)
+	assert.Equal(t, []TSSample{{10, 1}, {20, 7}, {30, 3}}, samples)
+	sample, err = ds.TSGet(key)
+	assert.Nil(t, err)
+	assert.Equal(t, &TSSample{30, 3}, sample)
+
+	// samples persist after a restart
+	assert.Nil(t, ds.Close())
+	ds, _ = New(testingDBOptions)
+	info, err := ds.TSInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), info.TotalSamples)
+	assert.Equal(t, int64(10), info.FirstTimestamp)
+	assert.Equal(t, int64(30), info.LastTimestamp)
+	assert.Equal(t, 1, info.Chunks)
+	dt, err := ds.Type(key)
+	assert.Nil(t, err)
+	assert.Equal(t, TimeSeries, dt)
+
+	assert.Nil(t, ds.Set([]byte("string"), []byte("value"), 0))
+	assert.Equal(t, ErrWrongTypeOperation, ds.TSAdd([]byte("string"), 1, 1, TSAddOptions{}))
+}
+
+func TestDS_TSDuplicatePolicy(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	tests := []struct {
+		policy DuplicatePolicy
+		value  float64
+	}{
+		{DuplicateFirst, 2},
+		{DuplicateLast, 3},
+		{DuplicateMin, 1},
+		{DuplicateMax, 3},
+		{DuplicateSum, 6},
+	}
+	for _, tt := range tests {
+		key := []byte(fmt.Sprintf("ts-%d", tt.policy))
+		opts := TSAddOptions{TSOptions: DefaultTSOptions}
+		opts.DuplicatePolicy = tt.policy
+		for _, v := range []float64{2, 1, 3} {
+			assert.Nil(t, ds.TSAdd(key, 100, v, opts))
+		}
+		sample, err := ds.TSGet(key)
+		assert.Nil(t, err)
+		assert.Equal(t, tt.value, sample.Value)
+	}
+}
+
+func TestDS_TSChunks(t *testing.T) {
+	for _, uncompressed := range []bool{false, true} {
+		ds, _ := New(testingDBOptions)
+
+		key := []byte("ts")
+		opts := TSOptions{ChunkSize: 128, Uncompressed: uncompressed}
+		assert.Nil(t, ds.TSCreate(key, opts))
+		var expected []TSSample
+		for i := 0; i < 300; i++ {
+			sample := TSSample{Timestamp: int64(1000 + i*10), Value: float64(i%7) * 1.5}
+			expected = append(expected, sample)
+			assert.Nil(t, ds.TSAdd(key, sample.Timestamp, sample.Value, TSAddOptions{}))
+		}
+		// a sample before all chunks and samples between chunks
+		for _, ts := range []int64{5, 1005, 2005} {
+			assert.Nil(t, ds.TSAdd(key, ts, -1, TSAddOptions{}))
+		}
+
+		samples, err := ds.TSRange(key, TSRangeOptions{To: math.MaxInt64})
+		assert.Nil(t, err)
+		assert.Len(t, samples, 303)
+		assert.Equal(t, TSSample{5, -1}, samples[0])
+		assert.Equal(t, TSSample{1005, -1}, samples[2])
+		assert.Equal(t, expected[len(expected)-1], samples[len(samples)-1])
+		for i := 1; i < len(samples); i++ {
+			assert.Less(t, samples[i-1].Timestamp, samples[i].Timestamp)
+		}
+
+		samples, err = ds.TSRange(key, TSRangeOptions{From: 2000, To: 2030})
+		assert.Nil(t, err)
+		assert.Equal(t, []TSSample{expected[100], {2005, -1}, expected[101], expected[102], expected[103]}, samples)
+		info, err := ds.TSInfo(key)
+		assert.Nil(t, err)
+		assert.Greater(t, info.Chunks, 1)
+
+		destroyDS(ds, testingDBOptions.Directory)
+	}
+}
+
+func TestDS_TSRetention(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("ts")
+	assert.Nil(t, ds.TSCreate(key, TSOptions{Retention: 100, ChunkSize: 64}))
+	for ts := int64(0); ts <= 1000; ts += 10 {
+		assert.Nil(t, ds.TSAdd(key, ts, 1, TSAddOptions{}))
+	}
+	assert.Equal(t, ErrTSTimestampTooOld, ds.TSAdd(key, 899, 1, TSAddOptions{}))
+
+	samples, err := ds.TSRange(key, TSRangeOptions{To: math.MaxInt64})
+	assert.Nil(t, err)
+	assert.Len(t, samples, 11)
+	assert.Equal(t, int64(900), samples[0].Timestamp)
+
+	// expired samples are trimmed by the reclaimer
+	ds.reclaimer.drain(ds)
+	info, err := ds.TSInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(900), info.FirstTimestamp)
+	assert.Equal(t, uint32(11), info.TotalSamples)
+	assert.Equal(t, uint32(11), tsStoredSamples(ds, key))
+
+	// expired samples of an idle time series are trimmed once they are found
+	value, err := ds.db.Get(key)
+	assert.Nil(t, err)
+	md := decodeTimeSeriesMetadata(value)
+	md.opts.Retention = 50
+	assert.Nil(t, ds.db.Put(key, md.encode()))
+	sample, err := ds.TSGet(key)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(1000), sample.Timestamp)
+	ds.reclaimer.drain(ds)
+	info, err = ds.TSInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(950), info.FirstTimestamp)
+	assert.Equal(t, uint32(6), info.TotalSamples)
+	assert.Equal(t, uint32(6), tsStoredSamples(ds, key))
+}
+
+// tsStoredSamples counts samples stored in chunks of a time series
+func tsStoredSamples(ds *DS, key []byte) uint32 {
+	value, _ := ds.db.Get(key)
+	prefix := timeSeriesChunkPrefix(key, decodeTimeSeriesMetadata(value).version)
+	var n uint32
+	_ = ds.scanInternalKeys(prefix, prefix, func(encKey, value []byte) (bool, error) {
+		n += uint32(len(decodeTSChunk(value)))
+		return true, nil
+	})
+	return n
+}
+
+func TestDS_TSRangeAggregation(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("ts")
+	for i, v := range []float64{1, 2, 3, 4, 5, 6, 7} {
+		assert.Nil(t, ds.TSAdd(key, int64(i*5), v, TSAddOptions{TSOptions: DefaultTSOptions}))
+	}
+
+	tests := []struct {
+		aggregator TSAggregator
+		values     []float64
+	}{
+		{TSAggregateAvg, []float64{1.5, 3.5, 5.5, 7}},
+		{TSAggregateSum, []float64{3, 7, 11, 7}},
+		{TSAggregateMin, []float64{1, 3, 5, 7}},
+		{TSAggregateMax, []float64{2, 4, 6, 7}},
+		{TSAggregateCount, []float64{2, 2, 2, 1}},
+		{TSAggregateFirst, []float64{1, 3, 5, 7}},
+		{TSAggregateLast, []float64{2, 4, 6, 7}},
+	}
+	for _, tt := range tests {
+		samples, err := ds.TSRange(key, TSRangeOptions{
+			To:          math.MaxInt64,
+			Aggregation: &TSAggregation{Aggregator: tt.aggregator, BucketDuration: 10},
+		})
+		assert.Nil(t, err)
+		assert.Equal(t, []TSSample{{0, tt.values[0]}, {10, tt.values[1]}, {20, tt.values[2]}, {30, tt.values[3]}}, samples)
+	}
+
+	// buckets aligned to 5 and reported by their ends, in reverse order
+	samples, err := ds.TSRange(key, TSRangeOptions{
+		To:          math.MaxInt64,
+		Reverse:     true,
+		Count:       2,
+		Aggregation: &TSAggregation{Aggregator: TSAggregateSum, BucketDuration: 10, Align: 5, BucketTimestamp: TSBucketEnd},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []TSSample{{35, 13}, {25, 9}}, samples)
+
+	samples, err = ds.TSRange(key, TSRangeOptions{
+		From:          5,
+		To:            25,
+		FilterByTS:    []int64{0, 10, 15, 25},
+		FilterByValue: true,
+		MinValue:      3,
+		MaxValue:      4,
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []TSSample{{10, 3}, {15, 4}}, samples)
+}
+
+func TestDS_TSMRange(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	labels := map[string][]TSLabel{
+		"cpu:1": {{"metric", "cpu"}, {"host", "a"}},
+		"cpu:2": {{"metric", "cpu"}, {"host", "b"}},
+		"mem:1": {{"metric", "mem"}, {"host", "a"}},
+		"other": {{"metric", "cpu"}},
+	}
+	for key, l := range labels {
+		opts := DefaultTSOptions
+		opts.Labels = l
+		assert.Nil(t, ds.TSCreate([]byte(key), opts))
+		assert.Nil(t, ds.TSAdd([]byte(key), 1, 1, TSAddOptions{}))
+	}
+
+	keys := func(filters ...TSFilter) []string {
+		series, err := ds.TSMRange(filters, TSRangeOptions{To: math.MaxInt64})
+		assert.Nil(t, err)
+		var keys []string
+		for _, s := range series {
+			keys = append(keys, string(s.Key))
+			assert.Equal(t, labels[string(s.Key)], s.Labels)
+			assert.Equal(t, []TSSample{{1, 1}}, s.Samples)
+		}
+		return keys
+	}
+	assert.Equal(t, []string{"cpu:1", "cpu:2", "other"}, keys(TSFilter{Label: "metric", Values: []string{"cpu"}}))
+	assert.Equal(t, []string{"cpu:1", "mem:1"}, keys(TSFilter{Label: "host", Values: []string{"a"}}))
+	assert.Equal(t, []string{"cpu:2", "other"}, keys(
+		TSFilter{Label: "metric", Values: []string{"cpu"}},
+		TSFilter{Label: "host", Values: []string{"a"}, Negate: true},
+	))
+	assert.Equal(t, []string{"other"}, keys(
+		TSFilter{Label: "metric", Values: []string{"cpu", "mem"}},
+		TSFilter{Label: "host", Values: []string{""}},
+	))
+	assert.Equal(t, []string{"cpu:1", "cpu:2", "mem:1"}, keys(
+		TSFilter{Label: "metric", Values: []string{"cpu", "mem"}},
+		TSFilter{Label: "host", Values: []string{""}, Negate: true},
+	))
+
+	// removed time series are no longer matched
+	assert.Nil(t, ds.Del([]byte("cpu:1")))
+	assert.Nil(t, ds.Set([]byte("cpu:2"), []byte("value"), 0))
+	assert.Equal(t, []string{"other"}, keys(TSFilter{Label: "metric", Values: []string{"cpu"}}))
+	opts := DefaultTSOptions
+	opts.Labels = labels["cpu:1"]
+	assert.Nil(t, ds.TSAdd([]byte("cpu:1"), 1, 1, TSAddOptions{TSOptions: opts}))
+	assert.Equal(t, []string{"cpu:1", "other"}, keys(TSFilter{Label: "metric", Values: []string{"cpu"}}))
+}
@@ -0,0 +1,336 @@
+package ds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/saint-yellow/baradb"
+)
+
+// limits of a Top-K, which is stored as a single value rewritten by every TOPK.ADD and TOPK.INCRBY
+const (
+	maxTopKK       = 1 << 16
+	maxTopKBuckets = 1 << 21 // buckets take 16MB
+)
+
+// TopKOptions options of a Top-K
+type TopKOptions struct {
+	K     uint32
+	Width uint32  // the number of counters in each row
+	Depth uint32  // the number of rows
+	Decay float64 // the probability of decaying a counter held by another item is decay^count
+}
+
+// DefaultTopKOptions are the defaults of dimensions of a Top-K, which are the defaults of RedisBloom
+var DefaultTopKOptions = TopKOptions{
+	Width: 8,
+	Depth: 7,
+	Decay: 0.9,
+}
+
+// TopKItem is an item in a Top-K list along with its estimated count
+type TopKItem struct {
+	Item  []byte
+	Count uint32
+}
+
+// topKBucket is a counter of HeavyKeeper, which is held by the fingerprint of an item
+type topKBucket struct {
+	fingerprint uint32
+	count       uint32
+}
+
+// topK is a Top-K by the HeavyKeeper algorithm, which keeps the k heaviest items in a min-heap
+type topK struct {
+	expire  int64
+	opts    TopKOptions
+	buckets []topKBucket // depth rows of width buckets
+	heap    []topKHeapItem
+}
+
+type topKHeapItem struct {
+	fingerprint uint32
+	count       uint32
+	item        []byte // nil for an empty slot
+}
+
+// encode encodes a Top-K: type + expire + options + buckets + heap, which is laid out as a string is
+func (tk *topK) encode() []byte {
+	buffer := make([]byte, 0, 32+len(tk.buckets)*8)
+	buffer = binary.AppendUvarint(buffer, uint64(tk.opts.K))
+	buffer = binary.AppendUvarint(buffer, uint64(tk.opts.Width))
+	buffer = binary.AppendUvarint(buffer, uint64(tk.opts.Depth))
+	buffer = binary.BigEndian.AppendUint64(buffer, math.Float64bits(tk.opts.Decay))
+	for _, b := range tk.buckets {
+		buffer = binary.BigEndian.AppendUint32(buffer, b.fingerprint)
+		buffer = binary.BigEndian.AppendUint32(buffer, b.count)
+	}
+	for _, h := range tk.heap {
+		buffer = binary.AppendUvarint(buffer, uint64(h.fingerprint))
+		buffer = binary.AppendUvarint(buffer, uint64(h.count))
+		if h.item == nil {
+			buffer = binary.AppendVarint(buffer, -1)
+			continue
+		}
+		buffer = binary.AppendVarint(buffer, int64(len(h.item)))
+		buffer = append(buffer, h.item...)
+	}
+	encValue := encodeString(buffer, tk.expire)
+	encValue[0] = TopK
+	return encValue
+}
+
+func decodeTopK(encValue []byte) *topK {
+	expire, _ := binary.Varint(encValue[1:])
+	d := &fieldDecoder{buffer: decodeString(encValue)}
+	tk := &topK{expire: expire}
+	tk.opts.K = uint32(d.uvarint())
+	tk.opts.Width = uint32(d.uvarint())
+	tk.opts.Depth = uint32(d.uvarint())
+	tk.opts.Decay = d.float()
+	tk.buckets = make([]topKBucket, uint64(tk.opts.Width)*uint64(tk.opts.Depth))
+	for i := range tk.buckets {
+		tk.buckets[i].fingerprint = binary.BigEndian.Uint32(d.buffer[d.index:])
+		tk.buckets[i].count = binary.BigEndian.Uint32(d.buffer[d.index+4:])
+		d.index += 8
+	}
+	tk.heap = make([]topKHeapItem, tk.opts.K)
+	for i := range tk.heap {
+		tk.heap[i].fingerprint = uint32(d.uvarint())
+		tk.heap[i].count = uint32(d.uvarint())
+		if n := d.varint(); n >= 0 {
+			tk.heap[i].item = bytes.Clone(d.buffer[d.index : d.index+int(n)])
+			d.index += int(n)
+		}
+	}
+	return tk
+}
+
+// getTopK gets the Top-K of a key, and fails with ErrTopKKeyNotFound if the key does not exist
+func (ds *DS) getTopK(key []byte) (*topK, error) {
+	encValue, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, ErrTopKKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if encValue[0] != TopK {
+		return nil, ErrWrongTypeOperation
+	}
+	return decodeTopK(encValue), nil
+}
+
+// bucket gets the bucket of an item in a row
+func (tk *topK) bucket(row uint32, h1, h2 uint64) *topKBucket {
+	width := uint64(tk.opts.Width)
+	return &tk.buckets[uint64(row)*width+(h1+uint64(row)*h2)%width]
+}
+
+// topKFingerprint gets the fingerprint of an item from the lower half of its hash,
+// since the upper half of FNV varies little among short items
+func topKFingerprint(h2 uint64) uint32 {
+	return uint32(h2) ^ uint32(h2>>32)
+}
+
+// find finds an item in the heap
+func (tk *topK) find(item []byte, fingerprint uint32) int {
+	for i, h := range tk.heap {
+		if h.item != nil && h.fingerprint == fingerprint && bytes.Equal(h.item, item) {
+			return i
+		}
+	}
+	return -1
+}
+
+// siftDown restores the min-heap from the item at an index
+func (tk *topK) siftDown(i int) {
+	for {
+		smallest := i
+		for _, child := range []int{2*i + 1, 2*i + 2} {
+			if child < len(tk.heap) && tk.heap[child].count < tk.heap[smallest].count {
+				smallest = child
+			}
+		}
+		if smallest == i {
+			return
+		}
+		tk.heap[i], tk.heap[smallest] = tk.heap[smallest], tk.heap[i]
+		i = smallest
+	}
+}
+
+// add adds an increment to an item, and gets the item expelled from the heap, if any
+func (tk *topK) add(item []byte, increment uint32) []byte {
+	h1, h2 := hashItem(item)
+	fingerprint := topKFingerprint(h2)
+
+	var maxCount uint32
+	for row := uint32(0); row < tk.opts.Depth; row++ {
+		b := tk.bucket(row, h1, h2)
+		switch {
+		case b.count == 0:
+			b.fingerprint, b.count = fingerprint, increment
+		case b.fingerprint == fingerprint:
+			b.count += increment
+		default:
+			// a counter held by another item decays with a probability decreasing with its count,
+			// and is taken over once it decays to 0
+			for remaining := increment; remaining > 0; remaining-- {
+				if rand.Float64() >= math.Pow(tk.opts.Decay, float64(b.count)) {
+					continue
+				}
+				b.count--
+				if b.count == 0 {
+					b.fingerprint, b.count = fingerprint, remaining
+					break
+				}
+			}
+		}
+		if b.fingerprint == fingerprint && b.count > maxCount {
+			maxCount = b.count
+		}
+	}
+
+	if len(tk.heap) == 0 || maxCount < tk.heap[0].count {
+		return nil
+	}
+	if i := tk.find(item, fingerprint); i >= 0 {
+		tk.heap[i].count = maxCount
+		tk.siftDown(i)
+		return nil
+	}
+	expelled := tk.heap[0].item
+	tk.heap[0] = topKHeapItem{fingerprint: fingerprint, count: maxCount, item: bytes.Clone(item)}
+	tk.siftDown(0)
+	return expelled
+}
+
+// count gets the estimated count of an item, which is the maximum of its counters
+func (tk *topK) count(item []byte) uint32 {
+	h1, h2 := hashItem(item)
+	fingerprint := topKFingerprint(h2)
+	var count uint32
+	for row := uint32(0); row < tk.opts.Depth; row++ {
+		if b := tk.bucket(row, h1, h2); b.fingerprint == fingerprint && b.count > count {
+			count = b.count
+		}
+	}
+	return count
+}
+
+// TopKReserve redis TOPK.RESERVE
+func (ds *DS) TopKReserve(key []byte, opts TopKOptions) error {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	if _, err := ds.getValue(key); err != baradb.ErrKeyNotFound {
+		if err != nil {
+			return err
+		}
+		return ErrTopKKeyExists
+	}
+	size := uint64(opts.Width) * uint64(opts.Depth)
+	if opts.K == 0 || opts.K > maxTopKK || size == 0 || size > maxTopKBuckets {
+		return ErrTopKTooLarge
+	}
+	tk := &topK{
+		opts:    opts,
+		buckets: make([]topKBucket, size),
+		heap:    make([]topKHeapItem, opts.K),
+	}
+	return ds.db.Put(key, tk.encode())
+}
+
+// TopKAdd redis TOPK.ADD
+func (ds *DS) TopKAdd(key []byte, items ...[]byte) ([][]byte, error) {
+	increments := make([]uint32, len(items))
+	for i := range increments {
+		increments[i] = 1
+	}
+	return ds.TopKIncrBy(key, items, increments)
+}
+
+// TopKIncrBy redis TOPK.INCRBY
+//
+// It increments counts of items, and gets items expelled from the list by each of them, which are nil if none.
+func (ds *DS) TopKIncrBy(key []byte, items [][]byte, increments []uint32) ([][]byte, error) {
+	ds.mu.Lock()
+	defer ds.mu.Unlock()
+
+	tk, err := ds.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	expelled := make([][]byte, len(items))
+	for i, item := range items {
+		expelled[i] = tk.add(item, increments[i])
+	}
+	if err := ds.db.Put(key, tk.encode()); err != nil {
+		return nil, err
+	}
+	return expelled, nil
+}
+
+// TopKQuery redis TOPK.QUERY
+//
+// It tells whether each item is in the list.
+func (ds *DS) TopKQuery(key []byte, items ...[]byte) ([]bool, error) {
+	tk, err := ds.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	results := make([]bool, len(items))
+	for i, item := range items {
+		_, h2 := hashItem(item)
+		results[i] = tk.find(item, topKFingerprint(h2)) >= 0
+	}
+	return results, nil
+}
+
+// TopKCount redis TOPK.COUNT
+//
+// It gets estimated counts of items, whether or not they are in the list.
+func (ds *DS) TopKCount(key []byte, items ...[]byte) ([]uint32, error) {
+	tk, err := ds.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]uint32, len(items))
+	for i, item := range items {
+		counts[i] = tk.count(item)
+	}
+	return counts, nil
+}
+
+// TopKList redis TOPK.LIST
+//
+// It gets items in the list ordered by their counts from the largest.
+func (ds *DS) TopKList(key []byte) ([]TopKItem, error) {
+	tk, err := ds.getTopK(key)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]TopKItem, 0, len(tk.heap))
+	for _, h := range tk.heap {
+		if h.item != nil {
+			list = append(list, TopKItem{Item: h.item, Count: h.count})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Count > list[j].Count
+	})
+	return list, nil
+}
+
+// TopKInfo redis TOPK.INFO
+func (ds *DS) TopKInfo(key []byte) (TopKOptions, error) {
+	tk, err := ds.getTopK(key)
+	if err != nil {
+		return TopKOptions{}, err
+	}
+	return tk.opts, nil
+}
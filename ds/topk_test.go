@@ -0,0 +1,87 @@
+package ds
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDS_TopKAdd(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("topk")
+	_, err := ds.TopKAdd(key, []byte("a"))
+	assert.Equal(t, ErrTopKKeyNotFound, err)
+	opts := DefaultTopKOptions
+	opts.K = 2
+	assert.Nil(t, ds.TopKReserve(key, opts))
+	assert.Equal(t, ErrTopKKeyExists, ds.TopKReserve(key, opts))
+	// sizes are computed without overflows, and bounded
+	for _, o := range []TopKOptions{
+		{K: 10, Width: 65536, Depth: 65536, Decay: 0.9},
+		{K: 10, Width: math.MaxUint32, Depth: math.MaxUint32, Decay: 0.9},
+		{K: math.MaxUint32, Width: 8, Depth: 7, Decay: 0.9},
+	} {
+		assert.Equal(t, ErrTopKTooLarge, ds.TopKReserve([]byte("large"), o))
+	}
+
+	expelled, err := ds.TopKAdd(key, []byte("a"), []byte("b"), []byte("a"))
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{nil, nil, nil}, expelled)
+	expelled, err = ds.TopKIncrBy(key, [][]byte{[]byte("c")}, []uint32{5})
+	assert.Nil(t, err)
+	assert.Equal(t, [][]byte{[]byte("b")}, expelled)
+
+	results, err := ds.TopKQuery(key, []byte("a"), []byte("b"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, []bool{true, false, true}, results)
+	counts, err := ds.TopKCount(key, []byte("a"), []byte("c"))
+	assert.Nil(t, err)
+	assert.Equal(t, []uint32{2, 5}, counts)
+	list, err := ds.TopKList(key)
+	assert.Nil(t, err)
+	assert.Equal(t, []TopKItem{{Item: []byte("c"), Count: 5}, {Item: []byte("a"), Count: 2}}, list)
+
+	info, err := ds.TopKInfo(key)
+	assert.Nil(t, err)
+	assert.Equal(t, opts, info)
+	dt, err := ds.Type(key)
+	assert.Nil(t, err)
+	assert.Equal(t, TopK, dt)
+}
+
+func TestDS_TopKHeavyHitters(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer func() {
+		destroyDS(ds, testingDBOptions.Directory)
+	}()
+
+	key := []byte("topk")
+	assert.Nil(t, ds.TopKReserve(key, TopKOptions{K: 3, Width: 50, Depth: 5, Decay: 0.9}))
+	// 3 heavy hitters among many items added once
+	var items [][]byte
+	for i := 0; i < 200; i++ {
+		items = append(items, []byte(fmt.Sprintf("item-%d", i)))
+		if i%10 == 0 {
+			items = append(items, []byte("x"), []byte("y"), []byte("z"))
+		}
+	}
+	_, err := ds.TopKAdd(key, items...)
+	assert.Nil(t, err)
+
+	// the list persists after a restart
+	assert.Nil(t, ds.Close())
+	ds, _ = New(testingDBOptions)
+	list, err := ds.TopKList(key)
+	assert.Nil(t, err)
+	assert.Len(t, list, 3)
+	for _, item := range list {
+		assert.Contains(t, []string{"x", "y", "z"}, string(item.Item))
+		// counts are never overestimated, and rarely decayed much by collisions
+		assert.LessOrEqual(t, item.Count, uint32(20))
+		assert.Greater(t, item.Count, uint32(15))
+	}
+}
@@ -1,9 +1,12 @@
 package ds
 
 import (
+	"bytes"
 	"encoding/binary"
+	"math"
 
 	"github.com/saint-yellow/baradb"
+	"github.com/saint-yellow/baradb/index"
 	"github.com/saint-yellow/baradb/utils"
 )
 
@@ -33,32 +36,48 @@ func (zk *zsetInternalKey) encodeWithMember() []byte {
 	return buffer
 }
 
+// encodeWithScore encodes the internal key ordered by the score,
+// where the score is encoded by encodeZSetScore so that internal keys of a sorted set are sorted by their scores
 func (zk *zsetInternalKey) encodeWithScore() []byte {
-	scoreBuffer := utils.Float64ToBytes(zk.score)
-	buffer := make([]byte, len(zk.key)+len(zk.member)+len(scoreBuffer)+8+4)
-
-	index := 0
+	buffer := make([]byte, 0, len(zk.key)+8+8+len(zk.member)+4)
 
 	// key
-	copy(buffer[index:index+len(zk.key)], zk.key)
-	index += len(zk.key)
+	buffer = append(buffer, zk.key...)
 
 	// version
-	binary.LittleEndian.PutUint64(buffer[index:index+8], uint64(zk.version))
-	index += 8
+	buffer = binary.LittleEndian.AppendUint64(buffer, uint64(zk.version))
 
 	// score
-	copy(buffer[index:index+len(scoreBuffer)], scoreBuffer)
-	index += len(scoreBuffer)
+	buffer = append(buffer, encodeZSetScore(zk.score)...)
 
 	// member
-	copy(buffer[index:index+len(zk.member)], zk.member)
-	index += len(zk.member)
+	buffer = append(buffer, zk.member...)
 
 	// size of member
-	binary.LittleEndian.PutUint32(buffer[index:], uint32(len(zk.member)))
+	return binary.LittleEndian.AppendUint32(buffer, uint32(len(zk.member)))
+}
 
-	return buffer
+// encodeZSetScore encodes a score into 8 bytes, whose byte order is the same as the order of scores
+func encodeZSetScore(score float64) []byte {
+	bits := math.Float64bits(score)
+	if bits>>63 == 1 {
+		// negative scores are sorted reversely by their bits
+		bits = ^bits
+	} else {
+		bits |= 1 << 63
+	}
+	return binary.BigEndian.AppendUint64(nil, bits)
+}
+
+// decodeZSetScore reverses encodeZSetScore
+func decodeZSetScore(buffer []byte) float64 {
+	bits := binary.BigEndian.Uint64(buffer)
+	if bits>>63 == 1 {
+		bits &^= 1 << 63
+	} else {
+		bits = ^bits
+	}
+	return math.Float64frombits(bits)
 }
 
 func (ds *DS) ZAdd(key []byte, score float64, member []byte) (bool, error) {
@@ -136,3 +155,71 @@ func (ds *DS) ZScore(key, member []byte) (float64, error) {
 	value := utils.Float64FromBytes(buffer)
 	return value, nil
 }
+
+// zsetMetadata gets the metadata of a sorted set, which is nil if the key does not exist
+func (ds *DS) zsetMetadata(key []byte) (*metadata, error) {
+	value, err := ds.getValue(key)
+	if err == baradb.ErrKeyNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	md := decodeMetadata(value)
+	if md.dataType != ZSet {
+		return nil, ErrWrongTypeOperation
+	}
+	return md, nil
+}
+
+// zsetScore gets the score of a member, ok is false if the member does not exist
+func (ds *DS) zsetScore(key []byte, md *metadata, member []byte) (float64, bool, error) {
+	zk := &zsetInternalKey{
+		key:     key,
+		version: md.version,
+		member:  member,
+	}
+	buffer, err := ds.db.Get(zk.encodeWithMember())
+	if err == baradb.ErrKeyNotFound {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return utils.Float64FromBytes(buffer), true, nil
+}
+
+// zsetScanScores iterates members of a sorted set whose scores are in [min, max) in the order of scores,
+// along with their scores until fn returns false
+func (ds *DS) zsetScanScores(key []byte, md *metadata, min, max float64, fn func(member []byte, score float64) bool) error {
+	prefix := internalKeyPrefix(key, md.version)
+	end := encodeZSetScore(max)
+	opts := index.DefaultIteratorOptions
+	opts.Prefix = prefix
+	iter := ds.db.NewItrerator(opts)
+	defer iter.Close()
+
+	for iter.Seek(append(bytes.Clone(prefix), encodeZSetScore(min)...)); iter.Valid() && bytes.HasPrefix(iter.Key(), prefix); iter.Next() {
+		encKey := iter.Key()[len(prefix):]
+		if len(encKey) >= 8 && bytes.Compare(encKey[:8], end) >= 0 {
+			return nil
+		}
+		// internal keys with members and internal keys of other keys sharing the prefix are interleaved,
+		// while only internal keys with scores have empty values and end with the sizes of their members
+		if len(encKey) < 8+4 || int(binary.LittleEndian.Uint32(encKey[len(encKey)-4:])) != len(encKey)-8-4 {
+			continue
+		}
+		value, err := iter.Value()
+		if err != nil {
+			return err
+		}
+		if len(value) != 0 {
+			continue
+		}
+		member := bytes.Clone(encKey[8 : len(encKey)-4])
+		if !fn(member, decodeZSetScore(encKey[:8])) {
+			return nil
+		}
+	}
+	return nil
+}
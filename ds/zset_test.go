@@ -1,6 +1,7 @@
 package ds
 
 import (
+	"math"
 	"testing"
 
 	"github.com/saint-yellow/baradb"
@@ -56,3 +57,36 @@ func TestDS_ZScore(t *testing.T) {
 	assert.Equal(t, float64(114.514), value)
 	assert.Nil(t, err)
 }
+
+func TestDS_ZSetScanScores(t *testing.T) {
+	ds, _ := New(testingDBOptions)
+	defer destroyDS(ds, testingDBOptions.Directory)
+
+	key := []byte("scores")
+	scores := []float64{math.Inf(-1), -1e10, -2.5, -1, 0, 0.5, 1, 2.5, 1e10, math.Inf(1)}
+	for i := len(scores) - 1; i >= 0; i-- {
+		// members are in the opposite order of their scores
+		_, err := ds.ZAdd(key, scores[i], []byte{byte(len(scores) - i)})
+		assert.Nil(t, err)
+	}
+	// internal keys with members sharing bytes with encoded scores are skipped
+	_, err := ds.ZAdd(key, 3, encodeZSetScore(0.5))
+	assert.Nil(t, err)
+	md, _ := ds.zsetMetadata(key)
+
+	var found []float64
+	err = ds.zsetScanScores(key, md, -2.5, 2.5, func(member []byte, score float64) bool {
+		found = append(found, score)
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{-2.5, -1, 0, 0.5, 1}, found)
+
+	found = found[:0]
+	err = ds.zsetScanScores(key, md, math.Inf(-1), math.Inf(1), func(member []byte, score float64) bool {
+		found = append(found, score)
+		return len(found) < 3
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []float64{math.Inf(-1), -1e10, -2.5}, found)
+}
@@ -5,6 +5,7 @@ go 1.20
 require (
 	github.com/saint-yellow/baradb v0.1.1
 	github.com/stretchr/testify v1.8.4
+	github.com/tidwall/match v1.1.1
 	github.com/tidwall/redcon v1.6.2
 )
 
@@ -15,7 +16,6 @@ require (
 	github.com/plar/go-adaptive-radix-tree v1.0.5 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/tidwall/btree v1.1.0 // indirect
-	github.com/tidwall/match v1.1.1 // indirect
 	go.etcd.io/bbolt v1.3.7 // indirect
 	golang.org/x/exp v0.0.0-20230626212559-97b1e661b5df // indirect
 	golang.org/x/sys v0.10.0 // indirect
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"github.com/saint-yellow/baradb-redis/client"
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// Builder builds a Redis server embedded in another program,
+// which could be extended with custom commands.
+//
+//	rs, err := server.NewBuilder(service).
+//		WithOptions(opts).
+//		WithCommand(client.Command{Name: "reserve.seat", Handler: reserveSeat, Arity: 3}).
+//		Build()
+type Builder struct {
+	service  *ds.DS
+	opts     Options
+	commands []client.Command
+}
+
+// NewBuilder initializes a builder of a server with default options
+func NewBuilder(service *ds.DS) *Builder {
+	return &Builder{
+		service: service,
+		opts:    DefaultOptions,
+	}
+}
+
+// WithOptions sets options of the server
+func (b *Builder) WithOptions(opts Options) *Builder {
+	b.opts = opts
+	return b
+}
+
+// WithCommand adds a custom command to the server
+func (b *Builder) WithCommand(command client.Command) *Builder {
+	b.commands = append(b.commands, command)
+	return b
+}
+
+// Build initializes the server with its own table of built-in and custom commands.
+//
+// Custom commands are registered before the ACL file is loaded, so that its rules could refer to them,
+// and they are only available to the built server.
+func (b *Builder) Build() (*RedisServer, error) {
+	commands := client.NewCommandTable()
+	for _, command := range b.commands {
+		if err := commands.Register(command); err != nil {
+			return nil, err
+		}
+	}
+	return newServer(b.service, b.opts, commands)
+}
+
+// CommandStats lists statistics of commands of the server that have been called or rejected
+func (rs *RedisServer) CommandStats() []client.CommandStats {
+	return rs.commands.Stats()
+}
@@ -0,0 +1,64 @@
+package server
+
+import "os"
+
+// Options options of a Redis server
+type Options struct {
+	// Address is the TCP address to listen on.
+	//
+	// If it is empty, then the server does not listen on plain TCP.
+	Address string
+
+	// UnixSocket is the path of the Unix domain socket to listen on.
+	//
+	// If it is empty, then the server does not listen on a Unix domain socket.
+	UnixSocket string
+
+	// UnixSocketPerm is the permission of the Unix domain socket file
+	UnixSocketPerm os.FileMode
+
+	// RequirePass is the password clients need to authenticate with.
+	//
+	// If it is empty, then clients do not need to authenticate.
+	RequirePass string
+
+	// ACLFile is the path of the ACL file, which is loaded at startup.
+	//
+	// If it is empty, then only the default user is available at startup.
+	ACLFile string
+
+	// TLSAddress is the TCP address to listen on with TLS.
+	//
+	// If it is empty, then the server does not listen with TLS.
+	TLSAddress string
+
+	// TLSCertFile is the path of the certificate of the server
+	TLSCertFile string
+
+	// TLSKeyFile is the path of the private key of the server
+	TLSKeyFile string
+
+	// TLSCACertFile is the path of CA certificates to verify client certificates
+	TLSCACertFile string
+
+	// TLSAuthClients indicates whether clients must present a certificate signed by the CA
+	TLSAuthClients bool
+
+	// TLSMinVersion is the minimum TLS version accepted, either TLSv1.2 or TLSv1.3
+	TLSMinVersion string
+}
+
+// DefaultOptions default options of a Redis server
+var DefaultOptions = Options{
+	Address:        "127.0.0.1:6378",
+	UnixSocket:     "",
+	UnixSocketPerm: 0o700,
+	RequirePass:    "",
+	ACLFile:        "",
+	TLSAddress:     "",
+	TLSCertFile:    "",
+	TLSKeyFile:     "",
+	TLSCACertFile:  "",
+	TLSAuthClients: false,
+	TLSMinVersion:  "TLSv1.2",
+}
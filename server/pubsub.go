@@ -0,0 +1,347 @@
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/tidwall/match"
+	"github.com/tidwall/redcon"
+
+	"github.com/saint-yellow/baradb-redis/client"
+)
+
+// shardSubscriber is a connection subscribing shard channels.
+//
+// Once a connection subscribes a shard channel, it is detached from the server loop,
+// and its following commands are read by the subscriber itself.
+type shardSubscriber struct {
+	mu       sync.Mutex // guards writes to the connection
+	conn     redcon.DetachedConn
+	channels map[string]struct{} // guarded by the registry
+}
+
+// write writes a reply to the subscriber and flushes it immediately
+func (sub *shardSubscriber) write(fn func(conn redcon.Conn)) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+	fn(sub.conn)
+	sub.conn.Flush()
+}
+
+// shardPubSub is a registry of shard channels, which is separated from classic channels.
+//
+// Every shard channel belongs to a hash slot like a key does,
+// so subscriptions could be moved along with slots in cluster mode.
+type shardPubSub struct {
+	mu       sync.RWMutex
+	channels map[string]map[*shardSubscriber]struct{} // channel -> subscribers
+	slots    map[int]map[string]struct{}              // slot -> channels
+}
+
+func newShardPubSub() *shardPubSub {
+	return &shardPubSub{
+		channels: make(map[string]map[*shardSubscriber]struct{}),
+		slots:    make(map[int]map[string]struct{}),
+	}
+}
+
+// subscribe subscribes a shard channel and returns the number of shard channels of the subscriber
+func (ps *shardPubSub) subscribe(sub *shardSubscriber, channel string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	subscribers, ok := ps.channels[channel]
+	if !ok {
+		subscribers = make(map[*shardSubscriber]struct{})
+		ps.channels[channel] = subscribers
+
+		slot := keySlot([]byte(channel))
+		if ps.slots[slot] == nil {
+			ps.slots[slot] = make(map[string]struct{})
+		}
+		ps.slots[slot][channel] = struct{}{}
+	}
+	subscribers[sub] = struct{}{}
+	sub.channels[channel] = struct{}{}
+	return len(sub.channels)
+}
+
+// unsubscribe unsubscribes a shard channel and returns the number of shard channels of the subscriber
+func (ps *shardPubSub) unsubscribe(sub *shardSubscriber, channel string) int {
+	ps.mu.Lock()
+	defer ps.mu.Unlock()
+
+	delete(sub.channels, channel)
+	subscribers, ok := ps.channels[channel]
+	if !ok {
+		return len(sub.channels)
+	}
+	delete(subscribers, sub)
+	if len(subscribers) == 0 {
+		delete(ps.channels, channel)
+
+		slot := keySlot([]byte(channel))
+		delete(ps.slots[slot], channel)
+		if len(ps.slots[slot]) == 0 {
+			delete(ps.slots, slot)
+		}
+	}
+	return len(sub.channels)
+}
+
+// publish publishes a message to a shard channel and returns the number of receivers
+func (ps *shardPubSub) publish(channel, message []byte) int {
+	ps.mu.RLock()
+	subscribers := make([]*shardSubscriber, 0, len(ps.channels[string(channel)]))
+	for sub := range ps.channels[string(channel)] {
+		subscribers = append(subscribers, sub)
+	}
+	ps.mu.RUnlock()
+
+	for _, sub := range subscribers {
+		sub.write(func(conn redcon.Conn) {
+			w := client.NewReplyWriter(conn)
+			w.WritePush(3)
+			w.WriteBulkString("smessage")
+			w.WriteBulk(channel)
+			w.WriteBulk(message)
+		})
+	}
+	return len(subscribers)
+}
+
+// activeChannels lists shard channels with at least one subscriber matching the pattern
+func (ps *shardPubSub) activeChannels(pattern string) []string {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	channels := make([]string, 0)
+	for channel := range ps.channels {
+		if pattern == "" || match.Match(channel, pattern) {
+			channels = append(channels, channel)
+		}
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// subscriptions lists shard channels subscribed by a subscriber
+func (ps *shardPubSub) subscriptions(sub *shardSubscriber) []string {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+
+	channels := make([]string, 0, len(sub.channels))
+	for channel := range sub.channels {
+		channels = append(channels, channel)
+	}
+	sort.Strings(channels)
+	return channels
+}
+
+// numSub gets the number of subscribers of a shard channel
+func (ps *shardPubSub) numSub(channel string) int {
+	ps.mu.RLock()
+	defer ps.mu.RUnlock()
+	return len(ps.channels[channel])
+}
+
+// isShardPubSubCommand tells whether a command is served by the shard pub/sub registry
+func isShardPubSubCommand(commandName string) bool {
+	switch commandName {
+	case "ssubscribe", "sunsubscribe", "spublish", "pubsub":
+		return true
+	default:
+		return false
+	}
+}
+
+// executeShardPubSubCommand executes SSUBSCRIBE, SUNSUBSCRIBE, SPUBLISH and PUBSUB.
+//
+// If the connection is detached, the caller should hold the lock of its subscriber.
+func (rs *RedisServer) executeShardPubSubCommand(conn redcon.Conn, cmd redcon.Command) {
+	commandName := strings.ToLower(string(cmd.Args[0]))
+	args := cmd.Args[1:]
+
+	switch commandName {
+	case "ssubscribe":
+		if !sameSlot(args) {
+			conn.WriteError("CROSSSLOT Keys in request don't hash to the same slot")
+			return
+		}
+		if dconn, ok := conn.(redcon.DetachedConn); ok {
+			rs.ssubscribe(rs.subscriber(dconn), conn, args)
+			return
+		}
+
+		// The connection is detached from the server loop after its first subscription
+		sub := &shardSubscriber{
+			conn:     conn.Detach(),
+			channels: make(map[string]struct{}),
+		}
+		rs.mu.Lock()
+		rs.subscribers[sub.conn] = sub
+		rs.mu.Unlock()
+		sub.write(func(conn redcon.Conn) {
+			rs.ssubscribe(sub, conn, args)
+		})
+		go rs.serveShardSubscriber(sub)
+	case "sunsubscribe":
+		sub := &shardSubscriber{channels: make(map[string]struct{})}
+		if dconn, ok := conn.(redcon.DetachedConn); ok {
+			sub = rs.subscriber(dconn)
+		}
+		rs.sunsubscribe(sub, conn, args)
+	case "spublish":
+		conn.WriteInt(rs.shardPubSub.publish(args[0], args[1]))
+	case "pubsub":
+		rs.pubsub(conn, args)
+	}
+}
+
+// subscriber gets the subscriber of a detached connection
+func (rs *RedisServer) subscriber(conn redcon.DetachedConn) *shardSubscriber {
+	rs.mu.RLock()
+	defer rs.mu.RUnlock()
+	return rs.subscribers[conn]
+}
+
+func (rs *RedisServer) ssubscribe(sub *shardSubscriber, conn redcon.Conn, channels [][]byte) {
+	w := client.NewReplyWriter(conn)
+	for _, channel := range channels {
+		count := rs.shardPubSub.subscribe(sub, string(channel))
+		w.WritePush(3)
+		w.WriteBulkString("ssubscribe")
+		w.WriteBulk(channel)
+		w.WriteInt(count)
+	}
+}
+
+// sunsubscribe unsubscribes the given shard channels, or all shard channels if none is given
+func (rs *RedisServer) sunsubscribe(sub *shardSubscriber, conn redcon.Conn, channels [][]byte) {
+	if len(channels) == 0 {
+		for _, channel := range rs.shardPubSub.subscriptions(sub) {
+			channels = append(channels, []byte(channel))
+		}
+	}
+
+	w := client.NewReplyWriter(conn)
+	if len(channels) == 0 {
+		w.WritePush(3)
+		w.WriteBulkString("sunsubscribe")
+		w.WriteNull()
+		w.WriteInt(0)
+		return
+	}
+
+	for _, channel := range channels {
+		count := rs.shardPubSub.unsubscribe(sub, string(channel))
+		w.WritePush(3)
+		w.WriteBulkString("sunsubscribe")
+		w.WriteBulk(channel)
+		w.WriteInt(count)
+	}
+}
+
+// pubsub executes the PUBSUB introspection command
+func (rs *RedisServer) pubsub(conn redcon.Conn, args [][]byte) {
+	subcommand := strings.ToLower(string(args[0]))
+	switch subcommand {
+	case "shardchannels":
+		if len(args) > 2 {
+			conn.WriteError("ERR wrong number of arguments for 'pubsub|shardchannels' command")
+			return
+		}
+		var pattern string
+		if len(args) == 2 {
+			pattern = string(args[1])
+		}
+		channels := rs.shardPubSub.activeChannels(pattern)
+		conn.WriteArray(len(channels))
+		for _, channel := range channels {
+			conn.WriteBulkString(channel)
+		}
+	case "shardnumsub":
+		conn.WriteArray(len(args[1:]) * 2)
+		for _, channel := range args[1:] {
+			conn.WriteBulk(channel)
+			conn.WriteInt(rs.shardPubSub.numSub(string(channel)))
+		}
+	}
+}
+
+// serveShardSubscriber reads commands from a detached connection until it is closed.
+//
+// While a RESP2 connection subscribes any shard channel,
+// only SSUBSCRIBE, SUNSUBSCRIBE, PING and QUIT are allowed.
+// Otherwise the commands are executed as usual.
+func (rs *RedisServer) serveShardSubscriber(sub *shardSubscriber) {
+	defer func() {
+		for _, channel := range rs.shardPubSub.subscriptions(sub) {
+			rs.shardPubSub.unsubscribe(sub, channel)
+		}
+		sub.mu.Lock()
+		sub.conn.Close()
+		sub.mu.Unlock()
+
+		rs.mu.Lock()
+		delete(rs.subscribers, sub.conn)
+		rs.mu.Unlock()
+	}()
+
+	for {
+		cmd, err := sub.conn.ReadCommand()
+		if err != nil {
+			return
+		}
+		if len(cmd.Args) == 0 {
+			continue
+		}
+
+		commandName := strings.ToLower(string(cmd.Args[0]))
+		if commandName == "quit" {
+			sub.write(func(conn redcon.Conn) {
+				conn.WriteString("OK")
+			})
+			return
+		}
+
+		c, _ := sub.conn.Context().(*client.RedisClient)
+		restricted := c.Protocol() == 2 && len(rs.shardPubSub.subscriptions(sub)) > 0
+		sub.write(func(conn redcon.Conn) {
+			switch {
+			case !restricted:
+				rs.ExecuteCommand(conn, cmd)
+			case commandName != "ssubscribe" && commandName != "sunsubscribe" && commandName != "ping":
+				conn.WriteError(fmt.Sprintf("ERR Can't execute '%s': only (S)SUBSCRIBE / (S)UNSUBSCRIBE / PING / QUIT are allowed in this context", commandName))
+			case !c.Permit(conn, cmd):
+				// the arity and ACLs are checked by Permit, like by ExecuteCommand
+			case commandName != "ping":
+				rs.executeShardPubSubCommand(conn, cmd)
+			default:
+				var message []byte
+				if len(cmd.Args) > 1 {
+					message = cmd.Args[1]
+				}
+				conn.WriteArray(2)
+				conn.WriteBulkString("pong")
+				conn.WriteBulk(message)
+			}
+		})
+	}
+}
+
+// sameSlot tells whether all shard channels belong to the same hash slot, which is true if there is none
+func sameSlot(channels [][]byte) bool {
+	if len(channels) == 0 {
+		return true
+	}
+	slot := keySlot(channels[0])
+	for _, channel := range channels[1:] {
+		if keySlot(channel) != slot {
+			return false
+		}
+	}
+	return true
+}
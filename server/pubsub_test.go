@@ -0,0 +1,65 @@
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisServer_ShardPubSub(t *testing.T) {
+	rs, addr := newTestingServer(t)
+	sub, pub := newTestingClient(t, "tcp", addr), newTestingClient(t, "tcp", addr)
+
+	assert.Equal(t, []string{"-CROSSSLOT Keys in request don't hash to the same slot"}, sub.do("SSUBSCRIBE", "foo", "bar"))
+	assert.Equal(t, []string{"*3", "ssubscribe", "{news}.sports", ":1"}, sub.do("SSUBSCRIBE", "{news}.sports", "{news}.tech"))
+	assert.Equal(t, []string{"*3", "ssubscribe", "{news}.tech", ":2"}, sub.read())
+
+	assert.Equal(t, []string{":1"}, pub.do("SPUBLISH", "{news}.sports", "goal"))
+	assert.Equal(t, []string{"*3", "smessage", "{news}.sports", "goal"}, sub.read())
+	assert.Equal(t, []string{":0"}, pub.do("SPUBLISH", "{news}.weather", "rain"))
+	assert.Equal(t, []string{"*2", "{news}.sports", "{news}.tech"}, pub.do("PUBSUB", "SHARDCHANNELS"))
+	assert.Equal(t, []string{"*1", "{news}.tech"}, pub.do("PUBSUB", "SHARDCHANNELS", "*tech"))
+	assert.Equal(t, []string{"*4", "{news}.sports", ":1", "{news}.weather", ":0"}, pub.do("PUBSUB", "SHARDNUMSUB", "{news}.sports", "{news}.weather"))
+
+	// commands of a RESP2 subscriber are checked as usual
+	assert.Equal(t, []string{"-ERR wrong number of arguments for 'ssubscribe' command"}, sub.do("SSUBSCRIBE"))
+
+	// a RESP2 subscriber could only execute a few commands
+	assert.Equal(t, []string{"-ERR Can't execute 'get': only (S)SUBSCRIBE / (S)UNSUBSCRIBE / PING / QUIT are allowed in this context"}, sub.do("GET", "k"))
+	assert.Equal(t, []string{"*2", "pong", ""}, sub.do("PING"))
+
+	// all shard channels are unsubscribed if none is given
+	assert.Equal(t, []string{"*3", "sunsubscribe", "{news}.sports", ":1"}, sub.do("SUNSUBSCRIBE"))
+	assert.Equal(t, []string{"*3", "sunsubscribe", "{news}.tech", ":0"}, sub.read())
+	assert.Equal(t, []string{"*3", "sunsubscribe", "(nil)", ":0"}, sub.do("SUNSUBSCRIBE"))
+	assert.Equal(t, []string{"+PONG"}, sub.do("PING"))
+	assert.Equal(t, []string{"*0"}, pub.do("PUBSUB", "SHARDCHANNELS"))
+
+	// a RESP3 subscriber receives pushes and could execute any command
+	assert.Equal(t, "%7", sub.do("HELLO", "3")[0])
+	assert.Equal(t, []string{">3", "ssubscribe", "{news}.sports", ":1"}, sub.do("SSUBSCRIBE", "{news}.sports"))
+	assert.Equal(t, []string{"(nil)"}, sub.do("GET", "k"))
+	assert.Equal(t, []string{":1"}, pub.do("SPUBLISH", "{news}.sports", "goal"))
+	assert.Equal(t, []string{">3", "smessage", "{news}.sports", "goal"}, sub.read())
+
+	// subscriptions are removed once the subscriber quits
+	assert.Equal(t, []string{"+OK"}, sub.do("QUIT"))
+	assert.Eventually(t, func() bool {
+		return rs.shardPubSub.numSub("{news}.sports") == 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRedisServer_ShardPubSubACL(t *testing.T) {
+	_, addr := newTestingServer(t)
+	admin, sub := newTestingClient(t, "tcp", addr), newTestingClient(t, "tcp", addr)
+	assert.Equal(t, []string{"+OK"}, admin.do("ACL", "SETUSER", "alice", "on", "nopass", "&allowed", "+ssubscribe", "+sunsubscribe", "+auth"))
+	assert.Equal(t, []string{"+OK"}, sub.do("AUTH", "alice", "any"))
+
+	// channel permissions are checked before and after the connection subscribes a shard channel
+	assert.Equal(t, []string{"-NOPERM No permissions to access a channel"}, sub.do("SSUBSCRIBE", "secret"))
+	assert.Equal(t, []string{"*3", "ssubscribe", "allowed", ":1"}, sub.do("SSUBSCRIBE", "allowed"))
+	assert.Equal(t, []string{"-NOPERM No permissions to access a channel"}, sub.do("SSUBSCRIBE", "secret"))
+	assert.Equal(t, []string{"-NOPERM User alice has no permissions to run the 'ping' command"}, sub.do("PING"))
+	assert.Equal(t, []string{"*1", "allowed"}, admin.do("PUBSUB", "SHARDCHANNELS"))
+}
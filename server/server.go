@@ -1,10 +1,16 @@
 package server
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"net"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"github.com/tidwall/redcon"
@@ -14,29 +20,166 @@ import (
 )
 
 type RedisServer struct {
-	DBs    map[int]*ds.DS
-	Server *redcon.Server
-	Signal chan os.Signal
-	mu     *sync.RWMutex
+	DBs        map[int]*ds.DS
+	Server     *redcon.Server    // plain TCP server, nil if disabled
+	TLSServer  *redcon.TLSServer // TLS server, nil if disabled
+	UnixServer *redcon.Server    // Unix domain socket server, nil if disabled
+	Signal     chan os.Signal
+	mu         *sync.RWMutex
+
+	tls            *tlsReloader // TLS configuration, nil if TLS is disabled
+	unixSocket     string       // path of the Unix domain socket
+	unixSocketPerm os.FileMode  // permission of the Unix domain socket
+
+	acl          *client.ACL          // access control list shared by all clients
+	commands     *client.CommandTable // built-in and custom commands along with their statistics
+	lastClientID int64                // ID of the latest accepted client
+
+	shardPubSub *shardPubSub                             // registry of shard channels
+	subscribers map[redcon.DetachedConn]*shardSubscriber // connections subscribing shard channels
 }
 
-func New(service *ds.DS) *RedisServer {
+func New(service *ds.DS, opts Options) (*RedisServer, error) {
+	return newServer(service, opts, client.NewCommandTable())
+}
+
+// newServer initializes a server with a table of commands
+func newServer(service *ds.DS, opts Options, commands *client.CommandTable) (*RedisServer, error) {
+	acl := client.NewACL(opts.RequirePass, commands)
+	if opts.ACLFile != "" {
+		if err := acl.LoadFile(opts.ACLFile); err != nil {
+			return nil, err
+		}
+	}
+
 	rs := &RedisServer{
 		DBs: map[int]*ds.DS{
 			0: service,
 		},
-		Signal: make(chan os.Signal, 1),
-		mu:     new(sync.RWMutex),
+		Signal:      make(chan os.Signal, 1),
+		mu:          new(sync.RWMutex),
+		acl:         acl,
+		commands:    commands,
+		shardPubSub: newShardPubSub(),
+		subscribers: make(map[redcon.DetachedConn]*shardSubscriber),
+	}
+
+	if opts.Address == "" && opts.TLSAddress == "" && opts.UnixSocket == "" {
+		return nil, errors.New("no address to listen on")
+	}
+	if opts.Address != "" {
+		rs.Server = redcon.NewServer(opts.Address, rs.ExecuteCommand, rs.Accept, rs.closed)
+	}
+	if opts.TLSAddress != "" {
+		reloader, err := newTLSReloader(opts)
+		if err != nil {
+			return nil, err
+		}
+		rs.tls = reloader
+		rs.TLSServer = redcon.NewServerTLS(opts.TLSAddress, rs.ExecuteCommand, rs.Accept, rs.closed, reloader.config())
 	}
+	if opts.UnixSocket != "" {
+		rs.unixSocket = opts.UnixSocket
+		rs.unixSocketPerm = opts.UnixSocketPerm
+		rs.UnixServer = redcon.NewServerNetwork("unix", opts.UnixSocket, rs.ExecuteCommand, rs.Accept, rs.closed)
+	}
+
 	signal.Notify(rs.Signal, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
-	return rs
+	return rs, nil
 }
 
+// Listen listens on all enabled addresses and serves connections until the server is stopped
 func (rs *RedisServer) Listen() {
+	var wg sync.WaitGroup
+	listen := func(name string, listenAndServe func() error) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := listenAndServe(); err != nil {
+				log.Fatalf("listen and serve %s err, fail to start. %v", name, err)
+			}
+		}()
+	}
+
+	if rs.Server != nil {
+		listen("tcp", rs.Server.ListenAndServe)
+	}
+	if rs.TLSServer != nil {
+		listen("tls", rs.TLSServer.ListenAndServe)
+	}
+	if rs.UnixServer != nil {
+		listen("unix", rs.listenUnixSocket)
+	}
 	log.Println("server running, ready to accept connections")
-	if err := rs.Server.ListenAndServe(); err != nil {
-		log.Fatalf("listen and serve err, fail to start. %v", err)
-		return
+	wg.Wait()
+}
+
+// listenUnixSocket listens on the Unix domain socket with the configured permission
+func (rs *RedisServer) listenUnixSocket() error {
+	ln, err := listenUnix(rs.unixSocket, rs.unixSocketPerm)
+	if err != nil {
+		return err
+	}
+	return rs.UnixServer.Serve(ln)
+}
+
+// listenUnix listens on a Unix domain socket with a permission.
+//
+// The socket is created in a private directory next to the path, and moved to the path after its permission is set,
+// so it is never accessible with the default permission.
+// A socket left by a previous process is replaced, while other files or sockets in use are not.
+func listenUnix(path string, perm os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	dir, err := os.MkdirTemp(filepath.Dir(path), ".baradb-redis-")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	tmp := filepath.Join(dir, "socket")
+	ln, err := net.Listen("unix", tmp)
+	if err != nil {
+		return nil, err
+	}
+	// the socket is removed by Stop at the path it is moved to
+	ln.(*net.UnixListener).SetUnlinkOnClose(false)
+	if err := os.Chmod(tmp, perm); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// WaitForSignal blocks until the server receives a signal to exit.
+//
+// SIGHUP does not make the server exit, but reloads TLS certificates.
+func (rs *RedisServer) WaitForSignal() {
+	for sig := range rs.Signal {
+		if sig != syscall.SIGHUP {
+			return
+		}
+		if rs.tls == nil {
+			continue
+		}
+		if err := rs.tls.reload(); err != nil {
+			log.Printf("reload TLS certificates err: %v", err)
+			continue
+		}
+		log.Println("TLS certificates reloaded")
 	}
 }
 
@@ -45,20 +188,54 @@ func (rs *RedisServer) Accept(conn redcon.Conn) bool {
 	defer rs.mu.Unlock()
 
 	client := &client.RedisClient{
-		DB: rs.DBs[0],
+		DB:       rs.DBs[0],
+		ID:       atomic.AddInt64(&rs.lastClientID, 1),
+		ACL:      rs.acl,
+		Commands: rs.commands,
 	}
 	conn.SetContext(client)
 	return true
 }
 
+func (rs *RedisServer) closed(conn redcon.Conn, err error) {}
+
+// ExecuteCommand executes a command of a client.
+//
+// Commands related to the server state, such as pub/sub, are executed by the server itself,
+// and the others are passed to the client.
+func (rs *RedisServer) ExecuteCommand(conn redcon.Conn, cmd redcon.Command) {
+	commandName := strings.ToLower(string(cmd.Args[0]))
+	if isShardPubSubCommand(commandName) {
+		c, _ := conn.Context().(*client.RedisClient)
+		if c.Permit(conn, cmd) {
+			rs.executeShardPubSubCommand(conn, cmd)
+		}
+		return
+	}
+	client.ExecuteClientCommand(conn, cmd)
+}
+
 func (rs *RedisServer) Stop() {
 	for _, db := range rs.DBs {
 		if err := db.Close(); err != nil {
 			log.Fatalf("close db err: %v", err)
 		}
 	}
-	if err := rs.Server.Close(); err != nil {
-		log.Fatalf("close server err: %v", err)
+	if rs.Server != nil {
+		if err := rs.Server.Close(); err != nil {
+			log.Fatalf("close server err: %v", err)
+		}
+	}
+	if rs.TLSServer != nil {
+		if err := rs.TLSServer.Close(); err != nil {
+			log.Fatalf("close TLS server err: %v", err)
+		}
+	}
+	if rs.UnixServer != nil {
+		if err := rs.UnixServer.Close(); err != nil {
+			log.Fatalf("close Unix socket server err: %v", err)
+		}
+		os.Remove(rs.unixSocket)
 	}
 	log.Println("baradb-redis is ready to exit, bye bye...")
 }
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// newTestingServer starts a server on a random TCP port, which is stopped when the test finishes
+func newTestingServer(t *testing.T) (*RedisServer, string) {
+	dbOpts := baradb.DefaultDBOptions
+	dbOpts.Directory = t.TempDir()
+	service, err := ds.New(dbOpts)
+	assert.Nil(t, err)
+
+	opts := DefaultOptions
+	opts.Address = "127.0.0.1:0"
+	rs, err := New(service, opts)
+	assert.Nil(t, err)
+	ln, err := net.Listen("tcp", opts.Address)
+	assert.Nil(t, err)
+	go rs.Server.Serve(ln)
+	t.Cleanup(rs.Stop)
+	return rs, ln.Addr().String()
+}
+
+// testingClient is a client speaking RESP, whose replies are flattened into lines
+type testingClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+func newTestingClient(t *testing.T, network, address string) *testingClient {
+	conn, err := net.Dial(network, address)
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return &testingClient{conn: conn, reader: bufio.NewReader(conn)}
+}
+
+// do sends a command and reads its first reply
+func (c *testingClient) do(args ...string) []string {
+	buffer := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+	for _, arg := range args {
+		buffer = append(buffer, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+	}
+	if _, err := c.conn.Write(buffer); err != nil {
+		return []string{err.Error()}
+	}
+	return c.read()
+}
+
+// read reads a reply, where headers of aggregates are kept as lines and nulls are "(nil)"
+func (c *testingClient) read() []string {
+	_ = c.conn.SetReadDeadline(time.Now().Add(time.Second))
+	var lines []string
+	if err := c.readValue(&lines); err != nil {
+		return append(lines, err.Error())
+	}
+	return lines
+}
+
+func (c *testingClient) readValue(lines *[]string) error {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return err
+	}
+	line = line[:len(line)-2]
+	switch line[0] {
+	case '$':
+		n, _ := strconv.Atoi(line[1:])
+		if n < 0 {
+			*lines = append(*lines, "(nil)")
+			return nil
+		}
+		bulk := make([]byte, n+2)
+		if _, err := io.ReadFull(c.reader, bulk); err != nil {
+			return err
+		}
+		*lines = append(*lines, string(bulk[:n]))
+	case '*', '>', '~', '%':
+		*lines = append(*lines, line)
+		n, _ := strconv.Atoi(line[1:])
+		if line[0] == '%' {
+			n *= 2
+		}
+		for i := 0; i < n; i++ {
+			if err := c.readValue(lines); err != nil {
+				return err
+			}
+		}
+	case '_':
+		*lines = append(*lines, "(nil)")
+	default:
+		*lines = append(*lines, line)
+	}
+	return nil
+}
+
+func TestListenUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "redis.sock")
+	ln, err := listenUnix(path, 0o700)
+	assert.Nil(t, err)
+	info, err := os.Lstat(path)
+	assert.Nil(t, err)
+	assert.Equal(t, os.ModeSocket|0o700, info.Mode())
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(t, entries, 1)
+
+	// a socket in use is not replaced
+	_, err = listenUnix(path, 0o700)
+	assert.NotNil(t, err)
+
+	// a socket left by a previous process is replaced
+	assert.Nil(t, ln.Close())
+	ln, err = listenUnix(path, 0o770)
+	assert.Nil(t, err)
+	info, _ = os.Lstat(path)
+	assert.Equal(t, os.ModeSocket|0o770, info.Mode())
+	assert.Nil(t, ln.Close())
+
+	// other files are not replaced
+	file := filepath.Join(filepath.Dir(path), "redis.conf")
+	assert.Nil(t, os.WriteFile(file, []byte("port 6379"), 0o600))
+	_, err = listenUnix(file, 0o700)
+	assert.NotNil(t, err)
+	content, _ := os.ReadFile(file)
+	assert.Equal(t, "port 6379", string(content))
+}
+
+func TestRedisServer_UnixSocket(t *testing.T) {
+	dbOpts := baradb.DefaultDBOptions
+	dbOpts.Directory = t.TempDir()
+	service, err := ds.New(dbOpts)
+	assert.Nil(t, err)
+
+	opts := DefaultOptions
+	opts.Address = ""
+	opts.UnixSocket = filepath.Join(t.TempDir(), "redis.sock")
+	rs, err := New(service, opts)
+	assert.Nil(t, err)
+	assert.Nil(t, rs.Server)
+	go rs.listenUnixSocket()
+	assert.Eventually(t, func() bool {
+		_, err := os.Lstat(opts.UnixSocket)
+		return err == nil
+	}, time.Second, 10*time.Millisecond)
+
+	c := newTestingClient(t, "unix", opts.UnixSocket)
+	assert.Equal(t, []string{"+PONG"}, c.do("PING"))
+	assert.Equal(t, []string{"+OK"}, c.do("SET", "k", "v"))
+	assert.Equal(t, []string{"v"}, c.do("GET", "k"))
+
+	rs.Stop()
+	_, err = os.Lstat(opts.UnixSocket)
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,43 @@
+package server
+
+import "bytes"
+
+// slotCount is the number of hash slots in a Redis cluster
+const slotCount = 16384
+
+// crc16Table is the lookup table of CRC16 (XMODEM), which is used by Redis cluster
+var crc16Table = func() [256]uint16 {
+	var table [256]uint16
+	for i := 0; i < 256; i++ {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return table
+}()
+
+func crc16(buffer []byte) uint16 {
+	var crc uint16
+	for _, b := range buffer {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^b]
+	}
+	return crc
+}
+
+// keySlot computes the hash slot of a key (or a shard channel) like Redis cluster does.
+//
+// If the key contains a non-empty hash tag such as {user1000}, only the hash tag is hashed.
+func keySlot(key []byte) int {
+	if start := bytes.IndexByte(key, '{'); start >= 0 {
+		if end := bytes.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % slotCount
+}
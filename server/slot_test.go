@@ -0,0 +1,40 @@
+package server
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCRC16(t *testing.T) {
+	// the check value of CRC16 (XMODEM)
+	assert.Equal(t, uint16(0x31C3), crc16([]byte("123456789")))
+	assert.Equal(t, uint16(0), crc16(nil))
+}
+
+func TestKeySlot(t *testing.T) {
+	// slots of Redis cluster
+	assert.Equal(t, 12182, keySlot([]byte("foo")))
+	assert.Equal(t, 5061, keySlot([]byte("bar")))
+	assert.Equal(t, 866, keySlot([]byte("hello")))
+
+	// only the first non-empty hash tag is hashed
+	tests := []struct {
+		key    string
+		hashed string
+	}{
+		{"{user1000}.following", "user1000"},
+		{"{user1000}.followers", "user1000"},
+		{"foo{bar}{zap}", "bar"},
+		{"foo{{bar}}zap", "{bar"},
+		{"foo{}{bar}", "foo{}{bar}"},
+		{"foo{bar", "foo{bar"},
+		{"foo}bar{", "foo}bar{"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, keySlot([]byte(tt.hashed)), keySlot([]byte(tt.key)), tt.key)
+	}
+	assert.True(t, sameSlot([][]byte{[]byte("{user1000}.following"), []byte("{user1000}.followers")}))
+	assert.False(t, sameSlot([][]byte{[]byte("foo"), []byte("bar")}))
+	assert.True(t, sameSlot(nil))
+}
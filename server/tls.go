@@ -0,0 +1,94 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync/atomic"
+)
+
+// tlsVersions maps names of TLS versions in options to versions in crypto/tls
+var tlsVersions = map[string]uint16{
+	"TLSv1.2": tls.VersionTLS12,
+	"TLSv1.3": tls.VersionTLS13,
+}
+
+// tlsReloader holds the current TLS configuration of a server.
+//
+// New connections always use the latest configuration,
+// so certificates could be reloaded without restarting the server.
+type tlsReloader struct {
+	opts    Options
+	current atomic.Pointer[tls.Config]
+}
+
+func newTLSReloader(opts Options) (*tlsReloader, error) {
+	reloader := &tlsReloader{opts: opts}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// reload loads certificates from files again.
+//
+// If it fails, then the previous configuration is kept.
+func (reloader *tlsReloader) reload() error {
+	config, err := loadTLSConfig(reloader.opts)
+	if err != nil {
+		return err
+	}
+	reloader.current.Store(config)
+	return nil
+}
+
+// config gets the configuration for listening, which delegates to the current configuration
+func (reloader *tlsReloader) config() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return reloader.current.Load(), nil
+		},
+	}
+}
+
+// loadTLSConfig loads a TLS configuration from options
+func loadTLSConfig(opts Options) (*tls.Config, error) {
+	minVersion, ok := tlsVersions[opts.TLSMinVersion]
+	if !ok {
+		return nil, fmt.Errorf("unsupported TLS version: %s", opts.TLSMinVersion)
+	}
+
+	certificate, err := tls.LoadX509KeyPair(opts.TLSCertFile, opts.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load TLS certificate: %w", err)
+	}
+
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certificate},
+		MinVersion:   minVersion,
+	}
+
+	if opts.TLSCACertFile != "" {
+		pem, err := os.ReadFile(opts.TLSCACertFile)
+		if err != nil {
+			return nil, fmt.Errorf("load TLS CA certificate: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid certificate found in %s", opts.TLSCACertFile)
+		}
+		config.ClientCAs = pool
+	}
+
+	if opts.TLSAuthClients {
+		if config.ClientCAs == nil {
+			return nil, fmt.Errorf("a CA certificate is required to verify client certificates")
+		}
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	} else if config.ClientCAs != nil {
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+
+	return config, nil
+}
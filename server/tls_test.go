@@ -0,0 +1,201 @@
+package server
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/saint-yellow/baradb"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saint-yellow/baradb-redis/ds"
+)
+
+// testingCA is a throwaway CA, which signs certificates of servers and clients
+type testingCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pool *x509.CertPool
+	file string // path of the certificate in PEM
+}
+
+func newTestingCA(t *testing.T, dir string) *testingCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "testing CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+
+	ca := &testingCA{cert: cert, key: key, pool: x509.NewCertPool(), file: filepath.Join(dir, "ca.crt")}
+	ca.pool.AddCert(cert)
+	assert.Nil(t, os.WriteFile(ca.file, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	return ca
+}
+
+// issue signs a certificate of the serial number, and writes it and its key in PEM
+func (ca *testingCA) issue(t *testing.T, serial int64, usage x509.ExtKeyUsage, certFile, keyFile string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "baradb-redis"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	assert.Nil(t, err)
+	encKey, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: encKey})
+	assert.Nil(t, os.WriteFile(certFile, certPEM, 0o600))
+	assert.Nil(t, os.WriteFile(keyFile, keyPEM, 0o600))
+	certificate, err := tls.X509KeyPair(certPEM, keyPEM)
+	assert.Nil(t, err)
+	return certificate
+}
+
+// newTestingTLSOptions generates a CA and a certificate of a server in a temporary directory
+func newTestingTLSOptions(t *testing.T) (Options, *testingCA) {
+	dir := t.TempDir()
+	ca := newTestingCA(t, dir)
+	opts := DefaultOptions
+	opts.TLSCertFile = filepath.Join(dir, "server.crt")
+	opts.TLSKeyFile = filepath.Join(dir, "server.key")
+	ca.issue(t, 2, x509.ExtKeyUsageServerAuth, opts.TLSCertFile, opts.TLSKeyFile)
+	return opts, ca
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	opts, ca := newTestingTLSOptions(t)
+
+	// the minimum version
+	config, err := loadTLSConfig(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS12), config.MinVersion)
+	assert.Len(t, config.Certificates, 1)
+	opts.TLSMinVersion = "TLSv1.3"
+	config, err = loadTLSConfig(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, uint16(tls.VersionTLS13), config.MinVersion)
+	for _, version := range []string{"TLSv1.1", "tlsv1.2", ""} {
+		opts.TLSMinVersion = version
+		_, err = loadTLSConfig(opts)
+		assert.EqualError(t, err, "unsupported TLS version: "+version)
+	}
+	opts.TLSMinVersion = "TLSv1.2"
+
+	// client certificates are not requested without a CA
+	config, err = loadTLSConfig(opts)
+	assert.Nil(t, err)
+	assert.Nil(t, config.ClientCAs)
+	assert.Equal(t, tls.NoClientCert, config.ClientAuth)
+	opts.TLSAuthClients = true
+	_, err = loadTLSConfig(opts)
+	assert.EqualError(t, err, "a CA certificate is required to verify client certificates")
+
+	// client certificates are verified if given, or required if clients are authenticated
+	opts.TLSCACertFile = ca.file
+	config, err = loadTLSConfig(opts)
+	assert.Nil(t, err)
+	assert.NotNil(t, config.ClientCAs)
+	assert.Equal(t, tls.RequireAndVerifyClientCert, config.ClientAuth)
+	opts.TLSAuthClients = false
+	config, err = loadTLSConfig(opts)
+	assert.Nil(t, err)
+	assert.Equal(t, tls.VerifyClientCertIfGiven, config.ClientAuth)
+
+	// invalid files
+	invalid := filepath.Join(t.TempDir(), "invalid.crt")
+	assert.Nil(t, os.WriteFile(invalid, []byte("not a certificate"), 0o600))
+	opts.TLSCACertFile = invalid
+	_, err = loadTLSConfig(opts)
+	assert.EqualError(t, err, "no valid certificate found in "+invalid)
+	opts.TLSCACertFile = filepath.Join(t.TempDir(), "missing.crt")
+	_, err = loadTLSConfig(opts)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	opts.TLSCACertFile = ""
+	opts.TLSCertFile = invalid
+	_, err = loadTLSConfig(opts)
+	assert.NotNil(t, err)
+}
+
+func TestRedisServer_TLS(t *testing.T) {
+	opts, ca := newTestingTLSOptions(t)
+	opts.Address = ""
+	opts.TLSAddress = "127.0.0.1:0"
+	opts.TLSCACertFile = ca.file
+	opts.TLSAuthClients = true
+	dbOpts := baradb.DefaultDBOptions
+	dbOpts.Directory = t.TempDir()
+	service, err := ds.New(dbOpts)
+	assert.Nil(t, err)
+	rs, err := New(service, opts)
+	assert.Nil(t, err)
+	ln, err := net.Listen("tcp", opts.TLSAddress)
+	assert.Nil(t, err)
+	go rs.TLSServer.Serve(tls.NewListener(ln, rs.tls.config()))
+	t.Cleanup(rs.Stop)
+
+	dir := t.TempDir()
+	clientCert := ca.issue(t, 100, x509.ExtKeyUsageClientAuth, filepath.Join(dir, "client.crt"), filepath.Join(dir, "client.key"))
+	dial := func(certificates ...tls.Certificate) (*testingClient, *tls.Conn) {
+		conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{
+			RootCAs:      ca.pool,
+			Certificates: certificates,
+		})
+		assert.Nil(t, err)
+		t.Cleanup(func() { conn.Close() })
+		return &testingClient{conn: conn, reader: bufio.NewReader(conn)}, conn
+	}
+	serial := func(conn *tls.Conn) int64 {
+		return conn.ConnectionState().PeerCertificates[0].SerialNumber.Int64()
+	}
+
+	// clients without a certificate are rejected
+	c, _ := dial()
+	assert.Equal(t, []string{"remote error: tls: certificate required"}, c.do("PING"))
+
+	before, conn := dial(clientCert)
+	assert.Equal(t, []string{"+PONG"}, before.do("PING"))
+	assert.Equal(t, int64(2), serial(conn))
+
+	// new connections get the new certificate after a reload, while established connections are kept
+	ca.issue(t, 3, x509.ExtKeyUsageServerAuth, opts.TLSCertFile, opts.TLSKeyFile)
+	assert.Nil(t, rs.tls.reload())
+	after, conn := dial(clientCert)
+	assert.Equal(t, []string{"+PONG"}, after.do("PING"))
+	assert.Equal(t, int64(3), serial(conn))
+	assert.Equal(t, []string{"+PONG"}, before.do("PING"))
+
+	// the previous certificate is kept if a reload fails
+	assert.Nil(t, os.WriteFile(opts.TLSCertFile, []byte("not a certificate"), 0o600))
+	assert.NotNil(t, rs.tls.reload())
+	c, conn = dial(clientCert)
+	assert.Equal(t, []string{"+PONG"}, c.do("PING"))
+	assert.Equal(t, int64(3), serial(conn))
+}